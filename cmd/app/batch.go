@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cliffcolvin/helmscan/internal/helmscan"
+	"github.com/cliffcolvin/helmscan/internal/reports"
+)
+
+const batchIndexName = "batch_index"
+
+// readChartRefs reads one chart reference per line, skipping blank lines and # comments.
+func readChartRefs(r io.Reader) ([]string, error) {
+	var refs []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		refs = append(refs, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// scanBatch scans every chart listed in source, a file or - for stdin, saving a report per chart
+// and an index of them all in the reports directory. A chart that fails to scan is recorded in the
+// index and the batch continues; helmscan then exits with exitScanFailed. Otherwise it exits with
+// status 1 when any chart trips a --fail-on-* gate.
+func scanBatch(ctx context.Context, source string, opts options) {
+	var input io.Reader = os.Stdin
+	if source != "-" {
+		file, err := os.Open(source)
+		if err != nil {
+			logger.Fatalf("Error reading --batch: %v", err)
+		}
+		defer file.Close()
+		input = file
+	}
+	chartRefs, err := readChartRefs(input)
+	if err != nil {
+		logger.Fatalf("Error reading --batch: %v", err)
+	}
+	if len(chartRefs) == 0 {
+		logger.Fatal("--batch lists no chart references")
+	}
+
+	ext := ".md"
+	if opts.jsonOutput {
+		ext = ".json"
+	}
+	reportOpts := reportOptions(opts)
+	var index reports.BatchIndex
+	failed, scanFailed := false, false
+	for i, chartRef := range chartRefs {
+		if ctx.Err() != nil {
+			logger.Fatalf("Batch interrupted: %v", ctx.Err())
+		}
+		if !isHelmChart(chartRef) || !validChartReference(chartRef) || helmscan.HasVersionConstraint(chartRef) {
+			logger.Errorf("Invalid Helm chart reference %q. Expected format: repo/chart@version", chartRef)
+			index.Charts = append(index.Charts, reports.BatchEntry{ChartRef: chartRef, Error: "invalid chart reference, expected repo/chart@version"})
+			scanFailed = true
+			continue
+		}
+
+		logger.Infof("Scanning Helm chart %d of %d: %s", i+1, len(chartRefs), chartRef)
+		result, err := helmscan.ScanContext(ctx, chartRef, opts.scan)
+		if err != nil {
+			logger.Errorf("Error scanning Helm chart %s: %v", chartRef, err)
+			index.Charts = append(index.Charts, reports.BatchEntry{ChartRef: chartRef, Error: err.Error()})
+			scanFailed = true
+			continue
+		}
+		chartRef = fmt.Sprintf("%s/%s@%s", result.HelmRepo, result.Name, result.Version)
+
+		filename := reports.ReportFilename("helm_scan", chartRef, reportOpts) + ext
+		report := helmscan.GenerateSingleScanReport(result, opts.jsonOutput, opts.scan.IgnoreUnfixed, reportOpts)
+		if err := reports.WriteReport(report, filename, reportOpts); err != nil {
+			logger.Fatalf("Error saving report: %v", err)
+		}
+		entry := helmscan.NewBatchEntry(chartRef, result)
+		entry.Report = reports.ReportSubpath(filename)
+		index.Charts = append(index.Charts, entry)
+
+		for _, failure := range append(gateFailures(chartVulnerabilities(result), opts), imageFailures(result, opts)...) {
+			logger.Errorf("Failing %s: %s", chartRef, failure)
+			failed = true
+		}
+		if opts.warnOnNoImages && len(result.ContainsImages) == 0 {
+			logger.Errorf("Failing %s: renders no images", chartRef)
+			failed = true
+		}
+	}
+
+	indexOutput, err := reports.GenerateBatchIndex(index, opts.jsonOutput, reportOpts)
+	if err != nil {
+		logger.Fatalf("Error generating report: %v", err)
+	}
+	if err := os.MkdirAll(opts.outputDir, 0755); err != nil {
+		logger.Fatalf("Error creating reports directory: %v", err)
+	}
+	indexPath := filepath.Join(opts.outputDir, batchIndexName+ext)
+	if err := os.WriteFile(indexPath, []byte(indexOutput), 0644); err != nil {
+		logger.Fatalf("Error saving report: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "\nReport saved to: %s\n", indexPath)
+	fmt.Println(indexOutput)
+
+	if scanFailed {
+		os.Exit(exitScanFailed)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
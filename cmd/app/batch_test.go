@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cliffcolvin/helmscan/internal/reports"
+)
+
+func TestReadChartRefs(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{name: "empty"},
+		{name: "one per line", input: "bitnami/redis@18.1.0\nbitnami/nginx@15.0.0\n", want: []string{"bitnami/redis@18.1.0", "bitnami/nginx@15.0.0"}},
+		{
+			name:  "blank lines and comments",
+			input: "# charts to scan\n\n  bitnami/redis@18.1.0  \n\t\n  # bitnami/nginx@15.0.0\nbitnami/nginx@15.0.0",
+			want:  []string{"bitnami/redis@18.1.0", "bitnami/nginx@15.0.0"},
+		},
+		{name: "windows line endings", input: "bitnami/redis@18.1.0\r\nbitnami/nginx@15.0.0\r\n", want: []string{"bitnami/redis@18.1.0", "bitnami/nginx@15.0.0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readChartRefs(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("readChartRefs() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readChartRefs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatch(t *testing.T) {
+	charts := map[string]string{
+		"bitnami/redis@18.1.0": redisManifest,
+		"bitnami/redis@18.2.0": strings.ReplaceAll(redisManifest, "7.2.4-debian-12-r9", "7.2.5-debian-12-r0"),
+	}
+	const input = "# redis versions\nbitnami/redis@18.1.0\n\nbitnami/redis@18.2.0\n"
+	tests := []struct {
+		name         string
+		stdin        string
+		file         string
+		args         []string
+		wantExitCode int
+		wantStderr   string
+		wantCharts   []string
+		wantErrors   []string
+		wantReports  int
+	}{
+		{name: "stdin", stdin: input, wantCharts: []string{"bitnami/redis@18.1.0", "bitnami/redis@18.2.0"}, wantReports: 2},
+		{name: "file", file: input, wantCharts: []string{"bitnami/redis@18.1.0", "bitnami/redis@18.2.0"}, wantReports: 2},
+		{
+			// A chart that fails is recorded in the index and the rest of the batch still runs.
+			name:         "chart fails",
+			stdin:        "bitnami/redis@9.9.9\nbitnami/redis@18.1.0\n",
+			wantExitCode: exitScanFailed,
+			wantCharts:   []string{"bitnami/redis@9.9.9", "bitnami/redis@18.1.0"},
+			wantErrors:   []string{"error templating chart", ""},
+			wantReports:  1,
+		},
+		{
+			name:         "invalid reference",
+			stdin:        "bitnami/redis\nbitnami/redis@18.1.0\n",
+			wantExitCode: exitScanFailed,
+			wantCharts:   []string{"bitnami/redis", "bitnami/redis@18.1.0"},
+			wantErrors:   []string{"invalid chart reference", ""},
+			wantReports:  1,
+		},
+		{name: "no references", stdin: "# nothing yet\n\n", wantExitCode: 1, wantStderr: "--batch lists no chart references"},
+		{
+			name:         "with artifact arguments",
+			stdin:        input,
+			args:         []string{"bitnami/redis@18.1.0"},
+			wantExitCode: 1,
+			wantStderr:   "--batch does not take artifact arguments or another scan mode",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := "-"
+			if tt.file != "" {
+				source = filepath.Join(t.TempDir(), "charts.txt")
+				if err := os.WriteFile(source, []byte(tt.file), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			args := append([]string{"--json", "--batch", source}, tt.args...)
+			run := runHelmscanWithStdin(t, charts, strings.NewReader(tt.stdin), args...)
+			if run.exitCode != tt.wantExitCode {
+				t.Fatalf("helmscan exited with %d, want %d:\n%s", run.exitCode, tt.wantExitCode, run.stderr)
+			}
+			if !strings.Contains(run.stderr, tt.wantStderr) {
+				t.Errorf("stderr is missing %q:\n%s", tt.wantStderr, run.stderr)
+			}
+			if tt.wantCharts == nil {
+				return
+			}
+
+			scans := filepath.Join(run.workDir, "working-files", "scans")
+			data, err := os.ReadFile(filepath.Join(scans, "batch_index.json"))
+			if err != nil {
+				t.Fatalf("reading the batch index: %v", err)
+			}
+			var index reports.BatchIndex
+			if err := json.Unmarshal(data, &index); err != nil {
+				t.Fatalf("batch index is not JSON: %v", err)
+			}
+			var gotCharts []string
+			for i, entry := range index.Charts {
+				gotCharts = append(gotCharts, entry.ChartRef)
+				wantErr := ""
+				if tt.wantErrors != nil {
+					wantErr = tt.wantErrors[i]
+				}
+				if (entry.Error == "") != (wantErr == "") || !strings.Contains(entry.Error, wantErr) {
+					t.Errorf("index entry %s has error %q, want %q", entry.ChartRef, entry.Error, wantErr)
+				}
+				if entry.Error == "" {
+					if _, err := os.Stat(filepath.Join(scans, entry.Report)); err != nil {
+						t.Errorf("report of %s: %v", entry.ChartRef, err)
+					}
+				}
+			}
+			if !slices.Equal(gotCharts, tt.wantCharts) {
+				t.Errorf("index charts = %v, want %v", gotCharts, tt.wantCharts)
+			}
+
+			var saved int
+			filepath.WalkDir(scans, func(path string, d os.DirEntry, err error) error {
+				if err == nil && !d.IsDir() && d.Name() != "batch_index.json" {
+					saved++
+				}
+				return nil
+			})
+			if saved != tt.wantReports {
+				t.Errorf("saved %d chart reports, want %d", saved, tt.wantReports)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// errorsLogged records whether the run logged an error, such as a scan that failed, so --clean
+// keeps the intermediate files of a run that may need debugging.
+var errorsLogged atomic.Bool
+
+func recordErrors(entry zapcore.Entry) error {
+	if entry.Level >= zapcore.ErrorLevel {
+		errorsLogged.Store(true)
+	}
+	return nil
+}
+
+// intermediateDirs are the directories under --work-dir that hold the rendered Helm output and
+// Trivy JSON of a run.
+func intermediateDirs(opts options) []string {
+	return []string{opts.scan.WorkPath("tmp", "helm_output"), opts.scan.WorkPath("tmp", "trivy_output")}
+}
+
+// cleanIntermediateFiles removes the intermediate files of a run that logged no errors when
+// --clean is set and --keep-intermediate is not. Directories holding reports or other files the
+// user asked for are kept.
+func cleanIntermediateFiles(opts options) {
+	if !opts.clean || opts.keepFiles || errorsLogged.Load() {
+		return
+	}
+	for _, dir := range intermediateDirs(opts) {
+		if holdsOutputs(dir, opts) {
+			logger.Warnf("Keeping %s, which holds saved reports", dir)
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			logger.Warnf("Failed to remove intermediate files: %v", err)
+			continue
+		}
+		logger.Infof("Removed intermediate files in %s", dir)
+	}
+}
+
+// holdsOutputs reports whether dir contains the reports directory or a file written by
+// --report-file, --save-scan or --metrics-file.
+func holdsOutputs(dir string, opts options) bool {
+	for _, output := range []string{opts.outputDir, opts.reportFile, opts.saveScan, opts.metricsFile} {
+		if output == "" || output == "-" {
+			continue
+		}
+		if isWithin(dir, output) {
+			return true
+		}
+	}
+	return false
+}
+
+func isWithin(dir, path string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return true
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return true
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func TestHoldsOutputs(t *testing.T) {
+	dir := filepath.Join("working-files", "tmp", "helm_output")
+	tests := []struct {
+		name string
+		opts options
+		want bool
+	}{
+		{name: "no outputs", opts: options{outputDir: filepath.Join("working-files", "scans")}},
+		{name: "report to stdout", opts: options{reportFile: "-"}},
+		{name: "reports directory inside", opts: options{outputDir: filepath.Join(dir, "reports")}, want: true},
+		{name: "reports directory is the directory", opts: options{outputDir: dir}, want: true},
+		{name: "report file inside", opts: options{reportFile: filepath.Join(dir, "report.md")}, want: true},
+		{name: "saved scan inside", opts: options{saveScan: filepath.Join(dir, "scan.json")}, want: true},
+		{name: "metrics file inside", opts: options{metricsFile: filepath.Join(dir, "metrics.prom")}, want: true},
+		{name: "sibling with a common prefix", opts: options{reportFile: dir + "-report.md"}},
+		{name: "parent directory", opts: options{outputDir: "working-files"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := holdsOutputs(dir, tt.opts); got != tt.want {
+				t.Errorf("holdsOutputs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanIntermediateFiles(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        options
+		errorLogged bool
+		wantRemoved bool
+	}{
+		{name: "default keeps files"},
+		{name: "clean removes files", opts: options{clean: true}, wantRemoved: true},
+		{name: "keep-intermediate wins over clean", opts: options{clean: true, keepFiles: true}},
+		{name: "errors keep files", opts: options{clean: true}, errorLogged: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			work := t.TempDir()
+			tt.opts.scan = helmscanTypes.ScanOptions{WorkDir: work}
+			tt.opts.outputDir = filepath.Join(work, "scans")
+			for _, dir := range append(intermediateDirs(tt.opts), tt.opts.outputDir) {
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			errorsLogged.Store(tt.errorLogged)
+			t.Cleanup(func() { errorsLogged.Store(false) })
+
+			cleanIntermediateFiles(tt.opts)
+
+			for _, dir := range intermediateDirs(tt.opts) {
+				_, err := os.Stat(dir)
+				if removed := os.IsNotExist(err); removed != tt.wantRemoved {
+					t.Errorf("%s removed = %v, want %v", dir, removed, tt.wantRemoved)
+				}
+			}
+			if _, err := os.Stat(tt.opts.outputDir); err != nil {
+				t.Errorf("reports directory was removed: %v", err)
+			}
+		})
+	}
+
+	t.Run("directory holding a report is kept", func(t *testing.T) {
+		work := t.TempDir()
+		opts := options{clean: true, scan: helmscanTypes.ScanOptions{WorkDir: work}}
+		opts.reportFile = filepath.Join(opts.scan.WorkPath("tmp", "helm_output"), "report.md")
+		if err := os.MkdirAll(filepath.Dir(opts.reportFile), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(opts.reportFile, []byte("# report"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		cleanIntermediateFiles(opts)
+
+		if _, err := os.Stat(opts.reportFile); err != nil {
+			t.Errorf("report was removed: %v", err)
+		}
+	})
+}
+
+func TestCleanFlag(t *testing.T) {
+	charts := map[string]string{"bitnami/redis@18.1.0": redisManifest}
+	tests := []struct {
+		name        string
+		args        []string
+		wantRemoved bool
+	}{
+		{name: "default keeps intermediate files"},
+		{name: "clean", args: []string{"--clean"}, wantRemoved: true},
+		{name: "keep-intermediate", args: []string{"--clean", "--keep-intermediate"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := "redis.md"
+			args := append(append([]string{}, tt.args...), "--report-file", report, "bitnami/redis@18.1.0")
+			run := runHelmscan(t, charts, args...)
+			if run.exitCode != 0 {
+				t.Fatalf("exit code = %d, stderr:\n%s", run.exitCode, run.stderr)
+			}
+
+			_, err := os.Stat(filepath.Join(run.workDir, "working-files", "tmp", "helm_output"))
+			if removed := os.IsNotExist(err); removed != tt.wantRemoved {
+				t.Errorf("helm output removed = %v, want %v\n%s", removed, tt.wantRemoved, run.stderr)
+			}
+			if _, err := os.Stat(filepath.Join(run.workDir, report)); err != nil {
+				t.Errorf("report was not kept: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultConfigFile = "helmscan.yaml"
+
+// applyConfigFile sets every flag named in the YAML or JSON config file at path that was not
+// given on the command line, so flags take precedence over the file and the file over defaults.
+// Keys are flag names; list values are joined with commas.
+func applyConfigFile(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+
+	setOnCommandLine := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		setOnCommandLine[f.Name] = true
+	})
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == "config" || fs.Lookup(name) == nil {
+			return fmt.Errorf("unknown option %q in config file %s", name, path)
+		}
+		if setOnCommandLine[name] {
+			continue
+		}
+		if err := fs.Set(name, configValue(values[name])); err != nil {
+			return fmt.Errorf("invalid value for %q in config file %s: %w", name, path, err)
+		}
+	}
+	return nil
+}
+
+func configValue(value interface{}) string {
+	if items, ok := value.([]interface{}); ok {
+		parts := make([]string, 0, len(items))
+		for _, item := range items {
+			parts = append(parts, fmt.Sprint(item))
+		}
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+// configFilePath returns the explicit --config path, or helmscan.yaml from the current directory
+// when it exists.
+func configFilePath(explicit string) (string, error) {
+	if explicit != "" {
+		return explicit, nil
+	}
+	if _, err := os.Stat(defaultConfigFile); err == nil {
+		return defaultConfigFile, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	return "", nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyConfigFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		args    []string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:   "yaml",
+			config: "severity: HIGH\njson: true\nskip-repos:\n  - docker.io/bitnami\n  - quay.io/**\n",
+			want:   map[string]string{"severity": "HIGH", "json": "true", "skip-repos": "docker.io/bitnami,quay.io/**"},
+		},
+		{
+			name:   "json",
+			config: `{"severity": "CRITICAL", "max-images": 20}`,
+			want:   map[string]string{"severity": "CRITICAL", "max-images": "20"},
+		},
+		{
+			// Flags given on the command line win over the file, which wins over the defaults.
+			name:   "flags override the file",
+			config: "severity: HIGH\njson: true\n",
+			args:   []string{"--severity=LOW"},
+			want:   map[string]string{"severity": "LOW", "json": "true", "max-images": "100"},
+		},
+		{
+			name:    "unknown option",
+			config:  "severity: HIGH\nfail-on-everything: true\n",
+			wantErr: `unknown option "fail-on-everything"`,
+		},
+		{
+			name:    "config names another config",
+			config:  "config: other.yaml\n",
+			wantErr: `unknown option "config"`,
+		},
+		{
+			name:    "invalid value",
+			config:  "max-images: lots\n",
+			wantErr: `invalid value for "max-images"`,
+		},
+		{
+			name:    "not yaml",
+			config:  "severity: [HIGH\n",
+			wantErr: "error parsing config file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("helmscan", flag.ContinueOnError)
+			fs.String("config", "", "")
+			fs.String("severity", "", "")
+			fs.Bool("json", false, "")
+			fs.Int("max-images", 100, "")
+			fs.Var(new(stringList), "skip-repos", "")
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "helmscan.yaml")
+			if err := os.WriteFile(path, []byte(tt.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := applyConfigFile(fs, path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("applyConfigFile() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyConfigFile() error = %v", err)
+			}
+			for name, want := range tt.want {
+				if got := fs.Lookup(name).Value.String(); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigFilePath(t *testing.T) {
+	tests := []struct {
+		name       string
+		explicit   string
+		createFile bool
+		want       string
+	}{
+		{name: "explicit", explicit: "team.yaml", want: "team.yaml"},
+		{name: "explicit wins over the default", explicit: "team.yaml", createFile: true, want: "team.yaml"},
+		{name: "default file present", createFile: true, want: defaultConfigFile},
+		{name: "no config"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			if tt.createFile {
+				if err := os.WriteFile(defaultConfigFile, []byte("json: true\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := configFilePath(tt.explicit)
+			if err != nil {
+				t.Fatalf("configFilePath() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("configFilePath(%q) = %q, want %q", tt.explicit, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+	"github.com/cliffcolvin/helmscan/internal/helmscan"
+)
+
+// gateFailures returns a reason for every --fail-on-* condition the vulnerabilities trip.
+func gateFailures(vulns []helmscanTypes.Vulnerability, opts options) []string {
+	var failures []string
+	if len(opts.failOn) > 0 {
+		counts := make(map[string]int)
+		seen := make(map[string]bool)
+		for _, vuln := range vulns {
+			severity := strings.ToLower(vuln.Severity)
+			if slices.Contains(opts.failOn, severity) && !seen[vuln.ID] {
+				seen[vuln.ID] = true
+				counts[severity]++
+			}
+		}
+		for _, severity := range opts.failOn {
+			if counts[severity] == 1 {
+				failures = append(failures, fmt.Sprintf("found 1 %s CVE (--fail-on %s)", severity, strings.Join(opts.failOn, ",")))
+			} else if counts[severity] > 1 {
+				failures = append(failures, fmt.Sprintf("found %d %s CVEs (--fail-on %s)", counts[severity], severity, strings.Join(opts.failOn, ",")))
+			}
+		}
+	}
+	if opts.failOnEPSS > 0 {
+		seen := make(map[string]bool)
+		for _, vuln := range vulns {
+			if vuln.EPSS != nil && *vuln.EPSS >= opts.failOnEPSS && !seen[vuln.ID] {
+				seen[vuln.ID] = true
+				failures = append(failures, fmt.Sprintf("%s has an EPSS score of %.3f (threshold %.3f)", vuln.ID, *vuln.EPSS, opts.failOnEPSS))
+			}
+		}
+	}
+	if opts.failOnKEV {
+		seen := make(map[string]bool)
+		for _, vuln := range vulns {
+			if vuln.KEV && !seen[vuln.ID] {
+				seen[vuln.ID] = true
+				failures = append(failures, fmt.Sprintf("%s is in the CISA Known Exploited Vulnerabilities catalog", vuln.ID))
+			}
+		}
+	}
+	return failures
+}
+
+// severities are the values --fail-on and --display-severity accept.
+var severities = []string{"critical", "high", "medium", "low", "unknown"}
+
+// parseSeverities lowercases the severities given to the flag name, failing on any that Trivy does
+// not report.
+func parseSeverities(name string, values []string) []string {
+	var parsed []string
+	for _, value := range values {
+		severity := strings.ToLower(value)
+		if !slices.Contains(severities, severity) {
+			logger.Fatalf("Invalid --%s %q, expected a comma-separated list of %s", name, value, strings.Join(severities, ", "))
+		}
+		if !slices.Contains(parsed, severity) {
+			parsed = append(parsed, severity)
+		}
+	}
+	return parsed
+}
+
+// mutableTagFailures warns about every image of chart referenced by a mutable tag, listing the
+// resources that use it, and returns them as gate failures when --no-mutable-tags is set.
+func mutableTagFailures(chart helmscanTypes.HelmChart, opts options) []string {
+	var failures []string
+	for _, img := range helmscan.MutableTagImages(chart) {
+		failure := fmt.Sprintf("%s uses the mutable tag %s", img.Identity(), img.Tag)
+		if sources := formatSourceRefs(img.SourceRefs); sources != "" {
+			failure += " in " + sources
+		}
+		if opts.noMutableTags {
+			failures = append(failures, failure)
+		} else {
+			logger.Warn(failure)
+		}
+	}
+	return failures
+}
+
+// unsignedImageFailures returns a failure for every image of chart whose signature
+// --verify-signatures could not verify, when --fail-on-unsigned is set.
+func unsignedImageFailures(chart helmscanTypes.HelmChart, opts options) []string {
+	if !opts.failOnUnsigned {
+		return nil
+	}
+	var failures []string
+	for _, img := range chart.ContainsImages {
+		if img.Signature == nil || img.Signature.Verified {
+			continue
+		}
+		failures = append(failures, fmt.Sprintf("the signature of %s could not be verified: %s", img.Identity(), img.Signature.Error))
+	}
+	return failures
+}
+
+// imageFailures returns the gate failures of the images of chart themselves, rather than of their
+// vulnerabilities.
+func imageFailures(chart helmscanTypes.HelmChart, opts options) []string {
+	return append(mutableTagFailures(chart, opts), unsignedImageFailures(chart, opts)...)
+}
+
+func formatSourceRefs(refs []helmscanTypes.SourceRef) string {
+	var sources []string
+	for _, ref := range refs {
+		source := ref.Kind + "/" + ref.Name
+		if ref.Container != "" {
+			source += " (" + ref.Container + ")"
+		}
+		sources = append(sources, source)
+	}
+	return strings.Join(sources, ", ")
+}
+
+// exitOnGateFailures exits with status 1 after the report has been written when a gate fails.
+// Failures found by checks other than gateFailures, such as mutableTagFailures, are passed in extra.
+func exitOnGateFailures(vulns []helmscanTypes.Vulnerability, opts options, extra ...string) {
+	failures := append(gateFailures(vulns, opts), extra...)
+	if len(failures) == 0 {
+		return
+	}
+	cleanIntermediateFiles(opts)
+	for _, failure := range failures {
+		logger.Errorf("Failing: %s", failure)
+	}
+	os.Exit(1)
+}
+
+// exitScanFailed is the exit status of a run in which a scan failed, even partly, distinct from the
+// status 1 of a failed gate. The gates are not checked against an incomplete scan, so a run that
+// scanned nothing never passes.
+const exitScanFailed = 2
+
+// exitOnScanError logs err and exits with exitScanFailed.
+func exitOnScanError(message string, err error) {
+	logger.Errorf("%s: %v", message, err)
+	os.Exit(exitScanFailed)
+}
+
+// exitNoImages is the exit status of --warn-on-no-images, distinct from the status 1 of a failed gate.
+const exitNoImages = 3
+
+// exitOnNoImages exits with exitNoImages after the report has been written when --warn-on-no-images
+// is set and chart rendered no images. Otherwise an imageless chart, such as one holding only RBAC
+// or config resources, passes.
+func exitOnNoImages(chartRef string, chart helmscanTypes.HelmChart, opts options) {
+	if !opts.warnOnNoImages || len(chart.ContainsImages) > 0 {
+		return
+	}
+	cleanIntermediateFiles(opts)
+	logger.Errorf("Failing: %s renders no images", chartRef)
+	os.Exit(exitNoImages)
+}
+
+func chartVulnerabilities(chart helmscanTypes.HelmChart) []helmscanTypes.Vulnerability {
+	var vulns []helmscanTypes.Vulnerability
+	for _, img := range chart.ContainsImages {
+		if img != nil {
+			vulns = append(vulns, img.ScanResult.VulnList...)
+		}
+	}
+	return vulns
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cliffcolvin/helmscan/internal/fakeexec"
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func epssScore(score float64) *float64 {
+	return &score
+}
+
+func TestGateFailures(t *testing.T) {
+	vulns := []helmscanTypes.Vulnerability{
+		{ID: "CVE-2021-44228", Severity: "critical", EPSS: epssScore(0.97565), KEV: true},
+		{ID: "CVE-2023-45853", Severity: "critical", EPSS: epssScore(0.00421)},
+		{ID: "CVE-2023-45288", Severity: "high", EPSS: epssScore(0.5)},
+		// The same CVE found in a second image.
+		{ID: "CVE-2021-44228", Severity: "critical", EPSS: epssScore(0.97565), KEV: true},
+		{ID: "CVE-2011-3374", Severity: "low", KEV: true},
+	}
+	tests := []struct {
+		name string
+		opts options
+		want []string
+	}{
+		{
+			name: "no gates",
+		},
+		{
+			// The threshold is inclusive, each CVE fails once, and CVEs without a score never fail.
+			name: "EPSS threshold",
+			opts: options{failOnEPSS: 0.5},
+			want: []string{
+				"CVE-2021-44228 has an EPSS score of 0.976 (threshold 0.500)",
+				"CVE-2023-45288 has an EPSS score of 0.500 (threshold 0.500)",
+			},
+		},
+		{
+			name: "EPSS threshold not reached",
+			opts: options{failOnEPSS: 0.99},
+		},
+		{
+			// Known exploited CVEs fail whatever their severity.
+			name: "KEV",
+			opts: options{failOnKEV: true},
+			want: []string{
+				"CVE-2021-44228 is in the CISA Known Exploited Vulnerabilities catalog",
+				"CVE-2011-3374 is in the CISA Known Exploited Vulnerabilities catalog",
+			},
+		},
+		{
+			// Each CVE counts once, however many images it is found in.
+			name: "fail on severities",
+			opts: options{failOn: []string{"critical", "high"}},
+			want: []string{
+				"found 2 critical CVEs (--fail-on critical,high)",
+				"found 1 high CVE (--fail-on critical,high)",
+			},
+		},
+		{
+			name: "fail on severities not found",
+			opts: options{failOn: []string{"medium"}},
+		},
+		{
+			// Hiding severities from the report does not change the gate.
+			name: "fail on severities not displayed",
+			opts: options{failOn: []string{"low"}, displaySeverity: []string{"critical"}},
+			want: []string{"found 1 low CVE (--fail-on low)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gateFailures(vulns, tt.opts); !slices.Equal(got, tt.want) {
+				t.Errorf("gateFailures() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMutableTagFailures(t *testing.T) {
+	chart := helmscanTypes.HelmChart{Name: "app", Version: "1.0.0", HelmRepo: "bitnami", ContainsImages: []*helmscanTypes.ContainerImage{
+		{Repository: "docker.io/bitnami", ImageName: "redis", Tag: "7.2.4"},
+		{Repository: "docker.io/bitnami", ImageName: "redis-exporter", Tag: "latest", SourceRefs: []helmscanTypes.SourceRef{
+			{Kind: "StatefulSet", Name: "redis-master", Container: "metrics"},
+			{Kind: "StatefulSet", Name: "redis-replicas", Container: "metrics"},
+		}},
+		{ImageName: "busybox", Tag: "latest", SourceRefs: []helmscanTypes.SourceRef{{Kind: "Job", Name: "migrate"}}},
+		{ImageName: "kubectl", Tag: "latest"},
+	}}
+	tests := []struct {
+		name string
+		opts options
+		want []string
+	}{
+		// Without --no-mutable-tags the images are only warned about.
+		{name: "warn"},
+		{
+			name: "fail",
+			opts: options{noMutableTags: true},
+			want: []string{
+				"docker.io/bitnami/redis-exporter:latest uses the mutable tag latest in StatefulSet/redis-master (metrics), StatefulSet/redis-replicas (metrics)",
+				"docker.io/library/busybox:latest uses the mutable tag latest in Job/migrate",
+				"docker.io/library/kubectl:latest uses the mutable tag latest",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mutableTagFailures(chart, tt.opts); !slices.Equal(got, tt.want) {
+				t.Errorf("mutableTagFailures() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// rbacManifest is a chart holding only RBAC resources, so it renders no images.
+const rbacManifest = `---
+# Source: rbac/templates/role.yaml
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: release-name-reader
+rules:
+  - apiGroups: [""]
+    resources: ["pods"]
+    verbs: ["get", "list"]
+`
+
+func TestWarnOnNoImages(t *testing.T) {
+	charts := map[string]string{"bitnami/redis@18.1.0": redisManifest, "internal/rbac@1.0.0": rbacManifest}
+	const noImages = "No images were found in internal/rbac@1.0.0, so no images were scanned."
+	tests := []struct {
+		name         string
+		args         []string
+		stdin        string
+		wantExitCode int
+		wantStdout   string
+		wantStderr   string
+	}{
+		{name: "imageless chart", args: []string{"--report-file=-", "internal/rbac@1.0.0"}, wantStdout: noImages},
+		{
+			// The report is still written before helmscan exits.
+			name:         "imageless chart with flag",
+			args:         []string{"--warn-on-no-images", "--report-file=-", "internal/rbac@1.0.0"},
+			wantExitCode: exitNoImages,
+			wantStdout:   noImages,
+			wantStderr:   "Failing: internal/rbac@1.0.0 renders no images",
+		},
+		{name: "chart with images and flag", args: []string{"--warn-on-no-images", "--report-file=-", "bitnami/redis@18.1.0"}},
+		{
+			name:         "batch with flag",
+			args:         []string{"--warn-on-no-images", "--json", "--batch", "-"},
+			stdin:        "bitnami/redis@18.1.0\ninternal/rbac@1.0.0\n",
+			wantExitCode: 1,
+			wantStderr:   "Failing internal/rbac@1.0.0: renders no images",
+		},
+		{name: "batch", args: []string{"--json", "--batch", "-"}, stdin: "bitnami/redis@18.1.0\ninternal/rbac@1.0.0\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := runHelmscanWithStdin(t, charts, strings.NewReader(tt.stdin), tt.args...)
+			if run.exitCode != tt.wantExitCode {
+				t.Fatalf("helmscan exited with %d, want %d:\n%s", run.exitCode, tt.wantExitCode, run.stderr)
+			}
+			if !strings.Contains(run.stdout, tt.wantStdout) {
+				t.Errorf("stdout is missing %q:\n%s", tt.wantStdout, run.stdout)
+			}
+			if !strings.Contains(run.stderr, tt.wantStderr) {
+				t.Errorf("stderr is missing %q:\n%s", tt.wantStderr, run.stderr)
+			}
+		})
+	}
+}
+
+func TestScanFailures(t *testing.T) {
+	charts := map[string]string{
+		"bitnami/redis@18.1.0": redisManifest,
+		"bitnami/redis@18.2.0": strings.ReplaceAll(redisManifest, "7.2.4-debian-12-r9", "7.2.5-debian-12-r0"),
+	}
+	const (
+		newRedis = "docker.io/bitnami/redis:7.2.5-debian-12-r0"
+		exporter = "docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4"
+	)
+	rootfs := t.TempDir()
+	tests := []struct {
+		name         string
+		args         []string
+		fail         []string
+		wantExitCode int
+		wantStdout   string
+		wantStderr   string
+	}{
+		{name: "image", args: []string{"--fail-on", "critical,high", "nginx:1.25"}},
+		{
+			name:         "image fails",
+			args:         []string{"--fail-on", "critical,high", "nginx:1.25"},
+			fail:         []string{"nginx:1.25"},
+			wantExitCode: exitScanFailed,
+			wantStderr:   "Error scanning image",
+		},
+		{
+			name:         "image fails with a JSON summary",
+			args:         []string{"--json-summary", "--fail-on-kev", "nginx:1.25"},
+			fail:         []string{"nginx:1.25"},
+			wantExitCode: exitScanFailed,
+			wantStderr:   "Error scanning image",
+		},
+		{
+			name:         "filesystem fails",
+			args:         []string{"--fail-on", "critical", "--target", "fs:" + rootfs},
+			fail:         []string{rootfs},
+			wantExitCode: exitScanFailed,
+			wantStderr:   "Error scanning filesystem",
+		},
+		{
+			// One image of the chart failing is enough to fail the run.
+			name:         "chart image fails",
+			args:         []string{"--no-mutable-tags", "bitnami/redis@18.1.0"},
+			fail:         []string{exporter},
+			wantExitCode: exitScanFailed,
+			wantStderr:   "error scanning image " + exporter,
+		},
+		{
+			name:         "second image of a comparison fails",
+			args:         []string{"--compare", "docker.io/bitnami/redis:7.2.4-debian-12-r9", newRedis},
+			fail:         []string{newRedis},
+			wantExitCode: exitScanFailed,
+			wantStderr:   "Error scanning second image",
+		},
+		{
+			name:         "chart comparison fails",
+			args:         []string{"--fail-on", "critical", "--compare", "bitnami/redis@18.1.0", "bitnami/redis@18.2.0"},
+			fail:         []string{newRedis},
+			wantExitCode: exitScanFailed,
+			wantStderr:   "error scanning second Helm chart",
+		},
+		{
+			// The versions that scanned are reported, but the gates are not checked against them.
+			name:         "newest version of a range fails",
+			args:         []string{"--fail-on", "critical", "bitnami/redis@>=18.0.0"},
+			fail:         []string{newRedis},
+			wantExitCode: exitScanFailed,
+			wantStdout:   "| 18.1.0 |",
+			wantStderr:   "Error scanning Helm chart versions",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(fakeTrivyFailEnv, strings.Join(tt.fail, ","))
+
+			run := runHelmscan(t, charts, tt.args...)
+			if run.exitCode != tt.wantExitCode {
+				t.Fatalf("helmscan exited with %d, want %d:\n%s", run.exitCode, tt.wantExitCode, run.stderr)
+			}
+			if !strings.Contains(run.stdout, tt.wantStdout) {
+				t.Errorf("stdout is missing %q:\n%s", tt.wantStdout, run.stdout)
+			}
+			if !strings.Contains(run.stderr, tt.wantStderr) {
+				t.Errorf("stderr is missing %q:\n%s", tt.wantStderr, run.stderr)
+			}
+			if tt.wantExitCode == exitScanFailed && tt.wantStdout == "" && run.stdout != "" {
+				t.Errorf("a failed scan printed a report:\n%s", run.stdout)
+			}
+		})
+	}
+}
+
+func TestUnsignedImageFailures(t *testing.T) {
+	chart := helmscanTypes.HelmChart{Name: "app", Version: "1.0.0", HelmRepo: "bitnami", ContainsImages: []*helmscanTypes.ContainerImage{
+		{ImageName: "redis", Tag: "7.2.4", ScanResult: helmscanTypes.ScanResult{Image: "docker.io/bitnami/redis:7.2.4"},
+			Signature: &helmscanTypes.SignatureVerification{Verified: true}},
+		{ImageName: "busybox", Tag: "1.36", ScanResult: helmscanTypes.ScanResult{Image: "docker.io/library/busybox:1.36"},
+			Signature: &helmscanTypes.SignatureVerification{Error: "no signatures found"}},
+		// Images whose signatures were not checked never fail.
+		{ImageName: "kubectl", Tag: "1.30", ScanResult: helmscanTypes.ScanResult{Image: "docker.io/bitnami/kubectl:1.30"}},
+	}}
+	tests := []struct {
+		name string
+		opts options
+		want []string
+	}{
+		// Without --fail-on-unsigned unverified images are only reported.
+		{name: "report"},
+		{
+			name: "fail",
+			opts: options{failOnUnsigned: true},
+			want: []string{"the signature of docker.io/library/busybox:1.36 could not be verified: no signatures found"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unsignedImageFailures(chart, tt.opts); !slices.Equal(got, tt.want) {
+				t.Errorf("unsignedImageFailures() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifySignaturesFlags(t *testing.T) {
+	charts := map[string]string{"bitnami/redis@18.1.0": redisManifest}
+	tests := []struct {
+		name         string
+		args         []string
+		wantExitCode int
+		wantStdout   string
+		wantStderr   string
+		wantCosign   int
+	}{
+		{name: "off", args: []string{"bitnami/redis@18.1.0"}},
+		{
+			// The unsigned redis-exporter image is reported but does not fail the scan.
+			name:       "key",
+			args:       []string{"--verify-signatures", "--cosign-key", "cosign.pub", "bitnami/redis@18.1.0"},
+			wantStdout: "| docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4 | ❌ Not verified | no signatures found |",
+			wantCosign: 2,
+		},
+		{
+			name:       "keyless",
+			args:       []string{"--verify-signatures", "--cosign-identity", ".*", "--cosign-issuer", "https://token.actions.githubusercontent.com", "bitnami/redis@18.1.0"},
+			wantStdout: "| docker.io/bitnami/redis:7.2.4-debian-12-r9 | ✅ Verified |",
+			wantCosign: 2,
+		},
+		{
+			name:         "fail on unsigned",
+			args:         []string{"--fail-on-unsigned", "--cosign-key", "cosign.pub", "bitnami/redis@18.1.0"},
+			wantExitCode: 1,
+			wantStderr:   "the signature of docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4 could not be verified",
+			wantCosign:   2,
+		},
+		{
+			name:         "no key or identity",
+			args:         []string{"--verify-signatures", "--cosign-identity", ".*", "bitnami/redis@18.1.0"},
+			wantExitCode: 1,
+			wantStderr:   "--verify-signatures requires --cosign-key, or --cosign-identity and --cosign-issuer",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := runHelmscan(t, charts, append([]string{"--report-file=-"}, tt.args...)...)
+			if run.exitCode != tt.wantExitCode {
+				t.Fatalf("helmscan exited with %d, want %d:\n%s", run.exitCode, tt.wantExitCode, run.stderr)
+			}
+			if !strings.Contains(run.stdout, tt.wantStdout) {
+				t.Errorf("stdout is missing %q:\n%s", tt.wantStdout, run.stdout)
+			}
+			if !strings.Contains(run.stderr, tt.wantStderr) {
+				t.Errorf("stderr is missing %q:\n%s", tt.wantStderr, run.stderr)
+			}
+			if calls := fakeexec.Calls(t, filepath.Join(run.dir, "cosign.log")); len(calls) != tt.wantCosign {
+				t.Errorf("cosign was run %d times, want %d", len(calls), tt.wantCosign)
+			}
+		})
+	}
+}
+
+func TestSeverityFlags(t *testing.T) {
+	charts := map[string]string{"bitnami/redis@18.1.0": redisManifest}
+	tests := []struct {
+		name         string
+		args         []string
+		wantExitCode int
+		wantStdout   []string
+		wantNoStdout []string
+		wantStderr   string
+	}{
+		{
+			name:       "all severities",
+			wantStdout: []string{"| Critical | 0 |", "| Low | 0 |"},
+		},
+		{
+			name:         "display severity",
+			args:         []string{"--display-severity", "CRITICAL,high", "--fail-on", "low"},
+			wantStdout:   []string{"| Critical | 0 |", "| High | 0 |"},
+			wantNoStdout: []string{"| Medium |", "| Low |"},
+		},
+		{
+			name:         "invalid fail on",
+			args:         []string{"--fail-on", "severe"},
+			wantExitCode: 1,
+			wantStderr:   `Invalid --fail-on "severe", expected a comma-separated list of critical, high, medium, low, unknown`,
+		},
+		{
+			name:         "invalid display severity",
+			args:         []string{"--display-severity", "info"},
+			wantExitCode: 1,
+			wantStderr:   `Invalid --display-severity "info"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := runHelmscan(t, charts, append(tt.args, "--report-file=-", "bitnami/redis@18.1.0")...)
+			if run.exitCode != tt.wantExitCode {
+				t.Fatalf("helmscan exited with %d, want %d:\n%s", run.exitCode, tt.wantExitCode, run.stderr)
+			}
+			for _, want := range tt.wantStdout {
+				if !strings.Contains(run.stdout, want) {
+					t.Errorf("stdout is missing %q:\n%s", want, run.stdout)
+				}
+			}
+			for _, unwanted := range tt.wantNoStdout {
+				if strings.Contains(run.stdout, unwanted) {
+					t.Errorf("stdout has %q:\n%s", unwanted, run.stdout)
+				}
+			}
+			if !strings.Contains(run.stderr, tt.wantStderr) {
+				t.Errorf("stderr is missing %q:\n%s", tt.wantStderr, run.stderr)
+			}
+		})
+	}
+}
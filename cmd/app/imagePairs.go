@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+	"github.com/cliffcolvin/helmscan/internal/imageScan"
+	"github.com/cliffcolvin/helmscan/internal/reports"
+)
+
+type imagePair struct {
+	before, after string
+}
+
+// readImagePairs reads one before,after pair of image references per line, skipping blank lines
+// and # comments.
+func readImagePairs(r io.Reader) ([]imagePair, error) {
+	var pairs []imagePair
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, ",")
+		if len(fields) != 2 || strings.TrimSpace(fields[0]) == "" || strings.TrimSpace(fields[1]) == "" {
+			return nil, fmt.Errorf("line %d: expected before,after image references, got %q", line, text)
+		}
+		pairs = append(pairs, imagePair{before: strings.TrimSpace(fields[0]), after: strings.TrimSpace(fields[1])})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return pairs, nil
+}
+
+// compareImagePairs compares every pair of images listed in source, a file or - for stdin, in one
+// report. An image listed in several pairs is scanned once. A pair whose images fail to scan is
+// recorded in the report and the rest continue; helmscan then exits with exitScanFailed.
+func compareImagePairs(ctx context.Context, source string, opts options) {
+	var input io.Reader = os.Stdin
+	if source != "-" {
+		file, err := os.Open(source)
+		if err != nil {
+			logger.Fatalf("Error reading --image-pairs: %v", err)
+		}
+		defer file.Close()
+		input = file
+	}
+	pairs, err := readImagePairs(input)
+	if err != nil {
+		logger.Fatalf("Error reading --image-pairs: %v", err)
+	}
+	if len(pairs) == 0 {
+		logger.Fatal("--image-pairs lists no image pairs")
+	}
+
+	var refs []string
+	listed := make(map[string]bool)
+	for _, pair := range pairs {
+		for _, ref := range []string{pair.before, pair.after} {
+			if !listed[ref] {
+				listed[ref] = true
+				refs = append(refs, ref)
+			}
+		}
+	}
+
+	scans := make(map[string]helmscanTypes.ScanResult)
+	scanErrors := make(map[string]error)
+	for i, ref := range refs {
+		if ctx.Err() != nil {
+			logger.Fatalf("Image pair comparison interrupted: %v", ctx.Err())
+		}
+		if isHelmChart(ref) {
+			scanErrors[ref] = fmt.Errorf("%s is not an image reference", ref)
+			continue
+		}
+		logger.Infof("Scanning image %d of %d: %s", i+1, len(refs), ref)
+		result, err := imageScan.ScanImageContext(ctx, ref, opts.scan)
+		if err != nil {
+			scanErrors[ref] = err
+			continue
+		}
+		scans[ref] = result
+	}
+
+	reportOpts := reportOptions(opts)
+	var report reports.ImagePairsReport
+	var vulns []helmscanTypes.Vulnerability
+	failed := false
+	for _, pair := range pairs {
+		if err := errors.Join(scanErrors[pair.before], scanErrors[pair.after]); err != nil {
+			logger.Errorf("Error comparing %s with %s: %v", pair.before, pair.after, err)
+			report.Pairs = append(report.Pairs, reports.ImagePairEntry{Before: pair.before, After: pair.after, Error: err.Error()})
+			failed = true
+			continue
+		}
+		comparison := imageScan.CompareScans(scans[pair.before], scans[pair.after])
+		report.Pairs = append(report.Pairs, reports.NewImagePairEntry(pair.before, pair.after, imageScan.NewImageReportGenerator(comparison), reportOpts))
+		vulns = append(vulns, comparison.Image2.VulnList...)
+	}
+
+	reportOutput, err := reports.GenerateImagePairsReport(report, opts.jsonOutput, reportOpts)
+	if err != nil {
+		logger.Fatalf("Error generating report: %v", err)
+	}
+	if opts.report {
+		ext := ".md"
+		if opts.jsonOutput {
+			ext = ".json"
+		}
+		if err := reports.WriteReport(reportOutput, reports.ReportFilename("image_pairs", source, reportOpts)+ext, reportOpts); err != nil {
+			logger.Fatalf("Error saving report: %v", err)
+		}
+	}
+	fmt.Println(reportOutput)
+
+	if failed {
+		os.Exit(exitScanFailed)
+	}
+	exitOnGateFailures(vulns, opts)
+}
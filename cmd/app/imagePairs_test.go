@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cliffcolvin/helmscan/internal/fakeexec"
+	"github.com/cliffcolvin/helmscan/internal/reports"
+)
+
+func TestReadImagePairs(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []imagePair
+		wantErr string
+	}{
+		{
+			name:  "pairs with comments and blank lines",
+			input: "# before,after\nredis:7.2.4, redis:7.2.5\n\n  nginx:1.25,nginx:1.26  \n",
+			want:  []imagePair{{before: "redis:7.2.4", after: "redis:7.2.5"}, {before: "nginx:1.25", after: "nginx:1.26"}},
+		},
+		{name: "empty", input: "# no pairs\n"},
+		{name: "one image", input: "redis:7.2.4\n", wantErr: `line 1: expected before,after image references, got "redis:7.2.4"`},
+		{name: "three images", input: "redis:7.2.4,redis:7.2.5\na,b,c\n", wantErr: "line 2:"},
+		{name: "missing after", input: "redis:7.2.4, \n", wantErr: "line 1:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readImagePairs(strings.NewReader(tt.input))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("readImagePairs() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readImagePairs() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readImagePairs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImagePairsFlag(t *testing.T) {
+	const pairs = `# before,after
+docker.io/bitnami/redis:7.2.4,docker.io/bitnami/redis:7.2.5
+docker.io/bitnami/redis:7.2.5,docker.io/bitnami/redis:7.2.6
+docker.io/library/nginx:1.25,docker.io/bitnami/redis:7.2.5
+`
+	tests := []struct {
+		name         string
+		input        string
+		args         []string
+		wantExitCode int
+		wantStderr   string
+		wantPairs    int
+		wantScanned  []string
+	}{
+		{
+			name:      "shared images are scanned once",
+			input:     pairs,
+			wantPairs: 3,
+			wantScanned: []string{
+				"docker.io/bitnami/redis:7.2.4",
+				"docker.io/bitnami/redis:7.2.5",
+				"docker.io/bitnami/redis:7.2.6",
+				"docker.io/library/nginx:1.25",
+			},
+		},
+		{
+			name:         "chart in a pair",
+			input:        "bitnami/redis@18.1.0,docker.io/bitnami/redis:7.2.5\n",
+			wantExitCode: exitScanFailed,
+			wantStderr:   "bitnami/redis@18.1.0 is not an image reference",
+			wantPairs:    1,
+			wantScanned:  []string{"docker.io/bitnami/redis:7.2.5"},
+		},
+		{name: "no pairs", input: "# none\n", wantExitCode: 1, wantStderr: "--image-pairs lists no image pairs"},
+		{name: "malformed", input: "docker.io/bitnami/redis:7.2.4\n", wantExitCode: 1, wantStderr: "expected before,after image references"},
+		{
+			name:         "artifact arguments",
+			input:        pairs,
+			args:         []string{"docker.io/bitnami/redis:7.2.4"},
+			wantExitCode: 1,
+			wantStderr:   "--image-pairs does not take artifact arguments",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "pairs.csv")
+			if err := os.WriteFile(file, []byte(tt.input), 0644); err != nil {
+				t.Fatal(err)
+			}
+			args := append([]string{"--json", "--image-pairs", file}, tt.args...)
+			run := runHelmscan(t, nil, args...)
+			if run.exitCode != tt.wantExitCode {
+				t.Fatalf("helmscan exited with %d, want %d:\n%s", run.exitCode, tt.wantExitCode, run.stderr)
+			}
+			if !strings.Contains(run.stderr, tt.wantStderr) {
+				t.Errorf("stderr is missing %q:\n%s", tt.wantStderr, run.stderr)
+			}
+
+			var scanned []string
+			for _, args := range fakeexec.Calls(t, filepath.Join(run.dir, "trivy.log")) {
+				if args[0] == "image" {
+					scanned = append(scanned, args[len(args)-1])
+				}
+			}
+			slices.Sort(scanned)
+			if !slices.Equal(scanned, tt.wantScanned) {
+				t.Errorf("trivy scanned %v, want %v", scanned, tt.wantScanned)
+			}
+			if tt.wantPairs == 0 {
+				return
+			}
+			var report reports.ImagePairsReport
+			if err := json.Unmarshal([]byte(run.stdout), &report); err != nil {
+				t.Fatalf("stdout is not a JSON report: %v\n%s", err, run.stdout)
+			}
+			if len(report.Pairs) != tt.wantPairs {
+				t.Errorf("report has %d pairs, want %d", len(report.Pairs), tt.wantPairs)
+			}
+		})
+	}
+}
@@ -2,191 +2,1341 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"regexp"
+	"slices"
 	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
 
+	"github.com/cliffcolvin/helmscan/internal/github"
 	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
 	"github.com/cliffcolvin/helmscan/internal/helmscan"
 	"github.com/cliffcolvin/helmscan/internal/imageScan"
+	"github.com/cliffcolvin/helmscan/internal/kev"
+	"github.com/cliffcolvin/helmscan/internal/notify"
 	"github.com/cliffcolvin/helmscan/internal/reports"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"golang.org/x/term"
 )
 
+var Version = "dev"
+
 var logger *zap.SugaredLogger
 
+const (
+	formatMarkdown = "markdown"
+	formatJSON     = "json"
+	formatJSONL    = "jsonl"
+	formatJUnit    = "junit"
+	formatMDGitHub = "md-github"
+)
+
+type options struct {
+	jsonOutput      bool
+	jsonSummary     bool
+	report          bool
+	strict          bool
+	dryRun          bool
+	mirror          bool
+	failOnEPSS      float64
+	failOnKEV       bool
+	baseline        string
+	saveScan        string
+	clean           bool
+	keepFiles       bool
+	fromScan        string
+	groupBy         string
+	countMode       string
+	explain         bool
+	compareOutput   string
+	remediation     bool
+	autoOrder       bool
+	failOn          []string
+	displaySeverity []string
+	warnOnNoImages  bool
+	failOnUnsigned  bool
+	reportFile      string
+	webhook         string
+	notifyLevel     string
+	ignoreBelow     string
+	metricsFile     string
+	format          string
+	hashedFilenames bool
+	githubComment   bool
+	githubRepo      string
+	githubPR        int
+	chartFile       string
+	chartImage      string
+	compareLists    bool
+	compareDigests  bool
+	outputDir       string
+	listRepos       bool
+	noMutableTags   bool
+	target          string
+	namespace       string
+	batch           string
+	imagePairs      string
+	serve           string
+	baseRef         string
+	headRef         string
+	riskWeights     reports.RiskWeights
+	top             int
+	dbMaxAge        time.Duration
+	template        *template.Template
+	templateExt     string
+	scan            helmscanTypes.ScanOptions
+}
+
+// stringList is a flag.Value that accepts comma-separated values and can be repeated.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*s = append(*s, item)
+		}
+	}
+	return nil
+}
+
+// registryMirrorList is a flag.Value that accepts comma-separated from=to rules and can be repeated.
+type registryMirrorList []helmscanTypes.RegistryMirror
+
+func (m *registryMirrorList) String() string {
+	rules := make([]string, 0, len(*m))
+	for _, mirror := range *m {
+		rules = append(rules, mirror.From+"="+mirror.To)
+	}
+	return strings.Join(rules, ",")
+}
+
+func (m *registryMirrorList) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		mirror, err := helmscan.ParseRegistryMirror(item)
+		if err != nil {
+			return err
+		}
+		*m = append(*m, mirror)
+	}
+	return nil
+}
+
 func init() {
+	logger, _ = newLogger("console", "info", stderrIsTerminal())
+}
+
+// newLogger builds the stderr logger shared with the internal packages. format is "console" or "json";
+// color only applies to console logs.
+func newLogger(format string, level string, color bool) (*zap.SugaredLogger, error) {
+	zapLevel, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
 	encoderConfig := zap.NewProductionEncoderConfig()
 	encoderConfig.TimeKey = "timestamp"
 	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-	encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
 
-	core := zapcore.NewCore(
-		zapcore.NewConsoleEncoder(encoderConfig),
-		zapcore.AddSync(os.Stdout),
-		zap.InfoLevel,
-	)
+	var encoder zapcore.Encoder
+	switch format {
+	case "console":
+		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		if color {
+			encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		}
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	case "json":
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	default:
+		return nil, fmt.Errorf("unknown log format %q, expected console or json", format)
+	}
 
-	zapLogger := zap.New(core)
-	defer zapLogger.Sync()
+	core := zapcore.NewCore(encoder, zapcore.AddSync(os.Stderr), zapLevel)
+	return zap.New(core, zap.Hooks(recordErrors)).Sugar(), nil
+}
 
-	logger = zapLogger.Sugar()
+func main() {
+	var opts options
+	compare := flag.Bool("compare", false, "Enable comparison mode")
+	configFile := flag.String("config", "", "YAML or JSON file of default flag values (defaults to ./helmscan.yaml when present)")
+	logFormat := flag.String("log-format", "console", "Log format written to stderr (console or json)")
+	logLevel := flag.String("log-level", "info", "Minimum log level (debug, info, warn, error)")
+	noColor := flag.Bool("no-color", false, "Disable colored log levels (color is also disabled when stderr is not a terminal)")
+	flag.BoolVar(&opts.jsonOutput, "json", false, "Output in JSON format")
+	flag.StringVar(&opts.format, "format", formatMarkdown, "Report format: markdown, md-github (markdown with collapsible CVE tables), json (same as --json), jsonl (one JSON object per vulnerability, single scans only) or junit (JUnit XML for CI test reports, single scans only)")
+	flag.BoolVar(&opts.jsonSummary, "json-summary", false, "Print only a compact JSON summary of the results to stdout")
+	flag.BoolVar(&opts.report, "report", false, "Generate a report file")
+	flag.BoolVar(&opts.scan.IgnoreUnfixed, "ignore-unfixed", false, "Ignore unfixed vulnerabilities in Trivy scans")
+	flag.StringVar(&opts.scan.Scanners, "scanners", helmscanTypes.DefaultScanners, "Comma-separated Trivy scanners to run (vuln, secret, misconfig)")
+	flag.StringVar(&opts.scan.DBRepository, "db-repository", "", "OCI repository to download the Trivy vulnerability DB from")
+	flag.BoolVar(&opts.scan.SkipDBUpdate, "skip-db-update", false, "Skip updating the Trivy vulnerability DB")
+	flag.BoolVar(&opts.scan.OfflineScan, "offline-scan", false, "Do not issue API requests from Trivy during scans")
+	flag.BoolVar(&opts.scan.ScanManifests, "scan-manifests", false, "Scan the rendered Helm manifests for misconfigurations with trivy config")
+	flag.StringVar(&opts.scan.TrivyConfig, "trivy-config", "", "Path to a trivy.yaml config file passed to every Trivy invocation")
+	flag.Var((*stringList)(&opts.scan.SkipRepos), "skip-repos", "Comma-separated repository prefixes or globs to exclude from scanning")
+	flag.Var((*stringList)(&opts.scan.OnlyRepos), "only-repos", "Comma-separated repository prefixes or globs; only matching images are scanned")
+	flag.Var((*registryMirrorList)(&opts.scan.RegistryMirrors), "registry-mirror", "Scan chart images through a registry mirror, rewriting references that start with from to start with to (from=to, repeatable)")
+	flag.BoolVar(&opts.scan.EnableAll, "enable-all", false, "Set every enabled toggle the chart's default values turn off to true before templating, to scan optional components too")
+	flag.BoolVar(&opts.scan.ScanAnnotations, "scan-annotations", false, "Also scan images referenced by chart annotations and labels whose keys match --annotation-keys, such as app.kubernetes.io/related-image")
+	flag.StringVar(&opts.scan.AnnotationKeys, "annotation-keys", helmscanTypes.DefaultAnnotationKeys, "Regexp matching the annotation and label keys --scan-annotations reads images from")
+	flag.BoolVar(&opts.scan.ImageExtractionDebug, "image-extraction-debug", false, "Save the image references found in each rendered chart, before parsing, and the images parsed from them to <work-dir>/tmp/image_extraction")
+	flag.BoolVar(&opts.scan.UpdateDependencies, "update-dependencies", false, "Run helm dependency build on local chart directories before templating them")
+	flag.IntVar(&opts.scan.Concurrency, "concurrency", helmscanTypes.DefaultConcurrency, "Number of images of a chart scanned at once")
+	flag.IntVar(&opts.scan.MaxParallel, "max-parallel", imageScan.DefaultMaxParallel(), "Maximum number of Trivy processes run at once, across both charts of a comparison; the default depends on available memory")
+	flag.StringVar(&opts.scan.ReleaseName, "release-name", helmscanTypes.DefaultReleaseName, "Release name charts are rendered with, for charts whose resource or image names depend on .Release.Name")
+	flag.IntVar(&opts.scan.MaxImages, "max-images", helmscanTypes.DefaultMaxImages, "Abort a chart scan that would scan more than this many images (0 disables the limit)")
+	flag.BoolVar(&opts.scan.IncludeRaw, "include-raw", false, "Save each image's raw Trivy JSON under <work-dir>/raw and list the files in the report")
+	flag.BoolVar(&opts.scan.EPSS, "epss", false, "Fetch EPSS exploit prediction scores from FIRST.org for each CVE")
+	flag.Float64Var(&opts.failOnEPSS, "fail-on-epss", 0, "Exit with status 1 if any CVE has an EPSS score at or above this value (implies --epss)")
+	flag.BoolVar(&opts.scan.KEV, "kev", false, "Flag CVEs listed in the CISA Known Exploited Vulnerabilities catalog")
+	flag.DurationVar(&opts.scan.KEVCacheTTL, "kev-cache-ttl", kev.DefaultCacheTTL, "How long a downloaded KEV catalog is reused before it is downloaded again")
+	flag.BoolVar(&opts.scan.VerifySignatures, "verify-signatures", false, "Verify the signature of each chart image with cosign and show the results in reports")
+	flag.StringVar(&opts.scan.CosignKey, "cosign-key", "", "Key cosign verifies image signatures against: a public key file, KMS URI or k8s:// secret (default keyless)")
+	flag.StringVar(&opts.scan.CosignIdentity, "cosign-identity", "", "Regexp the signing certificate identity must match for keyless verification")
+	flag.StringVar(&opts.scan.CosignIssuer, "cosign-issuer", "", "Regexp the signing certificate OIDC issuer must match for keyless verification")
+	flag.BoolVar(&opts.failOnUnsigned, "fail-on-unsigned", false, "Exit with status 1 if --verify-signatures cannot verify an image (implies --verify-signatures)")
+	flag.BoolVar(&opts.warnOnNoImages, "warn-on-no-images", false, "Exit with status 3 if a scanned Helm chart renders no images")
+	flag.Var((*stringList)(&opts.failOn), "fail-on", "Comma-separated severities; exit with status 1 if any CVE has one of them, whatever --display-severity shows")
+	flag.Var((*stringList)(&opts.displaySeverity), "display-severity", "Comma-separated severities reports show the CVEs and counts of (default all); gating is unaffected")
+	flag.BoolVar(&opts.failOnKEV, "fail-on-kev", false, "Exit with status 1 if any CVE is in the KEV catalog, regardless of severity (implies --kev)")
+	flag.StringVar(&opts.baseline, "baseline", "", "JSON report from a previous scan to diff a single scan against")
+	templatePath := flag.String("template", "", "Render Helm chart scans and comparisons through this Go text/template file instead of the built-in formats")
+	flag.StringVar(&opts.metricsFile, "metrics-file", "", "Write vulnerability counts, image count and scan duration to this file in the Prometheus text format")
+	flag.StringVar(&opts.saveScan, "save-scan", "", "Write the complete chart scan to this JSON file so reports can be regenerated with --from-scan")
+	flag.StringVar(&opts.fromScan, "from-scan", "", "Generate the report from a scan saved with --save-scan instead of running Helm and Trivy")
+	severityPolicy := flag.String("severity-policy", "", "YAML or JSON file of overrides that reclassify the severity of CVE IDs or packages")
+	riskWeights := flag.String("risk-weights", "", "Risk score points per vulnerability by severity, e.g. critical=10,high=5,medium=2,low=1 (the default)")
+	flag.IntVar(&opts.top, "top", 0, "Show only the N highest CVSS scored CVEs of each severity in markdown reports (0 shows all; JSON reports are never truncated)")
+	flag.BoolVar(&opts.explain, "explain", false, "Describe each CVE, with a link to its primary reference, in reports (truncated in markdown, in full in JSON)")
+	flag.BoolVar(&opts.autoOrder, "auto-order", false, "With --compare, compare two chart versions lower version first, whichever order they are given in")
+	flag.BoolVar(&opts.remediation, "remediation", false, "Add a remediation plan recommending, for each vulnerable package of each image, the smallest upgrade that fixes its most severe CVEs")
+	flag.StringVar(&opts.compareOutput, "compare-output", reports.CompareOutputSections, "How comparison reports list CVEs: sections lists added, removed and unchanged CVEs separately, inline-diff in one table marked +/-")
+	flag.StringVar(&opts.countMode, "count-mode", reports.CountModeImage, "How report summaries count CVEs: image counts a CVE once per image it is found in, chart counts each CVE once")
+	flag.StringVar(&opts.groupBy, "group-by", reports.GroupByCVE, "How chart scan reports list vulnerabilities: cve, or package to merge each vulnerable package across images")
+	flag.BoolVar(&opts.hashedFilenames, "hashed-filenames", false, "Name saved reports by a short hash of the artifact refs and options, so re-runs write to the same path")
+	flag.StringVar(&opts.reportFile, "report-file", "", "Write the report to this path instead of the reports directory, or to stdout only with - (implies --report)")
+	flag.StringVar(&opts.scan.WorkDir, "work-dir", helmscanTypes.DefaultWorkDir, "Directory for intermediate files such as rendered Helm output and Trivy JSON")
+	flag.BoolVar(&opts.clean, "clean", false, "Remove the rendered Helm output and Trivy JSON from --work-dir after a run without errors, keeping reports")
+	flag.BoolVar(&opts.keepFiles, "keep-intermediate", false, "Keep the rendered Helm output and Trivy JSON in --work-dir even with --clean, for debugging (the default)")
+	flag.StringVar(&opts.outputDir, "compare-output-dir", "", "Directory saved reports are written to, separate from --work-dir (default <work-dir>/scans)")
+	flag.StringVar(&opts.scan.Proxy, "proxy", "", "HTTP(S) proxy URL set as HTTP_PROXY and HTTPS_PROXY for Helm and Trivy")
+	flag.StringVar(&opts.scan.NoProxy, "no-proxy", "", "Comma-separated hosts set as NO_PROXY for Helm and Trivy")
+	flag.StringVar(&opts.scan.TrivyCacheDir, "trivy-cache-dir", "", "Directory set as TRIVY_CACHE_DIR for Trivy, for a per-job or shared DB and image cache (default Trivy's own)")
+	flag.BoolVar(&opts.githubComment, "github-comment", false, "Post the comparison report as a pull request comment, updating the comment from earlier runs (needs GITHUB_TOKEN)")
+	flag.StringVar(&opts.githubRepo, "github-repo", os.Getenv("GITHUB_REPOSITORY"), "Repository (owner/name) for --github-comment")
+	flag.IntVar(&opts.githubPR, "github-pr", pullRequestFromEnv(), "Pull request number for --github-comment (default from GITHUB_REF in pull request workflows)")
+	flag.BoolVar(&opts.compareLists, "compare-images", false, "Compare two files listing image references, one per line, like a chart comparison")
+	flag.StringVar(&opts.chartImage, "chart-image", "", "With --compare, compare this image of the chart given first (by name or repository/name) with the image given second")
+	flag.StringVar(&opts.chartFile, "chart-file", "", "Chart.yaml in a git checkout; compares the chart at the versions it has at --base-ref and --head-ref")
+	flag.StringVar(&opts.baseRef, "base-ref", baseRefFromEnv(), "Git ref holding the before version of --chart-file (default origin/$GITHUB_BASE_REF in pull request workflows)")
+	flag.StringVar(&opts.headRef, "head-ref", "HEAD", "Git ref holding the after version of --chart-file")
+	flag.StringVar(&opts.webhook, "notify-webhook", "", "POST a JSON summary to this URL when a comparison adds CVEs at or above --notify-severity")
+	flag.StringVar(&opts.notifyLevel, "notify-severity", "high", "Lowest severity of added CVEs that triggers --notify-webhook and fails the report verdict (critical, high, medium, low)")
+	flag.StringVar(&opts.ignoreBelow, "ignore-below", "", "Leave CVEs a comparison adds or removes below this severity out of the added and removed CVEs and the verdict, listing them as informational changes (critical, high, medium, low)")
+	flag.DurationVar(&opts.dbMaxAge, "db-max-age", imageScan.DefaultDBMaxAge, "Warn, or fail with --strict, when the Trivy vulnerability DB is older than this (0 disables the check)")
+	flag.BoolVar(&opts.strict, "strict", false, "Fail instead of warning when the environment is not fully supported")
+	flag.BoolVar(&opts.compareDigests, "compare-by-digest", false, "Treat an image as changed when the digest it resolves to differs, even if its tag is the same")
+	flag.BoolVar(&opts.mirror, "mirror", false, "Compare a chart with a mirror of the same chart and version, reporting repository differences per image")
+	flag.StringVar(&opts.target, "target", "", "Scan a target other than a chart or image: fs:<path> runs trivy fs on a directory such as a built rootfs, cluster scans the images running in the current kubecontext")
+	flag.StringVar(&opts.namespace, "namespace", "", "Namespace --target cluster scans (default all namespaces)")
+	flag.StringVar(&opts.serve, "serve", "", "Serve on-demand scans over HTTP on this address, e.g. :8080, at /scan?ref= and /compare?a=&b=")
+	flag.StringVar(&opts.batch, "batch", "", "Scan every chart listed one per line in this file, or in stdin with -, saving a report per chart and an index to the reports directory")
+	flag.StringVar(&opts.imagePairs, "image-pairs", "", "Compare every before,after pair of images listed one per line in this file, or in stdin with -, in one report")
+	flag.BoolVar(&opts.listRepos, "list-repos", false, "List the Helm repos configured in helm, which chart references must name, and exit")
+	flag.BoolVar(&opts.noMutableTags, "no-mutable-tags", false, "Exit with status 1 if any chart image uses the latest tag, or no tag, without a digest (such images are otherwise only warned about)")
+	flag.BoolVar(&opts.dryRun, "dry-run", false, "List the images that would be scanned without running Trivy")
+	flag.Parse()
 
-	if err := imageScan.CheckTrivyInstallation(); err != nil {
-		logger.Fatalf("Trivy installation check failed: %v", err)
+	configPath, err := configFilePath(*configFile)
+	if err != nil {
+		logger.Fatalf("Error locating config file: %v", err)
+	}
+	if configPath != "" {
+		if err := applyConfigFile(flag.CommandLine, configPath); err != nil {
+			logger.Fatalf("Error loading config file: %v", err)
+		}
 	}
-}
 
-func main() {
-	if err := os.MkdirAll("working-files", os.ModePerm); err != nil {
-		logger.Fatalf("Failed to create working-files directory: %v", err)
+	if opts.groupBy != reports.GroupByCVE && opts.groupBy != reports.GroupByPackage {
+		logger.Fatalf("Invalid --group-by %q, expected %s or %s", opts.groupBy, reports.GroupByCVE, reports.GroupByPackage)
+	}
+	if opts.countMode != reports.CountModeImage && opts.countMode != reports.CountModeChart {
+		logger.Fatalf("Invalid --count-mode %q, expected %s or %s", opts.countMode, reports.CountModeImage, reports.CountModeChart)
+	}
+	if opts.compareOutput != reports.CompareOutputSections && opts.compareOutput != reports.CompareOutputInlineDiff {
+		logger.Fatalf("Invalid --compare-output %q, expected %s or %s", opts.compareOutput, reports.CompareOutputSections, reports.CompareOutputInlineDiff)
+	}
+	if opts.reportFile != "" {
+		opts.report = true
+	}
+	if err := os.MkdirAll(opts.scan.WorkPath(), os.ModePerm); err != nil {
+		logger.Fatalf("Failed to create %s directory: %v", opts.scan.WorkPath(), err)
+	}
+	if opts.outputDir == "" {
+		opts.outputDir = opts.scan.WorkPath("scans")
+	}
+	defer func() { cleanIntermediateFiles(opts) }()
+	if *severityPolicy != "" {
+		if opts.scan.SeverityOverrides, err = imageScan.LoadSeverityPolicy(*severityPolicy); err != nil {
+			logger.Fatalf("Invalid --severity-policy: %v", err)
+		}
+	}
+	if opts.riskWeights, err = reports.ParseRiskWeights(*riskWeights); err != nil {
+		logger.Fatalf("Invalid --risk-weights: %v", err)
+	}
+	if reports.SeverityValue(opts.notifyLevel) == 0 {
+		logger.Fatalf("Invalid --notify-severity %q, expected critical, high, medium or low", opts.notifyLevel)
+	}
+	if opts.ignoreBelow = strings.ToLower(opts.ignoreBelow); opts.ignoreBelow != "" && reports.SeverityValue(opts.ignoreBelow) == 0 {
+		logger.Fatalf("Invalid --ignore-below %q, expected critical, high, medium or low", opts.ignoreBelow)
+	}
+	if opts.reportFile == "-" && opts.jsonSummary {
+		logger.Fatal("--report-file=- writes the report to stdout and cannot be combined with --json-summary")
+	}
+	switch opts.format {
+	case formatMarkdown:
+	case formatMDGitHub:
+		if opts.jsonOutput || *templatePath != "" {
+			logger.Fatal("--format=md-github cannot be combined with --json or --template")
+		}
+	case formatJSON:
+		opts.jsonOutput = true
+	case formatJSONL:
+		if opts.jsonOutput || opts.jsonSummary || opts.baseline != "" || *templatePath != "" {
+			logger.Fatal("--format=jsonl cannot be combined with --json, --json-summary, --baseline or --template")
+		}
+		if *compare || opts.compareLists {
+			logger.Fatal("--format=jsonl is only supported for single chart and image scans")
+		}
+	case formatJUnit:
+		if opts.jsonOutput || opts.jsonSummary || opts.baseline != "" || *templatePath != "" {
+			logger.Fatal("--format=junit cannot be combined with --json, --json-summary, --baseline or --template")
+		}
+		if *compare || opts.compareLists {
+			logger.Fatal("--format=junit is only supported for single chart and image scans")
+		}
+	default:
+		logger.Fatalf("Invalid --format %q, expected %s, %s, %s, %s or %s", opts.format, formatMarkdown, formatMDGitHub, formatJSON, formatJSONL, formatJUnit)
+	}
+	if *templatePath != "" {
+		if opts.jsonOutput || opts.jsonSummary || opts.baseline != "" {
+			logger.Fatal("--template cannot be combined with --json, --json-summary or --baseline")
+		}
+		opts.template, err = reports.LoadTemplate(*templatePath)
+		if err != nil {
+			logger.Fatalf("Invalid --template: %v", err)
+		}
+		opts.templateExt = reports.TemplateExtension(*templatePath)
+	}
+	if opts.top < 0 {
+		logger.Fatal("--top must not be negative")
+	}
+	if _, err := regexp.Compile(opts.scan.AnnotationKeys); err != nil {
+		logger.Fatalf("Invalid --annotation-keys: %v", err)
+	}
+	opts.failOn = parseSeverities("fail-on", opts.failOn)
+	opts.displaySeverity = parseSeverities("display-severity", opts.displaySeverity)
+	if opts.failOnEPSS > 0 {
+		opts.scan.EPSS = true
+	}
+	if opts.failOnKEV {
+		opts.scan.KEV = true
+	}
+	if opts.failOnUnsigned {
+		opts.scan.VerifySignatures = true
+	}
+	if opts.scan.VerifySignatures && opts.scan.CosignKey == "" && (opts.scan.CosignIdentity == "" || opts.scan.CosignIssuer == "") {
+		logger.Fatal("--verify-signatures requires --cosign-key, or --cosign-identity and --cosign-issuer for keyless verification")
 	}
 
-	compare := flag.Bool("compare", false, "Enable comparison mode")
-	jsonOutput := flag.Bool("json", false, "Output in JSON format")
-	report := flag.Bool("report", false, "Generate a report file")
-	ignoreUnfixed := flag.Bool("ignore-unfixed", false, "Ignore unfixed vulnerabilities in Trivy scans")
-	flag.Parse()
+	configuredLogger, err := newLogger(*logFormat, *logLevel, !*noColor && stderrIsTerminal())
+	if err != nil {
+		logger.Fatalf("Invalid logging options: %v", err)
+	}
+	logger = configuredLogger
+	defer logger.Sync()
+	helmscan.SetLogger(logger)
+	imageScan.SetLogger(logger)
+	logger.Info("Application started")
 
 	args := flag.Args()
+	if len(args) == 0 && opts.fromScan == "" && !opts.listRepos && opts.batch == "" && opts.imagePairs == "" && opts.target == "" && opts.serve == "" && !stdinIsTerminal() {
+		fmt.Fprintln(os.Stderr, "At least one artifact reference is required when stdin is not a terminal.")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if opts.listRepos {
+		listHelmRepos(ctx, opts)
+		return
+	}
+
+	if opts.chartFile != "" {
+		if len(args) != 1 || opts.fromScan != "" {
+			logger.Fatal("--chart-file takes a single repo or repo/chart argument without a version")
+		}
+		if opts.baseRef == "" {
+			logger.Fatal("--chart-file requires --base-ref")
+		}
+		chartRef1, chartRef2, err := helmscan.ChartRefsFromGit(ctx, args[0], opts.chartFile, opts.baseRef, opts.headRef)
+		if err != nil {
+			logger.Fatalf("Error reading chart versions from git: %v", err)
+		}
+		logger.Infof("Comparing %s at %s with %s at %s", chartRef1, opts.baseRef, chartRef2, opts.headRef)
+		args = []string{chartRef1, chartRef2}
+		*compare = true
+	}
+
+	if opts.mirror && !*compare {
+		logger.Fatal("--mirror requires --compare")
+	}
+	if opts.compareDigests && opts.mirror {
+		logger.Fatal("--compare-by-digest cannot be combined with --mirror")
+	}
+	if opts.chartImage != "" && !*compare {
+		logger.Fatal("--chart-image requires --compare")
+	}
+	if opts.compareLists {
+		if len(args) != 2 || *compare || opts.chartFile != "" || opts.fromScan != "" {
+			logger.Fatal("--compare-images takes exactly two image list files and no other comparison mode")
+		}
+		if opts.mirror || opts.template != nil || opts.dryRun {
+			logger.Fatal("--compare-images cannot be combined with --mirror, --template or --dry-run")
+		}
+	}
+	if opts.githubComment {
+		if !*compare {
+			logger.Fatal("--github-comment requires --compare")
+		}
+		if os.Getenv("GITHUB_TOKEN") == "" || opts.githubRepo == "" || opts.githubPR <= 0 {
+			logger.Fatal("--github-comment requires GITHUB_TOKEN, --github-repo and --github-pr")
+		}
+	}
+
+	if *compare && len(args) == 2 && opts.chartImage == "" {
+		// Catch a chart compared with an image before templating, DB checks or scanning start.
+		if err := checkComparable(args[0], args[1], opts); err != nil {
+			logger.Fatalf("Invalid comparison: %v", err)
+		}
+	}
+
+	if opts.batch != "" {
+		if len(args) > 0 || *compare || opts.compareLists || opts.chartFile != "" || opts.fromScan != "" || opts.dryRun {
+			logger.Fatal("--batch does not take artifact arguments or another scan mode")
+		}
+		if opts.reportFile != "" || opts.jsonSummary || opts.baseline != "" || opts.saveScan != "" || opts.template != nil || opts.format == formatJSONL || opts.format == formatJUnit {
+			logger.Fatal("--batch cannot be combined with --report-file, --json-summary, --baseline, --save-scan, --template, --format=jsonl or --format=junit")
+		}
+	}
+
+	if opts.imagePairs != "" {
+		if len(args) > 0 || *compare || opts.compareLists || opts.batch != "" || opts.chartFile != "" || opts.fromScan != "" || opts.dryRun {
+			logger.Fatal("--image-pairs does not take artifact arguments or another scan mode")
+		}
+		if opts.jsonSummary || opts.baseline != "" || opts.saveScan != "" || opts.template != nil || opts.format == formatJSONL || opts.format == formatJUnit {
+			logger.Fatal("--image-pairs cannot be combined with --json-summary, --baseline, --save-scan, --template, --format=jsonl or --format=junit")
+		}
+	}
+
+	if opts.serve != "" && (len(args) > 0 || *compare || opts.compareLists || opts.batch != "" || opts.imagePairs != "" || opts.target != "" || opts.chartFile != "" || opts.fromScan != "" || opts.dryRun) {
+		logger.Fatal("--serve does not take artifact arguments or another scan mode")
+	}
+
+	if opts.target != "" {
+		if _, ok := imageScan.FilesystemPath(opts.target); !ok && opts.target != clusterTarget {
+			logger.Fatalf("Invalid --target %q, expected fs:<path> or %s", opts.target, clusterTarget)
+		}
+		if len(args) > 0 || *compare || opts.compareLists || opts.chartFile != "" || opts.fromScan != "" || opts.batch != "" || opts.imagePairs != "" || opts.dryRun {
+			logger.Fatal("--target does not take artifact arguments or another scan mode")
+		}
+		if opts.template != nil {
+			logger.Fatal("--template is only supported for Helm chart scans and comparisons")
+		}
+	}
+
+	if opts.fromScan != "" {
+		if len(args) > 0 || *compare {
+			logger.Fatal("--from-scan does not take artifact arguments or --compare")
+		}
+		chart, err := reports.LoadScan(opts.fromScan)
+		if err != nil {
+			logger.Fatalf("Error loading saved scan: %v", err)
+		}
+		reportHelmChart(fmt.Sprintf("%s/%s@%s", chart.HelmRepo, chart.Name, chart.Version), chart, opts)
+		return
+	}
+
+	if opts.dryRun {
+		if len(args) == 0 {
+			logger.Fatal("At least one artifact reference is required")
+		}
+		if !*compare && len(args) > 1 {
+			logger.Fatal("Too many arguments for single artifact scan")
+		}
+		if *compare && len(args) != 2 {
+			logger.Fatal("Comparison mode requires exactly two artifacts")
+		}
+		var tagFailures []string
+		for _, ref := range args {
+			tagFailures = append(tagFailures, listImages(ctx, ref, opts)...)
+		}
+		exitOnGateFailures(nil, opts, tagFailures...)
+		return
+	}
+
+	if err := imageScan.CheckTrivyInstallation(opts.strict); err != nil {
+		logger.Fatalf("Trivy installation check failed: %v", err)
+	}
+	if err := imageScan.CheckTrivyDB(opts.dbMaxAge, opts.scan, opts.strict); err != nil {
+		logger.Fatalf("Trivy DB check failed: %v", err)
+	}
+
+	if opts.batch != "" {
+		scanBatch(ctx, opts.batch, opts)
+		return
+	}
+
+	if opts.imagePairs != "" {
+		compareImagePairs(ctx, opts.imagePairs, opts)
+		return
+	}
+
+	if opts.serve != "" {
+		serve(ctx, opts.serve, opts)
+		return
+	}
+
+	if opts.target == clusterTarget {
+		scanCluster(ctx, opts)
+		return
+	}
+	if opts.target != "" {
+		path, _ := imageScan.FilesystemPath(opts.target)
+		scanFilesystem(ctx, path, opts)
+		return
+	}
+
 	if len(args) == 0 {
-		logger.Fatal("At least one artifact reference is required")
+		runInteractiveMenu(ctx, opts)
+		return
+	}
+
+	if opts.compareLists {
+		compareImageLists(ctx, args[0], args[1], opts)
+		return
 	}
 
 	if *compare {
 		if len(args) != 2 {
 			logger.Fatal("Comparison mode requires exactly two artifacts")
 		}
-		compareArtifacts(args[0], args[1], *jsonOutput, *report, *ignoreUnfixed)
+		if opts.chartImage != "" {
+			compareChartImage(ctx, args[0], args[1], opts)
+			return
+		}
+		compareArtifacts(ctx, args[0], args[1], opts)
 	} else {
 		if len(args) > 1 {
 			logger.Fatal("Too many arguments for single artifact scan")
 		}
-		scanSingleArtifact(args[0], *jsonOutput, *report, *ignoreUnfixed)
+		scanSingleArtifact(ctx, args[0], opts)
 	}
 }
 
-func scanSingleArtifact(artifactRef string, jsonOutput bool, report bool, ignoreUnfixed bool) {
+func scanSingleArtifact(ctx context.Context, artifactRef string, opts options) {
 	if isHelmChart(artifactRef) {
-		scanSingleHelmChart(artifactRef, jsonOutput, report, ignoreUnfixed)
+		scanSingleHelmChart(ctx, artifactRef, opts)
 	} else {
-		scanSingleImage(artifactRef, jsonOutput, report, ignoreUnfixed)
+		if opts.template != nil {
+			logger.Fatal("--template is only supported for Helm chart scans and comparisons")
+		}
+		scanSingleImage(ctx, artifactRef, opts)
 	}
 }
 
-func compareArtifacts(ref1, ref2 string, jsonOutput bool, report bool, ignoreUnfixed bool) {
-	if isHelmChart(ref1) != isHelmChart(ref2) {
-		logger.Fatal("Cannot compare a Helm chart with a Docker image")
+func compareArtifacts(ctx context.Context, ref1, ref2 string, opts options) {
+	if err := checkComparable(ref1, ref2, opts); err != nil {
+		logger.Fatalf("Invalid comparison: %v", err)
 	}
 
 	if isHelmChart(ref1) {
-		compareHelmCharts(ref1, ref2, jsonOutput, report, ignoreUnfixed)
+		if opts.autoOrder {
+			ref1, ref2 = orderByVersion(ref1, ref2)
+		}
+		compareHelmCharts(ctx, ref1, ref2, opts)
 	} else {
-		compareImages(ref1, ref2, jsonOutput, report, ignoreUnfixed)
+		compareImages(ctx, ref1, ref2, opts)
+	}
+}
+
+// orderByVersion returns two chart references with the lower chart version first, so the
+// comparison reads as an upgrade. References that cannot be ordered are kept as given.
+func orderByVersion(ref1, ref2 string) (string, string) {
+	order, err := helmscan.CompareChartVersions(ref1, ref2)
+	switch {
+	case err != nil:
+		logger.Warnf("--auto-order: keeping the given order, %v", err)
+	case order > 0:
+		logger.Infof("--auto-order: comparing %s as before and %s as after, by chart version", ref2, ref1)
+		return ref2, ref1
+	default:
+		logger.Infof("--auto-order: %s is not newer than %s, keeping the given order", ref1, ref2)
 	}
+	return ref1, ref2
+}
+
+// checkComparable reports a comparison of two different kinds of artifact, or of images with
+// options only chart comparisons support.
+func checkComparable(ref1, ref2 string, opts options) error {
+	if isHelmChart(ref1) != isHelmChart(ref2) {
+		return fmt.Errorf("cannot compare %s (%s) with %s (%s): both references must be Helm charts (repo/chart@version or a local chart) or both images",
+			ref1, artifactType(ref1), ref2, artifactType(ref2))
+	}
+	if isHelmChart(ref1) {
+		return nil
+	}
+	if opts.mirror {
+		return fmt.Errorf("--mirror is only supported for Helm chart comparisons")
+	}
+	if opts.template != nil {
+		return fmt.Errorf("--template is only supported for Helm chart scans and comparisons")
+	}
+	return nil
+}
+
+// artifactType describes what kind of artifact isHelmChart and the scanners take ref to be.
+func artifactType(ref string) string {
+	switch {
+	case helmscan.IsLocalChart(ref):
+		return "local Helm chart"
+	case isHelmChart(ref):
+		return "Helm chart"
+	}
+	if _, ok := imageScan.TarballPath(ref); ok {
+		return "image tarball"
+	}
+	return "image"
 }
 
 func isHelmChart(ref string) bool {
-	return strings.Contains(ref, "/") && strings.Contains(ref, "@")
+	if _, ok := imageScan.TarballPath(ref); ok {
+		return false
+	}
+	return helmscan.IsLocalChart(ref) || helmscan.IsOCIChart(ref) || strings.Contains(ref, "/") && strings.Contains(ref, "@")
+}
+
+func validChartReference(ref string) bool {
+	if helmscan.IsOCIChart(ref) {
+		_, err := helmscan.NormalizeChartReference(ref)
+		return err == nil
+	}
+	return helmscan.IsLocalChart(ref) || len(strings.Split(ref, "@")) == 2
 }
 
-func scanSingleImage(imageURL string, jsonOutput bool, report bool, ignoreUnfixed bool) {
+func scanSingleImage(ctx context.Context, imageURL string, opts options) {
 	logger.Infof("Scanning image: %s", imageURL)
-	result, err := imageScan.ScanImage(imageURL, ignoreUnfixed)
+	start := time.Now()
+	result, err := imageScan.ScanImageContext(ctx, imageURL, opts.scan)
+	if err != nil {
+		exitOnScanError("Error scanning image", err)
+	}
+	writeMetrics(opts, reports.ScanMetrics{
+		ArtifactType: "image",
+		ArtifactRef:  imageURL,
+		Counts:       imageScan.SeverityCounts(result),
+		Images:       1,
+		Duration:     time.Since(start),
+	})
+
+	if opts.baseline != "" {
+		compareWithBaseline(imageURL, imageScan.VulnerabilitiesByCVE(result), result.VulnList, opts)
+		return
+	}
+
+	if opts.format == formatJSONL {
+		streamJSONLines(reports.ReportFilename("image_scan", imageURL, reportOptions(opts))+".jsonl", opts, func(w io.Writer) error {
+			return reports.WriteJSONLines(w, "", imageURL, result.VulnList)
+		})
+		exitOnGateFailures(result.VulnList, opts)
+		return
+	}
+	if opts.format == formatJUnit {
+		reportJUnit(imageURL, reports.ReportFilename("image_scan", imageURL, reportOptions(opts)), map[string][]helmscanTypes.Vulnerability{imageURL: result.VulnList}, opts)
+		exitOnGateFailures(result.VulnList, opts)
+		return
+	}
+
+	reportOutput := imageScan.GenerateSingleScanReport(result, opts.jsonOutput, opts.scan.IgnoreUnfixed, reportOptions(opts))
+	if opts.report {
+		ext := ".md"
+		if opts.jsonOutput {
+			ext = ".json"
+		}
+		filename := reports.ReportFilename("image_scan", imageURL, reportOptions(opts))
+		if err := reports.WriteReport(reportOutput, filename+ext, reportOptions(opts)); err != nil {
+			logger.Fatalf("Error saving report: %v", err)
+		}
+	}
+
+	if opts.jsonSummary {
+		fmt.Println(imageScan.GenerateSingleScanSummary(result))
+	} else {
+		fmt.Println(reportOutput)
+	}
+
+	exitOnGateFailures(result.VulnList, opts)
+}
+
+func scanFilesystem(ctx context.Context, path string, opts options) {
+	logger.Infof("Scanning filesystem: %s", path)
+	start := time.Now()
+	result, err := imageScan.ScanFilesystemContext(ctx, path, opts.scan)
+	if err != nil {
+		exitOnScanError("Error scanning filesystem", err)
+	}
+	writeMetrics(opts, reports.ScanMetrics{
+		ArtifactType: "filesystem",
+		ArtifactRef:  result.Image,
+		Counts:       imageScan.SeverityCounts(result),
+		Duration:     time.Since(start),
+	})
+
+	if opts.baseline != "" {
+		compareWithBaseline(result.Image, imageScan.VulnerabilitiesByCVE(result), result.VulnList, opts)
+		return
+	}
+
+	filename := reports.ReportFilename("fs_scan", path, reportOptions(opts))
+	if opts.format == formatJSONL {
+		streamJSONLines(filename+".jsonl", opts, func(w io.Writer) error {
+			return reports.WriteJSONLines(w, "", result.Image, result.VulnList)
+		})
+		exitOnGateFailures(result.VulnList, opts)
+		return
+	}
+	if opts.format == formatJUnit {
+		reportJUnit(result.Image, filename, map[string][]helmscanTypes.Vulnerability{result.Image: result.VulnList}, opts)
+		exitOnGateFailures(result.VulnList, opts)
+		return
+	}
+
+	reportOutput := imageScan.GenerateFilesystemReport(result, opts.jsonOutput, opts.scan.IgnoreUnfixed, reportOptions(opts))
+	if opts.report {
+		ext := ".md"
+		if opts.jsonOutput {
+			ext = ".json"
+		}
+		if err := reports.WriteReport(reportOutput, filename+ext, reportOptions(opts)); err != nil {
+			logger.Fatalf("Error saving report: %v", err)
+		}
+	}
+
+	if opts.jsonSummary {
+		fmt.Println(imageScan.GenerateSingleScanSummary(result))
+	} else {
+		fmt.Println(reportOutput)
+	}
+
+	exitOnGateFailures(result.VulnList, opts)
+}
+
+// clusterTarget is the --target that scans the images running in the current kubecontext.
+const clusterTarget = "cluster"
+
+func scanCluster(ctx context.Context, opts options) {
+	ref, err := helmscan.ClusterRef(opts.namespace)
+	if err != nil {
+		logger.Fatalf("Error scanning cluster: %v", err)
+	}
+	logger.Infof("Scanning images running in %s", ref)
+	start := time.Now()
+	result, err := helmscan.ScanClusterContext(ctx, opts.namespace, opts.scan)
 	if err != nil {
-		logger.Errorf("Error scanning image: %v", err)
+		exitOnScanError("Error scanning cluster", err)
+	}
+	writeMetrics(opts, reports.ScanMetrics{
+		ArtifactType: "cluster",
+		ArtifactRef:  ref,
+		Counts:       helmscan.SeverityCounts(result),
+		Images:       len(result.ContainsImages),
+		Duration:     time.Since(start),
+	})
+
+	if opts.baseline != "" {
+		compareWithBaseline(ref, helmscan.VulnerabilitiesByCVE(result), chartVulnerabilities(result), opts)
+		return
+	}
+
+	imgFailures := imageFailures(result, opts)
+	filename := reports.ReportFilename("cluster_scan", ref, reportOptions(opts))
+	if opts.format == formatJSONL {
+		streamJSONLines(filename+".jsonl", opts, func(w io.Writer) error {
+			return helmscan.WriteJSONLines(w, ref, result)
+		})
+		exitOnGateFailures(chartVulnerabilities(result), opts, imgFailures...)
+		return
+	}
+	if opts.format == formatJUnit {
+		reportJUnit(ref, filename, helmscan.VulnerabilitiesByImage(result), opts)
+		exitOnGateFailures(chartVulnerabilities(result), opts, imgFailures...)
 		return
 	}
 
-	reportOutput := imageScan.GenerateReport(&helmscanTypes.ImageComparisonReport{
-		Image2: result,
-	}, jsonOutput, report)
+	reportOutput := helmscan.GenerateClusterReport(result, opts.jsonOutput, opts.scan.IgnoreUnfixed, reportOptions(opts))
+	if opts.report {
+		ext := ".md"
+		if opts.jsonOutput {
+			ext = ".json"
+		}
+		if err := reports.WriteReport(reportOutput, filename+ext, reportOptions(opts)); err != nil {
+			logger.Fatalf("Error saving report: %v", err)
+		}
+	}
+
+	if opts.jsonSummary {
+		fmt.Println(helmscan.GenerateSingleScanSummary(result))
+	} else {
+		fmt.Println(reportOutput)
+	}
 
-	fmt.Println(reportOutput)
+	exitOnGateFailures(chartVulnerabilities(result), opts, imgFailures...)
 }
 
-func scanSingleHelmChart(chartRef string, jsonOutput bool, report bool, ignoreUnfixed bool) {
+func scanSingleHelmChart(ctx context.Context, chartRef string, opts options) {
 	logger.Infof("Scanning Helm chart: %s", chartRef)
-	parts := strings.Split(chartRef, "@")
-	if len(parts) != 2 {
-		logger.Fatalf("Invalid Helm chart reference. Expected format: repo/chart@version")
+	if !validChartReference(chartRef) {
+		logger.Fatalf("Invalid Helm chart reference. Expected format: repo/chart@version or oci://host/path/chart:version")
 	}
-	result, err := helmscan.Scan(chartRef, ignoreUnfixed)
+	if helmscan.HasVersionConstraint(chartRef) {
+		if opts.template != nil {
+			logger.Fatal("--template is not supported when scanning a range of chart versions")
+		}
+		if opts.format == formatJSONL || opts.format == formatJUnit {
+			logger.Fatalf("--format=%s is not supported when scanning a range of chart versions", opts.format)
+		}
+		scanHelmChartVersions(ctx, chartRef, opts)
+		return
+	}
+	start := time.Now()
+	result, err := helmscan.ScanContext(ctx, chartRef, opts.scan)
 	if err != nil {
-		logger.Errorf("Error scanning Helm chart: %v", err)
+		exitOnScanError("Error scanning Helm chart", err)
+	}
+	// Report the concrete version when chartRef used the latest pseudo-version.
+	chartRef = fmt.Sprintf("%s/%s@%s", result.HelmRepo, result.Name, result.Version)
+	writeMetrics(opts, reports.ScanMetrics{
+		ArtifactType: "chart",
+		ArtifactRef:  chartRef,
+		Counts:       helmscan.SeverityCounts(result),
+		Images:       len(result.ContainsImages),
+		Duration:     time.Since(start),
+	})
+
+	if opts.saveScan != "" {
+		if err := reports.SaveScan(result, opts.saveScan); err != nil {
+			logger.Fatalf("Error saving scan: %v", err)
+		}
+		logger.Infof("Scan saved to: %s", opts.saveScan)
+	}
+
+	reportHelmChart(chartRef, result, opts)
+}
+
+func writeMetrics(opts options, metrics reports.ScanMetrics) {
+	if opts.metricsFile == "" {
+		return
+	}
+	if err := reports.WriteMetricsFile(opts.metricsFile, metrics); err != nil {
+		logger.Fatalf("Error writing metrics: %v", err)
+	}
+	logger.Infof("Metrics written to: %s", opts.metricsFile)
+}
+
+func reportHelmChart(chartRef string, result helmscanTypes.HelmChart, opts options) {
+	if opts.baseline != "" {
+		compareWithBaseline(chartRef, helmscan.VulnerabilitiesByCVE(result), chartVulnerabilities(result), opts)
 		return
 	}
 
-	reportOutput := helmscan.GenerateSingleScanReport(result, jsonOutput, ignoreUnfixed)
-	
-	if report {
+	imgFailures := imageFailures(result, opts)
+	filename := reports.ReportFilename("helm_scan", chartRef, reportOptions(opts))
+	if opts.template != nil {
+		fmt.Println(renderTemplateReport(result, filename, opts))
+		exitOnNoImages(chartRef, result, opts)
+		exitOnGateFailures(chartVulnerabilities(result), opts, imgFailures...)
+		return
+	}
+	if opts.format == formatJSONL {
+		streamJSONLines(filename+".jsonl", opts, func(w io.Writer) error {
+			return helmscan.WriteJSONLines(w, chartRef, result)
+		})
+		exitOnNoImages(chartRef, result, opts)
+		exitOnGateFailures(chartVulnerabilities(result), opts, imgFailures...)
+		return
+	}
+	if opts.format == formatJUnit {
+		reportJUnit(chartRef, filename, helmscan.VulnerabilitiesByImage(result), opts)
+		exitOnNoImages(chartRef, result, opts)
+		exitOnGateFailures(chartVulnerabilities(result), opts, imgFailures...)
+		return
+	}
+
+	reportOutput := helmscan.GenerateSingleScanReport(result, opts.jsonOutput, opts.scan.IgnoreUnfixed, reportOptions(opts))
+
+	if opts.report {
 		ext := ".md"
-		if jsonOutput {
+		if opts.jsonOutput {
 			ext = ".json"
 		}
-		filename := fmt.Sprintf("helm_scan_%s%s", reports.CreateSafeFileName(chartRef), ext)
-		if err := reports.SaveToFile(reportOutput, filename); err != nil {
-			logger.Errorf("Error saving report: %v", err)
-		} else {
-			logger.Infof("Report saved to: %s", filename)
+		if err := reports.WriteReport(reportOutput, filename+ext, reportOptions(opts)); err != nil {
+			logger.Fatalf("Error saving report: %v", err)
 		}
 	}
-	
-	fmt.Println(reportOutput)
+
+	if opts.jsonSummary {
+		fmt.Println(helmscan.GenerateSingleScanSummary(result))
+	} else {
+		fmt.Println(reportOutput)
+	}
+
+	exitOnNoImages(chartRef, result, opts)
+	exitOnGateFailures(chartVulnerabilities(result), opts, imgFailures...)
 }
 
-func compareHelmCharts(chartRef1, chartRef2 string, jsonOutput bool, report bool, ignoreUnfixed bool) {
-	parts1 := strings.Split(chartRef1, "@")
-	parts2 := strings.Split(chartRef2, "@")
-	if len(parts1) != 2 || len(parts2) != 2 {
-		logger.Fatalf("Invalid Helm chart reference(s). Expected format: repo/chart@version")
+// renderTemplateReport renders data through --template, saving the result under baseFilename
+// when --report is set.
+func renderTemplateReport(data interface{}, baseFilename string, opts options) string {
+	output, err := reports.RenderTemplate(opts.template, data)
+	if err != nil {
+		logger.Fatalf("Error generating report: %v", err)
+	}
+	if opts.report {
+		if err := reports.WriteReport(output, baseFilename+opts.templateExt, reportOptions(opts)); err != nil {
+			logger.Fatalf("Error saving report: %v", err)
+		}
+	}
+	return output
+}
+
+// reportJUnit prints the --format=junit report of vulnsByImage and, with --report, saves it under
+// filename as well.
+func reportJUnit(name, filename string, vulnsByImage map[string][]helmscanTypes.Vulnerability, opts options) {
+	output, err := reports.GenerateJUnit(name, vulnsByImage, reportOptions(opts))
+	if err != nil {
+		logger.Fatalf("Error generating report: %v", err)
+	}
+	if opts.report {
+		if err := reports.WriteReport(output, filename+".xml", reportOptions(opts)); err != nil {
+			logger.Fatalf("Error saving report: %v", err)
+		}
+	}
+	fmt.Println(output)
+}
+
+// streamJSONLines writes --format=jsonl output to stdout and, with --report, to filename or
+// --report-file as well, without building the whole report in memory.
+func streamJSONLines(filename string, opts options, write func(io.Writer) error) {
+	var out io.Writer = os.Stdout
+	var file *os.File
+	if opts.report {
+		var err error
+		if file, err = reports.CreateReportFile(filename, reportOptions(opts)); err != nil {
+			logger.Fatalf("Error saving report: %v", err)
+		}
+		if file != nil {
+			defer file.Close()
+			out = io.MultiWriter(os.Stdout, file)
+		}
+	}
+
+	w := bufio.NewWriter(out)
+	if err := write(w); err != nil {
+		logger.Fatalf("Error generating report: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		logger.Fatalf("Error generating report: %v", err)
+	}
+	if file != nil {
+		if err := file.Close(); err != nil {
+			logger.Fatalf("Error saving report: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "\nReport saved to: %s\n", file.Name())
+	}
+}
+
+func scanHelmChartVersions(ctx context.Context, chartRef string, opts options) {
+	charts, scanErr := helmscan.ScanVersionsContext(ctx, chartRef, opts.scan)
+	if len(charts) == 0 {
+		exitOnScanError("Error scanning Helm chart versions", scanErr)
+	}
+
+	reportOutput, err := helmscan.GenerateTrendReport(chartRef, charts, opts.jsonOutput, reportOptions(opts))
+	if err != nil {
+		logger.Fatalf("Error generating report: %v", err)
+	}
+
+	if opts.report {
+		ext := ".md"
+		if opts.jsonOutput {
+			ext = ".json"
+		}
+		filename := reports.ReportFilename("helm_trend", chartRef, reportOptions(opts)) + ext
+		if err := reports.WriteReport(reportOutput, filename, reportOptions(opts)); err != nil {
+			logger.Fatalf("Error saving report: %v", err)
+		}
+	}
+
+	if opts.jsonSummary {
+		fmt.Println(helmscan.GenerateSingleScanSummary(charts[len(charts)-1]))
+	} else {
+		fmt.Println(reportOutput)
+	}
+
+	// The versions that scanned are reported, but the gates are not checked against an older
+	// version standing in for one that failed.
+	if scanErr != nil {
+		exitOnScanError("Error scanning Helm chart versions", scanErr)
+	}
+	latest := charts[len(charts)-1]
+	exitOnNoImages(fmt.Sprintf("%s/%s@%s", latest.HelmRepo, latest.Name, latest.Version), latest, opts)
+	exitOnGateFailures(chartVulnerabilities(latest), opts, imageFailures(latest, opts)...)
+}
+
+func compareHelmCharts(ctx context.Context, chartRef1, chartRef2 string, opts options) {
+	if !validChartReference(chartRef1) || !validChartReference(chartRef2) {
+		logger.Fatalf("Invalid Helm chart reference(s). Expected format: repo/chart@version or oci://host/path/chart:version")
+	}
+	if helmscan.HasVersionConstraint(chartRef1) || helmscan.HasVersionConstraint(chartRef2) {
+		logger.Fatal("Version constraints are only supported when scanning a single Helm chart")
 	}
 
 	logger.Infof("Comparing Helm charts: %s and %s", chartRef1, chartRef2)
 
-	scannedChart1, err := helmscan.Scan(chartRef1, ignoreUnfixed)
+	scannedChart1, scannedChart2, err := helmscan.ScanPairContext(ctx, chartRef1, chartRef2, opts.scan)
 	if err != nil {
-		logger.Errorf("Error scanning first Helm chart: %v", err)
-		return
+		exitOnScanError("Error scanning Helm charts", err)
 	}
 
-	scannedChart2, err := helmscan.Scan(chartRef2, ignoreUnfixed)
+	comparison, err := chartComparer(opts)(ctx, scannedChart1, scannedChart2)
 	if err != nil {
-		logger.Errorf("Error scanning second Helm chart: %v", err)
-		return
+		exitOnScanError("Error comparing Helm charts", err)
+	}
+	if opts.template != nil {
+		output := renderTemplateReport(comparison, reports.GeneratorFilename(helmscan.NewHelmReportGenerator(comparison), reportOptions(opts)), opts)
+		if !opts.report || opts.reportFile == "-" {
+			fmt.Println(output)
+		}
+	} else {
+		reportOutput, err := helmscan.GenerateReport(comparison, opts.jsonOutput, opts.report, reportOptions(opts))
+		if err != nil {
+			logger.Fatalf("Error generating report: %v", err)
+		}
+		if opts.reportFile == "-" {
+			fmt.Println(reportOutput)
+		}
 	}
+	notifyRegression(ctx, helmscan.NewHelmReportGenerator(comparison), opts)
+	commentOnPullRequest(ctx, helmscan.NewHelmReportGenerator(comparison), opts)
 
-	comparison := helmscan.CompareHelmCharts(scannedChart1, scannedChart2)
-	helmscan.GenerateReport(comparison, jsonOutput, report)
+	if opts.jsonSummary {
+		fmt.Println(helmscan.GenerateSummary(comparison))
+	}
 
+	exitOnNoImages(chartRef2, comparison.After, opts)
+	exitOnGateFailures(chartVulnerabilities(comparison.After), opts, imageFailures(comparison.After, opts)...)
 }
 
-func compareImages(imageURL1, imageURL2 string, jsonOutput bool, report bool, ignoreUnfixed bool) {
+func compareImages(ctx context.Context, imageURL1, imageURL2 string, opts options) {
 	if imageURL1 == "" || imageURL2 == "" {
-		fmt.Print("Enter the first image URL: ")
-		imageURL1 = getUserInput()
-		fmt.Print("Enter the second image URL: ")
-		imageURL2 = getUserInput()
+		logger.Fatal("Two image references are required for comparison")
+	}
+
+	scan1, err := imageScan.ScanImageContext(ctx, imageURL1, opts.scan)
+	if err != nil {
+		exitOnScanError("Error scanning first image", err)
 	}
 
-	scan1, err := imageScan.ScanImage(imageURL1, ignoreUnfixed)
+	scan2, err := imageScan.ScanImageContext(ctx, imageURL2, opts.scan)
 	if err != nil {
-		logger.Errorf("Error scanning first image: %v", err)
+		exitOnScanError("Error scanning second image", err)
+	}
+
+	comparison := imageScan.CompareScans(scan1, scan2)
+	notifyRegression(ctx, imageScan.NewImageReportGenerator(comparison), opts)
+	commentOnPullRequest(ctx, imageScan.NewImageReportGenerator(comparison), opts)
+	reportOutput, err := imageScan.GenerateReport(comparison, opts.jsonOutput, opts.report, reportOptions(opts))
+	if err != nil {
+		logger.Fatalf("Error generating report: %v", err)
+	}
+
+	if opts.jsonSummary {
+		fmt.Println(imageScan.GenerateSummary(comparison))
+	} else {
+		fmt.Println(reportOutput)
+	}
+
+	exitOnGateFailures(comparison.Image2.VulnList, opts)
+}
+
+// compareImageLists compares the images listed in two files with the chart comparison logic.
+func compareImageLists(ctx context.Context, beforePath, afterPath string, opts options) {
+	logger.Infof("Comparing image lists: %s and %s", beforePath, afterPath)
+
+	before, err := helmscan.ScanImageListContext(ctx, beforePath, opts.scan)
+	if err != nil {
+		exitOnScanError("Error scanning first image list", err)
+	}
+	after, err := helmscan.ScanImageListContext(ctx, afterPath, opts.scan)
+	if err != nil {
+		exitOnScanError("Error scanning second image list", err)
+	}
+
+	compare := helmscan.CompareHelmChartsContext
+	if opts.compareDigests {
+		compare = helmscan.CompareHelmChartsByDigestContext
+	}
+	comparison, err := compare(ctx, before, after)
+	if err != nil {
+		exitOnScanError("Error comparing image lists", err)
+	}
+	generator := helmscan.NewImageListReportGenerator(comparison, beforePath, afterPath)
+	reportOutput, err := reports.GenerateReport(generator, opts.jsonOutput, opts.report, reportOptions(opts))
+	if err != nil {
+		logger.Fatalf("Error generating report: %v", err)
+	}
+	if opts.reportFile == "-" {
+		fmt.Println(reportOutput)
+	}
+	notifyRegression(ctx, generator, opts)
+	commentOnPullRequest(ctx, generator, opts)
+
+	if opts.jsonSummary {
+		fmt.Println(reports.GenerateComparisonSummary(generator))
+	}
+
+	exitOnGateFailures(chartVulnerabilities(comparison.After), opts, imageFailures(comparison.After, opts)...)
+}
+
+// compareChartImage compares the image named by --chart-image, as pinned in chartRef, with imageRef.
+func compareChartImage(ctx context.Context, chartRef, imageRef string, opts options) {
+	if !isHelmChart(chartRef) || isHelmChart(imageRef) {
+		logger.Fatal("--chart-image compares a Helm chart's image with an image: pass the chart first and the image second")
+	}
+	if opts.mirror || opts.template != nil {
+		logger.Fatal("--chart-image cannot be combined with --mirror or --template")
+	}
+	chartImage, err := helmscan.ChartImageReference(ctx, chartRef, opts.chartImage, opts.scan)
+	if err != nil {
+		exitOnScanError("Error finding image in Helm chart", err)
+	}
+	logger.Infof("Comparing %s from %s with %s", chartImage, chartRef, imageRef)
+	compareImages(ctx, chartImage, imageRef, opts)
+}
+
+func listHelmRepos(ctx context.Context, opts options) {
+	repos, err := helmscan.ListHelmRepos(ctx, opts.scan)
+	if err != nil {
+		logger.Fatalf("Error listing Helm repos: %v", err)
+	}
+	if len(repos) == 0 {
+		fmt.Println("No Helm repos are configured; add one with helm repo add <name> <url>.")
 		return
 	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tURL")
+	for _, repo := range repos {
+		fmt.Fprintf(w, "%s\t%s\n", repo.Name, repo.URL)
+	}
+	w.Flush()
+}
+
+// listImages prints the images of a chart without scanning them, returning a gate failure for each
+// image using a mutable tag when --no-mutable-tags is set.
+func listImages(ctx context.Context, artifactRef string, opts options) []string {
+	if !isHelmChart(artifactRef) {
+		fmt.Println(artifactRef)
+		return nil
+	}
 
-	scan2, err := imageScan.ScanImage(imageURL2, ignoreUnfixed)
+	chart, err := helmscan.ListImagesContext(ctx, artifactRef, opts.scan)
 	if err != nil {
-		logger.Errorf("Error scanning second image: %v", err)
+		exitOnScanError("Error listing images for Helm chart", err)
+	}
+
+	fmt.Printf("%s/%s@%s\n", chart.HelmRepo, chart.Name, chart.Version)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REPOSITORY\tNAME\tTAG\tDIGEST\tSOURCES")
+	for _, img := range chart.ContainsImages {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", img.Repository, img.ImageName, img.Tag, img.Digest, formatSourceRefs(img.SourceRefs))
+	}
+	w.Flush()
+	for _, skipped := range chart.SkippedImages {
+		fmt.Printf("skipped %s: %s\n", skipped.Reference, skipped.Reason)
+	}
+	return mutableTagFailures(chart, opts)
+}
+
+func compareWithBaseline(artifactRef string, current map[string]map[string]helmscanTypes.Vulnerability, vulns []helmscanTypes.Vulnerability, opts options) {
+	baseline, err := reports.LoadBaseline(opts.baseline, artifactRef)
+	if err != nil {
+		logger.Fatalf("Error loading baseline: %v", err)
+	}
+
+	generator := reports.NewBaselineReportGenerator(artifactRef, current, baseline)
+	reportOutput, err := reports.GenerateReport(generator, opts.jsonOutput, opts.report, reportOptions(opts))
+	if err != nil {
+		logger.Fatalf("Error generating report: %v", err)
+	}
+	if reportOutput == "" {
+		reportOutput = reports.RenderMarkdown(generator, reportOptions(opts))
+	}
+
+	if opts.jsonSummary {
+		fmt.Println(reports.GenerateComparisonSummary(generator))
+	} else {
+		fmt.Println(reportOutput)
+	}
+
+	exitOnGateFailures(vulns, opts)
+}
+
+// notifyRegression posts a summary of the comparison's new CVEs to --notify-webhook. Failures are
+// logged but never fail the run.
+func notifyRegression(ctx context.Context, generator reports.ReportGenerator, opts options) {
+	if opts.webhook == "" {
+		return
+	}
+	minSeverity := opts.notifyLevel
+	if reports.SeverityValue(opts.ignoreBelow) > reports.SeverityValue(minSeverity) {
+		minSeverity = opts.ignoreBelow
+	}
+	summary, regressed := reports.NewRegressionSummary(generator, minSeverity)
+	if !regressed {
+		return
+	}
+	if err := notify.PostJSON(ctx, opts.webhook, summary); err != nil {
+		logger.Warnf("Failed to send webhook notification: %v", err)
 		return
 	}
+	logger.Infof("Sent regression notification for %d new CVEs", len(summary.NewCVEIDs))
+}
 
-	comparison := imageScan.CompareScans(scan1, scan2)
-	reportOutput := imageScan.GenerateReport(comparison, jsonOutput, report)
+// commentOnPullRequest posts the markdown report to the pull request. API errors are logged and
+// do not fail the run.
+func commentOnPullRequest(ctx context.Context, generator reports.ReportGenerator, opts options) {
+	if !opts.githubComment {
+		return
+	}
+	commentOptions := reportOptions(opts)
+	commentOptions.CollapsibleCVEs = true
+	report := reports.RenderMarkdown(generator, commentOptions)
+	if err := github.UpsertComment(ctx, os.Getenv("GITHUB_TOKEN"), opts.githubRepo, opts.githubPR, "helmscan-report", report); err != nil {
+		logger.Warnf("Failed to post GitHub comment: %v", err)
+		return
+	}
+	logger.Infof("Posted report to %s#%d", opts.githubRepo, opts.githubPR)
+}
+
+// pullRequestFromEnv returns the pull request number from GITHUB_REF (refs/pull/<n>/merge), which
+// GitHub Actions sets for pull_request events, or 0.
+func pullRequestFromEnv() int {
+	var pr int
+	if _, err := fmt.Sscanf(os.Getenv("GITHUB_REF"), "refs/pull/%d/", &pr); err != nil {
+		return 0
+	}
+	return pr
+}
+
+// baseRefFromEnv returns origin/<branch> for GITHUB_BASE_REF, which GitHub Actions sets to the
+// target branch in pull request workflows, or "".
+func baseRefFromEnv() string {
+	if branch := os.Getenv("GITHUB_BASE_REF"); branch != "" {
+		return "origin/" + branch
+	}
+	return ""
+}
+
+func reportOptions(opts options) reports.ReportOptions {
+	return reports.ReportOptions{
+		Scanners:        opts.scan.Scanners,
+		ScanManifests:   opts.scan.ScanManifests,
+		GroupBy:         opts.groupBy,
+		ReportFile:      opts.reportFile,
+		IgnoreUnfixed:   opts.scan.IgnoreUnfixed,
+		HashedFilenames: opts.hashedFilenames,
+		RiskWeights:     opts.riskWeights,
+		CollapsibleCVEs: opts.format == formatMDGitHub,
+		Top:             opts.top,
+		OutputDir:       opts.outputDir,
+		StatusSeverity:  opts.notifyLevel,
+		CountMode:       opts.countMode,
+		Explain:         opts.explain,
+		CompareOutput:   opts.compareOutput,
+		Remediation:     opts.remediation,
+		DisplaySeverity: opts.displaySeverity,
+		IgnoreBelow:     opts.ignoreBelow,
+		Metadata: &reports.Metadata{
+			ToolVersion:      Version,
+			GeneratedAt:      time.Now().UTC().Format(time.RFC3339),
+			TrivyVersion:     imageScan.TrivyVersion(),
+			TrivyDBUpdatedAt: imageScan.TrivyDBUpdatedAt(opts.scan),
+			Command:          redactCommand(os.Args),
+		},
+	}
+}
+
+// urlUserinfo matches the user:password@ of a URL in an argument, such as a --proxy with credentials.
+var urlUserinfo = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://)[^/@\s]+@`)
+
+// secretFlags are the flags whose whole value is a secret, such as a webhook URL holding a token.
+var secretFlags = []string{"notify-webhook"}
+
+// redactCommand joins args into the command recorded in report metadata. URL credentials and the
+// values of secretFlags are redacted, since reports are committed and posted to pull requests.
+func redactCommand(args []string) string {
+	redacted := make([]string, len(args))
+	secretValue := false
+	for i, arg := range args {
+		if secretValue {
+			arg, secretValue = "REDACTED", false
+		} else if name, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "="); strings.HasPrefix(arg, "-") && slices.Contains(secretFlags, name) {
+			if hasValue {
+				arg = arg[:strings.Index(arg, "=")+1] + "REDACTED"
+			} else {
+				secretValue = true
+			}
+		}
+		redacted[i] = urlUserinfo.ReplaceAllString(arg, "${1}REDACTED@")
+	}
+	return strings.Join(redacted, " ")
+}
+
+// stdinIsTerminal reports whether stdin is an interactive terminal, so the menu is never
+// started when helmscan is run from a pipe or CI job.
+func stdinIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
 
-	fmt.Println(reportOutput)
+func stderrIsTerminal() bool {
+	return term.IsTerminal(int(os.Stderr.Fd()))
 }
 
 func getUserInput() string {
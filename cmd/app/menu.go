@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func runInteractiveMenu(ctx context.Context, opts options) {
+	for {
+		fmt.Println("\nHelmScan")
+		fmt.Println("1. Scan a single chart or image")
+		fmt.Println("2. Compare two charts or images")
+		fmt.Println("3. Scan a list of references from a file")
+		fmt.Println("4. Paste a list of references")
+		fmt.Println("5. Exit")
+		fmt.Print("Choose an option: ")
+
+		switch getUserInput() {
+		case "1":
+			fmt.Print("Enter a chart (repo/chart@version) or image reference: ")
+			scanSingleArtifact(ctx, getUserInput(), opts)
+		case "2":
+			fmt.Print("Enter the first chart or image reference: ")
+			ref1 := getUserInput()
+			fmt.Print("Enter the second chart or image reference: ")
+			ref2 := getUserInput()
+			compareArtifacts(ctx, ref1, ref2, opts)
+		case "3":
+			fmt.Print("Enter the path to the list file: ")
+			data, err := os.ReadFile(getUserInput())
+			if err != nil {
+				logger.Errorf("Error reading list file: %v", err)
+				continue
+			}
+			scanReferenceList(ctx, parseReferenceList(string(data)), opts)
+		case "4":
+			fmt.Println("Paste references, one per line, followed by an empty line:")
+			var lines []string
+			for line := getUserInput(); line != ""; line = getUserInput() {
+				lines = append(lines, line)
+			}
+			scanReferenceList(ctx, parseReferenceList(strings.Join(lines, "\n")), opts)
+		case "5":
+			return
+		default:
+			fmt.Println("Invalid option, please try again.")
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// parseReferenceList returns the chart and image references in text, one per line. Blank lines
+// and lines starting with # are ignored, and duplicates are dropped.
+func parseReferenceList(text string) []string {
+	seen := make(map[string]bool)
+	var refs []string
+	for _, line := range strings.Split(text, "\n") {
+		ref := strings.TrimSpace(line)
+		if ref == "" || strings.HasPrefix(ref, "#") || seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// scanReferenceList scans each reference in turn and saves an individual report for each one.
+func scanReferenceList(ctx context.Context, refs []string, opts options) {
+	if len(refs) == 0 {
+		fmt.Println("No references to scan.")
+		return
+	}
+
+	opts.report = true
+	for i, ref := range refs {
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Infof("Scanning reference %d of %d: %s", i+1, len(refs), ref)
+		scanSingleArtifact(ctx, ref, opts)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseReferenceList(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "one per line",
+			text: "bitnami/redis@18.1.0\nnginx:1.25\n",
+			want: []string{"bitnami/redis@18.1.0", "nginx:1.25"},
+		},
+		{
+			name: "comments and blank lines",
+			text: "# charts\nbitnami/redis@18.1.0\n\n   \n# images\nnginx:1.25",
+			want: []string{"bitnami/redis@18.1.0", "nginx:1.25"},
+		},
+		{
+			name: "surrounding whitespace and CRLF",
+			text: "  bitnami/redis@18.1.0 \r\n\tnginx:1.25\r\n",
+			want: []string{"bitnami/redis@18.1.0", "nginx:1.25"},
+		},
+		{
+			name: "duplicates keep the first position",
+			text: "nginx:1.25\nbitnami/redis@18.1.0\nnginx:1.25\n",
+			want: []string{"nginx:1.25", "bitnami/redis@18.1.0"},
+		},
+		{
+			name: "empty",
+			text: "\n# nothing\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseReferenceList(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("parseReferenceList() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
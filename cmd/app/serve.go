@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+	"github.com/cliffcolvin/helmscan/internal/helmscan"
+	"github.com/cliffcolvin/helmscan/internal/imageScan"
+	"github.com/russross/blackfriday/v2"
+)
+
+// maxServeScans is how many /scan and /compare requests --serve runs at once. Further requests
+// wait for one to finish, on top of the --max-parallel limit on Trivy processes.
+const maxServeScans = 2
+
+const (
+	serveFormatJSON = "json"
+	serveFormatHTML = "html"
+)
+
+// serve scans on demand over HTTP until ctx is cancelled: /scan?ref= scans one chart or image and
+// /compare?a=&b= compares two, returning the report as JSON or, with format=html, rendered HTML.
+func serve(ctx context.Context, addr string, opts options) {
+	server := &http.Server{Addr: addr, Handler: newServeHandler(opts)}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Infof("Serving scans on %s", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Fatalf("Error serving: %v", err)
+	}
+}
+
+type scanServer struct {
+	opts  options
+	slots chan struct{}
+}
+
+func newServeHandler(opts options) http.Handler {
+	s := &scanServer{opts: opts, slots: make(chan struct{}, maxServeScans)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /scan", s.handleScan)
+	mux.HandleFunc("GET /compare", s.handleCompare)
+	return mux
+}
+
+func (s *scanServer) handleScan(w http.ResponseWriter, r *http.Request) {
+	ref := r.URL.Query().Get("ref")
+	if err := checkServedRef(ref); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.respond(w, r, ref, func(ctx context.Context, jsonOutput bool) (string, error) {
+		return s.scanReport(ctx, ref, jsonOutput)
+	})
+}
+
+func (s *scanServer) handleCompare(w http.ResponseWriter, r *http.Request) {
+	ref1, ref2 := r.URL.Query().Get("a"), r.URL.Query().Get("b")
+	for _, ref := range []string{ref1, ref2} {
+		if err := checkServedRef(ref); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := checkComparable(ref1, ref2, s.opts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.respond(w, r, ref1+" → "+ref2, func(ctx context.Context, jsonOutput bool) (string, error) {
+		return s.compareReport(ctx, ref1, ref2, jsonOutput)
+	})
+}
+
+// respond runs report once a scan slot is free and writes it in the requested format.
+func (s *scanServer) respond(w http.ResponseWriter, r *http.Request, title string, report func(ctx context.Context, jsonOutput bool) (string, error)) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = serveFormatJSON
+	}
+	if format != serveFormatJSON && format != serveFormatHTML {
+		http.Error(w, fmt.Sprintf("invalid format %q, expected %s or %s", format, serveFormatJSON, serveFormatHTML), http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-r.Context().Done():
+		return
+	}
+
+	output, err := report(r.Context(), format == serveFormatJSON)
+	if err != nil {
+		logger.Errorf("Error serving %s: %v", title, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if format == serveFormatJSON {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(w, output)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, renderHTML(title, output))
+}
+
+// checkServedRef accepts chart references and image references only. Local charts, image
+// tarballs and anything else naming a path on the server are refused.
+func checkServedRef(ref string) error {
+	switch {
+	case ref == "":
+		return fmt.Errorf("missing artifact reference")
+	case strings.HasPrefix(ref, "-") || strings.ContainsAny(ref, " \t\n"):
+		return fmt.Errorf("invalid artifact reference %q", ref)
+	case strings.HasPrefix(ref, ".") || strings.HasPrefix(ref, "/") || strings.HasPrefix(ref, "~") || helmscan.IsLocalChart(ref):
+		return fmt.Errorf("local paths cannot be scanned over HTTP")
+	}
+	if _, ok := imageScan.TarballPath(ref); ok {
+		return fmt.Errorf("image tarballs cannot be scanned over HTTP")
+	}
+	if isHelmChart(ref) && (!validChartReference(ref) || helmscan.HasVersionConstraint(ref)) {
+		return fmt.Errorf("invalid Helm chart reference %q, expected repo/chart@version or oci://host/path/chart:version", ref)
+	}
+	return nil
+}
+
+func (s *scanServer) scanReport(ctx context.Context, ref string, jsonOutput bool) (string, error) {
+	if isHelmChart(ref) {
+		result, err := helmscan.ScanContext(ctx, ref, s.opts.scan)
+		if err != nil {
+			return "", err
+		}
+		return helmscan.GenerateSingleScanReport(result, jsonOutput, s.opts.scan.IgnoreUnfixed, reportOptions(s.opts)), nil
+	}
+	result, err := imageScan.ScanImageContext(ctx, ref, s.opts.scan)
+	if err != nil {
+		return "", err
+	}
+	return imageScan.GenerateSingleScanReport(result, jsonOutput, s.opts.scan.IgnoreUnfixed, reportOptions(s.opts)), nil
+}
+
+func (s *scanServer) compareReport(ctx context.Context, ref1, ref2 string, jsonOutput bool) (string, error) {
+	if isHelmChart(ref1) {
+		chart1, chart2, err := helmscan.ScanPairContext(ctx, ref1, ref2, s.opts.scan)
+		if err != nil {
+			return "", err
+		}
+		comparison, err := chartComparer(s.opts)(ctx, chart1, chart2)
+		if err != nil {
+			return "", err
+		}
+		if jsonOutput {
+			return helmscan.RenderJSON(comparison, reportOptions(s.opts))
+		}
+		return helmscan.RenderMarkdown(comparison, reportOptions(s.opts)), nil
+	}
+
+	scan1, err := imageScan.ScanImageContext(ctx, ref1, s.opts.scan)
+	if err != nil {
+		return "", err
+	}
+	scan2, err := imageScan.ScanImageContext(ctx, ref2, s.opts.scan)
+	if err != nil {
+		return "", err
+	}
+	comparison := imageScan.CompareScans(scan1, scan2)
+	if jsonOutput {
+		return imageScan.RenderJSON(comparison, reportOptions(s.opts))
+	}
+	return imageScan.RenderMarkdown(comparison, reportOptions(s.opts)), nil
+}
+
+// renderHTML renders a markdown report as a standalone page. Raw HTML in the report, such as text
+// copied from CVE descriptions, is dropped rather than passed through.
+func renderHTML(title, markdown string) string {
+	renderer := blackfriday.NewHTMLRenderer(blackfriday.HTMLRendererParameters{
+		Flags: blackfriday.CommonHTMLFlags | blackfriday.SkipHTML,
+	})
+	body := blackfriday.Run([]byte(markdown), blackfriday.WithRenderer(renderer),
+		blackfriday.WithExtensions(blackfriday.CommonExtensions|blackfriday.AutoHeadingIDs))
+	return fmt.Sprintf("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n</head>\n<body>\n%s</body>\n</html>\n",
+		html.EscapeString(title), body)
+}
+
+// chartComparer returns the chart comparison --mirror or --compare-by-digest select.
+func chartComparer(opts options) func(ctx context.Context, before, after helmscanTypes.HelmChart) (helmscanTypes.HelmComparison, error) {
+	switch {
+	case opts.mirror:
+		return helmscan.CompareMirroredChartsContext
+	case opts.compareDigests:
+		return helmscan.CompareHelmChartsByDigestContext
+	}
+	return helmscan.CompareHelmChartsContext
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/cliffcolvin/helmscan/internal/reports"
+)
+
+func TestCheckServedRef(t *testing.T) {
+	tests := []struct {
+		ref     string
+		wantErr string
+	}{
+		{ref: "bitnami/redis@18.1.0"},
+		{ref: "oci://registry-1.docker.io/bitnamicharts/redis:18.1.0"},
+		{ref: "docker.io/bitnami/redis:7.2.4"},
+		{ref: "", wantErr: "missing artifact reference"},
+		{ref: "--help", wantErr: "invalid artifact reference"},
+		{ref: "bitnami/redis @18.1.0", wantErr: "invalid artifact reference"},
+		{ref: "./charts/redis", wantErr: "local paths cannot be scanned over HTTP"},
+		{ref: "/etc/passwd", wantErr: "local paths cannot be scanned over HTTP"},
+		{ref: "file://redis.tar", wantErr: "image tarballs cannot be scanned over HTTP"},
+		{ref: "bitnami/redis@>=18.0.0", wantErr: "invalid Helm chart reference"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			err := checkServedRef(tt.ref)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkServedRef(%q) error = %v", tt.ref, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkServedRef(%q) error = %v, want it to contain %q", tt.ref, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestServeHandler(t *testing.T) {
+	charts := map[string]string{
+		"bitnami/redis@18.1.0": redisManifest,
+		"bitnami/redis@18.2.0": redisManifest,
+	}
+	tests := []struct {
+		name            string
+		path            string
+		query           url.Values
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "chart scan",
+			path:            "/scan",
+			query:           url.Values{"ref": {"bitnami/redis@18.1.0"}},
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBody:        `"ArtifactRef": "bitnami/redis@18.1.0"`,
+		},
+		{
+			name:            "image scan",
+			path:            "/scan",
+			query:           url.Values{"ref": {"docker.io/bitnami/redis:7.2.4-debian-12-r9"}},
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBody:        `"ArtifactType": "image"`,
+		},
+		{
+			name:            "html",
+			path:            "/scan",
+			query:           url.Values{"ref": {"bitnami/redis@18.1.0"}, "format": {"html"}},
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/html; charset=utf-8",
+			wantBody:        "<title>bitnami/redis@18.1.0</title>",
+		},
+		{
+			name:            "chart comparison",
+			path:            "/compare",
+			query:           url.Values{"a": {"bitnami/redis@18.1.0"}, "b": {"bitnami/redis@18.2.0"}},
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBody:        `"report_type"`,
+		},
+		{
+			name:       "mixed comparison",
+			path:       "/compare",
+			query:      url.Values{"a": {"bitnami/redis@18.1.0"}, "b": {"docker.io/bitnami/redis:7.2.4"}},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid format",
+			path:       "/scan",
+			query:      url.Values{"ref": {"bitnami/redis@18.1.0"}, "format": {"pdf"}},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `invalid format "pdf", expected json or html`,
+		},
+		{
+			name:       "local chart",
+			path:       "/scan",
+			query:      url.Values{"ref": {"./charts/redis"}},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "local paths cannot be scanned over HTTP",
+		},
+		{
+			name:       "scan error",
+			path:       "/scan",
+			query:      url.Values{"ref": {"bitnami/redis@9.9.9"}},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installFakes(t, charts)
+			t.Chdir(t.TempDir())
+
+			recorder := httptest.NewRecorder()
+			newServeHandler(options{}).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.path+"?"+tt.query.Encode(), nil))
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d:\n%s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if got := recorder.Header().Get("Content-Type"); tt.wantContentType != "" && got != tt.wantContentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantContentType)
+			}
+			if !strings.Contains(recorder.Body.String(), tt.wantBody) {
+				t.Errorf("body is missing %q:\n%s", tt.wantBody, recorder.Body)
+			}
+		})
+	}
+
+	t.Run("json report", func(t *testing.T) {
+		installFakes(t, charts)
+		t.Chdir(t.TempDir())
+
+		recorder := httptest.NewRecorder()
+		newServeHandler(options{}).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/scan?ref=bitnami/redis@18.1.0", nil))
+		var report reports.SingleScanReport
+		if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+			t.Fatalf("/scan did not return a JSON report: %v\n%s", err, recorder.Body)
+		}
+		if report.ArtifactType != "helm" || report.ArtifactRef != "bitnami/redis@18.1.0" {
+			t.Errorf("artifact = %s %s, want helm bitnami/redis@18.1.0", report.ArtifactType, report.ArtifactRef)
+		}
+	})
+}
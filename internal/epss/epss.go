@@ -0,0 +1,139 @@
+package epss
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const batchSize = 100
+
+// apiURL, httpClient and cacheDir are variables so tests can point them at a fake API.
+var (
+	apiURL     = "https://api.first.org/data/v1/epss"
+	httpClient = &http.Client{Timeout: 30 * time.Second}
+	cacheDir   = "working-files/tmp/epss_cache"
+)
+
+// cacheMu serializes Scores so concurrent scans do not overwrite each other's cache updates.
+var cacheMu sync.Mutex
+
+type apiResponse struct {
+	Data []struct {
+		CVE  string `json:"cve"`
+		EPSS string `json:"epss"`
+	} `json:"data"`
+}
+
+// Scores returns the EPSS score of each CVE ID that FIRST.org has a score for. IDs that are not
+// CVEs (e.g. GHSA advisories) are ignored. Scores are published daily, so responses are cached
+// on disk for the current UTC day.
+func Scores(ctx context.Context, ids []string) (map[string]float64, error) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	cachePath := filepath.Join(cacheDir, time.Now().UTC().Format("2006-01-02")+".json")
+	scores := readCache(cachePath)
+
+	var missing []string
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if !strings.HasPrefix(id, "CVE-") || seen[id] {
+			continue
+		}
+		seen[id] = true
+		if _, cached := scores[id]; !cached {
+			missing = append(missing, id)
+		}
+	}
+
+	for start := 0; start < len(missing); start += batchSize {
+		end := min(start+batchSize, len(missing))
+		batch, err := fetch(ctx, missing[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for id, score := range batch {
+			scores[id] = score
+		}
+	}
+
+	if len(missing) > 0 {
+		writeCache(cachePath, scores)
+	}
+
+	result := make(map[string]float64)
+	for id := range seen {
+		if score, ok := scores[id]; ok {
+			result[id] = score
+		}
+	}
+	return result, nil
+}
+
+func fetch(ctx context.Context, ids []string) (map[string]float64, error) {
+	query := url.Values{}
+	query.Set("cve", strings.Join(ids, ","))
+	query.Set("limit", strconv.Itoa(len(ids)))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating EPSS request: %w", err)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching EPSS scores: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching EPSS scores: unexpected status %s", resp.Status)
+	}
+
+	var body apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("error parsing EPSS response: %w", err)
+	}
+
+	scores := make(map[string]float64)
+	for _, entry := range body.Data {
+		score, err := strconv.ParseFloat(entry.EPSS, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EPSS score %q for %s: %w", entry.EPSS, entry.CVE, err)
+		}
+		scores[entry.CVE] = score
+	}
+	return scores, nil
+}
+
+func readCache(path string) map[string]float64 {
+	scores := make(map[string]float64)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return scores
+	}
+	if err := json.Unmarshal(data, &scores); err != nil {
+		return make(map[string]float64)
+	}
+	return scores
+}
+
+func writeCache(path string, scores map[string]float64) {
+	data, err := json.Marshal(scores)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	_ = os.WriteFile(path, data, 0644)
+}
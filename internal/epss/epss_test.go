@@ -0,0 +1,188 @@
+package epss
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAPI serves the EPSS scores in scores like api.first.org, recording the CVEs of each request.
+type fakeAPI struct {
+	scores map[string]string
+	status int
+
+	mu       sync.Mutex
+	requests [][]string
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ids := strings.Split(r.URL.Query().Get("cve"), ",")
+	f.mu.Lock()
+	f.requests = append(f.requests, ids)
+	f.mu.Unlock()
+	if f.status != 0 {
+		w.WriteHeader(f.status)
+		return
+	}
+
+	var body apiResponse
+	for _, id := range ids {
+		if score, ok := f.scores[id]; ok {
+			body.Data = append(body.Data, struct {
+				CVE  string `json:"cve"`
+				EPSS string `json:"epss"`
+			}{id, score})
+		}
+	}
+	json.NewEncoder(w).Encode(body)
+}
+
+// useFakeAPI points Scores at api and an empty cache for the rest of the test.
+func useFakeAPI(t *testing.T, api *fakeAPI) {
+	t.Helper()
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	originalURL, originalClient, originalCache := apiURL, httpClient, cacheDir
+	apiURL, httpClient, cacheDir = server.URL, server.Client(), t.TempDir()
+	t.Cleanup(func() { apiURL, httpClient, cacheDir = originalURL, originalClient, originalCache })
+}
+
+func TestScores(t *testing.T) {
+	manyIDs := make([]string, 0, 150)
+	manyScores := make(map[string]string)
+	manyWant := make(map[string]float64)
+	for i := range 150 {
+		id := fmt.Sprintf("CVE-2024-%05d", i)
+		manyIDs = append(manyIDs, id)
+		manyScores[id], manyWant[id] = "0.001", 0.001
+	}
+
+	tests := []struct {
+		name         string
+		scores       map[string]string
+		status       int
+		ids          []string
+		want         map[string]float64
+		wantRequests int
+		wantErr      string
+	}{
+		{
+			name:         "scores",
+			scores:       map[string]string{"CVE-2023-45853": "0.00421", "CVE-2021-44228": "0.97565"},
+			ids:          []string{"CVE-2023-45853", "CVE-2021-44228"},
+			want:         map[string]float64{"CVE-2023-45853": 0.00421, "CVE-2021-44228": 0.97565},
+			wantRequests: 1,
+		},
+		{
+			// Advisories that are not CVEs and duplicates are never looked up.
+			name:         "non-CVE and duplicate IDs",
+			scores:       map[string]string{"CVE-2023-45853": "0.00421"},
+			ids:          []string{"GHSA-jfh8-c2jp-5v3q", "CVE-2023-45853", "CVE-2023-45853"},
+			want:         map[string]float64{"CVE-2023-45853": 0.00421},
+			wantRequests: 1,
+		},
+		{
+			name:         "CVE without a score",
+			scores:       map[string]string{},
+			ids:          []string{"CVE-2099-0001"},
+			want:         map[string]float64{},
+			wantRequests: 1,
+		},
+		{
+			name:         "batched",
+			scores:       manyScores,
+			ids:          manyIDs,
+			want:         manyWant,
+			wantRequests: 2,
+		},
+		{
+			name:         "no CVEs",
+			ids:          []string{"GHSA-jfh8-c2jp-5v3q"},
+			want:         map[string]float64{},
+			wantRequests: 0,
+		},
+		{
+			name:         "API error",
+			status:       http.StatusTooManyRequests,
+			ids:          []string{"CVE-2023-45853"},
+			wantRequests: 1,
+			wantErr:      "unexpected status 429",
+		},
+		{
+			name:         "invalid score",
+			scores:       map[string]string{"CVE-2023-45853": "high"},
+			ids:          []string{"CVE-2023-45853"},
+			wantRequests: 1,
+			wantErr:      `invalid EPSS score "high" for CVE-2023-45853`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &fakeAPI{scores: tt.scores, status: tt.status}
+			useFakeAPI(t, api)
+			got, err := Scores(context.Background(), tt.ids)
+			if len(api.requests) != tt.wantRequests {
+				t.Errorf("made %d requests, want %d", len(api.requests), tt.wantRequests)
+			}
+			for _, ids := range api.requests {
+				if len(ids) > batchSize {
+					t.Errorf("a request asked for %d CVEs, want at most %d", len(ids), batchSize)
+				}
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Scores() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Scores() error = %v", err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("Scores() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoresCache(t *testing.T) {
+	api := &fakeAPI{scores: map[string]string{"CVE-2023-45853": "0.00421", "CVE-2021-44228": "0.97565"}}
+	useFakeAPI(t, api)
+
+	if _, err := Scores(context.Background(), []string{"CVE-2023-45853"}); err != nil {
+		t.Fatal(err)
+	}
+	cachePath := filepath.Join(cacheDir, time.Now().UTC().Format("2006-01-02")+".json")
+	if _, err := os.Stat(cachePath); err != nil {
+		t.Fatalf("scores were not cached: %v", err)
+	}
+
+	// Only the CVE that is not cached yet is fetched.
+	got, err := Scores(context.Background(), []string{"CVE-2023-45853", "CVE-2021-44228"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(api.requests) != 2 || strings.Join(api.requests[1], ",") != "CVE-2021-44228" {
+		t.Errorf("requests = %v, want the second to ask only for CVE-2021-44228", api.requests)
+	}
+	if want := map[string]float64{"CVE-2023-45853": 0.00421, "CVE-2021-44228": 0.97565}; !maps.Equal(got, want) {
+		t.Errorf("Scores() = %v, want %v", got, want)
+	}
+
+	// A fully cached lookup makes no request.
+	if _, err := Scores(context.Background(), []string{"CVE-2021-44228"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(api.requests) != 2 {
+		t.Errorf("made %d requests, want the cached lookup to make none", len(api.requests))
+	}
+}
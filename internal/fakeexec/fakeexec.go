@@ -0,0 +1,162 @@
+// Package fakeexec stands in for the external commands helmscan runs, such as helm and trivy, in
+// tests. A fake runs in a child process started from the test binary, so it is exercised through
+// the same exec.Cmd plumbing, output capture and exit statuses as the real command.
+package fakeexec
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// Command is the fake of an external command. It writes to os.Stdout and os.Stderr as the real
+// command would and returns its exit status.
+type Command func(args []string) int
+
+var commands = make(map[string]Command)
+
+// stdout and stderr hold the standard streams of a fake's child process while its packages
+// initialize, so whatever the packages under test log from their init functions does not end up
+// in the fake's output. The files are swapped in place, so loggers that keep os.Stderr write to
+// the real stream again once Main restores it.
+var (
+	stdout, stderr os.File
+	silenced       bool
+)
+
+func init() {
+	// A fake is started under the name of the command it stands in for, not the test binary's.
+	binary, err := os.Executable()
+	if err != nil || filepath.Base(os.Args[0]) == filepath.Base(binary) {
+		return
+	}
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+	stdout, stderr = *os.Stdout, *os.Stderr
+	*os.Stdout, *os.Stderr = *devNull, *devNull
+	silenced = true
+}
+
+// Register makes the fake cmd run in place of name. Call it from TestMain before Main, so the
+// child process a fake runs in registers it too.
+func Register(name string, cmd Command) {
+	commands[name] = cmd
+}
+
+// Main runs the registered fake the test binary was started as, or else the tests.
+func Main(m *testing.M) {
+	if cmd, ok := commands[filepath.Base(os.Args[0])]; ok {
+		if silenced {
+			*os.Stdout, *os.Stderr = stdout, stderr
+		}
+		os.Exit(cmd(os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
+// CommandContext is a drop-in for exec.CommandContext that runs the registered fake of name
+// rather than the binary of that name on the PATH.
+func CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	binary, err := os.Executable()
+	cmd := exec.CommandContext(ctx, binary, args...)
+	// The fake is chosen by the name the child is started as.
+	cmd.Args[0] = name
+	if _, ok := commands[name]; !ok {
+		cmd.Err = fmt.Errorf("fakeexec: no fake registered for %s", name)
+	} else if err != nil {
+		cmd.Err = err
+	}
+	return cmd
+}
+
+// LookPath is a drop-in for exec.LookPath that finds the registered fakes.
+func LookPath(name string) (string, error) {
+	if _, ok := commands[name]; !ok {
+		return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+	}
+	return name, nil
+}
+
+// Install puts the registered fakes of names on the PATH for the rest of the test. It is for
+// commands run by another package, whose command runner the test cannot replace.
+func Install(t testing.TB, names ...string) {
+	t.Helper()
+	binary, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for _, name := range names {
+		if _, ok := commands[name]; !ok {
+			t.Fatalf("fakeexec: no fake registered for %s", name)
+		}
+		if err := os.Symlink(binary, filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// Arg returns the value following flag in args, as in trivy's -o path, or "" when flag is absent.
+func Arg(args []string, flag string) string {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == flag {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// LogArgs appends args to the file at path, unless path is "", so a test can read back how a fake
+// was invoked with Calls.
+func LogArgs(path string, args []string) {
+	if path == "" {
+		return
+	}
+	line, err := json.Marshal(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer file.Close()
+	fmt.Fprintf(file, "%s\n", line)
+}
+
+// Calls returns the arguments of every invocation LogArgs recorded in path, in order.
+func Calls(t testing.TB, path string) [][]string {
+	t.Helper()
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var calls [][]string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var args []string
+		if err := json.Unmarshal(scanner.Bytes(), &args); err != nil {
+			t.Fatalf("fakeexec: reading %s: %v", path, err)
+		}
+		calls = append(calls, args)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return calls
+}
@@ -0,0 +1,108 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxCommentLength is GitHub's limit on the length of a comment body.
+const maxCommentLength = 65536
+
+// apiURL and httpClient are variables so tests can point them at a fake API.
+var (
+	apiURL     = "https://api.github.com"
+	httpClient = &http.Client{Timeout: 30 * time.Second}
+)
+
+type issueComment struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+}
+
+// UpsertComment posts body as a comment on pull request pr of repo ("owner/name"), or edits the
+// comment a previous run posted, identified by the hidden marker, so re-runs keep a single
+// sticky comment instead of adding new ones.
+func UpsertComment(ctx context.Context, token, repo string, pr int, marker, body string) error {
+	body = commentBody(marker, body)
+
+	existing, err := findComment(ctx, token, repo, pr, marker)
+	if err != nil {
+		return err
+	}
+	if existing != 0 {
+		return send(ctx, token, http.MethodPatch, fmt.Sprintf("%s/repos/%s/issues/comments/%d", apiURL, repo, existing), body, nil)
+	}
+	return send(ctx, token, http.MethodPost, fmt.Sprintf("%s/repos/%s/issues/%d/comments", apiURL, repo, pr), body, nil)
+}
+
+func commentBody(marker, body string) string {
+	marker = fmt.Sprintf("<!-- %s -->\n", marker)
+	const truncated = "\n\n_Report truncated to fit in a GitHub comment._\n"
+	if len(marker)+len(body) > maxCommentLength {
+		body = strings.ToValidUTF8(body[:maxCommentLength-len(marker)-len(truncated)], "") + truncated
+	}
+	return marker + body
+}
+
+func findComment(ctx context.Context, token, repo string, pr int, marker string) (int64, error) {
+	marker = fmt.Sprintf("<!-- %s -->", marker)
+	for page := 1; ; page++ {
+		var comments []issueComment
+		url := fmt.Sprintf("%s/repos/%s/issues/%d/comments?per_page=100&page=%d", apiURL, repo, pr, page)
+		if err := send(ctx, token, http.MethodGet, url, "", &comments); err != nil {
+			return 0, err
+		}
+		for _, comment := range comments {
+			if strings.HasPrefix(comment.Body, marker) {
+				return comment.ID, nil
+			}
+		}
+		if len(comments) < 100 {
+			return 0, nil
+		}
+	}
+}
+
+func send(ctx context.Context, token, method, url, body string, result interface{}) error {
+	var payload *bytes.Reader
+	if body != "" {
+		data, err := json.Marshal(map[string]string{"body": body})
+		if err != nil {
+			return fmt.Errorf("error encoding GitHub comment: %w", err)
+		}
+		payload = bytes.NewReader(data)
+	} else {
+		payload = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, payload)
+	if err != nil {
+		return fmt.Errorf("error creating GitHub request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling GitHub API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("error calling GitHub API: %s %s returned %s", method, url, resp.Status)
+	}
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return fmt.Errorf("error parsing GitHub response: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+// fakeAPI serves the comments of one pull request like api.github.com, recording each request as
+// its method and path.
+type fakeAPI struct {
+	comments []issueComment
+	// failMethod fails every request with that method with status.
+	failMethod string
+	status     int
+
+	mu       sync.Mutex
+	requests []string
+	bodies   []string
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.Method == f.failMethod {
+		w.WriteHeader(f.status)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		start := min((page-1)*perPage, len(f.comments))
+		json.NewEncoder(w).Encode(f.comments[start:min(start+perPage, len(f.comments))])
+	case http.MethodPost, http.MethodPatch:
+		var payload struct {
+			Body string `json:"body"`
+		}
+		if r.Header.Get("Content-Type") != "application/json" || json.NewDecoder(r.Body).Decode(&payload) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.bodies = append(f.bodies, payload.Body)
+		json.NewEncoder(w).Encode(issueComment{ID: 1, Body: payload.Body})
+	}
+}
+
+// useFakeAPI points UpsertComment at api for the rest of the test.
+func useFakeAPI(t *testing.T, api *fakeAPI) {
+	t.Helper()
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	originalURL, originalClient := apiURL, httpClient
+	apiURL, httpClient = server.URL, server.Client()
+	t.Cleanup(func() { apiURL, httpClient = originalURL, originalClient })
+}
+
+func TestUpsertComment(t *testing.T) {
+	others := func(n int) []issueComment {
+		comments := make([]issueComment, n)
+		for i := range comments {
+			comments[i] = issueComment{ID: int64(1000 + i), Body: fmt.Sprintf("LGTM %d", i)}
+		}
+		return comments
+	}
+	const list = "GET /repos/acme/charts/issues/7/comments"
+	tests := []struct {
+		name         string
+		comments     []issueComment
+		failMethod   string
+		status       int
+		wantRequests []string
+		wantErr      string
+	}{
+		{
+			name:         "first run",
+			comments:     others(2),
+			wantRequests: []string{list, "POST /repos/acme/charts/issues/7/comments"},
+		},
+		{
+			name:         "re-run",
+			comments:     append(others(2), issueComment{ID: 42, Body: "<!-- helmscan-report -->\nold report"}),
+			wantRequests: []string{list, "PATCH /repos/acme/charts/issues/comments/42"},
+		},
+		{
+			// Only a comment that starts with the marker is ours.
+			name:         "marker quoted",
+			comments:     []issueComment{{ID: 42, Body: "> <!-- helmscan-report -->\n> quoted report"}},
+			wantRequests: []string{list, "POST /repos/acme/charts/issues/7/comments"},
+		},
+		{
+			name:         "comment on the second page",
+			comments:     append(others(150), issueComment{ID: 42, Body: "<!-- helmscan-report -->\nold report"}),
+			wantRequests: []string{list, list, "PATCH /repos/acme/charts/issues/comments/42"},
+		},
+		{
+			name:         "listing fails",
+			failMethod:   http.MethodGet,
+			status:       http.StatusForbidden,
+			wantRequests: []string{list},
+			wantErr:      "403 Forbidden",
+		},
+		{
+			name:         "posting fails",
+			failMethod:   http.MethodPost,
+			status:       http.StatusInternalServerError,
+			wantRequests: []string{list, "POST /repos/acme/charts/issues/7/comments"},
+			wantErr:      "500 Internal Server Error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &fakeAPI{comments: tt.comments, failMethod: tt.failMethod, status: tt.status}
+			useFakeAPI(t, api)
+
+			err := UpsertComment(context.Background(), "secret", "acme/charts", 7, "helmscan-report", "## Report")
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("UpsertComment() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("UpsertComment() error = %v, want %q", err, tt.wantErr)
+			}
+			if !slices.Equal(api.requests, tt.wantRequests) {
+				t.Errorf("requests = %v, want %v", api.requests, tt.wantRequests)
+			}
+			if tt.wantErr == "" && !slices.Equal(api.bodies, []string{"<!-- helmscan-report -->\n## Report"}) {
+				t.Errorf("comment bodies = %q, want the report after the marker", api.bodies)
+			}
+		})
+	}
+}
+
+func TestCommentBody(t *testing.T) {
+	const marker = "<!-- helmscan-report -->\n"
+	tests := []struct {
+		name          string
+		body          string
+		wantTruncated bool
+	}{
+		{name: "short", body: "## Report\n"},
+		{name: "at the limit", body: strings.Repeat("a", maxCommentLength-len(marker))},
+		{name: "too long", body: strings.Repeat("a", maxCommentLength), wantTruncated: true},
+		// Truncation must not split a multi-byte character.
+		{name: "too long multi-byte", body: strings.Repeat("→", maxCommentLength), wantTruncated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := commentBody("helmscan-report", tt.body)
+			if !strings.HasPrefix(got, marker) {
+				t.Errorf("commentBody() does not start with the marker: %.40q", got)
+			}
+			if len(got) > maxCommentLength {
+				t.Errorf("commentBody() is %d bytes, want at most %d", len(got), maxCommentLength)
+			}
+			if !utf8.ValidString(got) {
+				t.Error("commentBody() is not valid UTF-8")
+			}
+			if truncated := strings.HasSuffix(got, "_Report truncated to fit in a GitHub comment._\n"); truncated != tt.wantTruncated {
+				t.Errorf("commentBody() truncated = %v, want %v", truncated, tt.wantTruncated)
+			}
+			if !tt.wantTruncated && got != marker+tt.body {
+				t.Errorf("commentBody() changed a body that fits")
+			}
+		})
+	}
+}
@@ -2,26 +2,52 @@ package helmscanTypes
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
+// HelmComparison is the result of comparing two charts. The CVE maps are keyed by CVE ID and then
+// image, and classify each CVE per image: a CVE can be Unchanged in one image and Added or Removed
+// in another, but each image it affects lists it in exactly one of them.
 type HelmComparison struct {
-	Before          HelmChart
-	After           HelmChart
-	AddedImages     map[string][]*ContainerImage
-	RemovedImages   map[string][]*ContainerImage
-	ChangedImages   map[string][]*ContainerImage
-	UnChangedImages map[string][]*ContainerImage
-	RemovedCVEs     map[string]map[string]Vulnerability
-	AddedCVEs       map[string]map[string]Vulnerability
-	UnchangedCVEs   map[string]map[string]Vulnerability
+	Before            HelmChart
+	After             HelmChart
+	AddedImages       map[string][]*ContainerImage
+	RemovedImages     map[string][]*ContainerImage
+	ChangedImages     map[string][]*ContainerImage
+	UnChangedImages   map[string][]*ContainerImage
+	RemovedCVEs       map[string]map[string]Vulnerability
+	AddedCVEs         map[string]map[string]Vulnerability
+	UnchangedCVEs     map[string]map[string]Vulnerability
+	RepositoryChanges []RepositoryChange
+	ImageChanges      []ImageChange
+}
+
+// RepositoryChange records an image whose registry or repository path differs between the two charts.
+type RepositoryChange struct {
+	ImageName        string `json:"image"`
+	BeforeRepository string `json:"before_repository"`
+	AfterRepository  string `json:"after_repository"`
+}
+
+// ImageChange records the before and after tag and digest of an image compared by digest.
+type ImageChange struct {
+	ImageName    string `json:"image"`
+	BeforeTag    string `json:"before_tag"`
+	AfterTag     string `json:"after_tag"`
+	BeforeDigest string `json:"before_digest,omitempty"`
+	AfterDigest  string `json:"after_digest,omitempty"`
 }
 
 type HelmChart struct {
-	Name           string
-	Version        string
-	HelmRepo       string
-	ContainsImages []*ContainerImage
+	Name                      string
+	Version                   string
+	HelmRepo                  string
+	ContainsImages            []*ContainerImage
+	SkippedImages             []SkippedImage
+	ManifestMisconfigurations []Misconfiguration
 }
 
 func (hc HelmChart) String() string {
@@ -37,18 +63,92 @@ func (hc HelmChart) String() string {
 type ContainerImage struct {
 	Repository      string
 	Tag             string
+	Digest          string
 	ImageName       string
+	ScannedAs       string
+	OS              OperatingSystem
+	SourceRefs      []SourceRef
 	ScanResult      ScanResult
 	Vulnerabilities map[string]Vulnerability
+	// Signature is the result of --verify-signatures, nil when signatures were not checked.
+	Signature *SignatureVerification
+}
+
+// SignatureVerification records whether cosign verified an image's signature, and why not when it
+// did not.
+type SignatureVerification struct {
+	Verified bool   `json:"verified"`
+	Error    string `json:"error,omitempty"`
+}
+
+type SourceRef struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Container string `json:"container,omitempty"`
+	Path      string `json:"path"`
 }
 
 func (ci ContainerImage) String() string {
 	return fmt.Sprintf("Repository: %s\n, Tag: %s\n, ImageName: %s\n\n", ci.Repository, ci.Tag, ci.ImageName)
 }
 
+// defaultRegistry is the registry of image references that do not name one.
+const defaultRegistry = "docker.io"
+
+// CanonicalName returns the registry, repository path and name of the image, without its tag or
+// digest. Docker Hub's implicit docker.io registry and library/ namespace are filled in, so redis,
+// library/redis and docker.io/library/redis all name the same image.
+func (ci ContainerImage) CanonicalName() string {
+	path := ci.ImageName
+	if ci.Repository != "" {
+		path = ci.Repository + "/" + path
+	}
+
+	registry, rest, found := strings.Cut(path, "/")
+	// Like docker, only a first segment that looks like a host names a registry.
+	if !found || !strings.ContainsAny(registry, ".:") && registry != "localhost" {
+		registry, rest = defaultRegistry, path
+	}
+	if registry == "index.docker.io" {
+		registry = defaultRegistry
+	}
+	if registry == defaultRegistry && !strings.Contains(rest, "/") {
+		rest = "library/" + rest
+	}
+	return registry + "/" + rest
+}
+
+// Identity returns CanonicalName with the image's digest, or with its tag when it has no digest,
+// latest when it has neither. Two references with the same identity are the same image.
+func (ci ContainerImage) Identity() string {
+	if ci.Digest != "" {
+		return ci.CanonicalName() + "@" + ci.Digest
+	}
+	tag := ci.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+	return ci.CanonicalName() + ":" + tag
+}
+
+type SkippedImage struct {
+	Reference string `json:"reference"`
+	Reason    string `json:"reason"`
+}
+
 type Vulnerability struct {
-	ID       string
-	Severity string
+	ID               string
+	Severity         string
+	PkgName          string
+	InstalledVersion string
+	FixedVersion     string
+	CVSS             float64
+	EPSS             *float64
+	KEV              bool
+	Title            string
+	Description      string
+	PrimaryURL       string
+	References       []string
 }
 
 func (v Vulnerability) GetID() string {
@@ -60,10 +160,44 @@ func (v Vulnerability) GetSeverity() string {
 }
 
 type ScanResult struct {
-	Image           string
-	Vulnerabilities SeverityCounts
-	VulnsByLevel    map[string][]string
-	VulnList        []Vulnerability
+	Image             string
+	Vulnerabilities   SeverityCounts
+	VulnsByLevel      map[string][]string
+	VulnList          []Vulnerability
+	Secrets           []Secret
+	Misconfigurations []Misconfiguration
+	RawOutput         string
+	OS                OperatingSystem
+	RepoDigest        string
+}
+
+// OperatingSystem is the base OS Trivy detected in an image, e.g. debian 12.4.
+type OperatingSystem struct {
+	Family string `json:"family"`
+	Name   string `json:"name"`
+}
+
+func (o OperatingSystem) String() string {
+	return strings.TrimSpace(o.Family + " " + o.Name)
+}
+
+type Secret struct {
+	Image    string `json:"image"`
+	Target   string `json:"target"`
+	RuleID   string `json:"rule_id"`
+	Category string `json:"category"`
+	Severity string `json:"severity"`
+	Title    string `json:"title"`
+	Line     int    `json:"line,omitempty"`
+}
+
+type Misconfiguration struct {
+	Image    string `json:"image,omitempty"`
+	Target   string `json:"target"`
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Severity string `json:"severity"`
 }
 
 type GitHubRelease struct {
@@ -72,11 +206,117 @@ type GitHubRelease struct {
 type ImageComparisonReport struct {
 	Image1        ScanResult
 	Image2        ScanResult
-	RemovedCVEs   map[string][]Vulnerability
-	AddedCVEs     map[string][]Vulnerability
-	UnchangedCVEs map[string][]Vulnerability
+	RemovedCVEs   map[string]map[string]Vulnerability
+	AddedCVEs     map[string]map[string]Vulnerability
+	UnchangedCVEs map[string]map[string]Vulnerability
+}
+
+const DefaultScanners = "vuln,secret,misconfig"
+
+type ScanOptions struct {
+	Scanners           string
+	IgnoreUnfixed      bool
+	DBRepository       string
+	SkipDBUpdate       bool
+	OfflineScan        bool
+	TrivyConfig        string
+	ScanManifests      bool
+	SkipRepos          []string
+	OnlyRepos          []string
+	MaxImages          int
+	Concurrency        int
+	MaxParallel        int
+	EPSS               bool
+	KEV                bool
+	KEVCacheTTL        time.Duration
+	Proxy              string
+	NoProxy            string
+	RegistryMirrors    []RegistryMirror
+	UpdateDependencies bool
+	IncludeRaw         bool
+	SeverityOverrides  []SeverityOverride
+	WorkDir            string
+	EnableAll          bool
+	VerifySignatures   bool
+	CosignKey          string
+	CosignIdentity     string
+	CosignIssuer       string
+	ReleaseName        string
+	ScanAnnotations    bool
+	AnnotationKeys     string
+	TrivyCacheDir      string
+	// ImageExtractionDebug saves the image references found in each rendered chart, and the images
+	// parsed from them, under WorkPath("tmp", "image_extraction").
+	ImageExtractionDebug bool
+}
+
+const DefaultWorkDir = "working-files"
+
+// WorkPath joins elem onto the directory intermediate files are written to, DefaultWorkDir unless
+// WorkDir is set.
+func (o ScanOptions) WorkPath(elem ...string) string {
+	dir := o.WorkDir
+	if dir == "" {
+		dir = DefaultWorkDir
+	}
+	return filepath.Join(append([]string{dir}, elem...)...)
+}
+
+// SeverityOverride reclassifies the findings of one CVE, or of the packages matching a glob.
+type SeverityOverride struct {
+	CVE      string `yaml:"cve"`
+	Package  string `yaml:"package"`
+	Severity string `yaml:"severity"`
+	Reason   string `yaml:"reason"`
+}
+
+// HelmRepo is a chart repository configured in helm, as listed by helm repo list.
+type HelmRepo struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// RegistryMirror rewrites image references starting with From to start with To before they are scanned.
+type RegistryMirror struct {
+	From string
+	To   string
 }
 
+// CommandEnv returns the environment for Helm and Trivy commands with the configured proxy
+// settings and Trivy cache directory applied over the current environment, or nil to inherit it
+// unchanged.
+func (o ScanOptions) CommandEnv() []string {
+	if o.Proxy == "" && o.NoProxy == "" && o.TrivyCacheDir == "" {
+		return nil
+	}
+	// exec.Cmd uses the last value of a duplicated key, so these override the ambient settings.
+	env := os.Environ()
+	if o.Proxy != "" {
+		env = append(env, "HTTP_PROXY="+o.Proxy, "HTTPS_PROXY="+o.Proxy, "http_proxy="+o.Proxy, "https_proxy="+o.Proxy)
+	}
+	if o.NoProxy != "" {
+		env = append(env, "NO_PROXY="+o.NoProxy, "no_proxy="+o.NoProxy)
+	}
+	if o.TrivyCacheDir != "" {
+		env = append(env, "TRIVY_CACHE_DIR="+o.TrivyCacheDir)
+	}
+	return env
+}
+
+const DefaultMaxImages = 100
+
+// DefaultReleaseName is the release name charts are rendered with unless ScanOptions.ReleaseName is
+// set. It is helm template's own default, passed explicitly so every render of a chart version
+// uses the same name whatever helm's defaults become.
+const DefaultReleaseName = "release-name"
+
+// DefaultAnnotationKeys matches the annotation and label keys --scan-annotations reads images from
+// unless ScanOptions.AnnotationKeys is set, such as app.kubernetes.io/related-image and the
+// containerImage annotation of operator bundles.
+const DefaultAnnotationKeys = `(?i)(related-?image|container-?image)`
+
+const DefaultConcurrency = 4
+
 type SeverityCounts struct {
 	Low      int
 	Medium   int
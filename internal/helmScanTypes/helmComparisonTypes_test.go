@@ -0,0 +1,140 @@
+package helmscanTypes
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestWorkPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		workDir string
+		elem    []string
+		want    string
+	}{
+		{name: "default directory", want: "working-files"},
+		{name: "default directory with elements", elem: []string{"tmp", "helm_output"}, want: filepath.Join("working-files", "tmp", "helm_output")},
+		{name: "work directory", workDir: "build", want: "build"},
+		{name: "work directory with elements", workDir: "/tmp/helmscan", elem: []string{"raw"}, want: filepath.Join("/tmp/helmscan", "raw")},
+		{name: "cleaned", workDir: "build/", elem: []string{"../scans"}, want: "scans"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (ScanOptions{WorkDir: tt.workDir}).WorkPath(tt.elem...); got != tt.want {
+				t.Errorf("WorkPath(%q) = %q, want %q", tt.elem, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ScanOptions
+		want []string
+	}{
+		{name: "inherited", opts: ScanOptions{}},
+		{
+			name: "proxy",
+			opts: ScanOptions{Proxy: "http://proxy:3128", NoProxy: "localhost"},
+			want: []string{"HTTP_PROXY=http://proxy:3128", "HTTPS_PROXY=http://proxy:3128", "http_proxy=http://proxy:3128", "https_proxy=http://proxy:3128", "NO_PROXY=localhost", "no_proxy=localhost"},
+		},
+		{name: "trivy cache directory", opts: ScanOptions{TrivyCacheDir: "/var/cache/trivy"}, want: []string{"TRIVY_CACHE_DIR=/var/cache/trivy"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HELMSCAN_AMBIENT", "kept")
+			env := tt.opts.CommandEnv()
+			if tt.want == nil {
+				if env != nil {
+					t.Errorf("CommandEnv() = %v, want nil to inherit the environment", env)
+				}
+				return
+			}
+			if !slices.Contains(env, "HELMSCAN_AMBIENT=kept") {
+				t.Errorf("CommandEnv() dropped the current environment")
+			}
+			// The settings come last, so they override the ambient ones.
+			if got := env[len(env)-len(tt.want):]; !slices.Equal(got, tt.want) {
+				t.Errorf("CommandEnv() ends with %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	const digest = "sha256:3127bd2ec9e8a1b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f9012345ab"
+	tests := []struct {
+		name              string
+		image             ContainerImage
+		wantCanonicalName string
+		wantIdentity      string
+	}{
+		{
+			name:              "tag",
+			image:             ContainerImage{Repository: "docker.io/bitnami", ImageName: "redis", Tag: "7.2.4"},
+			wantCanonicalName: "docker.io/bitnami/redis",
+			wantIdentity:      "docker.io/bitnami/redis:7.2.4",
+		},
+		{
+			// The digest identifies the image, whatever its tag.
+			name:              "digest",
+			image:             ContainerImage{Repository: "docker.io/bitnami", ImageName: "redis", Tag: "7.2.4", Digest: digest},
+			wantCanonicalName: "docker.io/bitnami/redis",
+			wantIdentity:      "docker.io/bitnami/redis@" + digest,
+		},
+		{
+			name:              "default tag",
+			image:             ContainerImage{Repository: "quay.io/jetstack", ImageName: "cert-manager-controller"},
+			wantCanonicalName: "quay.io/jetstack/cert-manager-controller",
+			wantIdentity:      "quay.io/jetstack/cert-manager-controller:latest",
+		},
+		{
+			name:              "registry port",
+			image:             ContainerImage{Repository: "registry.internal:5000/team", ImageName: "app", Tag: "1.0"},
+			wantCanonicalName: "registry.internal:5000/team/app",
+			wantIdentity:      "registry.internal:5000/team/app:1.0",
+		},
+		{
+			name:              "localhost",
+			image:             ContainerImage{Repository: "localhost", ImageName: "app", Tag: "dev"},
+			wantCanonicalName: "localhost/app",
+			wantIdentity:      "localhost/app:dev",
+		},
+		{
+			name:              "official image",
+			image:             ContainerImage{ImageName: "redis", Tag: "7.2.4"},
+			wantCanonicalName: "docker.io/library/redis",
+			wantIdentity:      "docker.io/library/redis:7.2.4",
+		},
+		{
+			name:              "Docker Hub namespace",
+			image:             ContainerImage{Repository: "bitnami", ImageName: "redis", Tag: "7.2.4"},
+			wantCanonicalName: "docker.io/bitnami/redis",
+			wantIdentity:      "docker.io/bitnami/redis:7.2.4",
+		},
+		{
+			name:              "index.docker.io",
+			image:             ContainerImage{Repository: "index.docker.io/library", ImageName: "redis", Tag: "7.2.4"},
+			wantCanonicalName: "docker.io/library/redis",
+			wantIdentity:      "docker.io/library/redis:7.2.4",
+		},
+		{
+			name:              "library on docker.io",
+			image:             ContainerImage{Repository: "docker.io", ImageName: "redis", Tag: "7.2.4"},
+			wantCanonicalName: "docker.io/library/redis",
+			wantIdentity:      "docker.io/library/redis:7.2.4",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.image.CanonicalName(); got != tt.wantCanonicalName {
+				t.Errorf("CanonicalName() = %q, want %q", got, tt.wantCanonicalName)
+			}
+			if got := tt.image.Identity(); got != tt.wantIdentity {
+				t.Errorf("Identity() = %q, want %q", got, tt.wantIdentity)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package helmscan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+	"github.com/cliffcolvin/helmscan/internal/reports"
+)
+
+type helmSearchResult struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HasVersionConstraint reports whether the version part of chartRef is a semver range such as
+// ">=18.0.0 <19.0.0" rather than a single version.
+func HasVersionConstraint(chartRef string) bool {
+	_, _, version, err := parseChartReference(chartRef)
+	if err != nil {
+		return false
+	}
+	return isVersionConstraint(version)
+}
+
+func isVersionConstraint(version string) bool {
+	if isLatestVersion(version) {
+		return false
+	}
+	if _, err := semver.NewVersion(version); err == nil {
+		return false
+	}
+	_, err := semver.NewConstraint(version)
+	return err == nil
+}
+
+// ScanVersionsContext scans every released version of a chart that satisfies the constraint in
+// chartRef, oldest first. Versions that fail to scan are reported in the returned error.
+func ScanVersionsContext(ctx context.Context, chartRef string, opts helmscanTypes.ScanOptions) ([]helmscanTypes.HelmChart, error) {
+	repoName, chartName, constraint, err := parseChartReference(chartRef)
+	if err != nil {
+		return nil, err
+	}
+
+	available, err := chartVersions(ctx, repoName, chartName, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := filterVersions(available, constraint)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no versions of %s/%s match %q", repoName, chartName, constraint)
+	}
+	logger.Infof("Scanning %d versions of %s/%s matching %q", len(versions), repoName, chartName, constraint)
+
+	var charts []helmscanTypes.HelmChart
+	var scanErrors []string
+	for _, version := range versions {
+		if err := ctx.Err(); err != nil {
+			return charts, err
+		}
+		chart, err := ScanContext(ctx, fmt.Sprintf("%s/%s@%s", repoName, chartName, version), opts)
+		if err != nil {
+			logger.Errorf("Error scanning %s/%s@%s: %v", repoName, chartName, version, err)
+			scanErrors = append(scanErrors, fmt.Sprintf("%s: %v", version, err))
+			continue
+		}
+		charts = append(charts, chart)
+	}
+
+	if len(scanErrors) > 0 {
+		return charts, fmt.Errorf("errors occurred while scanning chart versions:\n%s", strings.Join(scanErrors, "\n"))
+	}
+	return charts, nil
+}
+
+// isLatestVersion reports whether version is the "latest" (or "*") pseudo-version, which resolves
+// to the newest stable release of the chart.
+func isLatestVersion(version string) bool {
+	return version == "latest" || version == "*"
+}
+
+// latestVersion returns the newest stable version of the chart known to the local Helm repo
+// cache, which the caller must have updated.
+func latestVersion(ctx context.Context, repoName, chartName string, opts helmscanTypes.ScanOptions) (string, error) {
+	available, err := searchChartVersions(ctx, repoName, chartName, opts)
+	if err != nil {
+		return "", err
+	}
+	versions, err := filterVersions(available, "*")
+	if err != nil {
+		return "", err
+	}
+	if len(versions) == 0 {
+		return "", fmt.Errorf("no released versions found for %s/%s", repoName, chartName)
+	}
+	return versions[len(versions)-1], nil
+}
+
+func chartVersions(ctx context.Context, repoName, chartName string, opts helmscanTypes.ScanOptions) ([]string, error) {
+	updateCmd := execCommand(ctx, "helm", "repo", "update")
+	updateCmd.Env = opts.CommandEnv()
+	output, err := updateCmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("error updating Helm repo: %v\nOutput: %s", err, string(output))
+	}
+	return searchChartVersions(ctx, repoName, chartName, opts)
+}
+
+func searchChartVersions(ctx context.Context, repoName, chartName string, opts helmscanTypes.ScanOptions) ([]string, error) {
+	if IsOCIChart(repoName) {
+		return nil, fmt.Errorf("cannot list the versions of OCI chart %s/%s, give an exact version", repoName, chartName)
+	}
+	fullName := fmt.Sprintf("%s/%s", repoName, chartName)
+	searchCmd := execCommand(ctx, "helm", "search", "repo", fullName, "--versions", "-o", "json")
+	searchCmd.Env = opts.CommandEnv()
+	output, err := searchCmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error searching chart versions for %s: %w", fullName, err)
+	}
+
+	var results []helmSearchResult
+	if err := json.Unmarshal(output, &results); err != nil {
+		return nil, fmt.Errorf("error parsing helm search output: %w", err)
+	}
+
+	var versions []string
+	for _, result := range results {
+		if result.Name == fullName {
+			versions = append(versions, result.Version)
+		}
+	}
+	return versions, nil
+}
+
+// CompareChartVersions compares the semver versions of two repo/chart@version references,
+// returning -1, 0 or 1 as chartRef1's version is lower than, equal to or higher than chartRef2's.
+// It fails for local charts and versions that are not semver, such as latest.
+func CompareChartVersions(chartRef1, chartRef2 string) (int, error) {
+	var versions [2]*semver.Version
+	for i, chartRef := range []string{chartRef1, chartRef2} {
+		if IsLocalChart(chartRef) {
+			return 0, fmt.Errorf("%s is a local chart", chartRef)
+		}
+		_, _, version, err := parseChartReference(chartRef)
+		if err != nil {
+			return 0, err
+		}
+		if versions[i], err = semver.NewVersion(version); err != nil {
+			return 0, fmt.Errorf("version %q of %s is not semver", version, chartRef)
+		}
+	}
+	return versions[0].Compare(versions[1]), nil
+}
+
+func filterVersions(versions []string, constraint string) ([]string, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version constraint %q: %w", constraint, err)
+	}
+
+	var matched []*semver.Version
+	for _, version := range versions {
+		v, err := semver.NewVersion(version)
+		if err != nil {
+			logger.Warnf("Ignoring chart version %q: %v", version, err)
+			continue
+		}
+		if c.Check(v) {
+			matched = append(matched, v)
+		}
+	}
+	sort.Sort(semver.Collection(matched))
+
+	filtered := make([]string, 0, len(matched))
+	for _, v := range matched {
+		filtered = append(filtered, v.Original())
+	}
+	return filtered, nil
+}
+
+func GenerateTrendReport(chartRef string, charts []helmscanTypes.HelmChart, jsonOutput bool, opts reports.ReportOptions) (string, error) {
+	report := reports.TrendReport{ChartRef: chartRef}
+	for _, chart := range charts {
+		report.Versions = append(report.Versions, reports.NewTrendEntry(chart.Version, len(chart.ContainsImages), chartVulnerabilities(chart)))
+	}
+	return reports.GenerateTrendReport(report, jsonOutput, opts)
+}
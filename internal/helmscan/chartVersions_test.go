@@ -0,0 +1,84 @@
+package helmscan
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHasVersionConstraint(t *testing.T) {
+	tests := []struct {
+		chartRef string
+		want     bool
+	}{
+		{chartRef: "bitnami/redis@>=18.0.0 <19.0.0", want: true},
+		{chartRef: "bitnami/redis@~18.1", want: true},
+		{chartRef: "bitnami/redis@18.x", want: true},
+		{chartRef: "bitnami/redis@18.1.0", want: false},
+		{chartRef: "bitnami/redis@latest", want: false},
+		{chartRef: "bitnami/redis@*", want: false},
+		{chartRef: "bitnami/redis", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.chartRef, func(t *testing.T) {
+			if got := HasVersionConstraint(tt.chartRef); got != tt.want {
+				t.Errorf("HasVersionConstraint(%q) = %v, want %v", tt.chartRef, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterVersions(t *testing.T) {
+	available := []string{"19.0.0", "18.2.0", "v18.1.0", "17.9.0", "18.3.0-rc.1", "not-a-version", "18.10.1"}
+	tests := []struct {
+		constraint string
+		want       []string
+		wantErr    bool
+	}{
+		// Matches are sorted oldest first by semver, not as strings, and keep their spelling.
+		{constraint: ">=18.0.0 <19.0.0", want: []string{"v18.1.0", "18.2.0", "18.10.1"}},
+		{constraint: "~18.2", want: []string{"18.2.0"}},
+		// Prereleases only match constraints that name one.
+		{constraint: ">=18.3.0-0 <18.4.0", want: []string{"18.3.0-rc.1"}},
+		{constraint: "*", want: []string{"17.9.0", "v18.1.0", "18.2.0", "18.10.1", "19.0.0"}},
+		{constraint: ">=20.0.0", want: []string{}},
+		{constraint: "not a constraint", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			got, err := filterVersions(available, tt.constraint)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("filterVersions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !slices.Equal(got, tt.want) {
+				t.Errorf("filterVersions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareChartVersions(t *testing.T) {
+	tests := []struct {
+		name                 string
+		chartRef1, chartRef2 string
+		want                 int
+		wantErr              bool
+	}{
+		{name: "lower first", chartRef1: "bitnami/redis@18.1.0", chartRef2: "bitnami/redis@18.2.0", want: -1},
+		{name: "higher first", chartRef1: "bitnami/redis@18.10.0", chartRef2: "bitnami/redis@18.9.1", want: 1},
+		{name: "equal", chartRef1: "bitnami/redis@18.1.0", chartRef2: "bitnami/redis@v18.1.0", want: 0},
+		{name: "prerelease", chartRef1: "bitnami/redis@19.0.0-rc.1", chartRef2: "bitnami/redis@19.0.0", want: -1},
+		{name: "not semver", chartRef1: "bitnami/redis@latest", chartRef2: "bitnami/redis@18.1.0", wantErr: true},
+		{name: "local chart", chartRef1: "./charts/redis", chartRef2: "bitnami/redis@18.1.0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CompareChartVersions(tt.chartRef1, tt.chartRef2)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CompareChartVersions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CompareChartVersions(%q, %q) = %d, want %d", tt.chartRef1, tt.chartRef2, got, tt.want)
+			}
+		})
+	}
+}
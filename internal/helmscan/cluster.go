@@ -0,0 +1,144 @@
+package helmscan
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+	"github.com/cliffcolvin/helmscan/internal/reports"
+)
+
+// clusterRepoName is the HelmRepo of the result of a cluster scan, which has no chart.
+const clusterRepoName = "cluster"
+
+// ClusterRef names the cluster of the current kubecontext, or of namespace in it when namespace is
+// set, as the artifact of a cluster scan.
+func ClusterRef(namespace string) (string, error) {
+	config, err := clusterConfig().RawConfig()
+	if err != nil {
+		return "", fmt.Errorf("error loading kubeconfig: %w", err)
+	}
+	ref := "cluster:" + config.CurrentContext
+	if namespace != "" {
+		ref += "/" + namespace
+	}
+	return ref, nil
+}
+
+// ScanClusterContext scans the images of the pods running in namespace, or in every namespace when
+// it is empty, of the cluster of the current kubecontext. Like an image list, the result is a
+// HelmChart so it can be reported like a chart.
+func ScanClusterContext(ctx context.Context, namespace string, opts helmscanTypes.ScanOptions) (helmscanTypes.HelmChart, error) {
+	config, err := clusterConfig().ClientConfig()
+	if err != nil {
+		return helmscanTypes.HelmChart{}, fmt.Errorf("error loading kubeconfig: %w", err)
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return helmscanTypes.HelmChart{}, fmt.Errorf("error creating Kubernetes client: %w", err)
+	}
+	ref, err := ClusterRef(namespace)
+	if err != nil {
+		return helmscanTypes.HelmChart{}, err
+	}
+
+	images, skipped, err := ClusterImages(ctx, client, namespace)
+	if err != nil {
+		return helmscanTypes.HelmChart{}, err
+	}
+	images = filterImagesByRepository(images, opts.OnlyRepos, opts.SkipRepos)
+	logger.Infof("Found %d images in %s", len(images), ref)
+	if opts.MaxImages > 0 && len(images) > opts.MaxImages {
+		return helmscanTypes.HelmChart{}, fmt.Errorf("%s runs %d images, more than the limit of %d; raise --max-images to scan it", ref, len(images), opts.MaxImages)
+	}
+
+	cluster := helmscanTypes.HelmChart{Name: ref, HelmRepo: clusterRepoName, SkippedImages: skipped}
+	cluster.ContainsImages, err = scanImages(ctx, images, opts)
+	return cluster, err
+}
+
+func clusterConfig() clientcmd.ClientConfig {
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(clientcmd.NewDefaultClientConfigLoadingRules(), &clientcmd.ConfigOverrides{})
+}
+
+// ClusterImages lists the images of the pods in namespace, or in every namespace when it is empty,
+// once each by identity. An image's sources are the workloads that own the pods running it, or the
+// pods themselves when they have no owner, so replicas of one workload are listed once.
+func ClusterImages(ctx context.Context, client kubernetes.Interface, namespace string) ([]*helmscanTypes.ContainerImage, []helmscanTypes.SkippedImage, error) {
+	pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error listing pods: %w", err)
+	}
+
+	var images []*helmscanTypes.ContainerImage
+	var skipped []helmscanTypes.SkippedImage
+	byIdentity := make(map[string]*helmscanTypes.ContainerImage)
+	skippedRefs := make(map[string]bool)
+	for _, pod := range pods.Items {
+		for _, container := range podContainers(pod) {
+			if reason := invalidImageReason(container.image); reason != "" {
+				if !skippedRefs[container.image] {
+					skippedRefs[container.image] = true
+					logger.Warnf("Skipping image %q: %s", container.image, reason)
+					skipped = append(skipped, helmscanTypes.SkippedImage{Reference: container.image, Reason: reason})
+				}
+				continue
+			}
+
+			source := podSource(pod, container.name, container.path)
+			image := parseImageString(container.image)
+			if existing, exists := byIdentity[image.Identity()]; exists {
+				if !slices.Contains(existing.SourceRefs, source) {
+					existing.SourceRefs = append(existing.SourceRefs, source)
+				}
+				continue
+			}
+			image.SourceRefs = []helmscanTypes.SourceRef{source}
+			byIdentity[image.Identity()] = image
+			images = append(images, image)
+		}
+	}
+	return images, skipped, nil
+}
+
+type podContainer struct {
+	name  string
+	image string
+	path  string
+}
+
+func podContainers(pod corev1.Pod) []podContainer {
+	var containers []podContainer
+	for i, c := range pod.Spec.InitContainers {
+		containers = append(containers, podContainer{c.Name, c.Image, fmt.Sprintf("spec.initContainers[%d].image", i)})
+	}
+	for i, c := range pod.Spec.Containers {
+		containers = append(containers, podContainer{c.Name, c.Image, fmt.Sprintf("spec.containers[%d].image", i)})
+	}
+	for i, c := range pod.Spec.EphemeralContainers {
+		containers = append(containers, podContainer{c.Name, c.Image, fmt.Sprintf("spec.ephemeralContainers[%d].image", i)})
+	}
+	return containers
+}
+
+// podSource attributes a container of pod to the workload controlling the pod, named with its
+// namespace.
+func podSource(pod corev1.Pod, container, path string) helmscanTypes.SourceRef {
+	source := helmscanTypes.SourceRef{Kind: "Pod", Name: pod.Namespace + "/" + pod.Name, Container: container, Path: path}
+	if owner := metav1.GetControllerOf(&pod); owner != nil {
+		source.Kind = owner.Kind
+		source.Name = pod.Namespace + "/" + owner.Name
+	}
+	return source
+}
+
+// GenerateClusterReport renders a single scan report of the images running in a cluster.
+func GenerateClusterReport(cluster helmscanTypes.HelmChart, jsonOutput bool, ignoreUnfixed bool, opts reports.ReportOptions) string {
+	return generateSingleScanReport("cluster", cluster.Name, cluster, jsonOutput, ignoreUnfixed, opts)
+}
@@ -0,0 +1,182 @@
+package helmscan
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+	"github.com/cliffcolvin/helmscan/internal/reports"
+)
+
+func clusterPod(namespace, name, owner string, containers ...corev1.Container) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       corev1.PodSpec{Containers: containers},
+	}
+	if owner != "" {
+		controller := true
+		pod.OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: owner, Controller: &controller}}
+	}
+	return pod
+}
+
+func TestClusterImages(t *testing.T) {
+	redis := corev1.Container{Name: "redis", Image: "docker.io/bitnami/redis:7.2.4"}
+	pods := []runtime.Object{
+		clusterPod("cache", "redis-abc12", "redis-7d9f", redis),
+		clusterPod("cache", "redis-def34", "redis-7d9f", redis),
+		clusterPod("cache", "debug", "", corev1.Container{Name: "shell", Image: "bitnami/redis:7.2.4"}),
+		clusterPod("web", "nginx-xyz98", "nginx-5c6b", corev1.Container{Name: "nginx", Image: "nginx:1.25"}),
+		clusterPod("web", "broken", "", corev1.Container{Name: "app", Image: "registry.example.com/App:1.0"}),
+	}
+	initPod := clusterPod("jobs", "migrate", "", corev1.Container{Name: "migrate", Image: "registry.example.com/migrate:2.0"})
+	initPod.Spec.InitContainers = []corev1.Container{{Name: "wait", Image: "busybox:1.36"}}
+	pods = append(pods, initPod)
+
+	tests := []struct {
+		name        string
+		namespace   string
+		want        map[string][]helmscanTypes.SourceRef
+		wantSkipped []helmscanTypes.SkippedImage
+	}{
+		{
+			name:      "replicas and spellings of one image are listed once",
+			namespace: "cache",
+			want: map[string][]helmscanTypes.SourceRef{
+				"docker.io/bitnami/redis:7.2.4": {
+					{Kind: "Pod", Name: "cache/debug", Container: "shell", Path: "spec.containers[0].image"},
+					{Kind: "ReplicaSet", Name: "cache/redis-7d9f", Container: "redis", Path: "spec.containers[0].image"},
+				},
+			},
+		},
+		{
+			name:      "invalid images are skipped",
+			namespace: "web",
+			want: map[string][]helmscanTypes.SourceRef{
+				"docker.io/library/nginx:1.25": {{Kind: "ReplicaSet", Name: "web/nginx-5c6b", Container: "nginx", Path: "spec.containers[0].image"}},
+			},
+			wantSkipped: []helmscanTypes.SkippedImage{{Reference: "registry.example.com/App:1.0", Reason: "image names must be lowercase"}},
+		},
+		{
+			name:      "init containers",
+			namespace: "jobs",
+			want: map[string][]helmscanTypes.SourceRef{
+				"docker.io/library/busybox:1.36":   {{Kind: "Pod", Name: "jobs/migrate", Container: "wait", Path: "spec.initContainers[0].image"}},
+				"registry.example.com/migrate:2.0": {{Kind: "Pod", Name: "jobs/migrate", Container: "migrate", Path: "spec.containers[0].image"}},
+			},
+		},
+		{name: "empty namespace", namespace: "monitoring", want: map[string][]helmscanTypes.SourceRef{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewClientset(pods...)
+
+			images, skipped, err := ClusterImages(context.Background(), client, tt.namespace)
+			if err != nil {
+				t.Fatalf("ClusterImages() error = %v", err)
+			}
+			got := make(map[string][]helmscanTypes.SourceRef)
+			for _, image := range images {
+				got[image.Identity()] = image.SourceRefs
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ClusterImages() images = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(skipped, tt.wantSkipped) {
+				t.Errorf("ClusterImages() skipped = %+v, want %+v", skipped, tt.wantSkipped)
+			}
+		})
+	}
+
+	t.Run("all namespaces", func(t *testing.T) {
+		images, _, err := ClusterImages(context.Background(), fake.NewClientset(pods...), "")
+		if err != nil {
+			t.Fatalf("ClusterImages() error = %v", err)
+		}
+		if len(images) != 4 {
+			t.Errorf("ClusterImages() found %d images, want 4", len(images))
+		}
+	})
+}
+
+func TestClusterRef(t *testing.T) {
+	const kubeconfig = `apiVersion: v1
+kind: Config
+current-context: staging
+contexts:
+  - name: staging
+    context: {cluster: staging, user: admin}
+clusters:
+  - name: staging
+    cluster: {server: "https://127.0.0.1:6443"}
+users:
+  - name: admin
+    user: {token: secret}
+`
+	tests := []struct {
+		name      string
+		namespace string
+		want      string
+	}{
+		{name: "all namespaces", want: "cluster:staging"},
+		{name: "namespace", namespace: "cache", want: "cluster:staging/cache"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config")
+			if err := os.WriteFile(path, []byte(kubeconfig), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("KUBECONFIG", path)
+
+			got, err := ClusterRef(tt.namespace)
+			if err != nil {
+				t.Fatalf("ClusterRef() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ClusterRef() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateClusterReport(t *testing.T) {
+	tests := []struct {
+		name         string
+		images       []*helmscanTypes.ContainerImage
+		wantNoImages bool
+	}{
+		{name: "images", images: []*helmscanTypes.ContainerImage{scannedImage("bitnami", "redis", "7.2.4", "CVE-2024-2961")}},
+		{name: "no images", wantNoImages: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := helmscanTypes.HelmChart{Name: "cluster:staging/cache", HelmRepo: clusterRepoName, ContainsImages: tt.images}
+
+			var report reports.SingleScanReport
+			if err := json.Unmarshal([]byte(GenerateClusterReport(cluster, true, false, reports.ReportOptions{})), &report); err != nil {
+				t.Fatalf("GenerateClusterReport() is not JSON: %v", err)
+			}
+			if report.ArtifactType != "cluster" || report.ArtifactRef != "cluster:staging/cache" {
+				t.Errorf("artifact = %s %s, want cluster cluster:staging/cache", report.ArtifactType, report.ArtifactRef)
+			}
+			if report.NoImages != tt.wantNoImages {
+				t.Errorf("NoImages = %v, want %v", report.NoImages, tt.wantNoImages)
+			}
+			markdown := GenerateClusterReport(cluster, false, false, reports.ReportOptions{})
+			if got := strings.Contains(markdown, "No images were found in cluster:staging/cache"); got != tt.wantNoImages {
+				t.Errorf("markdown report states no images were found: %v, want %v\n%s", got, tt.wantNoImages, markdown)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package helmscan
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+	"gopkg.in/yaml.v3"
+)
+
+// enableAllArgs returns the helm --set arguments that switch on every enabled toggle the chart's
+// default values turn off, so templating renders the optional components and their images too.
+// chartArgs are the chart and version arguments also given to helm template.
+func enableAllArgs(ctx context.Context, opts helmscanTypes.ScanOptions, chartArgs ...string) ([]string, error) {
+	cmd := execCommand(ctx, "helm", append([]string{"show", "values"}, chartArgs...)...)
+	cmd.Env = opts.CommandEnv()
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("error reading chart values: %v\nOutput: %s", err, string(output))
+	}
+
+	toggles, err := disabledToggles(output)
+	if err != nil {
+		return nil, err
+	}
+	var args []string
+	for _, toggle := range toggles {
+		args = append(args, "--set", toggle+"=true")
+	}
+	if len(toggles) > 0 {
+		logger.Infof("Enabling %d optional components of %s: %s", len(toggles), chartArgs[0], strings.Join(toggles, ", "))
+	}
+	return args, nil
+}
+
+// disabledToggles returns the --set path of every enabled key set to false in values, sorted.
+// Toggles inside lists are skipped because --set cannot address them by name.
+func disabledToggles(values []byte) ([]string, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(values, &document); err != nil {
+		return nil, fmt.Errorf("error parsing chart values: %w", err)
+	}
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+
+	var toggles []string
+	var walk func(node *yaml.Node, path []string)
+	walk = func(node *yaml.Node, path []string) {
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i].Value, node.Content[i+1]
+			keyPath := append(append([]string(nil), path...), escapeSetKey(key))
+			if key == "enabled" && value.Kind == yaml.ScalarNode && value.Tag == "!!bool" && strings.EqualFold(value.Value, "false") {
+				toggles = append(toggles, strings.Join(keyPath, "."))
+				continue
+			}
+			walk(value, keyPath)
+		}
+	}
+	walk(document.Content[0], nil)
+	sort.Strings(toggles)
+	return toggles, nil
+}
+
+// escapeSetKey escapes the characters helm --set treats as separators within a key.
+func escapeSetKey(key string) string {
+	return strings.NewReplacer(`\`, `\\`, ".", `\.`, ",", `\,`, "=", `\=`).Replace(key)
+}
@@ -0,0 +1,129 @@
+package helmscan
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cliffcolvin/helmscan/internal/fakeexec"
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func TestDisabledToggles(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  string
+		want    []string
+		wantErr bool
+	}{
+		{name: "no values"},
+		{name: "no toggles", values: "image:\n  tag: 7.2.4\n"},
+		{
+			name: "nested toggles are sorted",
+			values: `replica:
+  enabled: false
+metrics:
+  enabled: false
+  serviceMonitor:
+    enabled: false
+master:
+  enabled: true
+`,
+			want: []string{"metrics.enabled", "metrics.serviceMonitor.enabled", "replica.enabled"},
+		},
+		{
+			// The toggles of a disabled component's children are switched on with it.
+			name:   "children of a toggle",
+			values: "sentinel:\n  enabled: false\n  metrics:\n    enabled: false\n",
+			want:   []string{"sentinel.enabled", "sentinel.metrics.enabled"},
+		},
+		{name: "string false", values: "metrics:\n  enabled: \"false\"\n"},
+		{name: "top-level toggle", values: "enabled: false\n", want: []string{"enabled"}},
+		{name: "keys with separators", values: "\"app.kubernetes.io\":\n  enabled: false\n", want: []string{`app\.kubernetes\.io.enabled`}},
+		{name: "toggles in lists", values: "sidecars:\n  - name: proxy\n    enabled: false\n"},
+		{name: "invalid YAML", values: "metrics: [enabled\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := disabledToggles([]byte(tt.values))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("disabledToggles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("disabledToggles() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanEnableAll(t *testing.T) {
+	const values = "metrics:\n  enabled: false\nreplica:\n  enabled: false\n"
+	tests := []struct {
+		name      string
+		enableAll bool
+		values    map[string]string
+		wantSets  []string
+		wantErr   string
+	}{
+		{name: "disabled", values: map[string]string{"bitnami/redis@18.1.0": values}},
+		{
+			name:      "toggles found",
+			enableAll: true,
+			values:    map[string]string{"bitnami/redis@18.1.0": values},
+			wantSets:  []string{"--set", "metrics.enabled=true", "--set", "replica.enabled=true"},
+		},
+		{name: "no toggles", enableAll: true, values: map[string]string{"bitnami/redis@18.1.0": "image:\n  tag: 7.2.4\n"}},
+		{name: "values cannot be read", enableAll: true, wantErr: "error reading chart values"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := fakeTools{
+				manifests: map[string]string{"bitnami/redis@18.1.0": redisManifest},
+				vulns: map[string][]fakeVuln{
+					"docker.io/bitnami/redis:7.2.4-debian-12-r9":           nil,
+					"docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4": nil,
+				},
+				values: tt.values,
+			}.install(t)
+
+			_, err := ScanContext(context.Background(), "bitnami/redis@18.1.0", helmscanTypes.ScanOptions{EnableAll: tt.enableAll})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ScanContext() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ScanContext() error = %v", err)
+			}
+
+			var showValues, template []string
+			for _, args := range fakeexec.Calls(t, filepath.Join(dir, "helm.log")) {
+				switch args[0] {
+				case "show":
+					showValues = args
+				case "template":
+					template = args
+				}
+			}
+			if (showValues != nil) != tt.enableAll {
+				t.Errorf("helm show values was run = %v, want %v", showValues != nil, tt.enableAll)
+			}
+			if showValues != nil && !slices.Equal(showValues, []string{"show", "values", "bitnami/redis", "--version", "18.1.0"}) {
+				t.Errorf("helm show values args = %v, want the chart and version templated", showValues)
+			}
+			var sets []string
+			for i, arg := range template {
+				if arg == "--set" && i+1 < len(template) {
+					sets = append(sets, arg, template[i+1])
+				}
+			}
+			if !slices.Equal(sets, tt.wantSets) {
+				t.Errorf("helm template --set args = %q, want %q", sets, tt.wantSets)
+			}
+		})
+	}
+}
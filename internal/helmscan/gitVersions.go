@@ -0,0 +1,74 @@
+package helmscan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type chartMetadata struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+}
+
+// ChartRefsFromGit builds the before and after chart references of a comparison from the
+// version in chartFile (a Chart.yaml) at baseRef and headRef. chart is the chart's repo and
+// name as repo/chart, or only the repo to use the name from the Chart.yaml.
+func ChartRefsFromGit(ctx context.Context, chart, chartFile, baseRef, headRef string) (string, string, error) {
+	if strings.Contains(chart, "@") {
+		return "", "", fmt.Errorf("chart %s must not include a version; versions are read from %s", chart, chartFile)
+	}
+	base, err := chartAtRef(ctx, chartFile, baseRef)
+	if err != nil {
+		return "", "", err
+	}
+	head, err := chartAtRef(ctx, chartFile, headRef)
+	if err != nil {
+		return "", "", err
+	}
+	if !strings.Contains(chart, "/") {
+		chart = chart + "/" + head.Name
+	}
+	return chart + "@" + base.Version, chart + "@" + head.Version, nil
+}
+
+func chartAtRef(ctx context.Context, chartFile, ref string) (chartMetadata, error) {
+	// git show only accepts repo paths; a ./ prefix resolves them from the working directory
+	// rather than the repo root.
+	path := chartFile
+	if filepath.IsAbs(path) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return chartMetadata{}, err
+		}
+		if path, err = filepath.Rel(wd, path); err != nil {
+			return chartMetadata{}, err
+		}
+	}
+	if path = filepath.ToSlash(path); !strings.HasPrefix(path, "../") {
+		path = "./" + strings.TrimPrefix(path, "./")
+	}
+	output, err := execCommand(ctx, "git", "show", ref+":"+path).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return chartMetadata{}, fmt.Errorf("error reading %s at %s: %w: %s", chartFile, ref, err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return chartMetadata{}, fmt.Errorf("error reading %s at %s: %w", chartFile, ref, err)
+	}
+
+	var metadata chartMetadata
+	if err := yaml.Unmarshal(output, &metadata); err != nil {
+		return chartMetadata{}, fmt.Errorf("error parsing %s at %s: %w", chartFile, ref, err)
+	}
+	if metadata.Version == "" {
+		return chartMetadata{}, fmt.Errorf("%s at %s has no chart version", chartFile, ref)
+	}
+	return metadata, nil
+}
@@ -0,0 +1,119 @@
+package helmscan
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cliffcolvin/helmscan/internal/fakeexec"
+)
+
+func TestChartRefsFromGit(t *testing.T) {
+	files := map[string]string{
+		"main:./charts/redis/Chart.yaml": "apiVersion: v2\nname: redis\nversion: 18.1.0\n",
+		"HEAD:./charts/redis/Chart.yaml": "apiVersion: v2\nname: redis\nversion: 18.2.0\n",
+		"v1:./charts/redis/Chart.yaml":   "apiVersion: v2\nname: redis\n",
+		"v2:./charts/redis/Chart.yaml":   "name: [redis\n",
+	}
+	tests := []struct {
+		name      string
+		chart     string
+		chartFile string
+		baseRef   string
+		wantRefs  []string
+		wantShow  []string
+		wantErr   string
+	}{
+		{
+			name:      "repo and chart",
+			chart:     "bitnami/redis",
+			chartFile: "charts/redis/Chart.yaml",
+			baseRef:   "main",
+			wantRefs:  []string{"bitnami/redis@18.1.0", "bitnami/redis@18.2.0"},
+			wantShow:  []string{"main:./charts/redis/Chart.yaml", "HEAD:./charts/redis/Chart.yaml"},
+		},
+		{
+			name:      "name from Chart.yaml",
+			chart:     "bitnami",
+			chartFile: "./charts/redis/Chart.yaml",
+			baseRef:   "main",
+			wantRefs:  []string{"bitnami/redis@18.1.0", "bitnami/redis@18.2.0"},
+			wantShow:  []string{"main:./charts/redis/Chart.yaml", "HEAD:./charts/redis/Chart.yaml"},
+		},
+		{
+			// Absolute paths are made relative to the working directory, which install changes to.
+			name:      "absolute path",
+			chart:     "bitnami/redis",
+			chartFile: "ABS/charts/redis/Chart.yaml",
+			baseRef:   "main",
+			wantRefs:  []string{"bitnami/redis@18.1.0", "bitnami/redis@18.2.0"},
+			wantShow:  []string{"main:./charts/redis/Chart.yaml", "HEAD:./charts/redis/Chart.yaml"},
+		},
+		{
+			name:      "chart with a version",
+			chart:     "bitnami/redis@18.1.0",
+			chartFile: "charts/redis/Chart.yaml",
+			baseRef:   "main",
+			wantErr:   "must not include a version",
+		},
+		{
+			name:      "missing at the base ref",
+			chart:     "bitnami/redis",
+			chartFile: "charts/redis/Chart.yaml",
+			baseRef:   "origin/main",
+			wantShow:  []string{"origin/main:./charts/redis/Chart.yaml"},
+			wantErr:   "error reading charts/redis/Chart.yaml at origin/main: exit status 128: fatal: path 'charts/redis/Chart.yaml' does not exist in 'origin/main'",
+		},
+		{
+			name:      "no version",
+			chart:     "bitnami/redis",
+			chartFile: "charts/redis/Chart.yaml",
+			baseRef:   "v1",
+			wantShow:  []string{"v1:./charts/redis/Chart.yaml"},
+			wantErr:   "charts/redis/Chart.yaml at v1 has no chart version",
+		},
+		{
+			name:      "invalid YAML",
+			chart:     "bitnami/redis",
+			chartFile: "charts/redis/Chart.yaml",
+			baseRef:   "v2",
+			wantShow:  []string{"v2:./charts/redis/Chart.yaml"},
+			wantErr:   "error parsing charts/redis/Chart.yaml at v2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := fakeTools{files: files}.install(t)
+			chartFile := tt.chartFile
+			if rest, ok := strings.CutPrefix(chartFile, "ABS/"); ok {
+				wd, err := os.Getwd()
+				if err != nil {
+					t.Fatal(err)
+				}
+				chartFile = filepath.Join(wd, rest)
+			}
+
+			before, after, err := ChartRefsFromGit(context.Background(), tt.chart, chartFile, tt.baseRef, "HEAD")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ChartRefsFromGit() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("ChartRefsFromGit() error = %v", err)
+			} else if got := []string{before, after}; !slices.Equal(got, tt.wantRefs) {
+				t.Errorf("ChartRefsFromGit() = %v, want %v", got, tt.wantRefs)
+			}
+
+			var shown []string
+			for _, args := range fakeexec.Calls(t, filepath.Join(dir, "git.log")) {
+				shown = append(shown, args[len(args)-1])
+			}
+			if !slices.Equal(shown, tt.wantShow) {
+				t.Errorf("git show objects = %v, want %v", shown, tt.wantShow)
+			}
+		})
+	}
+}
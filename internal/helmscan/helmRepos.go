@@ -0,0 +1,49 @@
+package helmscan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+// noReposMessage is what helm repo list prints, with a non-zero exit, when no repos are configured.
+const noReposMessage = "no repositories to show"
+
+// ListHelmRepos returns the repos configured in helm, which chart references must name.
+func ListHelmRepos(ctx context.Context, opts helmscanTypes.ScanOptions) ([]helmscanTypes.HelmRepo, error) {
+	cmd := execCommand(ctx, "helm", "repo", "list", "-o", "json")
+	cmd.Env = opts.CommandEnv()
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			if strings.Contains(string(exitErr.Stderr), noReposMessage) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("error listing helm repos: %w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("error listing helm repos: %w", err)
+	}
+	return parseRepoList(output)
+}
+
+func parseRepoList(output []byte) ([]helmscanTypes.HelmRepo, error) {
+	var repos []helmscanTypes.HelmRepo
+	if err := json.Unmarshal(output, &repos); err != nil {
+		return nil, fmt.Errorf("error parsing helm repo list output: %w", err)
+	}
+	return repos, nil
+}
+
+// missingRepoHint explains a helm template failure caused by a chart repo that is not configured.
+func missingRepoHint(output []byte, repoName string) string {
+	if !strings.Contains(string(output), fmt.Sprintf("repo %s not found", repoName)) {
+		return ""
+	}
+	return fmt.Sprintf("\nThe Helm repo %q is not configured. Run helmscan --list-repos to see the configured repos, or add it with helm repo add %s <url>.", repoName, repoName)
+}
@@ -0,0 +1,102 @@
+package helmscan
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func TestListHelmRepos(t *testing.T) {
+	tests := []struct {
+		name  string
+		repos []helmscanTypes.HelmRepo
+	}{
+		{
+			name: "repos configured",
+			repos: []helmscanTypes.HelmRepo{
+				{Name: "bitnami", URL: "https://charts.bitnami.com/bitnami"},
+				{Name: "jetstack", URL: "https://charts.jetstack.io"},
+			},
+		},
+		// helm repo list fails when there are no repos, which is not an error here.
+		{name: "no repos"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeTools{repos: tt.repos}.install(t)
+
+			repos, err := ListHelmRepos(context.Background(), helmscanTypes.ScanOptions{})
+			if err != nil {
+				t.Fatalf("ListHelmRepos() error = %v", err)
+			}
+			if !reflect.DeepEqual(repos, tt.repos) {
+				t.Errorf("ListHelmRepos() = %+v, want %+v", repos, tt.repos)
+			}
+		})
+	}
+}
+
+func TestParseRepoList(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    []helmscanTypes.HelmRepo
+		wantErr bool
+	}{
+		{
+			name:   "repos",
+			output: `[{"name":"bitnami","url":"https://charts.bitnami.com/bitnami"},{"name":"internal","url":"oci://registry.example.com/charts"}]`,
+			want: []helmscanTypes.HelmRepo{
+				{Name: "bitnami", URL: "https://charts.bitnami.com/bitnami"},
+				{Name: "internal", URL: "oci://registry.example.com/charts"},
+			},
+		},
+		{name: "empty list", output: "[]", want: []helmscanTypes.HelmRepo{}},
+		{name: "table output", output: "NAME   \tURL\nbitnami\thttps://charts.bitnami.com/bitnami\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRepoList([]byte(tt.output))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRepoList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseRepoList() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanMissingRepoHint(t *testing.T) {
+	const hint = "Run helmscan --list-repos to see the configured repos"
+	tests := []struct {
+		name     string
+		chartRef string
+		wantHint bool
+	}{
+		{name: "repo not configured", chartRef: "stable/redis@18.1.0", wantHint: true},
+		{name: "chart version not found", chartRef: "bitnami/redis@9.9.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeTools{
+				manifests: map[string]string{"bitnami/redis@18.1.0": redisManifest},
+				repos:     []helmscanTypes.HelmRepo{{Name: "bitnami", URL: "https://charts.bitnami.com/bitnami"}},
+			}.install(t)
+
+			_, err := ScanContext(context.Background(), tt.chartRef, helmscanTypes.ScanOptions{})
+			if err == nil {
+				t.Fatal("ScanContext() error = nil, want a templating error")
+			}
+			if got := strings.Contains(err.Error(), hint); got != tt.wantHint {
+				t.Errorf("ScanContext() error = %v, want hint %v", err, tt.wantHint)
+			}
+			if tt.wantHint && !strings.Contains(err.Error(), `The Helm repo "stable" is not configured`) {
+				t.Errorf("ScanContext() error = %v, want it to name the stable repo", err)
+			}
+		})
+	}
+}
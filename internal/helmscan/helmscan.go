@@ -1,12 +1,18 @@
 package helmscan
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 
 	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
 	"github.com/cliffcolvin/helmscan/internal/imageScan"
@@ -19,6 +25,11 @@ import (
 
 var logger *zap.SugaredLogger
 
+// execCommand is a variable so fake helm and yq binaries can be substituted.
+var execCommand = exec.CommandContext
+
+var placeholderPattern = regexp.MustCompile(`(?i)^(replace[_-]?me|change[_-]?me|todo|tbd|none|null|nil|<.*>)$`)
+
 func init() {
 	encoderConfig := zap.NewProductionEncoderConfig()
 	encoderConfig.TimeKey = "timestamp"
@@ -27,7 +38,7 @@ func init() {
 
 	core := zapcore.NewCore(
 		zapcore.NewConsoleEncoder(encoderConfig),
-		zapcore.AddSync(os.Stdout),
+		zapcore.AddSync(os.Stderr),
 		zap.InfoLevel,
 	)
 
@@ -35,237 +46,763 @@ func init() {
 	defer zapLogger.Sync()
 
 	logger = zapLogger.Sugar()
+}
+
+// SetLogger replaces the package logger, e.g. to apply the --log-format and --log-level flags.
+func SetLogger(l *zap.SugaredLogger) {
+	logger = l
+}
 
-	logger.Info("Application started")
+func Scan(chartRef string, ignoreUnfixed bool) (helmscanTypes.HelmChart, error) {
+	return ScanContext(context.Background(), chartRef, helmscanTypes.ScanOptions{IgnoreUnfixed: ignoreUnfixed})
+}
 
-	if err := imageScan.CheckTrivyInstallation(); err != nil {
-		logger.Fatalf("Trivy installation check failed: %v", err)
+func ListImagesContext(ctx context.Context, chartRef string, opts helmscanTypes.ScanOptions) (helmscanTypes.HelmChart, error) {
+	if err := updateHelmRepos(ctx, opts, chartRef); err != nil {
+		return helmscanTypes.HelmChart{}, err
 	}
+	helmChart, _, err := renderChart(ctx, chartRef, opts)
+	return helmChart, err
 }
 
-func Scan(chartRef string, ignoreUnfixed bool) (helmscanTypes.HelmChart, error) {
-	if err := os.MkdirAll("working-files/tmp/helm_output", 0755); err != nil {
-		return helmscanTypes.HelmChart{}, fmt.Errorf("error creating working-files/tmp/helm_output directory: %w", err)
+func ScanContext(ctx context.Context, chartRef string, opts helmscanTypes.ScanOptions) (helmscanTypes.HelmChart, error) {
+	if err := updateHelmRepos(ctx, opts, chartRef); err != nil {
+		return helmscanTypes.HelmChart{}, err
 	}
+	return scanChart(ctx, chartRef, opts)
+}
 
-	repoName, chartName, version, err := parseChartReference(chartRef)
+// ScanPairContext scans the two charts of a comparison concurrently after a single helm repo
+// update. Errors from both scans are returned together.
+func ScanPairContext(ctx context.Context, chartRef1, chartRef2 string, opts helmscanTypes.ScanOptions) (helmscanTypes.HelmChart, helmscanTypes.HelmChart, error) {
+	if err := updateHelmRepos(ctx, opts, chartRef1, chartRef2); err != nil {
+		return helmscanTypes.HelmChart{}, helmscanTypes.HelmChart{}, err
+	}
+
+	var chart1, chart2 helmscanTypes.HelmChart
+	var err1, err2 error
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		chart1, err1 = scanChart(ctx, chartRef1, opts)
+	})
+	wg.Go(func() {
+		chart2, err2 = scanChart(ctx, chartRef2, opts)
+	})
+	wg.Wait()
+
+	if err1 != nil {
+		err1 = fmt.Errorf("error scanning first Helm chart: %w", err1)
+	}
+	if err2 != nil {
+		err2 = fmt.Errorf("error scanning second Helm chart: %w", err2)
+	}
+	return chart1, chart2, errors.Join(err1, err2)
+}
+
+func scanChart(ctx context.Context, chartRef string, opts helmscanTypes.ScanOptions) (helmscanTypes.HelmChart, error) {
+	helmChart, output, err := renderChart(ctx, chartRef, opts)
 	if err != nil {
 		return helmscanTypes.HelmChart{}, err
 	}
 
-	helm_repo_update_cmd := exec.Command("helm", "repo", "update")
+	images := helmChart.ContainsImages
+	logger.Infof("Found %d images in %s", len(images), chartRef)
+	if opts.MaxImages > 0 && len(images) > opts.MaxImages {
+		return helmscanTypes.HelmChart{}, fmt.Errorf("chart %s contains %d images, more than the limit of %d; raise --max-images to scan it", chartRef, len(images), opts.MaxImages)
+	}
+	if opts.ScanManifests {
+		manifestDir := opts.WorkPath("tmp", "helm_output", fmt.Sprintf("%s_%s_%s_manifests", repoFileName(helmChart.HelmRepo), helmChart.Name, helmChart.Version))
+		if err := writeRenderedManifests(output, manifestDir); err != nil {
+			return helmscanTypes.HelmChart{}, fmt.Errorf("error saving rendered manifests: %w", err)
+		}
+		misconfigs, err := imageScan.ScanConfigContext(ctx, manifestDir, opts)
+		if err != nil {
+			return helmscanTypes.HelmChart{}, fmt.Errorf("error scanning rendered manifests: %w", err)
+		}
+		helmChart.ManifestMisconfigurations = misconfigs
+	}
+
+	helmChart.ContainsImages, err = scanImages(ctx, images, opts)
+	if err != nil {
+		return helmChart, err
+	}
+
+	return helmChart, nil
+}
+
+// scanImages scans each image with Trivy, running up to opts.Concurrency scans at once. It returns
+// the images that scanned, in order, and an error joining the failures of the rest.
+func scanImages(ctx context.Context, images []*helmscanTypes.ContainerImage, opts helmscanTypes.ScanOptions) ([]*helmscanTypes.ContainerImage, error) {
+	// Each scan writes only its own index of scanned and errs, so the results need no locking.
+	scanned := make([]*helmscanTypes.ContainerImage, len(images))
+	errs := make([]error, len(images))
+	slots := make(chan struct{}, max(opts.Concurrency, 1))
+	var wg sync.WaitGroup
+	for id, img := range images {
+		wg.Go(func() {
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			scanned[id], errs[id] = scanImage(ctx, img, opts)
+		})
+	}
+	wg.Wait()
+
+	results := make([]*helmscanTypes.ContainerImage, 0, len(images))
+	var scanErrors []error
+	for id, err := range errs {
+		if err != nil {
+			scanErrors = append(scanErrors, fmt.Errorf("error scanning image %s: %w", images[id].Identity(), err))
+			continue
+		}
+		results = append(results, scanned[id])
+	}
+	if len(scanErrors) > 0 {
+		return results, fmt.Errorf("errors occurred during image scanning:\n%w", errors.Join(scanErrors...))
+	}
+	return results, nil
+}
+
+func scanImage(ctx context.Context, img *helmscanTypes.ContainerImage, opts helmscanTypes.ScanOptions) (*helmscanTypes.ContainerImage, error) {
+	reference := imageReference(img)
+	scanReference, mirrored := mirroredReference(reference, opts.RegistryMirrors)
+	if mirrored {
+		logger.Infof("Scanning %s from mirror %s", reference, scanReference)
+	}
+	scanResult, err := imageScan.ScanImageContext(ctx, scanReference, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var scannedAs string
+	if mirrored {
+		scannedAs = scanReference
+		restoreImageReference(&scanResult, reference)
+	}
+	tmpVulns := make(map[string]helmscanTypes.Vulnerability)
+	for i := range scanResult.VulnList {
+		if _, exists := tmpVulns[scanResult.VulnList[i].ID]; !exists {
+			tmpVulns[scanResult.VulnList[i].ID] = scanResult.VulnList[i]
+		}
+	}
+	var signature *helmscanTypes.SignatureVerification
+	if opts.VerifySignatures {
+		verification := imageScan.VerifySignatureContext(ctx, scanReference, opts)
+		if !verification.Verified {
+			logger.Warnf("Could not verify the signature of %s: %s", reference, verification.Error)
+		}
+		signature = &verification
+	}
+	return &helmscanTypes.ContainerImage{
+		Repository:      img.Repository,
+		ImageName:       img.ImageName,
+		Tag:             img.Tag,
+		Digest:          img.Digest,
+		ScannedAs:       scannedAs,
+		OS:              scanResult.OS,
+		SourceRefs:      img.SourceRefs,
+		ScanResult:      scanResult,
+		Vulnerabilities: tmpVulns,
+		Signature:       signature,
+	}, nil
+}
+
+// restoreImageReference replaces the mirror reference Trivy scanned with the chart's own
+// reference, so reports name images as the chart does.
+func restoreImageReference(result *helmscanTypes.ScanResult, reference string) {
+	result.Image = reference
+	for i := range result.Secrets {
+		result.Secrets[i].Image = reference
+	}
+	for i := range result.Misconfigurations {
+		result.Misconfigurations[i].Image = reference
+	}
+}
+
+// updateHelmRepos runs helm repo update unless every chart in chartRefs is a local directory.
+func updateHelmRepos(ctx context.Context, opts helmscanTypes.ScanOptions, chartRefs ...string) error {
+	if !needsRepoUpdate(chartRefs...) {
+		return nil
+	}
+	helm_repo_update_cmd := execCommand(ctx, "helm", "repo", "update")
+	helm_repo_update_cmd.Env = opts.CommandEnv()
 	output, err := helm_repo_update_cmd.CombinedOutput()
 	if err != nil {
 		logger.Errorf("Error updating Helm repo: %v\nOutput: %s", err, string(output))
-		return helmscanTypes.HelmChart{}, fmt.Errorf("error updating Helm repo: %v\nOutput: %s", err, string(output))
+		return fmt.Errorf("error updating Helm repo: %w\nOutput: %s", err, string(output))
 	}
 	logger.Infof("Helm repo update output: %s", string(output))
+	return nil
+}
+
+// renderChart templates chartRef and extracts the images it references without scanning them.
+// Helm repos must already be updated.
+func renderChart(ctx context.Context, chartRef string, opts helmscanTypes.ScanOptions) (helmscanTypes.HelmChart, []byte, error) {
+	if err := os.MkdirAll(opts.WorkPath("tmp", "helm_output"), 0755); err != nil {
+		return helmscanTypes.HelmChart{}, nil, fmt.Errorf("error creating %s directory: %w", opts.WorkPath("tmp", "helm_output"), err)
+	}
+
+	if IsLocalChart(chartRef) {
+		return renderLocalChart(ctx, chartRef, opts)
+	}
+
+	repoName, chartName, version, err := parseChartReference(chartRef)
+	if err != nil {
+		return helmscanTypes.HelmChart{}, nil, err
+	}
 
-	cmd := exec.Command("helm", "template", fmt.Sprintf("%s/%s", repoName, chartName), "--version", version)
-	output, err = cmd.CombinedOutput()
+	if isLatestVersion(version) {
+		resolved, err := latestVersion(ctx, repoName, chartName, opts)
+		if err != nil {
+			return helmscanTypes.HelmChart{}, nil, fmt.Errorf("error resolving %s: %w", chartRef, err)
+		}
+		logger.Infof("Resolved %s to version %s", chartRef, resolved)
+		version = resolved
+	}
+
+	return templateChart(ctx, fmt.Sprintf("%s/%s", repoName, chartName), repoName, chartName, version, opts, "--version", version)
+}
+
+// renderLocalChart templates a chart directory, taking its name and version from Chart.yaml.
+func renderLocalChart(ctx context.Context, chartPath string, opts helmscanTypes.ScanOptions) (helmscanTypes.HelmChart, []byte, error) {
+	metadata, err := readLocalChart(chartPath)
+	if err != nil {
+		return helmscanTypes.HelmChart{}, nil, err
+	}
+	if opts.UpdateDependencies {
+		if err := buildDependencies(ctx, chartPath, opts); err != nil {
+			return helmscanTypes.HelmChart{}, nil, err
+		}
+	}
+	return templateChart(ctx, chartPath, localRepoName, metadata.Name, metadata.Version, opts)
+}
+
+func templateChart(ctx context.Context, chart string, repoName, chartName, version string, opts helmscanTypes.ScanOptions, extraArgs ...string) (helmscanTypes.HelmChart, []byte, error) {
+	releaseName := opts.ReleaseName
+	if releaseName == "" {
+		releaseName = helmscanTypes.DefaultReleaseName
+	}
+	args := append([]string{"template", releaseName, chart}, extraArgs...)
+	if opts.EnableAll {
+		toggles, err := enableAllArgs(ctx, opts, append([]string{chart}, extraArgs...)...)
+		if err != nil {
+			return helmscanTypes.HelmChart{}, nil, err
+		}
+		args = append(args, toggles...)
+	}
+	cmd := execCommand(ctx, "helm", args...)
+	cmd.Env = opts.CommandEnv()
+	output, err := cmd.CombinedOutput()
 	if err != nil {
 		logger.Errorf("Error templating chart: %v\nOutput: %s", err, string(output))
-		return helmscanTypes.HelmChart{}, fmt.Errorf("error templating chart: %v\nOutput: %s", err, string(output))
+		return helmscanTypes.HelmChart{}, nil, fmt.Errorf("error templating chart: %v\nOutput: %s%s", err, string(output), missingRepoHint(output, repoName))
 	}
 
-	outputFileName := fmt.Sprintf("working-files/tmp/helm_output/%s_%s_%s_helm_output.yaml", repoName, chartName, version)
+	outputFileName := opts.WorkPath("tmp", "helm_output", fmt.Sprintf("%s_%s_%s_helm_output.yaml", repoFileName(repoName), chartName, version))
 	err = os.WriteFile(outputFileName, output, 0644)
 	if err != nil {
-		return helmscanTypes.HelmChart{}, fmt.Errorf("error saving helm output to file: %w", err)
+		return helmscanTypes.HelmChart{}, nil, fmt.Errorf("error saving helm output to file: %w", err)
 	}
 
-	images, err := extractImagesFromYAML(output)
+	if !hasRenderedResources(output) {
+		logger.Warnf("Chart %s@%s rendered no resources with the current values, so it has no images to scan", chart, version)
+		return helmscanTypes.HelmChart{Name: chartName, Version: version, HelmRepo: repoName}, output, nil
+	}
+
+	annotationKeys, err := annotationKeyPattern(opts)
+	if err != nil {
+		return helmscanTypes.HelmChart{}, nil, err
+	}
+	occurrences, err := findImageReferences(output, annotationKeys)
 	if err != nil {
-		return helmscanTypes.HelmChart{}, fmt.Errorf("error extracting images: %w", err)
+		return helmscanTypes.HelmChart{}, nil, fmt.Errorf("error extracting images: %w", err)
+	}
+	images, skipped := imagesFromOccurrences(occurrences)
+	if opts.ImageExtractionDebug {
+		debug := imageExtractionDebug{Chart: chart, Version: version, HelmOutput: outputFileName, Occurrences: occurrences, Images: images, Skipped: skipped}
+		path, err := writeImageExtractionDebug(opts, fmt.Sprintf("%s_%s_%s_image_extraction.json", repoFileName(repoName), chartName, version), debug)
+		if err != nil {
+			return helmscanTypes.HelmChart{}, nil, err
+		}
+		logger.Infof("Wrote image extraction output for %s@%s to %s", chart, version, path)
 	}
 
 	helmChart := helmscanTypes.HelmChart{
 		Name:           chartName,
 		Version:        version,
 		HelmRepo:       repoName,
-		ContainsImages: make([]*helmscanTypes.ContainerImage, len(images)),
+		ContainsImages: filterImagesByRepository(images, opts.OnlyRepos, opts.SkipRepos),
+		SkippedImages:  skipped,
 	}
+	return helmChart, output, nil
+}
 
-	var scanErrors []string
-	for id, img := range images {
-		imageName := fmt.Sprintf("%s/%s:%s", img.Repository, img.ImageName, img.Tag)
-		scanResult, err := imageScan.ScanImage(imageName, ignoreUnfixed)
-		if err != nil {
-			scanErrors = append(scanErrors, fmt.Sprintf("error scanning image %s: %v", img.ImageName, err))
-		} else {
-			tmpVulns := make(map[string]helmscanTypes.Vulnerability)
-			for i := range scanResult.VulnList {
-				if _, exists := tmpVulns[scanResult.VulnList[i].ID]; !exists {
-					tmpVulns[scanResult.VulnList[i].ID] = scanResult.VulnList[i]
-				}
-			}
-			helmChart.ContainsImages[id] = &helmscanTypes.ContainerImage{
-				Repository:      img.Repository,
-				ImageName:       img.ImageName,
-				Tag:             img.Tag,
-				ScanResult:      scanResult,
-				Vulnerabilities: tmpVulns,
+func CompareHelmCharts(before, after helmscanTypes.HelmChart) helmscanTypes.HelmComparison {
+	comparison, _ := CompareHelmChartsContext(context.Background(), before, after)
+	return comparison
+}
+
+func CompareHelmChartsContext(ctx context.Context, before, after helmscanTypes.HelmChart) (helmscanTypes.HelmComparison, error) {
+	return compareCharts(ctx, before, after, canonicalName, false)
+}
+
+// CompareHelmChartsByDigestContext compares like CompareHelmChartsContext, but an image whose tag
+// is unchanged is still Changed when the digest it resolved to differs, as when a mutable tag is
+// repinned. Changed images are listed with their tags and digests in ImageChanges.
+func CompareHelmChartsByDigestContext(ctx context.Context, before, after helmscanTypes.HelmChart) (helmscanTypes.HelmComparison, error) {
+	return compareCharts(ctx, before, after, canonicalName, true)
+}
+
+// imageDigest returns the digest an image reference pins, or else the digest Trivy resolved it to.
+func imageDigest(img *helmscanTypes.ContainerImage) string {
+	if img.Digest != "" {
+		return img.Digest
+	}
+	return img.ScanResult.RepoDigest
+}
+
+// CompareMirroredChartsContext compares a chart against a mirror of the same chart and version,
+// matching images by name so that registry and repository differences are reported instead of
+// showing up as added and removed images.
+func CompareMirroredChartsContext(ctx context.Context, upstream, mirror helmscanTypes.HelmChart) (helmscanTypes.HelmComparison, error) {
+	if upstream.Name != mirror.Name || upstream.Version != mirror.Version {
+		return helmscanTypes.HelmComparison{}, fmt.Errorf("mirror comparison requires the same chart and version, got %s@%s and %s@%s",
+			upstream.Name, upstream.Version, mirror.Name, mirror.Version)
+	}
+
+	return compareCharts(ctx, upstream, mirror, func(img *helmscanTypes.ContainerImage) string {
+		return img.ImageName
+	}, false)
+}
+
+// canonicalName keys an image by its canonical name so that images from different repositories
+// sharing a final path segment are not treated as the same image, while spellings of the same
+// image, such as redis and docker.io/library/redis, are.
+func canonicalName(img *helmscanTypes.ContainerImage) string {
+	return img.CanonicalName()
+}
+
+// pairImages pairs before and after images that share a comparison key. Images at the same
+// reference pair first and the rest pair in chart order; whatever is left over was removed or added.
+func pairImages(before, after []*helmscanTypes.ContainerImage) (pairs [][2]*helmscanTypes.ContainerImage, removed, added []*helmscanTypes.ContainerImage) {
+	matched := make(map[*helmscanTypes.ContainerImage]bool)
+	for _, beforeImg := range before {
+		for _, afterImg := range after {
+			if !matched[afterImg] && beforeImg.Identity() == afterImg.Identity() {
+				pairs = append(pairs, [2]*helmscanTypes.ContainerImage{beforeImg, afterImg})
+				matched[beforeImg], matched[afterImg] = true, true
+				break
 			}
 		}
 	}
 
-	if len(scanErrors) > 0 {
-		return helmChart, fmt.Errorf("errors occurred during image scanning:\n%s", strings.Join(scanErrors, "\n"))
+	for _, img := range before {
+		if !matched[img] {
+			removed = append(removed, img)
+		}
 	}
-
-	return helmChart, nil
+	for _, img := range after {
+		if !matched[img] {
+			added = append(added, img)
+		}
+	}
+	for len(removed) > 0 && len(added) > 0 {
+		pairs = append(pairs, [2]*helmscanTypes.ContainerImage{removed[0], added[0]})
+		removed, added = removed[1:], added[1:]
+	}
+	return pairs, removed, added
 }
 
-func CompareHelmCharts(before, after helmscanTypes.HelmChart) helmscanTypes.HelmComparison {
+func compareCharts(ctx context.Context, before, after helmscanTypes.HelmChart, key func(*helmscanTypes.ContainerImage) string, byDigest bool) (helmscanTypes.HelmComparison, error) {
+	if err := ctx.Err(); err != nil {
+		return helmscanTypes.HelmComparison{}, err
+	}
+
 	comparison := helmscanTypes.HelmComparison{
-		Before:          before,
-		After:           after,
-		AddedImages:     make(map[string][]*helmscanTypes.ContainerImage),
-		RemovedImages:   make(map[string][]*helmscanTypes.ContainerImage),
-		ChangedImages:   make(map[string][]*helmscanTypes.ContainerImage),
-		UnChangedImages: make(map[string][]*helmscanTypes.ContainerImage),
-		RemovedCVEs:     make(map[string]map[string]helmscanTypes.Vulnerability),
-		AddedCVEs:       make(map[string]map[string]helmscanTypes.Vulnerability),
-		UnchangedCVEs:   make(map[string]map[string]helmscanTypes.Vulnerability),
+		Before:            before,
+		After:             after,
+		AddedImages:       make(map[string][]*helmscanTypes.ContainerImage),
+		RemovedImages:     make(map[string][]*helmscanTypes.ContainerImage),
+		ChangedImages:     make(map[string][]*helmscanTypes.ContainerImage),
+		UnChangedImages:   make(map[string][]*helmscanTypes.ContainerImage),
+		RemovedCVEs:       make(map[string]map[string]helmscanTypes.Vulnerability),
+		AddedCVEs:         make(map[string]map[string]helmscanTypes.Vulnerability),
+		UnchangedCVEs:     make(map[string]map[string]helmscanTypes.Vulnerability),
+		RepositoryChanges: repositoryChanges(before, after),
 	}
 
-	beforeImages := make(map[string]*helmscanTypes.ContainerImage)
-	afterImages := make(map[string]*helmscanTypes.ContainerImage)
+	beforeImages := make(map[string][]*helmscanTypes.ContainerImage)
+	afterImages := make(map[string][]*helmscanTypes.ContainerImage)
+	var keys []string
 
 	for _, img := range before.ContainsImages {
-		beforeImages[img.ImageName] = img
+		if _, exists := beforeImages[key(img)]; !exists {
+			keys = append(keys, key(img))
+		}
+		beforeImages[key(img)] = append(beforeImages[key(img)], img)
 	}
 
 	for _, img := range after.ContainsImages {
-		afterImages[img.ImageName] = img
+		if _, exists := beforeImages[key(img)]; !exists && afterImages[key(img)] == nil {
+			keys = append(keys, key(img))
+		}
+		afterImages[key(img)] = append(afterImages[key(img)], img)
 	}
 
-	for name, beforeImg := range beforeImages {
-		if afterImg, exists := afterImages[name]; exists {
-			if beforeImg.Tag != afterImg.Tag {
+	for _, k := range keys {
+		// A key shared by several images on either side, such as one image at two tags, reports
+		// each image under its full reference so that none of them is lost.
+		entryName := func(img *helmscanTypes.ContainerImage) string {
+			if len(beforeImages[k]) > 1 || len(afterImages[k]) > 1 {
+				return img.Identity()
+			}
+			return k
+		}
+
+		pairs, removed, added := pairImages(beforeImages[k], afterImages[k])
+		for _, pair := range pairs {
+			beforeImg, afterImg := pair[0], pair[1]
+			name := entryName(afterImg)
+			changed := beforeImg.Tag != afterImg.Tag || beforeImg.Digest != afterImg.Digest
+			if byDigest && imageDigest(beforeImg) != "" && imageDigest(afterImg) != "" {
+				// Resolved digests are known on both sides, so they decide even where only one
+				// reference pins a digest.
+				changed = beforeImg.Tag != afterImg.Tag || imageDigest(beforeImg) != imageDigest(afterImg)
+			}
+			if changed {
 				comparison.ChangedImages[name] = []*helmscanTypes.ContainerImage{beforeImg, afterImg}
-				compareImageVulnerabilities(beforeImg, afterImg, &comparison)
+				compareImageVulnerabilities(name, beforeImg, afterImg, &comparison)
+				if byDigest {
+					comparison.ImageChanges = append(comparison.ImageChanges, helmscanTypes.ImageChange{
+						ImageName:    name,
+						BeforeTag:    beforeImg.Tag,
+						AfterTag:     afterImg.Tag,
+						BeforeDigest: imageDigest(beforeImg),
+						AfterDigest:  imageDigest(afterImg),
+					})
+				}
 			} else {
 				comparison.UnChangedImages[name] = []*helmscanTypes.ContainerImage{beforeImg, afterImg}
-				for ID, vuln := range beforeImg.Vulnerabilities {
-					if _, exists := comparison.UnchangedCVEs[ID]; !exists {
-						comparison.UnchangedCVEs[ID] = make(map[string]helmscanTypes.Vulnerability)
-					}
-					comparison.UnchangedCVEs[ID][name] = vuln
-				}
+				// The two scans of an unchanged image can still differ, as when the Trivy DB
+				// was updated in between, so its CVEs are bucketed like a changed image's.
+				compareImageVulnerabilities(name, beforeImg, afterImg, &comparison)
 			}
-		} else {
+		}
+		for _, beforeImg := range removed {
+			name := entryName(beforeImg)
 			comparison.RemovedImages[name] = []*helmscanTypes.ContainerImage{beforeImg}
-			for ID, vuln := range beforeImg.Vulnerabilities {
-				if _, exists := comparison.RemovedCVEs[ID]; !exists {
-					comparison.RemovedCVEs[ID] = make(map[string]helmscanTypes.Vulnerability)
-				}
-				comparison.RemovedCVEs[ID][name] = vuln
-			}
+			mergeVulnerabilities(comparison.RemovedCVEs, name, beforeImg.Vulnerabilities)
+		}
+		for _, afterImg := range added {
+			name := entryName(afterImg)
+			comparison.AddedImages[name] = []*helmscanTypes.ContainerImage{afterImg}
+			mergeVulnerabilities(comparison.AddedCVEs, name, afterImg.Vulnerabilities)
 		}
 	}
+	sort.Slice(comparison.ImageChanges, func(i, j int) bool {
+		return comparison.ImageChanges[i].ImageName < comparison.ImageChanges[j].ImageName
+	})
 
-	for name, afterImg := range afterImages {
-		if _, exists := beforeImages[name]; !exists {
-			comparison.AddedImages[name] = []*helmscanTypes.ContainerImage{afterImg}
-			for ID, vuln := range afterImg.Vulnerabilities {
-				if _, exists := comparison.AddedCVEs[ID]; !exists {
-					comparison.AddedCVEs[ID] = make(map[string]helmscanTypes.Vulnerability)
-				}
-				comparison.AddedCVEs[ID][name] = vuln
-			}
+	return comparison, ctx.Err()
+}
+
+// repositoryChanges pairs images by name and reports those whose repository differs between charts.
+func repositoryChanges(before, after helmscanTypes.HelmChart) []helmscanTypes.RepositoryChange {
+	afterRepos := make(map[string][]string)
+	for _, img := range after.ContainsImages {
+		afterRepos[img.ImageName] = append(afterRepos[img.ImageName], img.Repository)
+	}
+
+	var changes []helmscanTypes.RepositoryChange
+	for _, img := range before.ContainsImages {
+		repos := afterRepos[img.ImageName]
+		if len(repos) != 1 || repos[0] == img.Repository || hasRepository(before, img.ImageName, repos[0]) {
+			continue
 		}
+		changes = append(changes, helmscanTypes.RepositoryChange{
+			ImageName:        img.ImageName,
+			BeforeRepository: img.Repository,
+			AfterRepository:  repos[0],
+		})
 	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].ImageName < changes[j].ImageName })
+	return changes
+}
 
-	return comparison
+func hasRepository(chart helmscanTypes.HelmChart, imageName, repository string) bool {
+	for _, img := range chart.ContainsImages {
+		if img.ImageName == imageName && img.Repository == repository {
+			return true
+		}
+	}
+	return false
 }
 
-func compareImageVulnerabilities(before, after *helmscanTypes.ContainerImage, comparison *helmscanTypes.HelmComparison) {
+// compareImageVulnerabilities buckets the CVEs of one image found in both charts: Removed when only
+// the before image has a CVE, Added when only the after image has it and Unchanged when both do.
+func compareImageVulnerabilities(name string, before, after *helmscanTypes.ContainerImage, comparison *helmscanTypes.HelmComparison) {
 	for ID, vuln := range before.Vulnerabilities {
 		if _, exists := after.Vulnerabilities[ID]; !exists {
-			if _, exists := comparison.RemovedCVEs[ID]; !exists {
-				comparison.RemovedCVEs[ID] = make(map[string]helmscanTypes.Vulnerability)
-			}
-			comparison.RemovedCVEs[ID][before.ImageName] = vuln
+			addCVE(comparison.RemovedCVEs, ID, name, vuln)
 		} else {
-			if _, exists := comparison.UnchangedCVEs[ID]; !exists {
-				comparison.UnchangedCVEs[ID] = make(map[string]helmscanTypes.Vulnerability)
-			}
-			comparison.UnchangedCVEs[ID][before.ImageName] = vuln
+			addCVE(comparison.UnchangedCVEs, ID, name, vuln)
 		}
 	}
 
 	for ID, vuln := range after.Vulnerabilities {
 		if _, exists := before.Vulnerabilities[ID]; !exists {
-			if _, exists := comparison.AddedCVEs[ID]; !exists {
-				comparison.AddedCVEs[ID] = make(map[string]helmscanTypes.Vulnerability)
-			}
-			comparison.AddedCVEs[ID][after.ImageName] = vuln
+			addCVE(comparison.AddedCVEs, ID, name, vuln)
 		}
 	}
 }
 
-func extractImagesFromYAML(yamlData []byte) ([]*helmscanTypes.ContainerImage, error) {
-	cmd := exec.Command("bash", "-c", `yq '.. | .image? | select(.)'`)
-	cmd.Stdin = bytes.NewReader(yamlData)
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("error extracting images: %w", err)
+// mergeVulnerabilities adds the vulnerabilities of the image keyed name to cves, a set keyed by
+// CVE ID and then image. It runs after every image scan has finished and only reads the image's
+// map, so the maps built by concurrent scans are never written to once they are returned.
+func mergeVulnerabilities(cves map[string]map[string]helmscanTypes.Vulnerability, name string, vulns map[string]helmscanTypes.Vulnerability) {
+	for ID, vuln := range vulns {
+		addCVE(cves, ID, name, vuln)
 	}
+}
 
-	imageStrings := strings.Split(strings.TrimSpace(string(output)), "\n")
-	var images []*helmscanTypes.ContainerImage
-	m := map[string]bool{} // map to filter out duplicate images
-	for _, imageString := range imageStrings {
-		imageString = strings.Trim(imageString, "\"")
-		// Filter out YAML document separators
-		if imageString == "---" {
+func addCVE(cves map[string]map[string]helmscanTypes.Vulnerability, ID, name string, vuln helmscanTypes.Vulnerability) {
+	if _, exists := cves[ID]; !exists {
+		cves[ID] = make(map[string]helmscanTypes.Vulnerability)
+	}
+	cves[ID][name] = vuln
+}
+
+// imagesFromOccurrences parses the image references found in the rendered manifests, merging the
+// sources of duplicates and skipping references that are not valid images.
+func imagesFromOccurrences(occurrences []imageOccurrence) ([]*helmscanTypes.ContainerImage, []helmscanTypes.SkippedImage) {
+	images := []*helmscanTypes.ContainerImage{}
+	var skipped []helmscanTypes.SkippedImage
+	m := map[string]*helmscanTypes.ContainerImage{} // images by identity, to filter out duplicates
+	skippedRefs := map[string]bool{}
+	for _, occurrence := range occurrences {
+		imageString := strings.TrimSpace(occurrence.Reference)
+		// Filter out blank values
+		if imageString == "" {
+			continue
+		}
+		if reason := invalidImageReason(imageString); reason != "" {
+			if !skippedRefs[imageString] {
+				skippedRefs[imageString] = true
+				logger.Warnf("Skipping image %q: %s", imageString, reason)
+				skipped = append(skipped, helmscanTypes.SkippedImage{Reference: imageString, Reason: reason})
+			}
 			continue
 		}
 		image := parseImageString(imageString)
-		if _, exists := m[imageString]; exists {
+		if existing, exists := m[image.Identity()]; exists {
+			existing.SourceRefs = append(existing.SourceRefs, occurrence.Source)
 			continue
 		}
-		m[imageString] = true
+		image.SourceRefs = []helmscanTypes.SourceRef{occurrence.Source}
+		m[image.Identity()] = image
 		images = append(images, image)
 	}
 
-	return images, nil
+	if len(images) == 0 {
+		logger.Info("No images found in rendered chart")
+	}
+
+	return images, skipped
 }
 
-func parseImageString(imageString string) *helmscanTypes.ContainerImage {
-	parts := strings.Split(imageString, ":")
-	var repository, imageName, tag string
-
-	if len(parts) > 1 {
-		tag = parts[len(parts)-1]
-		repoAndImage := strings.Join(parts[:len(parts)-1], ":")
-		repoParts := strings.Split(repoAndImage, "/")
-		if len(repoParts) > 1 {
-			imageName = repoParts[len(repoParts)-1]
-			repository = strings.Join(repoParts[:len(repoParts)-1], "/")
-		} else {
-			imageName = repoAndImage
+// writeRenderedManifests splits helm template output on its "# Source:" comments so
+// that misconfiguration findings can be traced back to the template that produced them.
+func writeRenderedManifests(rendered []byte, dir string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+
+	manifests := make(map[string]*strings.Builder)
+	var order []string
+	source := "unknown.yaml"
+	for _, document := range strings.Split(string(rendered), "\n---") {
+		if strings.TrimSpace(document) == "" {
+			continue
 		}
-	} else {
-		repoParts := strings.Split(imageString, "/")
-		if len(repoParts) > 1 {
-			imageName = repoParts[len(repoParts)-1]
-			repository = strings.Join(repoParts[:len(repoParts)-1], "/")
-		} else {
-			imageName = imageString
+		for _, line := range strings.Split(document, "\n") {
+			if strings.HasPrefix(line, "# Source: ") {
+				source = strings.TrimSpace(strings.TrimPrefix(line, "# Source: "))
+				break
+			}
+		}
+		if _, exists := manifests[source]; !exists {
+			manifests[source] = &strings.Builder{}
+			order = append(order, source)
+		}
+		manifests[source].WriteString("---" + strings.TrimPrefix(document, "---") + "\n")
+	}
+
+	for _, source := range order {
+		path := filepath.Join(dir, filepath.Clean("/"+source))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
 		}
+		if err := os.WriteFile(path, []byte(manifests[source].String()), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func filterImagesByRepository(images []*helmscanTypes.ContainerImage, onlyRepos []string, skipRepos []string) []*helmscanTypes.ContainerImage {
+	if len(onlyRepos) == 0 && len(skipRepos) == 0 {
+		return images
+	}
+
+	filtered := []*helmscanTypes.ContainerImage{}
+	for _, img := range images {
+		repository := canonicalName(img)
+		if len(onlyRepos) > 0 && !matchesAnyRepository(repository, onlyRepos) {
+			logger.Infof("Skipping image %s: not matched by --only-repos", repository)
+			continue
+		}
+		if matchesAnyRepository(repository, skipRepos) {
+			logger.Infof("Skipping image %s: matched by --skip-repos", repository)
+			continue
+		}
+		filtered = append(filtered, img)
+	}
+	return filtered
+}
+
+// matchesAnyRepository reports whether repository matches one of the patterns. Patterns
+// containing glob characters are matched with path.Match, where * does not cross a /, except that
+// a trailing /** matches one or more path segments. Anything else is a prefix of whole path
+// segments, so docker.io/bitnami matches docker.io/bitnami/redis but not docker.io/bitnami-labs.
+func matchesAnyRepository(repository string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if strings.ContainsAny(pattern, "*?[") {
+			if matchesRepositoryGlob(repository, pattern) {
+				return true
+			}
+			continue
+		}
+		prefix := strings.TrimSuffix(pattern, "/")
+		if repository == prefix || strings.HasPrefix(repository, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesRepositoryGlob(repository string, pattern string) bool {
+	parent, recursive := strings.CutSuffix(pattern, "/**")
+	if !recursive {
+		matched, _ := path.Match(pattern, repository)
+		return matched
+	}
+	// Match the parent pattern against every proper ancestor of repository.
+	for i := len(repository) - 1; i > 0; i-- {
+		if repository[i] != '/' {
+			continue
+		}
+		if matched, _ := path.Match(parent, repository[:i]); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func invalidImageReason(imageString string) string {
+	if strings.Contains(imageString, "{{") || strings.Contains(imageString, "}}") {
+		return "unrendered template expression"
+	}
+	if strings.ContainsAny(imageString, " \t") {
+		return "contains whitespace"
+	}
+
+	image := parseImageString(imageString)
+	if image.ImageName == "" {
+		return "missing image name"
+	}
+	for _, part := range []string{imageString, image.ImageName, image.Tag} {
+		if placeholderPattern.MatchString(part) {
+			return "placeholder value"
+		}
+	}
+	if image.ImageName != strings.ToLower(image.ImageName) {
+		return "image names must be lowercase"
+	}
+	return ""
+}
+
+func parseImageString(imageString string) *helmscanTypes.ContainerImage {
+	reference, digest, _ := strings.Cut(imageString, "@")
+
+	// A colon before the last slash belongs to a registry port, not a tag.
+	var tag string
+	if colon := strings.LastIndex(reference, ":"); colon > strings.LastIndex(reference, "/") {
+		tag = reference[colon+1:]
+		reference = reference[:colon]
+	}
+	if tag == "" && digest == "" {
 		tag = "latest"
 	}
 
+	var repository, imageName string
+	repoParts := strings.Split(reference, "/")
+	if len(repoParts) > 1 {
+		imageName = repoParts[len(repoParts)-1]
+		repository = strings.Join(repoParts[:len(repoParts)-1], "/")
+	} else {
+		imageName = reference
+	}
+
 	return &helmscanTypes.ContainerImage{
 		Repository: repository,
 		ImageName:  imageName,
 		Tag:        tag,
+		Digest:     digest,
 	}
 }
 
+// ChartImageReference renders chartRef and returns the full reference of its image called name,
+// matched by image name (e.g. redis) or by repository and name (e.g. docker.io/bitnami/redis).
+// It fails when no image or more than one image matches.
+func ChartImageReference(ctx context.Context, chartRef, name string, opts helmscanTypes.ScanOptions) (string, error) {
+	chart, err := ListImagesContext(ctx, chartRef, opts)
+	if err != nil {
+		return "", err
+	}
+
+	var matches []string
+	for _, img := range chart.ContainsImages {
+		if img.ImageName == name || img.Repository+"/"+img.ImageName == name || canonicalName(img) == name {
+			matches = append(matches, imageReference(img))
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("chart %s has no image named %s", chartRef, name)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("chart %s has several images named %s (%s); name the repository too", chartRef, name, strings.Join(matches, ", "))
+	}
+}
+
+func imageReference(img *helmscanTypes.ContainerImage) string {
+	reference := img.ImageName
+	if img.Repository != "" {
+		reference = img.Repository + "/" + reference
+	}
+	if img.Tag != "" {
+		reference += ":" + img.Tag
+	}
+	if img.Digest != "" {
+		reference += "@" + img.Digest
+	}
+	return reference
+}
+
 func parseChartReference(chartRef string) (string, string, string, error) {
+	if IsOCIChart(chartRef) {
+		return parseOCIChartReference(chartRef)
+	}
 	parts := strings.Split(chartRef, "/")
 	if len(parts) != 2 {
 		return "", "", "", fmt.Errorf("invalid chart reference: %s", chartRef)
@@ -302,21 +839,183 @@ func downloadChart(repoName, chartName, version, destDir string) (string, error)
 	return chartPath, nil
 }
 
-func GenerateReport(comparison helmscanTypes.HelmComparison, generateJSON bool, generateMD bool) string {
+func GenerateReport(comparison helmscanTypes.HelmComparison, generateJSON bool, generateMD bool, opts reports.ReportOptions) (string, error) {
 	generator := NewHelmReportGenerator(comparison)
-	return reports.GenerateReport(generator, generateJSON, generateMD)
+	return reports.GenerateReport(generator, generateJSON, generateMD, opts)
+}
+
+func RenderMarkdown(comparison helmscanTypes.HelmComparison, opts reports.ReportOptions) string {
+	return reports.RenderMarkdown(NewHelmReportGenerator(comparison), opts)
+}
+
+func RenderJSON(comparison helmscanTypes.HelmComparison, opts reports.ReportOptions) (string, error) {
+	return reports.RenderJSON(NewHelmReportGenerator(comparison), opts)
+}
+
+func GenerateSummary(comparison helmscanTypes.HelmComparison) string {
+	return reports.GenerateComparisonSummary(NewHelmReportGenerator(comparison))
+}
+
+// NewBatchEntry summarises a chart scanned by a --batch run for the batch index.
+func NewBatchEntry(chartRef string, chart helmscanTypes.HelmChart) reports.BatchEntry {
+	return reports.NewBatchEntry(chartRef, len(chart.ContainsImages), chartVulnerabilities(chart))
+}
+
+func GenerateSingleScanReport(chart helmscanTypes.HelmChart, jsonOutput bool, ignoreUnfixed bool, opts reports.ReportOptions) string {
+	chartRef := fmt.Sprintf("%s/%s@%s", chart.HelmRepo, chart.Name, chart.Version)
+	return generateSingleScanReport("helm", chartRef, chart, jsonOutput, ignoreUnfixed, opts)
+}
+
+func generateSingleScanReport(artifactType, ref string, chart helmscanTypes.HelmChart, jsonOutput bool, ignoreUnfixed bool, opts reports.ReportOptions) string {
+	chart = displayedChart(chart, opts)
+	report := reports.NewSingleScanReport(artifactType, ref, chartVulnerabilities(chart))
+	if opts.CountMode == reports.CountModeChart {
+		report.Summary = reports.CountVulnerabilities(ChartLevelUniqueCVEs(chart))
+	}
+	report.SkippedImages = chart.SkippedImages
+	report.NoImages = len(chart.ContainsImages) == 0
+	report.ManifestMisconfigurations = chart.ManifestMisconfigurations
+	report.ImageSources = make(map[string][]helmscanTypes.SourceRef)
+	for _, img := range chart.ContainsImages {
+		report.ImageSources[img.Identity()] = append(report.ImageSources[img.Identity()], img.SourceRefs...)
+		if img.Signature != nil {
+			if report.Signatures == nil {
+				report.Signatures = make(map[string]helmscanTypes.SignatureVerification)
+			}
+			report.Signatures[img.Identity()] = *img.Signature
+		}
+		if img.ScannedAs != "" {
+			if report.MirroredImages == nil {
+				report.MirroredImages = make(map[string]string)
+			}
+			report.MirroredImages[img.Identity()] = img.ScannedAs
+		}
+	}
+	vulnsByImage := VulnerabilitiesByImage(chart)
+	if opts.GroupBy == reports.GroupByPackage {
+		report.Packages = reports.GroupByPackages(vulnsByImage)
+	}
+	if opts.Remediation {
+		report.Remediation = reports.NewRemediationPlan(vulnsByImage)
+	}
+	report.RawOutputs = make(map[string]string)
+	addRawOutputs(report.RawOutputs, chart)
+	report.OperatingSystems = make(map[string]helmscanTypes.OperatingSystem)
+	for _, img := range chart.ContainsImages {
+		addOperatingSystem(report.OperatingSystems, img.Identity(), img)
+	}
+	for _, img := range chart.ContainsImages {
+		report.Secrets = append(report.Secrets, img.ScanResult.Secrets...)
+		report.Misconfigurations = append(report.Misconfigurations, img.ScanResult.Misconfigurations...)
+	}
+	return reports.GenerateSingleScanReport(report, jsonOutput, ignoreUnfixed, opts)
 }
 
-func GenerateSingleScanReport(chart helmscanTypes.HelmChart, jsonOutput bool, ignoreUnfixed bool) string {
+// displayedChart copies chart with the vulnerabilities of each image limited to the severities
+// reports show.
+func displayedChart(chart helmscanTypes.HelmChart, opts reports.ReportOptions) helmscanTypes.HelmChart {
+	if len(opts.DisplaySeverity) == 0 {
+		return chart
+	}
+	images := make([]*helmscanTypes.ContainerImage, 0, len(chart.ContainsImages))
+	for _, img := range chart.ContainsImages {
+		displayed := *img
+		displayed.ScanResult.VulnList = opts.DisplayedVulnerabilities(img.ScanResult.VulnList)
+		displayed.Vulnerabilities = make(map[string]helmscanTypes.Vulnerability)
+		for id, vuln := range img.Vulnerabilities {
+			if opts.DisplaysSeverity(vuln.Severity) {
+				displayed.Vulnerabilities[id] = vuln
+			}
+		}
+		images = append(images, &displayed)
+	}
+	chart.ContainsImages = images
+	return chart
+}
+
+func GenerateSingleScanSummary(chart helmscanTypes.HelmChart) string {
+	return reports.GenerateSingleScanSummary(chartVulnerabilities(chart))
+}
+
+// WriteJSONLines streams every vulnerability found in the chart's images to w, one JSON object
+// per line.
+func WriteJSONLines(w io.Writer, chartRef string, chart helmscanTypes.HelmChart) error {
+	for _, img := range chart.ContainsImages {
+		if err := reports.WriteJSONLines(w, chartRef, img.Identity(), img.ScanResult.VulnList); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SeverityCounts counts the chart's vulnerabilities the same way the single scan report does.
+func SeverityCounts(chart helmscanTypes.HelmChart) helmscanTypes.SeverityCounts {
+	var counts helmscanTypes.SeverityCounts
+	for _, vuln := range chartVulnerabilities(chart) {
+		switch strings.ToLower(vuln.Severity) {
+		case "low":
+			counts.Low++
+		case "medium":
+			counts.Medium++
+		case "high":
+			counts.High++
+		case "critical":
+			counts.Critical++
+		}
+	}
+	return counts
+}
+
+// ChartLevelUniqueCVEs returns the chart's vulnerabilities keyed by CVE ID alone, so a CVE found in
+// several images is counted once, at the highest severity any of them reports.
+func ChartLevelUniqueCVEs(chart helmscanTypes.HelmChart) map[string]helmscanTypes.Vulnerability {
 	vulns := make(map[string]helmscanTypes.Vulnerability)
 	for _, img := range chart.ContainsImages {
 		for id, v := range img.Vulnerabilities {
-			vulns[fmt.Sprintf("%s:%s", img.ImageName, id)] = v
+			if existing, ok := vulns[id]; !ok || reports.SeverityValue(v.Severity) > reports.SeverityValue(existing.Severity) {
+				vulns[id] = v
+			}
 		}
 	}
+	return vulns
+}
 
-	chartRef := fmt.Sprintf("%s/%s@%s", chart.HelmRepo, chart.Name, chart.Version)
-	return reports.GenerateSingleScanReport("helm", chartRef, vulns, jsonOutput, ignoreUnfixed)
+// VulnerabilitiesByImage lists the chart's vulnerabilities by image reference, so that one image
+// at two tags is listed twice.
+func VulnerabilitiesByImage(chart helmscanTypes.HelmChart) map[string][]helmscanTypes.Vulnerability {
+	vulnsByImage := make(map[string][]helmscanTypes.Vulnerability)
+	for _, img := range chart.ContainsImages {
+		vulnsByImage[img.Identity()] = append(vulnsByImage[img.Identity()], img.ScanResult.VulnList...)
+	}
+	return vulnsByImage
+}
+
+// VulnerabilitiesByCVE groups the chart's vulnerabilities by CVE ID and then by image identity, so
+// the same image at two tags keeps both. Baselines match images by repository, so they still match
+// after an image is bumped.
+func VulnerabilitiesByCVE(chart helmscanTypes.HelmChart) map[string]map[string]helmscanTypes.Vulnerability {
+	cves := make(map[string]map[string]helmscanTypes.Vulnerability)
+	for _, img := range chart.ContainsImages {
+		for id, v := range img.Vulnerabilities {
+			if _, exists := cves[id]; !exists {
+				cves[id] = make(map[string]helmscanTypes.Vulnerability)
+			}
+			cves[id][img.Identity()] = v
+		}
+	}
+	return cves
+}
+
+// chartVulnerabilities keys the chart's vulnerabilities as "image:CVE-ID", naming images the same
+// way as VulnerabilitiesByCVE so that baselines written from them match.
+func chartVulnerabilities(chart helmscanTypes.HelmChart) map[string]helmscanTypes.Vulnerability {
+	vulns := make(map[string]helmscanTypes.Vulnerability)
+	for _, img := range chart.ContainsImages {
+		for id, v := range img.Vulnerabilities {
+			vulns[fmt.Sprintf("%s:%s", img.Identity(), id)] = v
+		}
+	}
+	return vulns
 }
 
 func scanSingleHelmChart(chartRef string, saveReport bool, jsonOutput bool, ignoreUnfixed bool) {
@@ -327,7 +1026,7 @@ func scanSingleHelmChart(chartRef string, saveReport bool, jsonOutput bool, igno
 		return
 	}
 
-	report := GenerateSingleScanReport(result, jsonOutput, ignoreUnfixed)
+	report := GenerateSingleScanReport(result, jsonOutput, ignoreUnfixed, reports.ReportOptions{})
 
 	if saveReport {
 		ext := ".md"
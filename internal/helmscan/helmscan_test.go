@@ -0,0 +1,2349 @@
+package helmscan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cliffcolvin/helmscan/internal/fakeexec"
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+	"github.com/cliffcolvin/helmscan/internal/imageScan"
+	"github.com/cliffcolvin/helmscan/internal/reports"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// The fakes read their configuration, written by fakeTools.install, from the directory named by
+// fakeDirEnv, and log their arguments to helm.log and trivy.log in it.
+const fakeDirEnv = "HELMSCAN_FAKE_DIR"
+
+func TestMain(m *testing.M) {
+	fakeexec.Register("helm", fakeHelm)
+	fakeexec.Register("trivy", fakeTrivy)
+	fakeexec.Register("git", fakeGit)
+	fakeexec.Register("cosign", fakeCosign)
+	fakeexec.Main(m)
+}
+
+// fakeTools configures the fake helm, trivy and cosign of a test.
+type fakeTools struct {
+	// manifests holds the helm template output of each chart, keyed by chart and version as in
+	// bitnami/redis@18.1.0, or by the path of a local chart.
+	manifests map[string]string
+	// vulns holds the vulnerabilities trivy finds in each image reference. Images without an entry
+	// fail to scan.
+	vulns map[string][]fakeVuln
+	// files holds the content git show prints for each object, keyed by ref and path as in
+	// main:./Chart.yaml.
+	files map[string]string
+	// repos holds the repos helm repo list prints. When set, templating a chart of any other repo
+	// fails like helm does for a repo that is not configured.
+	repos []helmscanTypes.HelmRepo
+	// values holds the default values helm show values prints for each chart, keyed like manifests.
+	values map[string]string
+	// signed lists the image references cosign verifies. It fails to verify any other image.
+	signed []string
+}
+
+type fakeVuln struct {
+	ID       string
+	Severity string
+	PkgName  string
+}
+
+// install makes helm, trivy and cosign run as fakes for the rest of the test, from a temporary
+// working directory, and returns the directory holding their configuration and logs.
+func (f fakeTools) install(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeJSON(t, filepath.Join(dir, "manifests.json"), f.manifests)
+	reports := make(map[string]any)
+	for image, vulns := range f.vulns {
+		reports[image] = trivyReport(image, vulns)
+	}
+	writeJSON(t, filepath.Join(dir, "reports.json"), reports)
+	writeJSON(t, filepath.Join(dir, "files.json"), f.files)
+	writeJSON(t, filepath.Join(dir, "repos.json"), f.repos)
+	writeJSON(t, filepath.Join(dir, "values.json"), f.values)
+	writeJSON(t, filepath.Join(dir, "signed.json"), f.signed)
+	t.Setenv(fakeDirEnv, dir)
+
+	original := execCommand
+	execCommand = fakeexec.CommandContext
+	t.Cleanup(func() { execCommand = original })
+	// Trivy and cosign are run by the imageScan package, so their fakes are found on the PATH.
+	fakeexec.Install(t, "trivy", "cosign")
+	t.Chdir(t.TempDir())
+	return dir
+}
+
+func writeJSON(t *testing.T, path string, v any) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFakeConfig(name string, v any) error {
+	data, err := os.ReadFile(filepath.Join(os.Getenv(fakeDirEnv), name))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// trivyReport returns the Trivy JSON report of an image with vulns, in the form trivy image -f json
+// writes.
+func trivyReport(image string, vulns []fakeVuln) map[string]any {
+	var results []map[string]any
+	for _, vuln := range vulns {
+		results = append(results, map[string]any{
+			"VulnerabilityID":  vuln.ID,
+			"PkgName":          vuln.PkgName,
+			"InstalledVersion": "1.0.0",
+			"Severity":         vuln.Severity,
+		})
+	}
+	return map[string]any{
+		"SchemaVersion": 2,
+		"ArtifactName":  image,
+		"ArtifactType":  "container_image",
+		"Metadata": map[string]any{
+			"OS": map[string]string{"Family": "debian", "Name": "12.5"},
+		},
+		"Results": []map[string]any{{
+			"Target":          image + " (debian 12.5)",
+			"Class":           "os-pkgs",
+			"Type":            "debian",
+			"Vulnerabilities": results,
+		}},
+	}
+}
+
+func fakeHelm(args []string) int {
+	logCall("helm.log", args)
+	switch {
+	case len(args) >= 2 && args[0] == "repo" && args[1] == "update":
+		fmt.Println("Hang tight while we grab the latest from your chart repositories...")
+		fmt.Println("Update Complete. ⎈Happy Helming!⎈")
+		return 0
+	case len(args) >= 2 && args[0] == "repo" && args[1] == "list":
+		var repos []helmscanTypes.HelmRepo
+		if err := readFakeConfig("repos.json", &repos); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if len(repos) == 0 {
+			fmt.Fprintln(os.Stderr, "Error: no repositories to show")
+			return 1
+		}
+		json.NewEncoder(os.Stdout).Encode(repos)
+		return 0
+	case len(args) == 3 && args[0] == "dependency" && args[1] == "build":
+		// Dependencies from unreachable.example repositories cannot be downloaded.
+		chart, err := os.ReadFile(filepath.Join(args[2], "Chart.yaml"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		if strings.Contains(string(chart), "unreachable.example") {
+			fmt.Fprintln(os.Stderr, "Error: no cached repository for helm-manager found. (try 'helm repo update')")
+			return 1
+		}
+		fmt.Println("Saving 1 charts")
+		return 0
+	case len(args) >= 3 && args[0] == "search" && args[1] == "repo":
+		var manifests map[string]string
+		if err := readFakeConfig("manifests.json", &manifests); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		// Like helm, list every chart whose name contains the search term.
+		results := []map[string]string{}
+		for ref := range manifests {
+			if name, version, _ := strings.Cut(ref, "@"); strings.Contains(name, args[2]) {
+				results = append(results, map[string]string{"name": name, "version": version})
+			}
+		}
+		json.NewEncoder(os.Stdout).Encode(results)
+		return 0
+	case len(args) >= 3 && args[0] == "show" && args[1] == "values":
+		var values map[string]string
+		if err := readFakeConfig("values.json", &values); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		chart := args[2]
+		if version := fakeexec.Arg(args, "--version"); version != "" {
+			chart += "@" + version
+		}
+		chartValues, ok := values[chart]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: chart %q not found\n", chart)
+			return 1
+		}
+		fmt.Print(chartValues)
+		return 0
+	case len(args) >= 3 && args[0] == "template":
+		var manifests map[string]string
+		if err := readFakeConfig("manifests.json", &manifests); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		var repos []helmscanTypes.HelmRepo
+		if err := readFakeConfig("repos.json", &repos); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		repo, _, _ := strings.Cut(args[2], "/")
+		if len(repos) > 0 && !slices.ContainsFunc(repos, func(r helmscanTypes.HelmRepo) bool { return r.Name == repo }) {
+			fmt.Fprintf(os.Stderr, "Error: repo %s not found\n", repo)
+			return 1
+		}
+		chart := args[2]
+		if version := fakeexec.Arg(args, "--version"); version != "" {
+			chart += "@" + version
+		}
+		manifest, ok := manifests[chart]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: chart %q not found\n", chart)
+			return 1
+		}
+		fmt.Print(manifest)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "Error: unknown command %q for \"helm\"\n", args[0])
+	return 1
+}
+
+// fakeGit prints the configured files for git show and fails like git for any other object.
+func fakeGit(args []string) int {
+	logCall("git.log", args)
+	if len(args) != 2 || args[0] != "show" {
+		fmt.Fprintf(os.Stderr, "git: unexpected arguments %q\n", args)
+		return 1
+	}
+	var files map[string]string
+	if err := readFakeConfig("files.json", &files); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	content, ok := files[args[1]]
+	if !ok {
+		ref, path, _ := strings.Cut(args[1], ":")
+		fmt.Fprintf(os.Stderr, "fatal: path '%s' does not exist in '%s'\n", strings.TrimPrefix(path, "./"), ref)
+		return 128
+	}
+	fmt.Print(content)
+	return 0
+}
+
+// fakeCosign verifies the configured signed images and fails like cosign for any other image.
+func fakeCosign(args []string) int {
+	logCall("cosign.log", args)
+	var signed []string
+	if err := readFakeConfig("signed.json", &signed); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if reference := args[len(args)-1]; !slices.Contains(signed, reference) {
+		fmt.Fprintf(os.Stderr, "Error: no signatures found\nmain.go:74: error during command execution: no signatures found\n")
+		return 1
+	}
+	return 0
+}
+
+// fakeTrivy writes the configured report of the scanned image to the -o file. Config scans are
+// answered by fakeTrivyConfig.
+func fakeTrivy(args []string) int {
+	logCall("trivy.log", args)
+	if slices.Contains(args, "config") {
+		return fakeTrivyConfig(args)
+	}
+	var reports map[string]json.RawMessage
+	if err := readFakeConfig("reports.json", &reports); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	image := args[len(args)-1]
+	report, ok := reports[image]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "FATAL\tFatal error\timage scan error: unable to find the specified image %q\n", image)
+		return 1
+	}
+	if err := os.WriteFile(fakeexec.Arg(args, "-o"), report, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// fakeTrivyConfig checks the manifests in the scanned directory like trivy config, failing
+// KSV017 for privileged containers and KSV012 for containers run as root.
+func fakeTrivyConfig(args []string) int {
+	dir := args[len(args)-1]
+	checks := []struct {
+		id, title, severity, pattern string
+	}{
+		{"KSV017", "Privileged container", "HIGH", "privileged: true"},
+		{"KSV012", "Runs as root user", "MEDIUM", "runAsUser: 0"},
+	}
+	var results []map[string]any
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		manifest, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		target, _ := filepath.Rel(dir, path)
+		var misconfigs []map[string]string
+		for _, check := range checks {
+			status := "PASS"
+			if strings.Contains(string(manifest), check.pattern) {
+				status = "FAIL"
+			}
+			misconfigs = append(misconfigs, map[string]string{
+				"ID": check.id, "Title": check.title, "Severity": check.severity, "Status": status,
+				"Message": fmt.Sprintf("%s check %s", check.title, strings.ToLower(status)),
+			})
+		}
+		results = append(results, map[string]any{"Target": filepath.ToSlash(target), "Class": "config", "Type": "kubernetes", "Misconfigurations": misconfigs})
+		return nil
+	})
+	if err == nil {
+		var report []byte
+		if report, err = json.Marshal(map[string]any{"SchemaVersion": 2, "ArtifactName": dir, "ArtifactType": "filesystem", "Results": results}); err == nil {
+			err = os.WriteFile(fakeexec.Arg(args, "-o"), report, 0644)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// logCall records args in the log file name, and the proxy settings the command ran with in the
+// matching .env file.
+func logCall(name string, args []string) {
+	dir := os.Getenv(fakeDirEnv)
+	fakeexec.LogArgs(filepath.Join(dir, name), args)
+	fakeexec.LogArgs(filepath.Join(dir, strings.TrimSuffix(name, ".log")+".env"), []string{os.Getenv("HTTPS_PROXY"), os.Getenv("NO_PROXY")})
+}
+
+const redisManifest = `---
+# Source: redis/templates/master/application.yaml
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: release-name-redis-master
+spec:
+  template:
+    spec:
+      containers:
+        - name: redis
+          image: docker.io/bitnami/redis:7.2.4-debian-12-r9
+        - name: metrics
+          image: docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4
+`
+
+func TestScan(t *testing.T) {
+	dir := fakeTools{
+		manifests: map[string]string{"bitnami/redis@18.1.0": redisManifest},
+		vulns: map[string][]fakeVuln{
+			"docker.io/bitnami/redis:7.2.4-debian-12-r9": {
+				{ID: "CVE-2023-45853", Severity: "CRITICAL", PkgName: "zlib1g"},
+				{ID: "CVE-2011-3374", Severity: "LOW", PkgName: "apt"},
+			},
+			"docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4": {
+				{ID: "CVE-2023-45288", Severity: "HIGH", PkgName: "golang.org/x/net"},
+			},
+		},
+	}.install(t)
+
+	chart, err := Scan("bitnami/redis@18.1.0", false)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if chart.Name != "redis" || chart.Version != "18.1.0" || chart.HelmRepo != "bitnami" {
+		t.Errorf("Scan() chart = %s/%s@%s, want bitnami/redis@18.1.0", chart.HelmRepo, chart.Name, chart.Version)
+	}
+
+	tests := []struct {
+		identity  string
+		container string
+		vulns     map[string]string
+	}{
+		{
+			identity:  "docker.io/bitnami/redis:7.2.4-debian-12-r9",
+			container: "redis",
+			vulns:     map[string]string{"CVE-2023-45853": "critical", "CVE-2011-3374": "low"},
+		},
+		{
+			identity:  "docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4",
+			container: "metrics",
+			vulns:     map[string]string{"CVE-2023-45288": "high"},
+		},
+	}
+	if len(chart.ContainsImages) != len(tests) {
+		t.Fatalf("Scan() found %d images, want %d", len(chart.ContainsImages), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.identity, func(t *testing.T) {
+			img := chart.ContainsImages[i]
+			if img.Identity() != tt.identity {
+				t.Fatalf("image %d = %s, want %s", i, img.Identity(), tt.identity)
+			}
+			if len(img.SourceRefs) != 1 || img.SourceRefs[0].Kind != "StatefulSet" || img.SourceRefs[0].Container != tt.container {
+				t.Errorf("SourceRefs = %+v, want the %s container of the StatefulSet", img.SourceRefs, tt.container)
+			}
+			if img.OS != (helmscanTypes.OperatingSystem{Family: "debian", Name: "12.5"}) {
+				t.Errorf("OS = %+v, want debian 12.5", img.OS)
+			}
+			got := make(map[string]string)
+			for id, vuln := range img.Vulnerabilities {
+				got[id] = vuln.Severity
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.vulns) {
+				t.Errorf("Vulnerabilities = %v, want %v", got, tt.vulns)
+			}
+		})
+	}
+
+	helmCalls := fakeexec.Calls(t, filepath.Join(dir, "helm.log"))
+	wantHelm := [][]string{
+		{"repo", "update"},
+		{"template", helmscanTypes.DefaultReleaseName, "bitnami/redis", "--version", "18.1.0"},
+	}
+	if !slices.EqualFunc(helmCalls, wantHelm, slices.Equal) {
+		t.Errorf("helm calls = %v, want %v", helmCalls, wantHelm)
+	}
+	if trivyCalls := fakeexec.Calls(t, filepath.Join(dir, "trivy.log")); len(trivyCalls) != 2 {
+		t.Errorf("trivy was run %d times, want once per image", len(trivyCalls))
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+
+	chart := helmscanTypes.HelmChart{Name: "redis", Version: "18.1.0", ContainsImages: []*helmscanTypes.ContainerImage{
+		{Repository: "docker.io/bitnami", ImageName: "redis", Tag: "7.2.4-debian-12-r9"},
+	}}
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		wantErr error
+	}{
+		{name: "cancelled", ctx: cancelled, wantErr: context.Canceled},
+		{name: "deadline exceeded", ctx: expired, wantErr: context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := fakeTools{manifests: map[string]string{"bitnami/redis@18.1.0": redisManifest}}.install(t)
+
+			if _, err := ScanContext(tt.ctx, "bitnami/redis@18.1.0", helmscanTypes.ScanOptions{}); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ScanContext() error = %v, want %v", err, tt.wantErr)
+			}
+			if calls := fakeexec.Calls(t, filepath.Join(dir, "helm.log")); len(calls) != 0 {
+				t.Errorf("helm calls = %v, want none once the context has ended", calls)
+			}
+			if _, err := CompareHelmChartsContext(tt.ctx, chart, chart); !errors.Is(err, tt.wantErr) {
+				t.Errorf("CompareHelmChartsContext() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScanChartWithoutImages(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+	}{
+		{name: "empty render", manifest: ""},
+		{name: "whitespace only", manifest: "\n   \n\n"},
+		{name: "comments only", manifest: "---\n# Source: redis/templates/disabled.yaml\n"},
+		{
+			name: "resources without images",
+			manifest: `---
+# Source: redis/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: release-name-redis-configuration
+data:
+  redis.conf: "appendonly yes"
+`,
+		},
+		{
+			name: "blank image fields",
+			manifest: `---
+# Source: redis/templates/master/application.yaml
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: release-name-redis-master
+spec:
+  template:
+    spec:
+      containers:
+        - name: redis
+          image: ""
+        - name: metrics
+          image: "   "
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := fakeTools{manifests: map[string]string{"bitnami/redis@18.1.0": tt.manifest}}.install(t)
+
+			chart, err := Scan("bitnami/redis@18.1.0", false)
+			if err != nil {
+				t.Fatalf("Scan() error = %v", err)
+			}
+			if len(chart.ContainsImages) != 0 || len(chart.SkippedImages) != 0 {
+				t.Errorf("Scan() images = %v, skipped = %v, want none", chart.ContainsImages, chart.SkippedImages)
+			}
+			if calls := fakeexec.Calls(t, filepath.Join(dir, "trivy.log")); len(calls) != 0 {
+				t.Errorf("trivy calls = %v, want none", calls)
+			}
+		})
+	}
+}
+
+func TestInvalidImageReason(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{image: "docker.io/bitnami/redis:7.2.4-debian-12-r9", want: ""},
+		{image: "redis", want: ""},
+		{image: "registry.internal:5000/team/app@sha256:3127bd2ec9e8a1b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f9012345ab", want: ""},
+		{image: "REPLACE_ME", want: "placeholder value"},
+		{image: "docker.io/bitnami/redis:changeme", want: "placeholder value"},
+		{image: "<your-image>", want: "placeholder value"},
+		{image: "null", want: "placeholder value"},
+		{image: "docker.io/bitnami/{{ .Values.image }}", want: "unrendered template expression"},
+		{image: "docker.io/bitnami/redis :7.2.4", want: "contains whitespace"},
+		{image: "docker.io/bitnami/:7.2.4", want: "missing image name"},
+		{image: "docker.io/bitnami/Redis:7.2.4", want: "image names must be lowercase"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			if got := invalidImageReason(tt.image); got != tt.want {
+				t.Errorf("invalidImageReason(%q) = %q, want %q", tt.image, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanSkipsPlaceholderImages(t *testing.T) {
+	const manifest = `---
+# Source: app/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: release-name-app
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: migrate
+          image: REPLACE_ME
+      containers:
+        - name: app
+          image: docker.io/bitnami/redis:7.2.4-debian-12-r9
+        - name: sidecar
+          image: "<sidecar-image>"
+        - name: second-sidecar
+          image: "<sidecar-image>"
+`
+	dir := fakeTools{
+		manifests: map[string]string{"bitnami/app@1.0.0": manifest},
+		vulns:     map[string][]fakeVuln{"docker.io/bitnami/redis:7.2.4-debian-12-r9": nil},
+	}.install(t)
+
+	chart, err := Scan("bitnami/app@1.0.0", false)
+	if err != nil {
+		t.Fatalf("Scan() error = %v, want placeholders skipped rather than failing the scan", err)
+	}
+	if len(chart.ContainsImages) != 1 || chart.ContainsImages[0].Identity() != "docker.io/bitnami/redis:7.2.4-debian-12-r9" {
+		t.Errorf("Scan() images = %v, want only the redis image", chart.ContainsImages)
+	}
+	// Each placeholder is listed once, however many containers use it.
+	wantSkipped := []helmscanTypes.SkippedImage{
+		{Reference: "REPLACE_ME", Reason: "placeholder value"},
+		{Reference: "<sidecar-image>", Reason: "placeholder value"},
+	}
+	if !slices.Equal(chart.SkippedImages, wantSkipped) {
+		t.Errorf("Scan() skipped = %v, want %v", chart.SkippedImages, wantSkipped)
+	}
+	if calls := fakeexec.Calls(t, filepath.Join(dir, "trivy.log")); len(calls) != 1 {
+		t.Errorf("trivy was run %d times, want only for the redis image", len(calls))
+	}
+}
+
+func TestScanManifests(t *testing.T) {
+	const manifest = `---
+# Source: redis/templates/master/application.yaml
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: release-name-redis-master
+spec:
+  template:
+    spec:
+      securityContext:
+        runAsUser: 0
+      containers:
+        - name: redis
+          image: docker.io/bitnami/redis:7.2.4-debian-12-r9
+---
+# Source: redis/templates/metrics/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: release-name-redis-metrics
+spec:
+  template:
+    spec:
+      containers:
+        - name: metrics
+          image: docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4
+          securityContext:
+            privileged: true
+---
+# Source: redis/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: release-name-redis-configuration
+`
+	tests := []struct {
+		name          string
+		scanManifests bool
+		want          []helmscanTypes.Misconfiguration
+	}{
+		{name: "disabled"},
+		{
+			// Findings name the template that rendered the manifest, and passed checks are left out.
+			name:          "enabled",
+			scanManifests: true,
+			want: []helmscanTypes.Misconfiguration{
+				{Target: "redis/templates/master/application.yaml", ID: "KSV012", Title: "Runs as root user", Message: "Runs as root user check fail", Severity: "medium"},
+				{Target: "redis/templates/metrics/deployment.yaml", ID: "KSV017", Title: "Privileged container", Message: "Privileged container check fail", Severity: "high"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := fakeTools{
+				manifests: map[string]string{"bitnami/redis@18.1.0": manifest},
+				vulns: map[string][]fakeVuln{
+					"docker.io/bitnami/redis:7.2.4-debian-12-r9":           nil,
+					"docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4": nil,
+				},
+			}.install(t)
+
+			chart, err := ScanContext(context.Background(), "bitnami/redis@18.1.0", helmscanTypes.ScanOptions{ScanManifests: tt.scanManifests})
+			if err != nil {
+				t.Fatalf("ScanContext() error = %v", err)
+			}
+			got := slices.Clone(chart.ManifestMisconfigurations)
+			slices.SortFunc(got, func(a, b helmscanTypes.Misconfiguration) int { return strings.Compare(a.Target, b.Target) })
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ManifestMisconfigurations = %+v, want %+v", got, tt.want)
+			}
+
+			configScans := 0
+			for _, args := range fakeexec.Calls(t, filepath.Join(dir, "trivy.log")) {
+				if slices.Contains(args, "config") {
+					configScans++
+				}
+			}
+			if want := map[bool]int{false: 0, true: 1}[tt.scanManifests]; configScans != want {
+				t.Errorf("trivy config ran %d times, want %d", configScans, want)
+			}
+		})
+	}
+}
+
+func TestScanMaxImages(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxImages int
+		wantErr   string
+	}{
+		{name: "no limit", maxImages: 0},
+		{name: "at the limit", maxImages: 2},
+		{name: "over the limit", maxImages: 1, wantErr: "chart bitnami/redis@18.1.0 contains 2 images, more than the limit of 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := fakeTools{
+				manifests: map[string]string{"bitnami/redis@18.1.0": redisManifest},
+				vulns: map[string][]fakeVuln{
+					"docker.io/bitnami/redis:7.2.4-debian-12-r9":           nil,
+					"docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4": nil,
+				},
+			}.install(t)
+
+			chart, err := ScanContext(context.Background(), "bitnami/redis@18.1.0", helmscanTypes.ScanOptions{MaxImages: tt.maxImages})
+			trivyCalls := fakeexec.Calls(t, filepath.Join(dir, "trivy.log"))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ScanContext() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				// The limit is checked before any image is scanned.
+				if len(trivyCalls) != 0 {
+					t.Errorf("trivy calls = %v, want none", trivyCalls)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ScanContext() error = %v", err)
+			}
+			if len(chart.ContainsImages) != 2 || len(trivyCalls) != 2 {
+				t.Errorf("scanned %d images with %d trivy calls, want 2", len(chart.ContainsImages), len(trivyCalls))
+			}
+		})
+	}
+}
+
+func TestProxyIsForwarded(t *testing.T) {
+	tests := []struct {
+		name string
+		opts helmscanTypes.ScanOptions
+		want []string
+	}{
+		{
+			name: "ambient settings",
+			want: []string{"http://ambient.internal:3128", "localhost"},
+		},
+		{
+			// The flags override the environment helmscan runs in.
+			name: "proxy flags",
+			opts: helmscanTypes.ScanOptions{Proxy: "http://proxy.internal:8080", NoProxy: "registry.internal,.svc"},
+			want: []string{"http://proxy.internal:8080", "registry.internal,.svc"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := fakeTools{
+				manifests: map[string]string{"bitnami/redis@18.1.0": redisManifest},
+				vulns: map[string][]fakeVuln{
+					"docker.io/bitnami/redis:7.2.4-debian-12-r9":           nil,
+					"docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4": nil,
+				},
+			}.install(t)
+			t.Setenv("HTTPS_PROXY", "http://ambient.internal:3128")
+			t.Setenv("NO_PROXY", "localhost")
+
+			if _, err := ScanContext(context.Background(), "bitnami/redis@18.1.0", tt.opts); err != nil {
+				t.Fatalf("ScanContext() error = %v", err)
+			}
+			for _, command := range []string{"helm", "trivy"} {
+				calls := fakeexec.Calls(t, filepath.Join(dir, command+".env"))
+				if len(calls) == 0 {
+					t.Fatalf("%s was not run", command)
+				}
+				for _, env := range calls {
+					if !slices.Equal(env, tt.want) {
+						t.Errorf("%s ran with HTTPS_PROXY and NO_PROXY %q, want %q", command, env, tt.want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestScanPairContext(t *testing.T) {
+	// Both versions share the exporter image, so the concurrent scans also share its Trivy output.
+	manifests := map[string]string{
+		"bitnami/redis@18.1.0": redisManifest,
+		"bitnami/redis@18.2.0": strings.ReplaceAll(redisManifest, "7.2.4-debian-12-r9", "7.2.5-debian-12-r0"),
+	}
+	vulns := map[string][]fakeVuln{
+		"docker.io/bitnami/redis:7.2.4-debian-12-r9":           {{ID: "CVE-2023-45853", Severity: "CRITICAL", PkgName: "zlib1g"}},
+		"docker.io/bitnami/redis:7.2.5-debian-12-r0":           nil,
+		"docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4": {{ID: "CVE-2023-45288", Severity: "HIGH", PkgName: "golang.org/x/net"}},
+	}
+
+	tests := []struct {
+		name      string
+		chartRef1 string
+		chartRef2 string
+		wantErrs  []string
+	}{
+		{name: "both charts scan", chartRef1: "bitnami/redis@18.1.0", chartRef2: "bitnami/redis@18.2.0"},
+		{name: "first chart fails", chartRef1: "bitnami/redis@9.9.9", chartRef2: "bitnami/redis@18.2.0", wantErrs: []string{"error scanning first Helm chart"}},
+		{name: "second chart fails", chartRef1: "bitnami/redis@18.1.0", chartRef2: "bitnami/redis@9.9.9", wantErrs: []string{"error scanning second Helm chart"}},
+		{name: "both charts fail", chartRef1: "bitnami/redis@9.9.8", chartRef2: "bitnami/redis@9.9.9", wantErrs: []string{"error scanning first Helm chart", "error scanning second Helm chart"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := fakeTools{manifests: manifests, vulns: vulns}.install(t)
+
+			chart1, chart2, err := ScanPairContext(context.Background(), tt.chartRef1, tt.chartRef2, helmscanTypes.ScanOptions{})
+			if tt.wantErrs == nil && err != nil {
+				t.Fatalf("ScanPairContext() error = %v", err)
+			}
+			for _, want := range tt.wantErrs {
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("ScanPairContext() error = %v, want it to contain %q", err, want)
+				}
+			}
+
+			helmCalls := fakeexec.Calls(t, filepath.Join(dir, "helm.log"))
+			if len(helmCalls) != 3 || !slices.Equal(helmCalls[0], []string{"repo", "update"}) {
+				t.Fatalf("helm calls = %v, want one repo update followed by one template per chart", helmCalls)
+			}
+			var templated []string
+			for _, args := range helmCalls[1:] {
+				templated = append(templated, fakeexec.Arg(args, "--version"))
+			}
+			slices.Sort(templated)
+			wantTemplated := []string{strings.Split(tt.chartRef1, "@")[1], strings.Split(tt.chartRef2, "@")[1]}
+			slices.Sort(wantTemplated)
+			if !slices.Equal(templated, wantTemplated) {
+				t.Errorf("templated versions %v, want %v", templated, wantTemplated)
+			}
+			if tt.wantErrs != nil {
+				return
+			}
+
+			if chart1.Version != "18.1.0" || chart2.Version != "18.2.0" {
+				t.Errorf("ScanPairContext() charts = %s, %s, want 18.1.0, 18.2.0", chart1.Version, chart2.Version)
+			}
+			comparison := CompareHelmCharts(chart1, chart2)
+			if _, ok := comparison.ChangedImages["docker.io/bitnami/redis"]; !ok || len(comparison.ChangedImages) != 1 {
+				t.Errorf("ChangedImages = %v, want docker.io/bitnami/redis", slices.Sorted(maps.Keys(comparison.ChangedImages)))
+			}
+			if _, ok := comparison.UnChangedImages["docker.io/bitnami/redis-exporter"]; !ok || len(comparison.UnChangedImages) != 1 {
+				t.Errorf("UnChangedImages = %v, want docker.io/bitnami/redis-exporter", slices.Sorted(maps.Keys(comparison.UnChangedImages)))
+			}
+		})
+	}
+}
+
+func TestChartImageReference(t *testing.T) {
+	// A second redis image from another repository makes the bare name ambiguous.
+	twoRedis := redisManifest + "        - name: backup\n          image: quay.io/opstree/redis:7.0.12\n"
+	tests := []struct {
+		name     string
+		manifest string
+		image    string
+		want     string
+		wantErr  string
+	}{
+		{name: "by name", manifest: redisManifest, image: "redis", want: "docker.io/bitnami/redis:7.2.4-debian-12-r9"},
+		{name: "by repository and name", manifest: redisManifest, image: "docker.io/bitnami/redis-exporter", want: "docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4"},
+		{name: "by repository and name when the name is ambiguous", manifest: twoRedis, image: "quay.io/opstree/redis", want: "quay.io/opstree/redis:7.0.12"},
+		{name: "unknown image", manifest: redisManifest, image: "postgresql", wantErr: "chart bitnami/redis@18.1.0 has no image named postgresql"},
+		{name: "ambiguous name", manifest: twoRedis, image: "redis", wantErr: "has several images named redis (docker.io/bitnami/redis:7.2.4-debian-12-r9, quay.io/opstree/redis:7.0.12); name the repository too"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := fakeTools{manifests: map[string]string{"bitnami/redis@18.1.0": tt.manifest}}.install(t)
+
+			got, err := ChartImageReference(context.Background(), "bitnami/redis@18.1.0", tt.image, helmscanTypes.ScanOptions{})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ChartImageReference() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("ChartImageReference() error = %v", err)
+			} else if got != tt.want {
+				t.Errorf("ChartImageReference() = %q, want %q", got, tt.want)
+			}
+			// Finding the image only renders the chart.
+			if calls := fakeexec.Calls(t, filepath.Join(dir, "trivy.log")); len(calls) != 0 {
+				t.Errorf("trivy was run %d times, want none", len(calls))
+			}
+		})
+	}
+}
+
+func TestCompareChartImageWithNewerTag(t *testing.T) {
+	fakeTools{
+		manifests: map[string]string{"bitnami/redis@18.1.0": redisManifest},
+		vulns: map[string][]fakeVuln{
+			"docker.io/bitnami/redis:7.2.4-debian-12-r9": {
+				{ID: "CVE-2023-45853", Severity: "CRITICAL", PkgName: "zlib1g"},
+				{ID: "CVE-2011-3374", Severity: "LOW", PkgName: "apt"},
+			},
+			"docker.io/bitnami/redis:7.2.5-debian-12-r0": {
+				{ID: "CVE-2011-3374", Severity: "LOW", PkgName: "apt"},
+				{ID: "CVE-2024-2511", Severity: "MEDIUM", PkgName: "openssl"},
+			},
+		},
+	}.install(t)
+
+	chartImage, err := ChartImageReference(context.Background(), "bitnami/redis@18.1.0", "redis", helmscanTypes.ScanOptions{})
+	if err != nil {
+		t.Fatalf("ChartImageReference() error = %v", err)
+	}
+	before, err := imageScan.ScanImageContext(context.Background(), chartImage, helmscanTypes.ScanOptions{})
+	if err != nil {
+		t.Fatalf("scanning %s: %v", chartImage, err)
+	}
+	after, err := imageScan.ScanImageContext(context.Background(), "docker.io/bitnami/redis:7.2.5-debian-12-r0", helmscanTypes.ScanOptions{})
+	if err != nil {
+		t.Fatalf("scanning the newer tag: %v", err)
+	}
+	comparison := imageScan.CompareScans(before, after)
+
+	for _, got := range []struct {
+		change string
+		cves   map[string]map[string]helmscanTypes.Vulnerability
+		want   []string
+	}{
+		{"removed", comparison.RemovedCVEs, []string{"CVE-2023-45853"}},
+		{"added", comparison.AddedCVEs, []string{"CVE-2024-2511"}},
+		{"unchanged", comparison.UnchangedCVEs, []string{"CVE-2011-3374"}},
+	} {
+		if ids := slices.Sorted(maps.Keys(got.cves)); !slices.Equal(ids, got.want) {
+			t.Errorf("%s CVEs = %v, want %v", got.change, ids, got.want)
+		}
+	}
+}
+
+func TestScanIncludeRaw(t *testing.T) {
+	fakeTools{
+		manifests: map[string]string{
+			"bitnami/redis@18.1.0": redisManifest,
+			"bitnami/redis@18.2.0": strings.ReplaceAll(redisManifest, "7.2.4-debian-12-r9", "7.2.5-debian-12-r0"),
+		},
+		vulns: map[string][]fakeVuln{
+			"docker.io/bitnami/redis:7.2.4-debian-12-r9":           {{ID: "CVE-2023-45853", Severity: "CRITICAL", PkgName: "zlib1g"}},
+			"docker.io/bitnami/redis:7.2.5-debian-12-r0":           nil,
+			"docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4": nil,
+		},
+	}.install(t)
+	opts := helmscanTypes.ScanOptions{IncludeRaw: true}
+	before, after, err := ScanPairContext(context.Background(), "bitnami/redis@18.1.0", "bitnami/redis@18.2.0", opts)
+	if err != nil {
+		t.Fatalf("ScanPairContext() error = %v", err)
+	}
+
+	var singleScan reports.SingleScanReport
+	if err := json.Unmarshal([]byte(GenerateSingleScanReport(before, true, false, reports.ReportOptions{})), &singleScan); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		raw    map[string]string
+		images []string
+	}{
+		{
+			name:   "single scan",
+			raw:    singleScan.RawOutputs,
+			images: []string{"docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4", "docker.io/bitnami/redis:7.2.4-debian-12-r9"},
+		},
+		{
+			name:   "comparison",
+			raw:    NewHelmReportGenerator(CompareHelmCharts(before, after)).GetRawOutputs(),
+			images: []string{"docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4", "docker.io/bitnami/redis:7.2.4-debian-12-r9", "docker.io/bitnami/redis:7.2.5-debian-12-r0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := slices.Sorted(maps.Keys(tt.raw)); !slices.Equal(got, tt.images) {
+				t.Fatalf("raw outputs of %v, want %v", got, tt.images)
+			}
+			for image, path := range tt.raw {
+				if want := opts.WorkPath("raw", reports.CreateSafeFileName(image)+"_trivy.json"); path != want {
+					t.Errorf("raw output of %s = %s, want %s", image, path, want)
+				}
+				var report struct{ ArtifactName string }
+				data, err := os.ReadFile(path)
+				if err == nil {
+					err = json.Unmarshal(data, &report)
+				}
+				if err != nil || report.ArtifactName != image {
+					t.Errorf("raw output of %s is not its Trivy report: %v", image, err)
+				}
+			}
+		})
+	}
+
+	markdown := GenerateSingleScanReport(before, false, false, reports.ReportOptions{})
+	if !strings.Contains(markdown, "### Raw Trivy Output") || !strings.Contains(markdown, "| docker.io/bitnami/redis:7.2.4-debian-12-r9 | "+singleScan.RawOutputs["docker.io/bitnami/redis:7.2.4-debian-12-r9"]+" |") {
+		t.Errorf("markdown report does not list the raw outputs:\n%s", markdown)
+	}
+}
+
+func TestScanImagesConcurrently(t *testing.T) {
+	// Six images share one CVE and each has one of its own; app-3 fails to scan.
+	var manifest strings.Builder
+	manifest.WriteString("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: apps\nspec:\n  template:\n    spec:\n      containers:\n")
+	vulns := make(map[string][]fakeVuln)
+	var wantImages []string
+	for i := range 6 {
+		ref := fmt.Sprintf("docker.io/bitnami/app-%d:1.0.0", i)
+		fmt.Fprintf(&manifest, "        - name: app-%d\n          image: %s\n", i, ref)
+		if i == 3 {
+			continue
+		}
+		vulns[ref] = []fakeVuln{
+			{ID: "CVE-2023-45853", Severity: "CRITICAL", PkgName: "zlib1g"},
+			{ID: fmt.Sprintf("CVE-2024-000%d", i), Severity: "LOW", PkgName: "apt"},
+		}
+		wantImages = append(wantImages, ref)
+	}
+
+	for _, concurrency := range []int{0, 1, 3, 10} {
+		t.Run(fmt.Sprintf("concurrency %d", concurrency), func(t *testing.T) {
+			fakeTools{manifests: map[string]string{"bitnami/apps@1.0.0": manifest.String()}, vulns: vulns}.install(t)
+
+			chart, err := ScanContext(context.Background(), "bitnami/apps@1.0.0", helmscanTypes.ScanOptions{Concurrency: concurrency})
+			if err == nil || !strings.Contains(err.Error(), "error scanning image docker.io/bitnami/app-3:1.0.0") {
+				t.Fatalf("ScanContext() error = %v, want the app-3 scan to fail", err)
+			}
+			if strings.Count(err.Error(), "error scanning image") != 1 {
+				t.Errorf("ScanContext() error = %v, want only the app-3 failure", err)
+			}
+
+			// The images that scanned are returned in chart order, without a gap for app-3.
+			var got []string
+			for _, img := range chart.ContainsImages {
+				if img == nil {
+					t.Fatal("ContainsImages holds a nil image")
+				}
+				got = append(got, img.Identity())
+			}
+			if !slices.Equal(got, wantImages) {
+				t.Errorf("ContainsImages = %v, want %v", got, wantImages)
+			}
+
+			comparison := CompareHelmCharts(helmscanTypes.HelmChart{}, chart)
+			if shared := comparison.AddedCVEs["CVE-2023-45853"]; len(shared) != len(wantImages) {
+				t.Errorf("CVE-2023-45853 is added in %d images, want %d", len(shared), len(wantImages))
+			}
+			if len(comparison.AddedCVEs) != 1+len(wantImages) {
+				t.Errorf("AddedCVEs has %d CVEs, want %d", len(comparison.AddedCVEs), 1+len(wantImages))
+			}
+			if _, ok := comparison.AddedCVEs["CVE-2024-0003"]; ok {
+				t.Error("AddedCVEs holds a CVE of the image that failed to scan")
+			}
+		})
+	}
+}
+
+func TestScanRecordsOperatingSystem(t *testing.T) {
+	fakeTools{
+		manifests: map[string]string{"bitnami/redis@18.1.0": redisManifest},
+		vulns: map[string][]fakeVuln{
+			"docker.io/bitnami/redis:7.2.4-debian-12-r9":           nil,
+			"docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4": nil,
+		},
+	}.install(t)
+
+	chart, err := ScanContext(context.Background(), "bitnami/redis@18.1.0", helmscanTypes.ScanOptions{})
+	if err != nil {
+		t.Fatalf("ScanContext() error = %v", err)
+	}
+	var report reports.SingleScanReport
+	if err := json.Unmarshal([]byte(GenerateSingleScanReport(chart, true, false, reports.ReportOptions{})), &report); err != nil {
+		t.Fatalf("GenerateSingleScanReport() is not JSON: %v", err)
+	}
+	markdown := GenerateSingleScanReport(chart, false, false, reports.ReportOptions{})
+
+	want := helmscanTypes.OperatingSystem{Family: "debian", Name: "12.5"}
+	tests := []string{
+		"docker.io/bitnami/redis:7.2.4-debian-12-r9",
+		"docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4",
+	}
+	for _, image := range tests {
+		t.Run(image, func(t *testing.T) {
+			i := slices.IndexFunc(chart.ContainsImages, func(img *helmscanTypes.ContainerImage) bool { return img.Identity() == image })
+			if i < 0 {
+				t.Fatalf("chart images do not include %s", image)
+			}
+			if got := chart.ContainsImages[i].OS; got != want {
+				t.Errorf("OS = %+v, want %+v", got, want)
+			}
+			if got := report.OperatingSystems[image]; got != want {
+				t.Errorf("JSON OperatingSystems[%s] = %+v, want %+v", image, got, want)
+			}
+			if row := "| " + image + " | debian 12.5 |"; !strings.Contains(markdown, row) {
+				t.Errorf("markdown report is missing the row %q:\n%s", row, markdown)
+			}
+		})
+	}
+}
+
+func TestScanChartRenderingNothing(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		wantWarn bool
+	}{
+		{name: "no output", manifest: "", wantWarn: true},
+		{name: "only source comments", manifest: "---\n# Source: redis/templates/master/application.yaml\n", wantWarn: true},
+		{name: "resources", manifest: redisManifest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := fakeTools{
+				manifests: map[string]string{"bitnami/redis@18.1.0": tt.manifest},
+				vulns: map[string][]fakeVuln{
+					"docker.io/bitnami/redis:7.2.4-debian-12-r9":           nil,
+					"docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4": nil,
+				},
+			}.install(t)
+			core, logs := observer.New(zapcore.WarnLevel)
+			originalLogger := logger
+			logger = zap.New(core).Sugar()
+			t.Cleanup(func() { logger = originalLogger })
+
+			chart, err := ScanContext(context.Background(), "bitnami/redis@18.1.0", helmscanTypes.ScanOptions{})
+			if err != nil {
+				t.Fatalf("ScanContext() error = %v", err)
+			}
+			if chart.Name != "redis" || chart.Version != "18.1.0" || chart.HelmRepo != "bitnami" {
+				t.Errorf("chart = %s/%s@%s, want bitnami/redis@18.1.0", chart.HelmRepo, chart.Name, chart.Version)
+			}
+			warnings := logs.FilterMessageSnippet("rendered no resources").Len()
+			if tt.wantWarn {
+				if warnings != 1 {
+					t.Errorf("logged %d rendered-no-resources warnings, want 1", warnings)
+				}
+				if len(chart.ContainsImages) != 0 || len(chart.SkippedImages) != 0 {
+					t.Errorf("chart has images %v and skipped images %v, want none", chart.ContainsImages, chart.SkippedImages)
+				}
+				if calls := fakeexec.Calls(t, filepath.Join(dir, "trivy.log")); len(calls) != 0 {
+					t.Errorf("trivy was run %d times, want 0", len(calls))
+				}
+				return
+			}
+			if warnings != 0 {
+				t.Errorf("logged %d rendered-no-resources warnings, want 0", warnings)
+			}
+			if len(chart.ContainsImages) != 2 {
+				t.Errorf("chart has %d images, want 2", len(chart.ContainsImages))
+			}
+		})
+	}
+}
+
+func TestParsedImageIdentity(t *testing.T) {
+	const digest = "sha256:3127bd2ec9e8a1b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f9012345ab"
+	tests := []struct {
+		references []string
+		want       string
+	}{
+		{references: []string{"redis", "redis:latest", "library/redis", "docker.io/library/redis:latest", "index.docker.io/library/redis"}, want: "docker.io/library/redis:latest"},
+		{references: []string{"bitnami/redis:7.2.4", "docker.io/bitnami/redis:7.2.4"}, want: "docker.io/bitnami/redis:7.2.4"},
+		{references: []string{"bitnami/redis@" + digest, "docker.io/bitnami/redis:7.2.4@" + digest}, want: "docker.io/bitnami/redis@" + digest},
+		{references: []string{"registry.internal:5000/app", "registry.internal:5000/app:latest"}, want: "registry.internal:5000/app:latest"},
+		{references: []string{"registry.internal:5000/team/app:1.0"}, want: "registry.internal:5000/team/app:1.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			for _, reference := range tt.references {
+				if got := parseImageString(reference).Identity(); got != tt.want {
+					t.Errorf("Identity() of %s = %q, want %q", reference, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestImagesFromOccurrencesIdentity(t *testing.T) {
+	source := func(name string) helmscanTypes.SourceRef {
+		return helmscanTypes.SourceRef{Kind: "Deployment", Name: name}
+	}
+	tests := []struct {
+		name        string
+		occurrences []imageOccurrence
+		want        map[string][]helmscanTypes.SourceRef
+	}{
+		{
+			name: "spellings of one image",
+			occurrences: []imageOccurrence{
+				{Reference: "redis", Source: source("cache")},
+				{Reference: "docker.io/library/redis:latest", Source: source("queue")},
+			},
+			want: map[string][]helmscanTypes.SourceRef{"docker.io/library/redis:latest": {source("cache"), source("queue")}},
+		},
+		{
+			// Images sharing a name in different repositories stay apart.
+			name: "same name",
+			occurrences: []imageOccurrence{
+				{Reference: "bitnami/redis:7.2.4", Source: source("cache")},
+				{Reference: "quay.io/opstree/redis:7.2.4", Source: source("queue")},
+			},
+			want: map[string][]helmscanTypes.SourceRef{
+				"docker.io/bitnami/redis:7.2.4": {source("cache")},
+				"quay.io/opstree/redis:7.2.4":   {source("queue")},
+			},
+		},
+		{
+			name: "different tags",
+			occurrences: []imageOccurrence{
+				{Reference: "bitnami/redis:7.2.4", Source: source("cache")},
+				{Reference: "bitnami/redis:7.2.5", Source: source("queue")},
+			},
+			want: map[string][]helmscanTypes.SourceRef{
+				"docker.io/bitnami/redis:7.2.4": {source("cache")},
+				"docker.io/bitnami/redis:7.2.5": {source("queue")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images, _ := imagesFromOccurrences(tt.occurrences)
+			got := make(map[string][]helmscanTypes.SourceRef)
+			for _, img := range images {
+				got[img.Identity()] = img.SourceRefs
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("images = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanReleaseName(t *testing.T) {
+	tests := []struct {
+		name        string
+		releaseName string
+		want        string
+	}{
+		{name: "default", want: helmscanTypes.DefaultReleaseName},
+		{name: "custom", releaseName: "cache", want: "cache"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := fakeTools{
+				manifests: map[string]string{"bitnami/redis@18.1.0": redisManifest},
+				vulns: map[string][]fakeVuln{
+					"docker.io/bitnami/redis:7.2.4-debian-12-r9":           nil,
+					"docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4": nil,
+				},
+			}.install(t)
+
+			opts := helmscanTypes.ScanOptions{ReleaseName: tt.releaseName}
+			for range 2 {
+				if _, err := ScanContext(context.Background(), "bitnami/redis@18.1.0", opts); err != nil {
+					t.Fatalf("ScanContext() error = %v", err)
+				}
+			}
+
+			var templates [][]string
+			for _, args := range fakeexec.Calls(t, filepath.Join(dir, "helm.log")) {
+				if args[0] == "template" {
+					templates = append(templates, args)
+				}
+			}
+			want := []string{"template", tt.want, "bitnami/redis", "--version", "18.1.0"}
+			if len(templates) != 2 {
+				t.Fatalf("helm template was run %d times, want 2", len(templates))
+			}
+			for _, args := range templates {
+				if !slices.Equal(args, want) {
+					t.Errorf("helm template args = %v, want %v", args, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateSingleScanReportRemediation(t *testing.T) {
+	img := scannedImage("bitnami", "redis", "7.2.4", "CVE-2024-2961")
+	img.ScanResult.VulnList = []helmscanTypes.Vulnerability{
+		{ID: "CVE-2024-2961", PkgName: "libc6", InstalledVersion: "2.36-9", FixedVersion: "2.36-10", Severity: "high"},
+	}
+	chart := helmscanTypes.HelmChart{Name: "redis", Version: "18.1.0", HelmRepo: "bitnami", ContainsImages: []*helmscanTypes.ContainerImage{img}}
+	tests := []struct {
+		name        string
+		remediation bool
+		wantPlan    int
+	}{
+		{name: "off"},
+		{name: "on", remediation: true, wantPlan: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := reports.ReportOptions{Remediation: tt.remediation}
+
+			markdown := GenerateSingleScanReport(chart, false, false, opts)
+			if got := strings.Contains(markdown, "Remediation Plan"); got != tt.remediation {
+				t.Errorf("markdown report has a remediation plan: %v, want %v\n%s", got, tt.remediation, markdown)
+			}
+			var report reports.SingleScanReport
+			if err := json.Unmarshal([]byte(GenerateSingleScanReport(chart, true, false, opts)), &report); err != nil {
+				t.Fatalf("GenerateSingleScanReport() is not JSON: %v", err)
+			}
+			if len(report.Remediation) != tt.wantPlan {
+				t.Fatalf("Remediation = %+v, want %d upgrades", report.Remediation, tt.wantPlan)
+			}
+			if tt.wantPlan > 0 && report.Remediation[0].RecommendedVersion != "2.36-10" {
+				t.Errorf("RecommendedVersion = %q, want 2.36-10", report.Remediation[0].RecommendedVersion)
+			}
+		})
+	}
+}
+
+func TestGenerateSingleScanReportDisplaySeverity(t *testing.T) {
+	img := scannedImage("bitnami", "redis", "7.2.4", "CVE-2024-2961")
+	img.Vulnerabilities["CVE-2011-3374"] = helmscanTypes.Vulnerability{ID: "CVE-2011-3374", Severity: "low"}
+	chart := helmscanTypes.HelmChart{Name: "redis", Version: "18.1.0", HelmRepo: "bitnami", ContainsImages: []*helmscanTypes.ContainerImage{img}}
+	tests := []struct {
+		name            string
+		displaySeverity []string
+		want            reports.SeveritySummary
+	}{
+		{name: "all", want: reports.SeveritySummary{High: 1, Low: 1}},
+		{name: "high only", displaySeverity: []string{"high"}, want: reports.SeveritySummary{High: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var report reports.SingleScanReport
+			output := GenerateSingleScanReport(chart, true, false, reports.ReportOptions{DisplaySeverity: tt.displaySeverity})
+			if err := json.Unmarshal([]byte(output), &report); err != nil {
+				t.Fatalf("GenerateSingleScanReport() is not JSON: %v", err)
+			}
+			if report.Summary != tt.want {
+				t.Errorf("Summary = %+v, want %+v", report.Summary, tt.want)
+			}
+			// The scanned chart itself keeps every CVE for gating.
+			if len(img.Vulnerabilities) != 2 {
+				t.Errorf("image has %d CVEs after reporting, want 2", len(img.Vulnerabilities))
+			}
+		})
+	}
+}
+
+func TestVulnerabilitiesByImage(t *testing.T) {
+	image := func(repository, name, tag string, ids ...string) *helmscanTypes.ContainerImage {
+		img := scannedImage(repository, name, tag)
+		for _, id := range ids {
+			img.ScanResult.VulnList = append(img.ScanResult.VulnList, helmscanTypes.Vulnerability{ID: id, Severity: "high"})
+		}
+		return img
+	}
+	tests := []struct {
+		name   string
+		images []*helmscanTypes.ContainerImage
+		want   map[string][]string
+	}{
+		{name: "no images", want: map[string][]string{}},
+		{
+			name: "by identity",
+			images: []*helmscanTypes.ContainerImage{
+				image("bitnami", "redis", "7.2.4", "CVE-2024-2961", "CVE-2023-45853"),
+				image("", "redis", "7.2", "CVE-2011-3374"),
+				image("bitnami", "redis-exporter", "1.58.0"),
+			},
+			want: map[string][]string{
+				"docker.io/bitnami/redis:7.2.4":           {"CVE-2024-2961", "CVE-2023-45853"},
+				"docker.io/library/redis:7.2":             {"CVE-2011-3374"},
+				"docker.io/bitnami/redis-exporter:1.58.0": nil,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chart := helmscanTypes.HelmChart{Name: "redis", HelmRepo: "bitnami", ContainsImages: tt.images}
+			got := make(map[string][]string)
+			for image, vulns := range VulnerabilitiesByImage(chart) {
+				got[image] = nil
+				for _, vuln := range vulns {
+					got[image] = append(got[image], vuln.ID)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("VulnerabilitiesByImage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVulnerabilitiesByCVE(t *testing.T) {
+	// The chart runs the same image at two tags, as during a rolling upgrade.
+	const manifest = redisManifest + `        - name: redis-next
+          image: docker.io/bitnami/redis:7.2.5-debian-12-r0
+`
+	fakeTools{
+		manifests: map[string]string{"bitnami/redis@18.1.0": manifest},
+		vulns: map[string][]fakeVuln{
+			"docker.io/bitnami/redis:7.2.4-debian-12-r9": {
+				{ID: "CVE-2023-45853", Severity: "CRITICAL", PkgName: "zlib1g"},
+				{ID: "CVE-2011-3374", Severity: "LOW", PkgName: "apt"},
+			},
+			"docker.io/bitnami/redis:7.2.5-debian-12-r0": {
+				{ID: "CVE-2023-45853", Severity: "CRITICAL", PkgName: "zlib1g"},
+			},
+			"docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4": nil,
+		},
+	}.install(t)
+
+	chart, err := Scan("bitnami/redis@18.1.0", false)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	var byCVE []string
+	for id, images := range VulnerabilitiesByCVE(chart) {
+		for image := range images {
+			byCVE = append(byCVE, id+" "+image)
+		}
+	}
+	slices.Sort(byCVE)
+	flat := slices.Sorted(maps.Keys(chartVulnerabilities(chart)))
+
+	tests := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{
+			name: "VulnerabilitiesByCVE",
+			got:  byCVE,
+			want: []string{
+				"CVE-2011-3374 docker.io/bitnami/redis:7.2.4-debian-12-r9",
+				"CVE-2023-45853 docker.io/bitnami/redis:7.2.4-debian-12-r9",
+				"CVE-2023-45853 docker.io/bitnami/redis:7.2.5-debian-12-r0",
+			},
+		},
+		{
+			name: "chartVulnerabilities",
+			got:  flat,
+			want: []string{
+				"docker.io/bitnami/redis:7.2.4-debian-12-r9:CVE-2011-3374",
+				"docker.io/bitnami/redis:7.2.4-debian-12-r9:CVE-2023-45853",
+				"docker.io/bitnami/redis:7.2.5-debian-12-r0:CVE-2023-45853",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !slices.Equal(tt.got, tt.want) {
+				t.Errorf("%s() = %q, want %q", tt.name, tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchesAnyRepository(t *testing.T) {
+	tests := []struct {
+		repository string
+		pattern    string
+		want       bool
+	}{
+		// Prefixes match whole path segments.
+		{"docker.io/bitnami/redis", "docker.io/bitnami", true},
+		{"docker.io/bitnami/redis", "docker.io/bitnami/", true},
+		{"docker.io/bitnami/redis", "docker.io/bitnami/redis", true},
+		{"docker.io/bitnami/redis", "docker.io", true},
+		{"docker.io/bitnami-labs/redis", "docker.io/bitnami", false},
+		{"docker.io/bitnami/redis-exporter", "docker.io/bitnami/redis", false},
+		{"quay.io/bitnami/redis", "docker.io/bitnami", false},
+		// A * does not cross a /.
+		{"docker.io/library/nginx", "docker.io/library/*", true},
+		{"docker.io/bitnami/redis", "docker.io/*", false},
+		{"docker.io/bitnami/redis", "docker.io/*/redis", true},
+		{"docker.io/bitnami/redis-exporter", "docker.io/bitnami/redis*", true},
+		{"registry-1.internal/team/app", "registry-?.internal/team/app", true},
+		// A trailing /** matches one or more path segments.
+		{"docker.io/bitnami/redis", "docker.io/**", true},
+		{"docker.io/library/nginx", "docker.io/**", true},
+		{"docker.io", "docker.io/**", false},
+		{"docker.io.evil.com/bitnami/redis", "docker.io/**", false},
+		{"123456789012.dkr.ecr.eu-west-1.amazonaws.com/team/app", "*.dkr.ecr.*.amazonaws.com/**", true},
+		{"gcr.io/team/app", "*.dkr.ecr.*.amazonaws.com/**", false},
+		{"docker.io/bitnami/redis", "docker.io/bitnami/**", true},
+		{"docker.io/bitnami-labs/redis", "docker.io/bitnami/**", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.repository, func(t *testing.T) {
+			if got := matchesAnyRepository(tt.repository, []string{tt.pattern}); got != tt.want {
+				t.Errorf("matchesAnyRepository(%q, [%q]) = %v, want %v", tt.repository, tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterImagesByRepository(t *testing.T) {
+	images := []*helmscanTypes.ContainerImage{
+		{Repository: "docker.io/bitnami", ImageName: "redis", Tag: "7.2.4"},
+		{Repository: "docker.io/bitnami", ImageName: "redis-exporter", Tag: "1.58.0"},
+		{Repository: "docker.io/library", ImageName: "busybox", Tag: "1.36"},
+		{Repository: "quay.io/oauth2-proxy", ImageName: "oauth2-proxy", Tag: "v7.6.0"},
+		{ImageName: "nginx", Tag: "1.25"},
+	}
+	tests := []struct {
+		name      string
+		onlyRepos []string
+		skipRepos []string
+		want      []string
+	}{
+		{
+			name: "no filters",
+			want: []string{"redis", "redis-exporter", "busybox", "oauth2-proxy", "nginx"},
+		},
+		{
+			// Images without a repository are matched as Docker Hub library images.
+			name:      "skip upstream bases",
+			skipRepos: []string{"docker.io/library"},
+			want:      []string{"redis", "redis-exporter", "oauth2-proxy"},
+		},
+		{
+			name:      "only one registry",
+			onlyRepos: []string{"docker.io/**"},
+			want:      []string{"redis", "redis-exporter", "busybox", "nginx"},
+		},
+		{
+			name:      "include and exclude",
+			onlyRepos: []string{"docker.io/bitnami", "quay.io/**"},
+			skipRepos: []string{"docker.io/bitnami/*-exporter"},
+			want:      []string{"redis", "oauth2-proxy"},
+		},
+		{
+			name:      "nothing matches",
+			onlyRepos: []string{"ghcr.io"},
+			want:      []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, img := range filterImagesByRepository(images, tt.onlyRepos, tt.skipRepos) {
+				got = append(got, img.ImageName)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("filterImagesByRepository() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// scannedImage returns an image as a scan leaves it, with the vulnerabilities of ids.
+func scannedImage(repository, name, tag string, ids ...string) *helmscanTypes.ContainerImage {
+	img := &helmscanTypes.ContainerImage{Repository: repository, ImageName: name, Tag: tag, Vulnerabilities: make(map[string]helmscanTypes.Vulnerability)}
+	for _, id := range ids {
+		img.Vulnerabilities[id] = helmscanTypes.Vulnerability{ID: id, Severity: "high"}
+	}
+	return img
+}
+
+func TestCompareHelmCharts(t *testing.T) {
+	chart := func(images ...*helmscanTypes.ContainerImage) helmscanTypes.HelmChart {
+		return helmscanTypes.HelmChart{Name: "redis", HelmRepo: "bitnami", ContainsImages: images}
+	}
+	tests := []struct {
+		name          string
+		before, after helmscanTypes.HelmChart
+		wantAdded     []string
+		wantRemoved   []string
+		wantChanged   []string
+		wantUnchanged []string
+		wantAddedCVEs []string
+	}{
+		{
+			name:          "tag bump",
+			before:        chart(scannedImage("bitnami", "redis", "7.2.4", "CVE-2023-45853")),
+			after:         chart(scannedImage("bitnami", "redis", "7.2.5", "CVE-2024-0001")),
+			wantChanged:   []string{"docker.io/bitnami/redis"},
+			wantAddedCVEs: []string{"CVE-2024-0001"},
+		},
+		{
+			name: "same name from different repositories",
+			before: chart(
+				scannedImage("", "redis", "7.2", "CVE-2023-45853"),
+				scannedImage("bitnami", "redis", "7.2.4", "CVE-2023-45288"),
+			),
+			after: chart(
+				scannedImage("", "redis", "7.2", "CVE-2023-45853"),
+				scannedImage("bitnami", "redis", "7.2.5", "CVE-2023-45288"),
+			),
+			wantChanged:   []string{"docker.io/bitnami/redis"},
+			wantUnchanged: []string{"docker.io/library/redis"},
+		},
+		{
+			name: "one image at two tags",
+			before: chart(
+				scannedImage("bitnami", "redis", "6.2.14", "CVE-2023-1"),
+				scannedImage("bitnami", "redis", "7.2.4", "CVE-2023-2"),
+			),
+			after: chart(
+				scannedImage("bitnami", "redis", "7.2.4", "CVE-2023-2"),
+				scannedImage("bitnami", "redis", "7.4.0", "CVE-2024-3"),
+			),
+			wantChanged:   []string{"docker.io/bitnami/redis:7.4.0"},
+			wantUnchanged: []string{"docker.io/bitnami/redis:7.2.4"},
+			wantAddedCVEs: []string{"CVE-2024-3"},
+		},
+		{
+			name:   "second tag added",
+			before: chart(scannedImage("bitnami", "redis", "7.2.4", "CVE-2023-2")),
+			after: chart(
+				scannedImage("bitnami", "redis", "7.2.4", "CVE-2023-2"),
+				scannedImage("bitnami", "redis", "6.2.14", "CVE-2023-1"),
+			),
+			wantAdded:     []string{"docker.io/bitnami/redis:6.2.14"},
+			wantUnchanged: []string{"docker.io/bitnami/redis:7.2.4"},
+			wantAddedCVEs: []string{"CVE-2023-1"},
+		},
+		{
+			name: "second tag removed",
+			before: chart(
+				scannedImage("bitnami", "redis", "6.2.14", "CVE-2023-1"),
+				scannedImage("bitnami", "redis", "7.2.4", "CVE-2023-2"),
+			),
+			after:         chart(scannedImage("bitnami", "redis", "7.2.4", "CVE-2023-2")),
+			wantRemoved:   []string{"docker.io/bitnami/redis:6.2.14"},
+			wantUnchanged: []string{"docker.io/bitnami/redis:7.2.4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparison := CompareHelmCharts(tt.before, tt.after)
+			for _, got := range []struct {
+				field  string
+				images map[string][]*helmscanTypes.ContainerImage
+				want   []string
+			}{
+				{"AddedImages", comparison.AddedImages, tt.wantAdded},
+				{"RemovedImages", comparison.RemovedImages, tt.wantRemoved},
+				{"ChangedImages", comparison.ChangedImages, tt.wantChanged},
+				{"UnChangedImages", comparison.UnChangedImages, tt.wantUnchanged},
+			} {
+				if keys := slices.Sorted(maps.Keys(got.images)); !slices.Equal(keys, got.want) {
+					t.Errorf("%s = %v, want %v", got.field, keys, got.want)
+				}
+			}
+			if added := slices.Sorted(maps.Keys(comparison.AddedCVEs)); !slices.Equal(added, tt.wantAddedCVEs) {
+				t.Errorf("AddedCVEs = %v, want %v", added, tt.wantAddedCVEs)
+			}
+		})
+	}
+}
+
+func TestCompareHelmChartsCVEBuckets(t *testing.T) {
+	const redis, exporter = "docker.io/bitnami/redis", "docker.io/bitnami/redis-exporter"
+	chart := func(images ...*helmscanTypes.ContainerImage) helmscanTypes.HelmChart {
+		return helmscanTypes.HelmChart{Name: "redis", HelmRepo: "bitnami", ContainsImages: images}
+	}
+	type buckets map[string][]string
+	tests := []struct {
+		name          string
+		before, after helmscanTypes.HelmChart
+		wantAdded     buckets
+		wantRemoved   buckets
+		wantUnchanged buckets
+	}{
+		{
+			// The CVE is fixed in the changed image but still affects the unchanged one.
+			name: "CVE in a changed and an unchanged image",
+			before: chart(
+				scannedImage("bitnami", "redis", "7.2.4", "CVE-2023-45853"),
+				scannedImage("bitnami", "redis-exporter", "1.58.0", "CVE-2023-45853"),
+			),
+			after: chart(
+				scannedImage("bitnami", "redis", "7.2.5"),
+				scannedImage("bitnami", "redis-exporter", "1.58.0", "CVE-2023-45853"),
+			),
+			wantRemoved:   buckets{"CVE-2023-45853": {redis}},
+			wantUnchanged: buckets{"CVE-2023-45853": {exporter}},
+		},
+		{
+			name: "CVE added to a changed image and unchanged in another",
+			before: chart(
+				scannedImage("bitnami", "redis", "7.2.4"),
+				scannedImage("bitnami", "redis-exporter", "1.58.0", "CVE-2023-45853"),
+			),
+			after: chart(
+				scannedImage("bitnami", "redis", "7.2.5", "CVE-2023-45853"),
+				scannedImage("bitnami", "redis-exporter", "1.58.0", "CVE-2023-45853"),
+			),
+			wantAdded:     buckets{"CVE-2023-45853": {redis}},
+			wantUnchanged: buckets{"CVE-2023-45853": {exporter}},
+		},
+		{
+			// An unchanged image rescanned with a newer Trivy DB can gain and lose CVEs.
+			name:          "unchanged image rescanned",
+			before:        chart(scannedImage("bitnami", "redis-exporter", "1.58.0", "CVE-2023-45853", "CVE-2023-45288")),
+			after:         chart(scannedImage("bitnami", "redis-exporter", "1.58.0", "CVE-2023-45853", "CVE-2024-2961")),
+			wantAdded:     buckets{"CVE-2024-2961": {exporter}},
+			wantRemoved:   buckets{"CVE-2023-45288": {exporter}},
+			wantUnchanged: buckets{"CVE-2023-45853": {exporter}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparison := CompareHelmCharts(tt.before, tt.after)
+			classified := make(map[string]string)
+			for _, got := range []struct {
+				field string
+				cves  map[string]map[string]helmscanTypes.Vulnerability
+				want  buckets
+			}{
+				{"AddedCVEs", comparison.AddedCVEs, tt.wantAdded},
+				{"RemovedCVEs", comparison.RemovedCVEs, tt.wantRemoved},
+				{"UnchangedCVEs", comparison.UnchangedCVEs, tt.wantUnchanged},
+			} {
+				images := buckets{}
+				for id, byImage := range got.cves {
+					images[id] = slices.Sorted(maps.Keys(byImage))
+					for image := range byImage {
+						if field, exists := classified[id+" "+image]; exists {
+							t.Errorf("%s in %s is in both %s and %s", id, image, field, got.field)
+						}
+						classified[id+" "+image] = got.field
+					}
+				}
+				if got.want == nil {
+					got.want = buckets{}
+				}
+				if !reflect.DeepEqual(images, got.want) {
+					t.Errorf("%s = %v, want %v", got.field, images, got.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCompareHelmChartsByDigest(t *testing.T) {
+	const (
+		digestA = "sha256:3127bd2ec9e8a1b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f9012345ab"
+		digestB = "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	)
+	// resolved is a scanned redis image at tag, which Trivy resolved to digest.
+	resolved := func(tag, digest string) helmscanTypes.HelmChart {
+		img := scannedImage("bitnami", "redis", tag, "CVE-2023-45853")
+		img.ScanResult.RepoDigest = digest
+		return helmscanTypes.HelmChart{Name: "redis", HelmRepo: "bitnami", ContainsImages: []*helmscanTypes.ContainerImage{img}}
+	}
+	tests := []struct {
+		name        string
+		before      helmscanTypes.HelmChart
+		after       helmscanTypes.HelmChart
+		wantChanges []helmscanTypes.ImageChange
+	}{
+		{name: "same tag and digest", before: resolved("7.2", digestA), after: resolved("7.2", digestA)},
+		{
+			name:   "tag repinned",
+			before: resolved("7.2", digestA),
+			after:  resolved("7.2", digestB),
+			wantChanges: []helmscanTypes.ImageChange{{
+				ImageName: "docker.io/bitnami/redis", BeforeTag: "7.2", AfterTag: "7.2", BeforeDigest: digestA, AfterDigest: digestB,
+			}},
+		},
+		{
+			name:   "tag bump",
+			before: resolved("7.2.4", digestA),
+			after:  resolved("7.2.5", digestB),
+			wantChanges: []helmscanTypes.ImageChange{{
+				ImageName: "docker.io/bitnami/redis", BeforeTag: "7.2.4", AfterTag: "7.2.5", BeforeDigest: digestA, AfterDigest: digestB,
+			}},
+		},
+		// Without a digest on both sides, the tags decide as in a plain comparison.
+		{name: "digest unknown before", before: resolved("7.2", ""), after: resolved("7.2", digestB)},
+		{
+			name:   "digest unknown after",
+			before: resolved("7.2.4", digestA),
+			after:  resolved("7.2.5", ""),
+			wantChanges: []helmscanTypes.ImageChange{{
+				ImageName: "docker.io/bitnami/redis", BeforeTag: "7.2.4", AfterTag: "7.2.5", BeforeDigest: digestA,
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparison, err := CompareHelmChartsByDigestContext(context.Background(), tt.before, tt.after)
+			if err != nil {
+				t.Fatalf("CompareHelmChartsByDigestContext() error = %v", err)
+			}
+			if !reflect.DeepEqual(comparison.ImageChanges, tt.wantChanges) {
+				t.Errorf("ImageChanges = %+v, want %+v", comparison.ImageChanges, tt.wantChanges)
+			}
+			wantChanged := tt.wantChanges != nil
+			if _, changed := comparison.ChangedImages["docker.io/bitnami/redis"]; changed != wantChanged {
+				t.Errorf("redis is in ChangedImages = %v, want %v", changed, wantChanged)
+			}
+			if _, unchanged := comparison.UnChangedImages["docker.io/bitnami/redis"]; unchanged == wantChanged {
+				t.Errorf("redis is in UnChangedImages = %v, want %v", unchanged, !wantChanged)
+			}
+
+			// A plain comparison ignores digests and lists no image changes.
+			plain := CompareHelmCharts(tt.before, tt.after)
+			if plain.ImageChanges != nil {
+				t.Errorf("CompareHelmCharts() ImageChanges = %+v, want none", plain.ImageChanges)
+			}
+			if _, changed := plain.ChangedImages["docker.io/bitnami/redis"]; changed != (tt.before.ContainsImages[0].Tag != tt.after.ContainsImages[0].Tag) {
+				t.Errorf("CompareHelmCharts() lists redis as changed = %v, want it changed only by a tag bump", changed)
+			}
+		})
+	}
+}
+
+func TestNewBatchEntry(t *testing.T) {
+	tests := []struct {
+		name        string
+		chart       helmscanTypes.HelmChart
+		wantImages  int
+		wantSummary reports.SeveritySummary
+	}{
+		{name: "no images", chart: helmscanTypes.HelmChart{Name: "redis", Version: "18.1.0", HelmRepo: "bitnami"}},
+		{
+			// A CVE is counted once for each image it is found in.
+			name: "images",
+			chart: helmscanTypes.HelmChart{Name: "redis", Version: "18.1.0", HelmRepo: "bitnami", ContainsImages: []*helmscanTypes.ContainerImage{
+				scannedImage("bitnami", "redis", "7.2.4", "CVE-2023-45853", "CVE-2024-2961"),
+				scannedImage("bitnami", "redis-exporter", "1.58.0", "CVE-2023-45853"),
+			}},
+			wantImages:  2,
+			wantSummary: reports.SeveritySummary{High: 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := NewBatchEntry("bitnami/redis@18.1.0", tt.chart)
+			if entry.ChartRef != "bitnami/redis@18.1.0" || entry.Images != tt.wantImages || entry.Summary != tt.wantSummary {
+				t.Errorf("NewBatchEntry() = %+v, want %d images and summary %+v", entry, tt.wantImages, tt.wantSummary)
+			}
+		})
+	}
+}
+
+func TestChartLevelUniqueCVEs(t *testing.T) {
+	critical := scannedImage("bitnami", "redis-sentinel", "7.2.4", "CVE-2023-45853")
+	critical.Vulnerabilities["CVE-2023-45853"] = helmscanTypes.Vulnerability{ID: "CVE-2023-45853", Severity: "critical"}
+	tests := []struct {
+		name           string
+		images         []*helmscanTypes.ContainerImage
+		wantSeverities map[string]string
+		wantSummary    reports.SeveritySummary
+	}{
+		{name: "no images", wantSeverities: map[string]string{}},
+		{
+			name: "CVE in three images",
+			images: []*helmscanTypes.ContainerImage{
+				scannedImage("bitnami", "redis", "7.2.4", "CVE-2023-45853", "CVE-2024-2961"),
+				scannedImage("bitnami", "redis-exporter", "1.58.0", "CVE-2023-45853"),
+				scannedImage("bitnami", "os-shell", "12", "CVE-2023-45853"),
+			},
+			wantSeverities: map[string]string{"CVE-2023-45853": "high", "CVE-2024-2961": "high"},
+			wantSummary:    reports.SeveritySummary{High: 2},
+		},
+		{
+			name: "highest severity wins",
+			images: []*helmscanTypes.ContainerImage{
+				scannedImage("bitnami", "redis", "7.2.4", "CVE-2023-45853"),
+				critical,
+			},
+			wantSeverities: map[string]string{"CVE-2023-45853": "critical"},
+			wantSummary:    reports.SeveritySummary{Critical: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vulns := ChartLevelUniqueCVEs(helmscanTypes.HelmChart{ContainsImages: tt.images})
+			severities := make(map[string]string)
+			for id, vuln := range vulns {
+				severities[id] = vuln.Severity
+			}
+			if !reflect.DeepEqual(severities, tt.wantSeverities) {
+				t.Errorf("ChartLevelUniqueCVEs() severities = %v, want %v", severities, tt.wantSeverities)
+			}
+			if got := reports.CountVulnerabilities(vulns); got != tt.wantSummary {
+				t.Errorf("CountVulnerabilities() = %+v, want %+v", got, tt.wantSummary)
+			}
+		})
+	}
+}
+
+func TestGenerateSingleScanReportCountMode(t *testing.T) {
+	chart := helmscanTypes.HelmChart{Name: "redis", Version: "18.1.0", HelmRepo: "bitnami", ContainsImages: []*helmscanTypes.ContainerImage{
+		scannedImage("bitnami", "redis", "7.2.4", "CVE-2023-45853", "CVE-2024-2961"),
+		scannedImage("bitnami", "redis-exporter", "1.58.0", "CVE-2023-45853"),
+	}}
+	tests := []struct {
+		countMode   string
+		wantSummary reports.SeveritySummary
+	}{
+		{countMode: "", wantSummary: reports.SeveritySummary{High: 3}},
+		{countMode: reports.CountModeImage, wantSummary: reports.SeveritySummary{High: 3}},
+		{countMode: reports.CountModeChart, wantSummary: reports.SeveritySummary{High: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.countMode, func(t *testing.T) {
+			var report reports.SingleScanReport
+			output := GenerateSingleScanReport(chart, true, false, reports.ReportOptions{CountMode: tt.countMode})
+			if err := json.Unmarshal([]byte(output), &report); err != nil {
+				t.Fatalf("GenerateSingleScanReport() is not JSON: %v", err)
+			}
+			if report.Summary != tt.wantSummary {
+				t.Errorf("Summary = %+v, want %+v", report.Summary, tt.wantSummary)
+			}
+		})
+	}
+}
+
+func TestGenerateSingleScanReportNoImages(t *testing.T) {
+	tests := []struct {
+		name         string
+		images       []*helmscanTypes.ContainerImage
+		wantNoImages bool
+	}{
+		{name: "imageless chart", wantNoImages: true},
+		{name: "images without CVEs", images: []*helmscanTypes.ContainerImage{scannedImage("bitnami", "redis", "7.2.4")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chart := helmscanTypes.HelmChart{Name: "rbac", Version: "1.0.0", HelmRepo: "internal", ContainsImages: tt.images}
+
+			markdown := GenerateSingleScanReport(chart, false, false, reports.ReportOptions{})
+			if got := strings.Contains(markdown, "No images were found in internal/rbac@1.0.0, so no images were scanned."); got != tt.wantNoImages {
+				t.Errorf("markdown report states no images were found: %v, want %v\n%s", got, tt.wantNoImages, markdown)
+			}
+			var report reports.SingleScanReport
+			if err := json.Unmarshal([]byte(GenerateSingleScanReport(chart, true, false, reports.ReportOptions{})), &report); err != nil {
+				t.Fatalf("GenerateSingleScanReport() is not JSON: %v", err)
+			}
+			if report.NoImages != tt.wantNoImages {
+				t.Errorf("NoImages = %v, want %v", report.NoImages, tt.wantNoImages)
+			}
+		})
+	}
+}
+
+func TestScanVerifySignatures(t *testing.T) {
+	const redis, exporter = "docker.io/bitnami/redis:7.2.4-debian-12-r9", "docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4"
+	tests := []struct {
+		name           string
+		opts           helmscanTypes.ScanOptions
+		wantSignatures map[string]helmscanTypes.SignatureVerification
+		wantWarnings   int
+	}{
+		// Signatures are only checked with --verify-signatures.
+		{name: "not verified"},
+		{
+			name: "verified",
+			opts: helmscanTypes.ScanOptions{VerifySignatures: true, CosignKey: "cosign.pub"},
+			wantSignatures: map[string]helmscanTypes.SignatureVerification{
+				redis:    {Verified: true},
+				exporter: {Error: "no signatures found"},
+			},
+			wantWarnings: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := fakeTools{
+				manifests: map[string]string{"bitnami/redis@18.1.0": redisManifest},
+				vulns:     map[string][]fakeVuln{redis: nil, exporter: nil},
+				signed:    []string{redis},
+			}.install(t)
+			core, logs := observer.New(zapcore.WarnLevel)
+			original := logger
+			logger = zap.New(core).Sugar()
+			t.Cleanup(func() { logger = original })
+
+			// An unsigned image does not stop the scan.
+			chart, err := ScanContext(context.Background(), "bitnami/redis@18.1.0", tt.opts)
+			if err != nil {
+				t.Fatalf("ScanContext() error = %v", err)
+			}
+			signatures := make(map[string]helmscanTypes.SignatureVerification)
+			for _, img := range chart.ContainsImages {
+				if img.Signature != nil {
+					signatures[img.Identity()] = *img.Signature
+				}
+			}
+			if len(signatures) == 0 {
+				signatures = nil
+			}
+			if !reflect.DeepEqual(signatures, tt.wantSignatures) {
+				t.Errorf("image signatures = %+v, want %+v", signatures, tt.wantSignatures)
+			}
+			if calls := fakeexec.Calls(t, filepath.Join(dir, "cosign.log")); len(calls) != len(tt.wantSignatures) {
+				t.Errorf("cosign was run %d times, want %d", len(calls), len(tt.wantSignatures))
+			}
+			if got := logs.FilterMessageSnippet("Could not verify the signature").Len(); got != tt.wantWarnings {
+				t.Errorf("logged %d signature warnings, want %d", got, tt.wantWarnings)
+			}
+
+			var report reports.SingleScanReport
+			if err := json.Unmarshal([]byte(GenerateSingleScanReport(chart, true, false, reports.ReportOptions{})), &report); err != nil {
+				t.Fatalf("GenerateSingleScanReport() is not JSON: %v", err)
+			}
+			if !reflect.DeepEqual(report.Signatures, tt.wantSignatures) {
+				t.Errorf("JSON Signatures = %+v, want %+v", report.Signatures, tt.wantSignatures)
+			}
+			markdown := GenerateSingleScanReport(chart, false, false, reports.ReportOptions{})
+			if got := strings.Contains(markdown, "### Image Signatures"); got != (tt.wantSignatures != nil) {
+				t.Errorf("markdown report has an Image Signatures section: %v, want %v", got, tt.wantSignatures != nil)
+			}
+		})
+	}
+}
+
+func TestGenerateSingleScanReportImageKeys(t *testing.T) {
+	older := scannedImage("bitnami", "redis", "6.2.14", "CVE-2023-1")
+	older.SourceRefs = []helmscanTypes.SourceRef{{Kind: "StatefulSet", Name: "redis-replicas"}}
+	older.ScannedAs = "registry.internal/bitnami/redis:6.2.14"
+	newer := scannedImage("bitnami", "redis", "7.2.4", "CVE-2023-2")
+	newer.SourceRefs = []helmscanTypes.SourceRef{{Kind: "StatefulSet", Name: "redis-master"}}
+	newer.ScannedAs = "registry.internal/bitnami/redis:7.2.4"
+	chart := helmscanTypes.HelmChart{Name: "redis", Version: "18.1.0", HelmRepo: "bitnami", ContainsImages: []*helmscanTypes.ContainerImage{older, newer}}
+
+	var report reports.SingleScanReport
+	if err := json.Unmarshal([]byte(GenerateSingleScanReport(chart, true, false, reports.ReportOptions{})), &report); err != nil {
+		t.Fatalf("GenerateSingleScanReport() is not JSON: %v", err)
+	}
+	want := []string{"docker.io/bitnami/redis:6.2.14", "docker.io/bitnami/redis:7.2.4"}
+	for _, got := range []struct {
+		field string
+		keys  []string
+	}{
+		{"ImageSources", slices.Sorted(maps.Keys(report.ImageSources))},
+		{"MirroredImages", slices.Sorted(maps.Keys(report.MirroredImages))},
+		{"VulnerabilitiesByImage", slices.Sorted(maps.Keys(VulnerabilitiesByImage(chart)))},
+	} {
+		if !slices.Equal(got.keys, want) {
+			t.Errorf("%s keys = %v, want %v", got.field, got.keys, want)
+		}
+	}
+	if sources := report.ImageSources["docker.io/bitnami/redis:6.2.14"]; len(sources) != 1 || sources[0].Name != "redis-replicas" {
+		t.Errorf("ImageSources of the 6.2.14 image = %v, want only redis-replicas", sources)
+	}
+}
+
+func TestCompareMirroredCharts(t *testing.T) {
+	upstream := helmscanTypes.HelmChart{Name: "redis", Version: "18.1.0", HelmRepo: "bitnami", ContainsImages: []*helmscanTypes.ContainerImage{
+		scannedImage("docker.io/bitnami", "redis", "7.2.4-debian-12-r9", "CVE-2023-45853"),
+		scannedImage("docker.io/bitnami", "redis-exporter", "1.58.0-debian-12-r4", "CVE-2023-45288"),
+	}}
+	mirror := helmscanTypes.HelmChart{Name: "redis", Version: "18.1.0", HelmRepo: "internal", ContainsImages: []*helmscanTypes.ContainerImage{
+		scannedImage("registry.internal/bitnami", "redis", "7.2.4-debian-12-r9", "CVE-2023-45853"),
+		scannedImage("registry.internal/bitnami", "redis-exporter", "1.58.0-debian-12-r4", "CVE-2023-45288"),
+	}}
+	otherVersion := mirror
+	otherVersion.Version = "18.2.0"
+
+	tests := []struct {
+		name          string
+		mirror        helmscanTypes.HelmChart
+		wantErr       string
+		wantUnchanged []string
+		wantChanges   []helmscanTypes.RepositoryChange
+	}{
+		{
+			name:          "same images from another registry",
+			mirror:        mirror,
+			wantUnchanged: []string{"redis", "redis-exporter"},
+			wantChanges: []helmscanTypes.RepositoryChange{
+				{ImageName: "redis", BeforeRepository: "docker.io/bitnami", AfterRepository: "registry.internal/bitnami"},
+				{ImageName: "redis-exporter", BeforeRepository: "docker.io/bitnami", AfterRepository: "registry.internal/bitnami"},
+			},
+		},
+		{
+			name:    "different version",
+			mirror:  otherVersion,
+			wantErr: "mirror comparison requires the same chart and version",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparison, err := CompareMirroredChartsContext(context.Background(), upstream, tt.mirror)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("CompareMirroredChartsContext() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CompareMirroredChartsContext() error = %v", err)
+			}
+			if len(comparison.AddedImages) != 0 || len(comparison.RemovedImages) != 0 || len(comparison.ChangedImages) != 0 {
+				t.Errorf("added %v, removed %v, changed %v, want every image unchanged", comparison.AddedImages, comparison.RemovedImages, comparison.ChangedImages)
+			}
+			if unchanged := slices.Sorted(maps.Keys(comparison.UnChangedImages)); !slices.Equal(unchanged, tt.wantUnchanged) {
+				t.Errorf("UnChangedImages = %v, want %v", unchanged, tt.wantUnchanged)
+			}
+			if len(comparison.AddedCVEs) != 0 || len(comparison.RemovedCVEs) != 0 || len(comparison.UnchangedCVEs) != 2 {
+				t.Errorf("added CVEs %v, removed CVEs %v, want the 2 CVEs unchanged", comparison.AddedCVEs, comparison.RemovedCVEs)
+			}
+			if !slices.Equal(comparison.RepositoryChanges, tt.wantChanges) {
+				t.Errorf("RepositoryChanges = %v, want %v", comparison.RepositoryChanges, tt.wantChanges)
+			}
+		})
+	}
+}
+
+func TestSeverityCounts(t *testing.T) {
+	// scannedImage reports every CVE as high; these are overridden.
+	severities := map[string]string{"CVE-2023-45853": "CRITICAL", "CVE-2011-3374": "low"}
+	tests := []struct {
+		name   string
+		images []*helmscanTypes.ContainerImage
+		want   helmscanTypes.SeverityCounts
+	}{
+		{name: "no images"},
+		{
+			name: "one image",
+			images: []*helmscanTypes.ContainerImage{
+				scannedImage("docker.io/bitnami", "redis", "7.2.4", "CVE-2023-45853", "CVE-2024-6387"),
+			},
+			want: helmscanTypes.SeverityCounts{Critical: 1, High: 1},
+		},
+		{
+			// A CVE counts once per image it is found in, as in the single scan report.
+			name: "CVE in two images",
+			images: []*helmscanTypes.ContainerImage{
+				scannedImage("docker.io/bitnami", "redis", "7.2.4", "CVE-2023-45853"),
+				scannedImage("docker.io/bitnami", "redis-exporter", "1.58.0", "CVE-2023-45853", "CVE-2011-3374"),
+			},
+			want: helmscanTypes.SeverityCounts{Critical: 2, Low: 1},
+		},
+		{
+			name: "same image twice",
+			images: []*helmscanTypes.ContainerImage{
+				scannedImage("docker.io/bitnami", "redis", "7.2.4", "CVE-2023-45853"),
+				scannedImage("docker.io/bitnami", "redis", "7.2.4", "CVE-2023-45853"),
+			},
+			want: helmscanTypes.SeverityCounts{Critical: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, img := range tt.images {
+				for id, vuln := range img.Vulnerabilities {
+					if severity, ok := severities[id]; ok {
+						vuln.Severity = severity
+						img.Vulnerabilities[id] = vuln
+					}
+				}
+			}
+			got := SeverityCounts(helmscanTypes.HelmChart{ContainsImages: tt.images})
+			if got != tt.want {
+				t.Errorf("SeverityCounts() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteJSONLines(t *testing.T) {
+	fakeTools{
+		manifests: map[string]string{"bitnami/redis@18.1.0": redisManifest},
+		vulns: map[string][]fakeVuln{
+			"docker.io/bitnami/redis:7.2.4-debian-12-r9": {
+				{ID: "CVE-2023-45853", Severity: "CRITICAL", PkgName: "zlib1g"},
+				{ID: "CVE-2011-3374", Severity: "LOW", PkgName: "apt"},
+			},
+			"docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4": {
+				{ID: "CVE-2023-45288", Severity: "HIGH", PkgName: "golang.org/x/net"},
+			},
+		},
+	}.install(t)
+	chart, err := Scan("bitnami/redis@18.1.0", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf strings.Builder
+	if err := WriteJSONLines(&buf, "bitnami/redis@18.1.0", chart); err != nil {
+		t.Fatalf("WriteJSONLines() error = %v", err)
+	}
+	var got []string
+	for i, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		var finding reports.JSONLine
+		if err := json.Unmarshal([]byte(line), &finding); err != nil {
+			t.Fatalf("line %d is not valid JSON on its own: %v\n%s", i, err, line)
+		}
+		if finding.Chart != "bitnami/redis@18.1.0" {
+			t.Errorf("line %d chart = %q, want bitnami/redis@18.1.0", i, finding.Chart)
+		}
+		got = append(got, fmt.Sprintf("%s %s %s %s", finding.Image, finding.ID, finding.Severity, finding.PkgName))
+	}
+	slices.Sort(got)
+	want := []string{
+		"docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4 CVE-2023-45288 high golang.org/x/net",
+		"docker.io/bitnami/redis:7.2.4-debian-12-r9 CVE-2011-3374 low apt",
+		"docker.io/bitnami/redis:7.2.4-debian-12-r9 CVE-2023-45853 critical zlib1g",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("WriteJSONLines() findings = %v, want %v", got, want)
+	}
+}
+
+func TestScanLatestVersion(t *testing.T) {
+	const imageManifest = `---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: redis
+spec:
+  containers:
+    - name: redis
+      image: docker.io/bitnami/redis:7.2.4
+`
+	released := map[string]string{
+		"bitnami/redis@18.1.0":         imageManifest,
+		"bitnami/redis@18.10.0":        imageManifest,
+		"bitnami/redis@19.0.0-rc.1":    imageManifest,
+		"bitnami/redis-cluster@20.0.0": imageManifest,
+	}
+	tests := []struct {
+		name        string
+		manifests   map[string]string
+		chartRef    string
+		wantVersion string
+		wantSearch  bool
+		wantErr     string
+	}{
+		{
+			// The newest stable version, compared as semver, of this chart and not of others
+			// whose name contains it.
+			name:        "latest",
+			manifests:   released,
+			chartRef:    "bitnami/redis@latest",
+			wantVersion: "18.10.0",
+			wantSearch:  true,
+		},
+		{
+			name:        "star",
+			manifests:   released,
+			chartRef:    "bitnami/redis@*",
+			wantVersion: "18.10.0",
+			wantSearch:  true,
+		},
+		{
+			name:        "exact version",
+			manifests:   released,
+			chartRef:    "bitnami/redis@18.1.0",
+			wantVersion: "18.1.0",
+		},
+		{
+			name:       "only pre-releases",
+			manifests:  map[string]string{"bitnami/redis@19.0.0-rc.1": imageManifest},
+			chartRef:   "bitnami/redis@latest",
+			wantSearch: true,
+			wantErr:    "no released versions found for bitnami/redis",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := fakeTools{
+				manifests: tt.manifests,
+				vulns:     map[string][]fakeVuln{"docker.io/bitnami/redis:7.2.4": nil},
+			}.install(t)
+
+			chart, err := Scan(tt.chartRef, false)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Scan() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("Scan() error = %v", err)
+			}
+			if chart.Version != tt.wantVersion {
+				t.Errorf("Scan() version = %q, want %q", chart.Version, tt.wantVersion)
+			}
+
+			var searched bool
+			var templated []string
+			for _, args := range fakeexec.Calls(t, filepath.Join(dir, "helm.log")) {
+				switch args[0] {
+				case "search":
+					searched = true
+				case "template":
+					templated = append(templated, fakeexec.Arg(args, "--version"))
+				}
+			}
+			if searched != tt.wantSearch {
+				t.Errorf("helm search was run = %v, want %v", searched, tt.wantSearch)
+			}
+			if wantTemplated := []string{tt.wantVersion}; tt.wantErr == "" && !slices.Equal(templated, wantTemplated) {
+				t.Errorf("helm template versions = %v, want %v", templated, wantTemplated)
+			}
+		})
+	}
+}
+
+func TestScanRegistryMirror(t *testing.T) {
+	// Only the mirror serves the redis image; the exporter is not mirrored.
+	dir := fakeTools{
+		manifests: map[string]string{"bitnami/redis@18.1.0": redisManifest},
+		vulns: map[string][]fakeVuln{
+			"mirror.internal/bitnami/redis:7.2.4-debian-12-r9": {
+				{ID: "CVE-2023-45853", Severity: "CRITICAL", PkgName: "zlib1g"},
+			},
+			"docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4": nil,
+		},
+	}.install(t)
+	opts := helmscanTypes.ScanOptions{RegistryMirrors: []helmscanTypes.RegistryMirror{
+		{From: "docker.io/bitnami/redis", To: "mirror.internal/bitnami/redis"},
+	}}
+
+	chart, err := ScanContext(context.Background(), "bitnami/redis@18.1.0", opts)
+	if err != nil {
+		t.Fatalf("ScanContext() error = %v", err)
+	}
+
+	var scanned []string
+	for _, args := range fakeexec.Calls(t, filepath.Join(dir, "trivy.log")) {
+		scanned = append(scanned, args[len(args)-1])
+	}
+	slices.Sort(scanned)
+	wantScanned := []string{"docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4", "mirror.internal/bitnami/redis:7.2.4-debian-12-r9"}
+	if !slices.Equal(scanned, wantScanned) {
+		t.Errorf("trivy scanned %v, want %v", scanned, wantScanned)
+	}
+
+	tests := []struct {
+		identity      string
+		wantScannedAs string
+	}{
+		{identity: "docker.io/bitnami/redis:7.2.4-debian-12-r9", wantScannedAs: "mirror.internal/bitnami/redis:7.2.4-debian-12-r9"},
+		{identity: "docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.identity, func(t *testing.T) {
+			img := chart.ContainsImages[i]
+			// Results keep the chart's own reference.
+			if img.Identity() != tt.identity || img.ScanResult.Image != tt.identity {
+				t.Errorf("image = %s scanned as %s, want %s", img.Identity(), img.ScanResult.Image, tt.identity)
+			}
+			if img.ScannedAs != tt.wantScannedAs {
+				t.Errorf("ScannedAs = %q, want %q", img.ScannedAs, tt.wantScannedAs)
+			}
+		})
+	}
+
+	var report reports.SingleScanReport
+	if err := json.Unmarshal([]byte(GenerateSingleScanReport(chart, true, false, reports.ReportOptions{})), &report); err != nil {
+		t.Fatal(err)
+	}
+	wantMirrored := map[string]string{"docker.io/bitnami/redis:7.2.4-debian-12-r9": "mirror.internal/bitnami/redis:7.2.4-debian-12-r9"}
+	if !maps.Equal(report.MirroredImages, wantMirrored) {
+		t.Errorf("MirroredImages = %v, want %v", report.MirroredImages, wantMirrored)
+	}
+	markdown := GenerateSingleScanReport(chart, false, false, reports.ReportOptions{})
+	if row := "| docker.io/bitnami/redis:7.2.4-debian-12-r9 | mirror.internal/bitnami/redis:7.2.4-debian-12-r9 |"; !strings.Contains(markdown, "### Registry Mirrors") || !strings.Contains(markdown, row) {
+		t.Errorf("markdown report is missing the Registry Mirrors row %q", row)
+	}
+}
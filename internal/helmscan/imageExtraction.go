@@ -0,0 +1,230 @@
+package helmscan
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+	"gopkg.in/yaml.v3"
+)
+
+type imageOccurrence struct {
+	Reference string                  `json:"reference"`
+	Source    helmscanTypes.SourceRef `json:"source"`
+}
+
+// imageExtractionDebug is the file --image-extraction-debug writes for each rendered chart.
+type imageExtractionDebug struct {
+	Chart       string                          `json:"chart"`
+	Version     string                          `json:"version"`
+	HelmOutput  string                          `json:"helm_output"`
+	Occurrences []imageOccurrence               `json:"occurrences"`
+	Images      []*helmscanTypes.ContainerImage `json:"images"`
+	Skipped     []helmscanTypes.SkippedImage    `json:"skipped"`
+}
+
+// writeImageExtractionDebug saves every image reference found in the helm output, before parsing
+// and deduplication, along with the images parsed from them and the references skipped as invalid.
+// It returns the path written.
+func writeImageExtractionDebug(opts helmscanTypes.ScanOptions, fileName string, debug imageExtractionDebug) (string, error) {
+	dir := opts.WorkPath("tmp", "image_extraction")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("error creating %s directory: %w", dir, err)
+	}
+	data, err := json.MarshalIndent(debug, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error encoding image extraction output: %w", err)
+	}
+	path := opts.WorkPath("tmp", "image_extraction", fileName)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("error saving image extraction output: %w", err)
+	}
+	return path, nil
+}
+
+// annotationKeyPattern compiles the annotation and label keys images are read from, or returns nil
+// when opts.ScanAnnotations is off.
+func annotationKeyPattern(opts helmscanTypes.ScanOptions) (*regexp.Regexp, error) {
+	if !opts.ScanAnnotations {
+		return nil, nil
+	}
+	pattern := opts.AnnotationKeys
+	if pattern == "" {
+		pattern = helmscanTypes.DefaultAnnotationKeys
+	}
+	annotationKeys, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid annotation key pattern: %w", err)
+	}
+	return annotationKeys, nil
+}
+
+// findImageReferences returns every image field of the rendered manifests and, when annotationKeys
+// is set, the image references held by metadata annotations and labels whose keys match it.
+func findImageReferences(yamlData []byte, annotationKeys *regexp.Regexp) ([]imageOccurrence, error) {
+	var occurrences []imageOccurrence
+	decoder := yaml.NewDecoder(bytes.NewReader(yamlData))
+	for {
+		var document yaml.Node
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing rendered manifests: %w", err)
+		}
+		if len(document.Content) == 0 {
+			continue
+		}
+
+		root := document.Content[0]
+		kind := scalarField(root, "kind")
+		name := scalarField(mappingField(root, "metadata"), "name")
+		walkImageFields(root, "", annotationKeys, func(path string, container string, reference string) {
+			occurrences = append(occurrences, imageOccurrence{
+				Reference: reference,
+				Source: helmscanTypes.SourceRef{
+					Kind:      kind,
+					Name:      name,
+					Container: container,
+					Path:      path,
+				},
+			})
+		})
+	}
+	return occurrences, nil
+}
+
+// hasRenderedResources reports whether yamlData holds at least one resource, as opposed to no
+// documents or only empty and comment-only ones.
+func hasRenderedResources(yamlData []byte) bool {
+	decoder := yaml.NewDecoder(bytes.NewReader(yamlData))
+	for {
+		var document yaml.Node
+		if err := decoder.Decode(&document); err != nil {
+			// A parse error is left for image extraction to report.
+			return !errors.Is(err, io.EOF)
+		}
+		if len(document.Content) > 0 && document.Content[0].Kind == yaml.MappingNode {
+			return true
+		}
+	}
+}
+
+// walkImageFields visits every image field below node. Every sequence element is
+// walked, so all containers, initContainers and ephemeralContainers of a pod are
+// reported, and an image shared by several containers keeps one source per container.
+func walkImageFields(node *yaml.Node, path string, annotationKeys *regexp.Regexp, visit func(path string, container string, reference string)) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		if reference := splitImageReference(node); reference != "" {
+			visit(path, "", reference)
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i].Value, node.Content[i+1]
+			fieldPath := key
+			if path != "" {
+				fieldPath = path + "." + key
+			}
+			if key == "image" && value.Kind == yaml.ScalarNode && value.Tag != "!!null" {
+				visit(fieldPath, scalarField(node, "name"), value.Value)
+				continue
+			}
+			if key == "image" {
+				if reference := splitImageReference(value); reference != "" {
+					visit(fieldPath, scalarField(node, "name"), reference)
+					continue
+				}
+			}
+			if annotationKeys != nil && isMetadataMap(path, key) && value.Kind == yaml.MappingNode {
+				walkAnnotationImages(value, fieldPath, annotationKeys, visit)
+				continue
+			}
+			walkImageFields(value, fieldPath, annotationKeys, visit)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			walkImageFields(item, fmt.Sprintf("%s[%d]", path, i), annotationKeys, visit)
+		}
+	}
+}
+
+func isMetadataMap(path, key string) bool {
+	return (key == "annotations" || key == "labels") && (path == "metadata" || strings.HasSuffix(path, ".metadata"))
+}
+
+// walkAnnotationImages visits the values of the annotations or labels in node whose keys match
+// annotationKeys. Values may list several images separated by commas. Only values with a tag or
+// digest count as images, so annotations that merely mention an image name are passed over.
+func walkAnnotationImages(node *yaml.Node, path string, annotationKeys *regexp.Regexp, visit func(path string, container string, reference string)) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		if value.Kind != yaml.ScalarNode || !annotationKeys.MatchString(key) {
+			continue
+		}
+		for _, reference := range strings.Split(value.Value, ",") {
+			reference = strings.TrimSpace(reference)
+			if looksLikeImageReference(reference) {
+				visit(path+"."+key, "", reference)
+			}
+		}
+	}
+}
+
+func looksLikeImageReference(value string) bool {
+	if value == "" || invalidImageReason(value) != "" {
+		return false
+	}
+	reference, digest, _ := strings.Cut(value, "@")
+	return digest != "" || strings.LastIndex(reference, ":") > strings.LastIndex(reference, "/")
+}
+
+// splitImageReference rebuilds an image reference from the registry/repository/tag
+// field groups that Bitnami-style charts render in place of a single image string.
+// A repository without a tag or digest is not treated as an image.
+func splitImageReference(node *yaml.Node) string {
+	repository := scalarField(node, "repository")
+	tag := scalarField(node, "tag")
+	digest := scalarField(node, "digest")
+	if repository == "" || (tag == "" && digest == "") {
+		return ""
+	}
+	reference := repository
+	if registry := scalarField(node, "registry"); registry != "" {
+		reference = strings.TrimSuffix(registry, "/") + "/" + strings.TrimPrefix(repository, "/")
+	}
+	if tag != "" {
+		reference += ":" + tag
+	}
+	if digest != "" {
+		reference += "@" + digest
+	}
+	return reference
+}
+
+func mappingField(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func scalarField(node *yaml.Node, key string) string {
+	value := mappingField(node, key)
+	if value == nil || value.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return value.Value
+}
@@ -0,0 +1,397 @@
+package helmscan
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func TestFindImageReferences(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     []imageOccurrence
+	}{
+		{
+			name: "deployment containers",
+			manifest: `---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: frontend
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: migrate
+          image: docker.io/bitnami/os-shell:12-debian-12-r16
+      containers:
+        - name: nginx
+          image: nginx:1.25
+`,
+			want: []imageOccurrence{
+				{Reference: "docker.io/bitnami/os-shell:12-debian-12-r16", Source: helmscanTypes.SourceRef{Kind: "Deployment", Name: "frontend", Container: "migrate", Path: "spec.template.spec.initContainers[0].image"}},
+				{Reference: "nginx:1.25", Source: helmscanTypes.SourceRef{Kind: "Deployment", Name: "frontend", Container: "nginx", Path: "spec.template.spec.containers[0].image"}},
+			},
+		},
+		{
+			name: "nested pod template",
+			manifest: `apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - name: pg-dump
+              image: docker.io/bitnami/postgresql:16.2.0
+`,
+			want: []imageOccurrence{
+				{Reference: "docker.io/bitnami/postgresql:16.2.0", Source: helmscanTypes.SourceRef{Kind: "CronJob", Name: "backup", Container: "pg-dump", Path: "spec.jobTemplate.spec.template.spec.containers[0].image"}},
+			},
+		},
+		{
+			// An image used by several resources and containers keeps a source for each of them.
+			name: "shared image",
+			manifest: `apiVersion: v1
+kind: Pod
+metadata:
+  name: debug
+spec:
+  containers:
+    - name: first
+      image: busybox:1.36
+    - name: second
+      image: busybox:1.36
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: node-agent
+spec:
+  template:
+    spec:
+      containers:
+        - name: agent
+          image: busybox:1.36
+`,
+			want: []imageOccurrence{
+				{Reference: "busybox:1.36", Source: helmscanTypes.SourceRef{Kind: "Pod", Name: "debug", Container: "first", Path: "spec.containers[0].image"}},
+				{Reference: "busybox:1.36", Source: helmscanTypes.SourceRef{Kind: "Pod", Name: "debug", Container: "second", Path: "spec.containers[1].image"}},
+				{Reference: "busybox:1.36", Source: helmscanTypes.SourceRef{Kind: "DaemonSet", Name: "node-agent", Container: "agent", Path: "spec.template.spec.containers[0].image"}},
+			},
+		},
+		{
+			// Every container is collected, including repeats of an image with another pull policy.
+			name: "multi-container pod",
+			manifest: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: wait-for-db
+          image: docker.io/bitnami/os-shell:12-debian-12-r16
+      containers:
+        - name: api
+          image: ghcr.io/example/api:2.3.1
+          imagePullPolicy: IfNotPresent
+        - name: worker
+          image: ghcr.io/example/api:2.3.1
+          imagePullPolicy: Always
+        - name: proxy
+          image: docker.io/envoyproxy/envoy:v1.29.2
+      ephemeralContainers:
+        - name: debugger
+          image: busybox:1.36
+`,
+			want: []imageOccurrence{
+				{Reference: "docker.io/bitnami/os-shell:12-debian-12-r16", Source: helmscanTypes.SourceRef{Kind: "Deployment", Name: "api", Container: "wait-for-db", Path: "spec.template.spec.initContainers[0].image"}},
+				{Reference: "ghcr.io/example/api:2.3.1", Source: helmscanTypes.SourceRef{Kind: "Deployment", Name: "api", Container: "api", Path: "spec.template.spec.containers[0].image"}},
+				{Reference: "ghcr.io/example/api:2.3.1", Source: helmscanTypes.SourceRef{Kind: "Deployment", Name: "api", Container: "worker", Path: "spec.template.spec.containers[1].image"}},
+				{Reference: "docker.io/envoyproxy/envoy:v1.29.2", Source: helmscanTypes.SourceRef{Kind: "Deployment", Name: "api", Container: "proxy", Path: "spec.template.spec.containers[2].image"}},
+				{Reference: "busybox:1.36", Source: helmscanTypes.SourceRef{Kind: "Deployment", Name: "api", Container: "debugger", Path: "spec.template.spec.ephemeralContainers[0].image"}},
+			},
+		},
+		{
+			// Bitnami-style charts can render an image as registry, repository and tag fields.
+			name: "split image fields",
+			manifest: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: redis
+spec:
+  template:
+    spec:
+      containers:
+        - name: redis
+          image:
+            registry: docker.io/
+            repository: bitnami/redis
+            tag: 7.2.4-debian-12-r9
+        - name: metrics
+          image:
+            repository: bitnami/redis-exporter
+            digest: sha256:4f1a2b3c
+`,
+			want: []imageOccurrence{
+				{Reference: "docker.io/bitnami/redis:7.2.4-debian-12-r9", Source: helmscanTypes.SourceRef{Kind: "Deployment", Name: "redis", Container: "redis", Path: "spec.template.spec.containers[0].image"}},
+				{Reference: "bitnami/redis-exporter@sha256:4f1a2b3c", Source: helmscanTypes.SourceRef{Kind: "Deployment", Name: "redis", Container: "metrics", Path: "spec.template.spec.containers[1].image"}},
+			},
+		},
+		{
+			// Custom resources may carry the field group without an image key.
+			name: "bare image field group",
+			manifest: `apiVersion: example.com/v1
+kind: RedisCluster
+metadata:
+  name: cache
+spec:
+  exporter:
+    registry: quay.io
+    repository: oliver006/redis_exporter
+    tag: v1.58.0
+`,
+			want: []imageOccurrence{
+				{Reference: "quay.io/oliver006/redis_exporter:v1.58.0", Source: helmscanTypes.SourceRef{Kind: "RedisCluster", Name: "cache", Path: "spec.exporter"}},
+			},
+		},
+		{
+			// A repository alone, like a Git or Helm repository setting, is not an image.
+			name: "repository without tag",
+			manifest: `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: web
+spec:
+  source:
+    repository: https://github.com/example/web
+    path: charts/web
+`,
+		},
+		{
+			name: "no images",
+			manifest: `apiVersion: v1
+kind: Service
+metadata:
+  name: frontend
+spec:
+  ports:
+    - port: 80
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findImageReferences([]byte(tt.manifest), nil)
+			if err != nil {
+				t.Fatalf("findImageReferences() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findImageReferences() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasRenderedResources(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     bool
+	}{
+		{name: "no output", manifest: "", want: false},
+		{name: "whitespace", manifest: "\n\n", want: false},
+		{name: "empty documents", manifest: "---\n---\n", want: false},
+		{
+			// helm template prints a source comment for every template, even when it renders nothing.
+			name:     "comment-only documents",
+			manifest: "---\n# Source: web/templates/deployment.yaml\n---\n# Source: web/templates/service.yaml\n",
+			want:     false,
+		},
+		{name: "resource", manifest: "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n", want: true},
+		{
+			name:     "resource after empty documents",
+			manifest: "---\n# Source: web/templates/ingress.yaml\n---\n# Source: web/templates/configmap.yaml\napiVersion: v1\nkind: ConfigMap\n",
+			want:     true,
+		},
+		{name: "scalar document", manifest: "--- just text\n", want: false},
+		// Invalid YAML is not reported as empty, so extraction reports the parse error.
+		{name: "invalid YAML", manifest: "kind: [ConfigMap\n", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasRenderedResources([]byte(tt.manifest)); got != tt.want {
+				t.Errorf("hasRenderedResources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindAnnotationImageReferences(t *testing.T) {
+	const manifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: operator
+  annotations:
+    app.kubernetes.io/related-image: "quay.io/example/agent:2.1.0, quay.io/example/sidecar@sha256:4d0a4b1c8f4d8b0d6f5a2c3e9b7a1f0e2d3c4b5a69788796a5b4c3d2e1f0a9b8"
+    containerImage: quay.io/example/operator:v1.4.0
+    description: built from quay.io/example/operator
+  labels:
+    related-image-name: agent
+spec:
+  template:
+    metadata:
+      annotations:
+        relatedImage: docker.io/bitnami/os-shell:12-debian-12-r16
+    spec:
+      containers:
+        - name: operator
+          image: quay.io/example/operator:v1.4.0
+`
+	container := imageOccurrence{Reference: "quay.io/example/operator:v1.4.0", Source: helmscanTypes.SourceRef{Kind: "Deployment", Name: "operator", Container: "operator", Path: "spec.template.spec.containers[0].image"}}
+	annotation := func(reference, path string) imageOccurrence {
+		return imageOccurrence{Reference: reference, Source: helmscanTypes.SourceRef{Kind: "Deployment", Name: "operator", Path: path}}
+	}
+	tests := []struct {
+		name string
+		opts helmscanTypes.ScanOptions
+		want []imageOccurrence
+	}{
+		{name: "off by default", want: []imageOccurrence{container}},
+		{
+			name: "default keys",
+			opts: helmscanTypes.ScanOptions{ScanAnnotations: true},
+			want: []imageOccurrence{
+				annotation("quay.io/example/agent:2.1.0", "metadata.annotations.app.kubernetes.io/related-image"),
+				annotation("quay.io/example/sidecar@sha256:4d0a4b1c8f4d8b0d6f5a2c3e9b7a1f0e2d3c4b5a69788796a5b4c3d2e1f0a9b8", "metadata.annotations.app.kubernetes.io/related-image"),
+				annotation("quay.io/example/operator:v1.4.0", "metadata.annotations.containerImage"),
+				annotation("docker.io/bitnami/os-shell:12-debian-12-r16", "spec.template.metadata.annotations.relatedImage"),
+				container,
+			},
+		},
+		{
+			name: "custom keys",
+			opts: helmscanTypes.ScanOptions{ScanAnnotations: true, AnnotationKeys: `^containerImage$`},
+			want: []imageOccurrence{
+				annotation("quay.io/example/operator:v1.4.0", "metadata.annotations.containerImage"),
+				container,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			annotationKeys, err := annotationKeyPattern(tt.opts)
+			if err != nil {
+				t.Fatalf("annotationKeyPattern() error = %v", err)
+			}
+			got, err := findImageReferences([]byte(manifest), annotationKeys)
+			if err != nil {
+				t.Fatalf("findImageReferences() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findImageReferences() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("invalid keys", func(t *testing.T) {
+		if _, err := annotationKeyPattern(helmscanTypes.ScanOptions{ScanAnnotations: true, AnnotationKeys: "image("}); err == nil {
+			t.Error("annotationKeyPattern() error = nil, want an invalid pattern error")
+		}
+	})
+}
+
+func TestImageExtractionDebug(t *testing.T) {
+	manifest := redisManifest + `        - name: sidecar
+          image: docker.io/bitnami/redis:7.2.4-debian-12-r9
+      initContainers:
+        - name: init
+          image: REPLACE_ME
+`
+	tests := []struct {
+		name      string
+		debug     bool
+		wantFiles int
+	}{
+		{name: "off by default"},
+		{name: "on", debug: true, wantFiles: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeTools{
+				manifests: map[string]string{"bitnami/redis@18.1.0": manifest},
+				vulns: map[string][]fakeVuln{
+					"docker.io/bitnami/redis:7.2.4-debian-12-r9":           nil,
+					"docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4": nil,
+				},
+			}.install(t)
+
+			opts := helmscanTypes.ScanOptions{WorkDir: t.TempDir(), ImageExtractionDebug: tt.debug}
+			if _, err := ScanContext(context.Background(), "bitnami/redis@18.1.0", opts); err != nil {
+				t.Fatalf("ScanContext() error = %v", err)
+			}
+
+			files, err := filepath.Glob(opts.WorkPath("tmp", "image_extraction", "*_image_extraction.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != tt.wantFiles {
+				t.Fatalf("wrote %d image extraction files, want %d: %v", len(files), tt.wantFiles, files)
+			}
+			if tt.wantFiles == 0 {
+				return
+			}
+
+			data, err := os.ReadFile(files[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			var debug imageExtractionDebug
+			if err := json.Unmarshal(data, &debug); err != nil {
+				t.Fatalf("image extraction file is not JSON: %v\n%s", err, data)
+			}
+			if debug.Chart != "bitnami/redis" || debug.Version != "18.1.0" {
+				t.Errorf("chart = %s@%s, want bitnami/redis@18.1.0", debug.Chart, debug.Version)
+			}
+			var references []string
+			for _, occurrence := range debug.Occurrences {
+				references = append(references, occurrence.Reference)
+			}
+			// Every reference is listed before parsing, including duplicates and the placeholder.
+			wantReferences := []string{
+				"docker.io/bitnami/redis:7.2.4-debian-12-r9",
+				"docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4",
+				"docker.io/bitnami/redis:7.2.4-debian-12-r9",
+				"REPLACE_ME",
+			}
+			if !slices.Equal(references, wantReferences) {
+				t.Errorf("occurrences = %v, want %v", references, wantReferences)
+			}
+			var images []string
+			for _, image := range debug.Images {
+				images = append(images, image.Identity())
+			}
+			slices.Sort(images)
+			wantImages := []string{"docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4", "docker.io/bitnami/redis:7.2.4-debian-12-r9"}
+			if !slices.Equal(images, wantImages) {
+				t.Errorf("images = %v, want %v", images, wantImages)
+			}
+			if len(debug.Skipped) != 1 || debug.Skipped[0].Reference != "REPLACE_ME" {
+				t.Errorf("skipped = %+v, want REPLACE_ME", debug.Skipped)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package helmscan
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+// ReadImageList reads one image reference per line from path, skipping blank lines and # comments.
+// Each reference is kept once, in the order it first appears.
+func ReadImageList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading image list: %w", err)
+	}
+	defer file.Close()
+
+	var refs []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		ref := strings.TrimSpace(scanner.Text())
+		if ref == "" || strings.HasPrefix(ref, "#") || seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		refs = append(refs, ref)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading image list %s: %w", path, err)
+	}
+	return refs, nil
+}
+
+// ScanImageListContext scans the images listed in path. The result is a HelmChart named after the
+// file so it can be compared like a chart; each image's source points at its line in the list.
+func ScanImageListContext(ctx context.Context, path string, opts helmscanTypes.ScanOptions) (helmscanTypes.HelmChart, error) {
+	refs, err := ReadImageList(path)
+	if err != nil {
+		return helmscanTypes.HelmChart{}, err
+	}
+
+	list := helmscanTypes.HelmChart{Name: filepath.Base(path)}
+	var images []*helmscanTypes.ContainerImage
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		if reason := invalidImageReason(ref); reason != "" {
+			logger.Warnf("Skipping image %q: %s", ref, reason)
+			list.SkippedImages = append(list.SkippedImages, helmscanTypes.SkippedImage{Reference: ref, Reason: reason})
+			continue
+		}
+		image := parseImageString(ref)
+		// Different spellings of one image, such as redis and docker.io/library/redis:latest, are scanned once.
+		if seen[image.Identity()] {
+			continue
+		}
+		seen[image.Identity()] = true
+		image.SourceRefs = []helmscanTypes.SourceRef{{Kind: "ImageList", Name: path, Path: ref}}
+		images = append(images, image)
+	}
+	images = filterImagesByRepository(images, opts.OnlyRepos, opts.SkipRepos)
+	logger.Infof("Found %d images in %s", len(images), path)
+	if opts.MaxImages > 0 && len(images) > opts.MaxImages {
+		return helmscanTypes.HelmChart{}, fmt.Errorf("image list %s contains %d images, more than the limit of %d; raise --max-images to scan it", path, len(images), opts.MaxImages)
+	}
+
+	list.ContainsImages, err = scanImages(ctx, images, opts)
+	return list, err
+}
+
+// ImageListReportGenerator reports a comparison of two image lists with the chart comparison
+// layout, labelled with the list files instead of chart references.
+type ImageListReportGenerator struct {
+	*HelmReportGenerator
+	beforePath string
+	afterPath  string
+}
+
+func NewImageListReportGenerator(comparison helmscanTypes.HelmComparison, beforePath, afterPath string) *ImageListReportGenerator {
+	return &ImageListReportGenerator{
+		HelmReportGenerator: NewHelmReportGenerator(comparison),
+		beforePath:          beforePath,
+		afterPath:           afterPath,
+	}
+}
+
+func (g *ImageListReportGenerator) GetTitle() string {
+	return "Image List Comparison Report"
+}
+
+func (g *ImageListReportGenerator) GetComparison() map[string]string {
+	return map[string]string{
+		"Before Images": g.beforePath,
+		"After Images":  g.afterPath,
+	}
+}
+
+func (g *ImageListReportGenerator) GetBaseFilename() string {
+	return fmt.Sprintf("%s_to_%s_image_list_comparison", g.beforePath, g.afterPath)
+}
+
+func (g *ImageListReportGenerator) GetReportKind() string {
+	return "image_list_cmp"
+}
@@ -0,0 +1,180 @@
+package helmscan
+
+import (
+	"context"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cliffcolvin/helmscan/internal/fakeexec"
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func writeImageList(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "images.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadImageList(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "one per line",
+			content: "docker.io/bitnami/redis:7.2.4\ndocker.io/bitnami/redis-exporter:1.58.0\n",
+			want:    []string{"docker.io/bitnami/redis:7.2.4", "docker.io/bitnami/redis-exporter:1.58.0"},
+		},
+		{
+			name:    "comments and blank lines",
+			content: "# cache\ndocker.io/bitnami/redis:7.2.4\n\n   \n  # metrics\n  docker.io/bitnami/redis-exporter:1.58.0  \n",
+			want:    []string{"docker.io/bitnami/redis:7.2.4", "docker.io/bitnami/redis-exporter:1.58.0"},
+		},
+		{
+			name:    "duplicates keep the first position",
+			content: "docker.io/bitnami/redis:7.2.4\ndocker.io/bitnami/redis-exporter:1.58.0\ndocker.io/bitnami/redis:7.2.4\n",
+			want:    []string{"docker.io/bitnami/redis:7.2.4", "docker.io/bitnami/redis-exporter:1.58.0"},
+		},
+		{
+			name:    "no trailing newline",
+			content: "docker.io/bitnami/redis:7.2.4",
+			want:    []string{"docker.io/bitnami/redis:7.2.4"},
+		},
+		{
+			name:    "empty",
+			content: "# nothing yet\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadImageList(writeImageList(t, tt.content))
+			if err != nil {
+				t.Fatalf("ReadImageList() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ReadImageList() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ReadImageList(filepath.Join(t.TempDir(), "missing.txt")); err == nil || !strings.Contains(err.Error(), "error reading image list") {
+		t.Errorf("ReadImageList() of a missing file error = %v, want an error reading the image list", err)
+	}
+}
+
+func TestCompareImageLists(t *testing.T) {
+	fakeTools{vulns: map[string][]fakeVuln{
+		"docker.io/bitnami/redis:7.2.4-debian-12-r9": {
+			{ID: "CVE-2023-45853", Severity: "CRITICAL", PkgName: "zlib1g"},
+			{ID: "CVE-2011-3374", Severity: "LOW", PkgName: "apt"},
+		},
+		"docker.io/bitnami/redis:7.2.5-debian-12-r0": {
+			{ID: "CVE-2011-3374", Severity: "LOW", PkgName: "apt"},
+		},
+		"docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4": {
+			{ID: "CVE-2023-45288", Severity: "HIGH", PkgName: "golang.org/x/net"},
+		},
+		"docker.io/bitnami/os-shell:12-debian-12-r16": {
+			{ID: "CVE-2024-2511", Severity: "MEDIUM", PkgName: "openssl"},
+		},
+		"nginx:latest": nil,
+	}}.install(t)
+
+	// Both lists share the exporter and differ on redis; os-shell is dropped and nginx added. The
+	// after list spells the exporter twice, which is scanned once, and names a placeholder.
+	beforePath := writeImageList(t, "docker.io/bitnami/redis:7.2.4-debian-12-r9\ndocker.io/bitnami/redis-exporter:1.58.0-debian-12-r4\ndocker.io/bitnami/os-shell:12-debian-12-r16\n")
+	afterPath := writeImageList(t, "docker.io/bitnami/redis:7.2.5-debian-12-r0\ndocker.io/bitnami/redis-exporter:1.58.0-debian-12-r4\nbitnami/redis-exporter:1.58.0-debian-12-r4\nnginx\nREPLACE_ME\n")
+
+	before, err := ScanImageListContext(context.Background(), beforePath, helmscanTypes.ScanOptions{})
+	if err != nil {
+		t.Fatalf("ScanImageListContext(before) error = %v", err)
+	}
+	after, err := ScanImageListContext(context.Background(), afterPath, helmscanTypes.ScanOptions{})
+	if err != nil {
+		t.Fatalf("ScanImageListContext(after) error = %v", err)
+	}
+	if len(after.ContainsImages) != 3 {
+		t.Errorf("after list scanned %d images, want 3", len(after.ContainsImages))
+	}
+	if len(after.SkippedImages) != 1 || after.SkippedImages[0].Reference != "REPLACE_ME" {
+		t.Errorf("after list skipped %+v, want only REPLACE_ME", after.SkippedImages)
+	}
+
+	comparison, err := CompareHelmChartsContext(context.Background(), before, after)
+	if err != nil {
+		t.Fatalf("CompareHelmChartsContext() error = %v", err)
+	}
+	tests := []struct {
+		field string
+		got   []string
+		want  []string
+	}{
+		{"AddedImages", slices.Sorted(maps.Keys(comparison.AddedImages)), []string{"docker.io/library/nginx"}},
+		{"RemovedImages", slices.Sorted(maps.Keys(comparison.RemovedImages)), []string{"docker.io/bitnami/os-shell"}},
+		{"ChangedImages", slices.Sorted(maps.Keys(comparison.ChangedImages)), []string{"docker.io/bitnami/redis"}},
+		{"UnChangedImages", slices.Sorted(maps.Keys(comparison.UnChangedImages)), []string{"docker.io/bitnami/redis-exporter"}},
+		{"AddedCVEs", slices.Sorted(maps.Keys(comparison.AddedCVEs)), nil},
+		{"RemovedCVEs", slices.Sorted(maps.Keys(comparison.RemovedCVEs)), []string{"CVE-2023-45853", "CVE-2024-2511"}},
+		{"UnchangedCVEs", slices.Sorted(maps.Keys(comparison.UnchangedCVEs)), []string{"CVE-2011-3374", "CVE-2023-45288"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			if !slices.Equal(tt.got, tt.want) {
+				t.Errorf("%s = %v, want %v", tt.field, tt.got, tt.want)
+			}
+		})
+	}
+
+	generator := NewImageListReportGenerator(comparison, beforePath, afterPath)
+	if got := generator.GetComparison(); got["Before Images"] != beforePath || got["After Images"] != afterPath {
+		t.Errorf("GetComparison() = %v, want the two list files", got)
+	}
+}
+
+func TestScanImageListIdentity(t *testing.T) {
+	tests := []struct {
+		name       string
+		list       string
+		wantImages []string
+	}{
+		{
+			name:       "spellings of one image",
+			list:       "redis\ndocker.io/library/redis:latest\nlibrary/redis:latest\n",
+			wantImages: []string{"docker.io/library/redis:latest"},
+		},
+		{
+			name:       "different images",
+			list:       "redis\nbitnami/redis:7.2.4\n",
+			wantImages: []string{"docker.io/library/redis:latest", "docker.io/bitnami/redis:7.2.4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Each image is scanned by the first spelling of it in the list.
+			dir := fakeTools{vulns: map[string][]fakeVuln{"redis:latest": nil, "bitnami/redis:7.2.4": nil}}.install(t)
+
+			list, err := ScanImageListContext(context.Background(), writeImageList(t, tt.list), helmscanTypes.ScanOptions{})
+			if err != nil {
+				t.Fatalf("ScanImageListContext() error = %v", err)
+			}
+			var images []string
+			for _, img := range list.ContainsImages {
+				images = append(images, img.Identity())
+			}
+			if !slices.Equal(images, tt.wantImages) {
+				t.Errorf("images = %q, want %q", images, tt.wantImages)
+			}
+			if calls := fakeexec.Calls(t, filepath.Join(dir, "trivy.log")); len(calls) != len(tt.wantImages) {
+				t.Errorf("trivy was run %d times, want once per image", len(calls))
+			}
+		})
+	}
+}
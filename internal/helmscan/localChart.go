@@ -0,0 +1,63 @@
+package helmscan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+	"gopkg.in/yaml.v3"
+)
+
+// localRepoName stands in for the repo name of charts scanned from a local directory.
+const localRepoName = "local"
+
+// IsLocalChart reports whether chartRef is a directory holding a Chart.yaml rather than a
+// repo/chart@version reference.
+func IsLocalChart(chartRef string) bool {
+	info, err := os.Stat(filepath.Join(chartRef, "Chart.yaml"))
+	return err == nil && !info.IsDir()
+}
+
+func readLocalChart(chartPath string) (chartMetadata, error) {
+	data, err := os.ReadFile(filepath.Join(chartPath, "Chart.yaml"))
+	if err != nil {
+		return chartMetadata{}, fmt.Errorf("error reading chart: %w", err)
+	}
+	var metadata chartMetadata
+	if err := yaml.Unmarshal(data, &metadata); err != nil {
+		return chartMetadata{}, fmt.Errorf("error parsing %s: %w", filepath.Join(chartPath, "Chart.yaml"), err)
+	}
+	if metadata.Name == "" || metadata.Version == "" {
+		return chartMetadata{}, fmt.Errorf("%s must set name and version", filepath.Join(chartPath, "Chart.yaml"))
+	}
+	return metadata, nil
+}
+
+// dependencyBuildArgs returns the helm arguments that fetch a local chart's dependencies into
+// its charts/ directory. Without a Chart.lock helm resolves them as dependency update would.
+func dependencyBuildArgs(chartPath string) []string {
+	return []string{"dependency", "build", chartPath}
+}
+
+func buildDependencies(ctx context.Context, chartPath string, opts helmscanTypes.ScanOptions) error {
+	cmd := execCommand(ctx, "helm", dependencyBuildArgs(chartPath)...)
+	cmd.Env = opts.CommandEnv()
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error building chart dependencies: %v\nOutput: %s", err, string(output))
+	}
+	logger.Infof("Helm dependency build output: %s", string(output))
+	return nil
+}
+
+// needsRepoUpdate reports whether any of chartRefs is fetched from a Helm repo.
+func needsRepoUpdate(chartRefs ...string) bool {
+	for _, chartRef := range chartRefs {
+		if !IsLocalChart(chartRef) && !IsOCIChart(chartRef) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package helmscan
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cliffcolvin/helmscan/internal/fakeexec"
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func TestScanLocalChart(t *testing.T) {
+	const chartYAML = "apiVersion: v2\nname: redis\nversion: 18.1.0\n"
+	templateCall := []string{"template", helmscanTypes.DefaultReleaseName, "mychart"}
+	tests := []struct {
+		name               string
+		chartYAML          string
+		updateDependencies bool
+		wantHelm           [][]string
+		wantErr            string
+	}{
+		{
+			name:      "template only",
+			chartYAML: chartYAML,
+			wantHelm:  [][]string{templateCall},
+		},
+		{
+			name:               "dependencies built first",
+			chartYAML:          chartYAML,
+			updateDependencies: true,
+			wantHelm:           [][]string{{"dependency", "build", "mychart"}, templateCall},
+		},
+		{
+			name:               "dependency build fails",
+			chartYAML:          chartYAML + "dependencies:\n  - name: common\n    version: 2.x.x\n    repository: https://charts.unreachable.example\n",
+			updateDependencies: true,
+			wantHelm:           [][]string{{"dependency", "build", "mychart"}},
+			wantErr:            "error building chart dependencies",
+		},
+		{
+			name:      "no version",
+			chartYAML: "apiVersion: v2\nname: redis\n",
+			wantErr:   "must set name and version",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := fakeTools{
+				manifests: map[string]string{"mychart": redisManifest},
+				vulns: map[string][]fakeVuln{
+					"docker.io/bitnami/redis:7.2.4-debian-12-r9":           nil,
+					"docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4": nil,
+				},
+			}.install(t)
+			if err := os.Mkdir("mychart", 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join("mychart", "Chart.yaml"), []byte(tt.chartYAML), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			chart, err := ScanContext(context.Background(), "mychart", helmscanTypes.ScanOptions{UpdateDependencies: tt.updateDependencies})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ScanContext() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("ScanContext() error = %v", err)
+			} else if chart.HelmRepo != localRepoName || chart.Name != "redis" || chart.Version != "18.1.0" || len(chart.ContainsImages) != 2 {
+				t.Errorf("ScanContext() = %s/%s@%s with %d images, want local/redis@18.1.0 with 2", chart.HelmRepo, chart.Name, chart.Version, len(chart.ContainsImages))
+			}
+
+			// Local charts never need helm repo update.
+			helmCalls := fakeexec.Calls(t, filepath.Join(dir, "helm.log"))
+			if !slices.EqualFunc(helmCalls, tt.wantHelm, slices.Equal) {
+				t.Errorf("helm calls = %v, want %v", helmCalls, tt.wantHelm)
+			}
+		})
+	}
+}
+
+func TestNeedsRepoUpdate(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.Mkdir("mychart", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("mychart", "Chart.yaml"), []byte("name: mychart\nversion: 0.1.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		chartRefs []string
+		want      bool
+	}{
+		{name: "repo chart", chartRefs: []string{"bitnami/redis@18.1.0"}, want: true},
+		{name: "local chart", chartRefs: []string{"mychart"}, want: false},
+		{name: "OCI chart", chartRefs: []string{"oci://registry-1.docker.io/bitnamicharts/redis@18.1.0"}, want: false},
+		{name: "local and OCI charts", chartRefs: []string{"mychart", "oci://registry-1.docker.io/bitnamicharts/redis@18.1.0"}, want: false},
+		{name: "local and repo charts", chartRefs: []string{"mychart", "bitnami/redis@18.1.0"}, want: true},
+		{name: "directory without Chart.yaml", chartRefs: []string{"."}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := needsRepoUpdate(tt.chartRefs...); got != tt.want {
+				t.Errorf("needsRepoUpdate(%q) = %v, want %v", tt.chartRefs, got, tt.want)
+			}
+		})
+	}
+}
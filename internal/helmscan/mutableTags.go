@@ -0,0 +1,19 @@
+package helmscan
+
+import helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+
+// mutableTag is the tag parseImageString gives images referenced without a tag or digest.
+const mutableTag = "latest"
+
+// MutableTagImages returns the images of chart referenced by the latest tag, given explicitly or
+// implied by a missing tag, and not pinned to a digest, so what they run can change without the
+// chart changing.
+func MutableTagImages(chart helmscanTypes.HelmChart) []*helmscanTypes.ContainerImage {
+	var images []*helmscanTypes.ContainerImage
+	for _, img := range chart.ContainsImages {
+		if img.Digest == "" && img.Tag == mutableTag {
+			images = append(images, img)
+		}
+	}
+	return images
+}
@@ -0,0 +1,48 @@
+package helmscan
+
+import (
+	"slices"
+	"testing"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func TestMutableTagImages(t *testing.T) {
+	tests := []struct {
+		name   string
+		images []string
+		want   []string
+	}{
+		{name: "no images"},
+		{name: "pinned tags", images: []string{"docker.io/bitnami/redis:7.2.4", "quay.io/oauth2-proxy/oauth2-proxy:v7.6.0"}},
+		{
+			name: "pinned and latest",
+			images: []string{
+				"docker.io/bitnami/redis:7.2.4",
+				"docker.io/bitnami/redis-exporter:latest",
+				"busybox",
+				"registry.internal:5000/tools/kubectl",
+			},
+			want: []string{"docker.io/bitnami/redis-exporter:latest", "docker.io/library/busybox:latest", "registry.internal:5000/tools/kubectl:latest"},
+		},
+		// A digest pins the image whatever its tag says.
+		{name: "latest pinned by digest", images: []string{"docker.io/bitnami/redis:latest@sha256:3127bd2ec9e8a1b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f9012345ab"}},
+		// Only latest is treated as mutable; other floating tags cannot be told from pinned ones.
+		{name: "other floating tags", images: []string{"docker.io/bitnami/redis:7", "docker.io/library/nginx:stable"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chart := helmscanTypes.HelmChart{Name: "app", Version: "1.0.0", HelmRepo: "bitnami"}
+			for _, image := range tt.images {
+				chart.ContainsImages = append(chart.ContainsImages, parseImageString(image))
+			}
+			var got []string
+			for _, img := range MutableTagImages(chart) {
+				got = append(got, img.Identity())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("MutableTagImages() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
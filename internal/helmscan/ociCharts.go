@@ -0,0 +1,53 @@
+package helmscan
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cliffcolvin/helmscan/internal/reports"
+)
+
+const ociScheme = "oci://"
+
+// IsOCIChart reports whether chartRef names a chart in an OCI registry, such as
+// oci://registry-1.docker.io/bitnamicharts/redis@18.1.5.
+func IsOCIChart(chartRef string) bool {
+	return strings.HasPrefix(chartRef, ociScheme)
+}
+
+// NormalizeChartReference returns chartRef as repo/chart@version. OCI charts may give their
+// version as a tag, oci://host/path/chart:1.2.3, which becomes oci://host/path/chart@1.2.3.
+func NormalizeChartReference(chartRef string) (string, error) {
+	repoName, chartName, version, err := parseChartReference(chartRef)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s@%s", repoName, chartName, version), nil
+}
+
+// parseOCIChartReference splits oci://host/path/chart@version or oci://host/path/chart:tag into
+// the registry path, which stands in for the repo name, the chart and its version. Helm stores a
+// version's build metadata in the tag with _ in place of +, so a tag is turned back into a version.
+func parseOCIChartReference(chartRef string) (string, string, string, error) {
+	path, version := strings.TrimPrefix(chartRef, ociScheme), ""
+	if i := strings.LastIndex(path, "@"); i >= 0 {
+		path, version = path[:i], path[i+1:]
+	} else if i := strings.LastIndex(path, ":"); i > strings.LastIndex(path, "/") {
+		path, version = path[:i], strings.ReplaceAll(path[i+1:], "_", "+")
+	}
+
+	slash := strings.LastIndex(path, "/")
+	if version == "" || slash <= 0 || slash == len(path)-1 {
+		return "", "", "", fmt.Errorf("invalid OCI chart reference: %s, expected oci://host/path/chart@version or oci://host/path/chart:version", chartRef)
+	}
+	return ociScheme + path[:slash], path[slash+1:], version, nil
+}
+
+// repoFileName is repoName as it appears in the names of working files: the registry path of an
+// OCI chart has its slashes replaced.
+func repoFileName(repoName string) string {
+	if IsOCIChart(repoName) {
+		return reports.CreateSafeFileName(repoName)
+	}
+	return repoName
+}
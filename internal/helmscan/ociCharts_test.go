@@ -0,0 +1,122 @@
+package helmscan
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/cliffcolvin/helmscan/internal/fakeexec"
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func TestParseOCIChartReference(t *testing.T) {
+	tests := []struct {
+		chartRef                         string
+		wantRepo, wantChart, wantVersion string
+		wantErr                          bool
+	}{
+		{chartRef: "oci://host/path/chart@1.2.3", wantRepo: "oci://host/path", wantChart: "chart", wantVersion: "1.2.3"},
+		{chartRef: "oci://host/path/chart:1.2.3", wantRepo: "oci://host/path", wantChart: "chart", wantVersion: "1.2.3"},
+		{chartRef: "oci://registry-1.docker.io/bitnamicharts/redis:18.1.5", wantRepo: "oci://registry-1.docker.io/bitnamicharts", wantChart: "redis", wantVersion: "18.1.5"},
+		// A registry port is not a version.
+		{chartRef: "oci://localhost:5000/charts/redis:18.1.5", wantRepo: "oci://localhost:5000/charts", wantChart: "redis", wantVersion: "18.1.5"},
+		// Helm stores build metadata in tags with _ in place of +.
+		{chartRef: "oci://host/charts/redis:1.2.3_build.7", wantRepo: "oci://host/charts", wantChart: "redis", wantVersion: "1.2.3+build.7"},
+		{chartRef: "oci://localhost:5000/charts/redis", wantErr: true},
+		{chartRef: "oci://host/redis", wantErr: true},
+		{chartRef: "oci://redis@1.2.3", wantErr: true},
+		{chartRef: "oci://host/charts/@1.2.3", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.chartRef, func(t *testing.T) {
+			repo, chart, version, err := parseChartReference(tt.chartRef)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseChartReference() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if repo != tt.wantRepo || chart != tt.wantChart || version != tt.wantVersion {
+				t.Errorf("parseChartReference() = %q, %q, %q, want %q, %q, %q", repo, chart, version, tt.wantRepo, tt.wantChart, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestNormalizeChartReference(t *testing.T) {
+	tests := []struct {
+		chartRef string
+		want     string
+		wantErr  bool
+	}{
+		{chartRef: "bitnami/redis@18.1.0", want: "bitnami/redis@18.1.0"},
+		{chartRef: "oci://registry-1.docker.io/bitnamicharts/redis@18.1.5", want: "oci://registry-1.docker.io/bitnamicharts/redis@18.1.5"},
+		{chartRef: "oci://registry-1.docker.io/bitnamicharts/redis:18.1.5", want: "oci://registry-1.docker.io/bitnamicharts/redis@18.1.5"},
+		{chartRef: "oci://registry-1.docker.io/bitnamicharts/redis", wantErr: true},
+		{chartRef: "redis", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.chartRef, func(t *testing.T) {
+			got, err := NormalizeChartReference(tt.chartRef)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeChartReference() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeChartReference(%q) = %q, want %q", tt.chartRef, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanOCIChart(t *testing.T) {
+	const repo = "oci://registry-1.docker.io/bitnamicharts"
+	tests := []struct {
+		name     string
+		chartRef string
+	}{
+		{name: "version", chartRef: repo + "/redis@18.1.0"},
+		{name: "tag", chartRef: repo + "/redis:18.1.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := fakeTools{
+				manifests: map[string]string{repo + "/redis@18.1.0": redisManifest},
+				vulns: map[string][]fakeVuln{
+					"docker.io/bitnami/redis:7.2.4-debian-12-r9":           nil,
+					"docker.io/bitnami/redis-exporter:1.58.0-debian-12-r4": nil,
+				},
+			}.install(t)
+
+			chart, err := ScanContext(context.Background(), tt.chartRef, helmscanTypes.ScanOptions{})
+			if err != nil {
+				t.Fatalf("ScanContext() error = %v", err)
+			}
+			if chart.HelmRepo != repo || chart.Name != "redis" || chart.Version != "18.1.0" {
+				t.Errorf("chart = %s %s %s, want %s redis 18.1.0", chart.HelmRepo, chart.Name, chart.Version, repo)
+			}
+			want := []string{"template", helmscanTypes.DefaultReleaseName, repo + "/redis", "--version", "18.1.0"}
+			calls := fakeexec.Calls(t, filepath.Join(dir, "helm.log"))
+			if !slices.ContainsFunc(calls, func(args []string) bool { return slices.Equal(args, want) }) {
+				t.Errorf("helm calls = %v, want %v", calls, want)
+			}
+		})
+	}
+}
+
+func TestRepoFileName(t *testing.T) {
+	tests := []struct {
+		repoName string
+		want     string
+	}{
+		{repoName: "bitnami", want: "bitnami"},
+		{repoName: "oci://registry-1.docker.io/bitnamicharts", want: "oci-registry-1-docker-io-bitnamicharts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.repoName, func(t *testing.T) {
+			if got := repoFileName(tt.repoName); got != tt.want {
+				t.Errorf("repoFileName(%q) = %q, want %q", tt.repoName, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package helmscan
+
+import (
+	"fmt"
+	"strings"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+// ParseRegistryMirror parses a from=to rewrite rule, e.g. docker.io=mirror.internal/dockerhub.
+func ParseRegistryMirror(value string) (helmscanTypes.RegistryMirror, error) {
+	from, to, ok := strings.Cut(value, "=")
+	from = strings.TrimSuffix(strings.TrimSpace(from), "/")
+	to = strings.TrimSuffix(strings.TrimSpace(to), "/")
+	if !ok || from == "" || to == "" {
+		return helmscanTypes.RegistryMirror{}, fmt.Errorf("invalid registry mirror %q, expected from=to", value)
+	}
+	return helmscanTypes.RegistryMirror{From: from, To: to}, nil
+}
+
+// mirroredReference applies the first mirror whose prefix matches reference, returning the
+// rewritten reference and whether a rule applied. Prefixes match whole path components, and
+// Docker Hub references are also tried in their fully qualified docker.io form.
+func mirroredReference(reference string, mirrors []helmscanTypes.RegistryMirror) (string, bool) {
+	qualified := qualifiedReference(reference)
+	for _, mirror := range mirrors {
+		for _, candidate := range []string{reference, qualified} {
+			if rest, ok := cutReferencePrefix(candidate, mirror.From); ok {
+				return mirror.To + rest, true
+			}
+		}
+	}
+	return reference, false
+}
+
+func cutReferencePrefix(reference, prefix string) (string, bool) {
+	rest, ok := strings.CutPrefix(reference, prefix)
+	if !ok {
+		return "", false
+	}
+	if rest == "" || strings.ContainsRune("/:@", rune(rest[0])) {
+		return rest, true
+	}
+	return "", false
+}
+
+// qualifiedReference expands a Docker Hub shorthand such as redis:7 or bitnami/redis:7 to
+// docker.io/library/redis:7 or docker.io/bitnami/redis:7.
+func qualifiedReference(reference string) string {
+	first, _, found := strings.Cut(reference, "/")
+	if !found {
+		return "docker.io/library/" + reference
+	}
+	if strings.ContainsAny(first, ".:") || first == "localhost" {
+		return reference
+	}
+	return "docker.io/" + reference
+}
@@ -0,0 +1,68 @@
+package helmscan
+
+import (
+	"testing"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func TestParseRegistryMirror(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    helmscanTypes.RegistryMirror
+		wantErr bool
+	}{
+		{value: "docker.io=mirror.internal/dockerhub", want: helmscanTypes.RegistryMirror{From: "docker.io", To: "mirror.internal/dockerhub"}},
+		{value: " quay.io/ = mirror.internal/quay/ ", want: helmscanTypes.RegistryMirror{From: "quay.io", To: "mirror.internal/quay"}},
+		{value: "docker.io", wantErr: true},
+		{value: "=mirror.internal", wantErr: true},
+		{value: "docker.io=", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseRegistryMirror(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRegistryMirror(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRegistryMirror(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMirroredReference(t *testing.T) {
+	mirrors := []helmscanTypes.RegistryMirror{
+		{From: "docker.io/bitnami", To: "mirror.internal/bitnami"},
+		{From: "docker.io", To: "mirror.internal/dockerhub"},
+		{From: "ghcr.io/example", To: "mirror.internal/example"},
+	}
+	tests := []struct {
+		name      string
+		reference string
+		want      string
+		wantOK    bool
+	}{
+		{name: "first matching rule", reference: "docker.io/bitnami/redis:7.2.4", want: "mirror.internal/bitnami/redis:7.2.4", wantOK: true},
+		{name: "registry rule", reference: "docker.io/grafana/grafana:10.4.0", want: "mirror.internal/dockerhub/grafana/grafana:10.4.0", wantOK: true},
+		{name: "Docker Hub shorthand", reference: "bitnami/redis:7.2.4", want: "mirror.internal/bitnami/redis:7.2.4", wantOK: true},
+		{name: "official image shorthand", reference: "busybox:1.36", want: "mirror.internal/dockerhub/library/busybox:1.36", wantOK: true},
+		{name: "digest", reference: "docker.io/library/nginx@sha256:0123", want: "mirror.internal/dockerhub/library/nginx@sha256:0123", wantOK: true},
+		// Prefixes match whole path components.
+		{name: "partial component", reference: "ghcr.io/example-org/api:1.0", want: "ghcr.io/example-org/api:1.0"},
+		{name: "other registry", reference: "quay.io/prometheus/node-exporter:v1.7.0", want: "quay.io/prometheus/node-exporter:v1.7.0"},
+		{name: "registry with a port", reference: "localhost:5000/api:1.0", want: "localhost:5000/api:1.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := mirroredReference(tt.reference, mirrors)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("mirroredReference(%q) = %q, %v, want %q, %v", tt.reference, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	if got, ok := mirroredReference("docker.io/bitnami/redis:7.2.4", nil); got != "docker.io/bitnami/redis:7.2.4" || ok {
+		t.Errorf("mirroredReference() without mirrors = %q, %v, want the reference unchanged", got, ok)
+	}
+}
@@ -20,10 +20,15 @@ func (g *HelmReportGenerator) GetTitle() string {
 }
 
 func (g *HelmReportGenerator) GetComparison() map[string]string {
-	return map[string]string{
+	comparison := map[string]string{
 		"Before Chart": fmt.Sprintf("%s/%s@%s", g.comparison.Before.HelmRepo, g.comparison.Before.Name, g.comparison.Before.Version),
 		"After Chart":  fmt.Sprintf("%s/%s@%s", g.comparison.After.HelmRepo, g.comparison.After.Name, g.comparison.After.Version),
 	}
+	// Call out repo migrations, where the chart refs can otherwise look alike at a glance.
+	if g.comparison.Before.HelmRepo != g.comparison.After.HelmRepo {
+		comparison["Repository Change"] = fmt.Sprintf("%s → %s", g.comparison.Before.HelmRepo, g.comparison.After.HelmRepo)
+	}
+	return comparison
 }
 
 func (g *HelmReportGenerator) GetSeverityCounts() []reports.SeverityCount {
@@ -42,6 +47,105 @@ func (g *HelmReportGenerator) GetUnchangedCVEs() map[string]map[string]helmscanT
 	return g.comparison.UnchangedCVEs
 }
 
+func (g *HelmReportGenerator) GetSkippedImages() []helmscanTypes.SkippedImage {
+	seen := make(map[string]bool)
+	var skipped []helmscanTypes.SkippedImage
+	for _, chart := range []helmscanTypes.HelmChart{g.comparison.Before, g.comparison.After} {
+		for _, image := range chart.SkippedImages {
+			if seen[image.Reference] {
+				continue
+			}
+			seen[image.Reference] = true
+			skipped = append(skipped, image)
+		}
+	}
+	return skipped
+}
+
+func (g *HelmReportGenerator) GetRepositoryChanges() []helmscanTypes.RepositoryChange {
+	return g.comparison.RepositoryChanges
+}
+
+func (g *HelmReportGenerator) GetImageChanges() []helmscanTypes.ImageChange {
+	return g.comparison.ImageChanges
+}
+
+// GetAffectedResources maps each compared image to the resources that run it in the before and
+// after charts, using the same image keys as the CVE maps.
+func (g *HelmReportGenerator) GetAffectedResources() (map[string][]reports.AffectedResource, map[string][]reports.AffectedResource) {
+	before := make(map[string][]reports.AffectedResource)
+	after := make(map[string][]reports.AffectedResource)
+	for name, images := range g.comparison.RemovedImages {
+		before[name] = affectedResources(images[0])
+	}
+	for name, images := range g.comparison.AddedImages {
+		after[name] = affectedResources(images[0])
+	}
+	for _, paired := range []map[string][]*helmscanTypes.ContainerImage{g.comparison.ChangedImages, g.comparison.UnChangedImages} {
+		for name, images := range paired {
+			before[name] = affectedResources(images[0])
+			after[name] = affectedResources(images[1])
+		}
+	}
+	return before, after
+}
+
+// GetOperatingSystems maps each compared image to the base OS Trivy found in its before and after
+// versions, using the same image keys as the CVE maps.
+func (g *HelmReportGenerator) GetOperatingSystems() (map[string]helmscanTypes.OperatingSystem, map[string]helmscanTypes.OperatingSystem) {
+	before := make(map[string]helmscanTypes.OperatingSystem)
+	after := make(map[string]helmscanTypes.OperatingSystem)
+	for name, images := range g.comparison.RemovedImages {
+		addOperatingSystem(before, name, images[0])
+	}
+	for name, images := range g.comparison.AddedImages {
+		addOperatingSystem(after, name, images[0])
+	}
+	for _, paired := range []map[string][]*helmscanTypes.ContainerImage{g.comparison.ChangedImages, g.comparison.UnChangedImages} {
+		for name, images := range paired {
+			addOperatingSystem(before, name, images[0])
+			addOperatingSystem(after, name, images[1])
+		}
+	}
+	return before, after
+}
+
+func addOperatingSystem(systems map[string]helmscanTypes.OperatingSystem, name string, img *helmscanTypes.ContainerImage) {
+	if img.OS.Family != "" {
+		systems[name] = img.OS
+	}
+}
+
+// GetRawOutputs maps each scanned image of both charts to its saved raw Trivy output.
+func (g *HelmReportGenerator) GetRawOutputs() map[string]string {
+	raw := make(map[string]string)
+	for _, chart := range []helmscanTypes.HelmChart{g.comparison.Before, g.comparison.After} {
+		addRawOutputs(raw, chart)
+	}
+	return raw
+}
+
+func addRawOutputs(raw map[string]string, chart helmscanTypes.HelmChart) {
+	for _, img := range chart.ContainsImages {
+		if img.ScanResult.RawOutput != "" {
+			raw[img.Identity()] = img.ScanResult.RawOutput
+		}
+	}
+}
+
+func affectedResources(img *helmscanTypes.ContainerImage) []reports.AffectedResource {
+	resources := make([]reports.AffectedResource, 0, len(img.SourceRefs))
+	for _, source := range img.SourceRefs {
+		resources = append(resources, reports.AffectedResource{
+			Kind:      source.Kind,
+			Name:      source.Name,
+			Container: source.Container,
+			Image:     img.Identity(),
+		})
+	}
+	return resources
+}
+
 func (g *HelmReportGenerator) GetBaseFilename() string {
 	return fmt.Sprintf("%s_%s_%s_to_%s_%s_%s_helm_comparison",
 		g.comparison.Before.HelmRepo,
@@ -51,3 +155,7 @@ func (g *HelmReportGenerator) GetBaseFilename() string {
 		g.comparison.After.Name,
 		g.comparison.After.Version)
 }
+
+func (g *HelmReportGenerator) GetReportKind() string {
+	return "helm_cmp"
+}
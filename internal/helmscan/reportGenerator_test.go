@@ -0,0 +1,149 @@
+package helmscan
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+	"github.com/cliffcolvin/helmscan/internal/reports"
+)
+
+func TestGetAffectedResources(t *testing.T) {
+	runIn := func(img *helmscanTypes.ContainerImage, refs ...helmscanTypes.SourceRef) *helmscanTypes.ContainerImage {
+		img.SourceRefs = refs
+		return img
+	}
+	master := helmscanTypes.SourceRef{Kind: "StatefulSet", Name: "redis-master", Container: "redis"}
+	replicas := helmscanTypes.SourceRef{Kind: "StatefulSet", Name: "redis-replicas", Container: "redis"}
+	before := helmscanTypes.HelmChart{Name: "redis", Version: "18.1.0", HelmRepo: "bitnami", ContainsImages: []*helmscanTypes.ContainerImage{
+		runIn(scannedImage("bitnami", "redis", "7.2.4", "CVE-2023-0001", "CVE-2023-0003"), master),
+		runIn(scannedImage("bitnami", "os-shell", "12", "CVE-2023-0004"), helmscanTypes.SourceRef{Kind: "StatefulSet", Name: "redis-master", Container: "volume-permissions"}),
+	}}
+	after := helmscanTypes.HelmChart{Name: "redis", Version: "18.2.0", HelmRepo: "bitnami", ContainsImages: []*helmscanTypes.ContainerImage{
+		runIn(scannedImage("bitnami", "redis", "7.2.5", "CVE-2023-0002", "CVE-2023-0003"), master, replicas),
+		runIn(scannedImage("bitnami", "redis-exporter", "1.58.0", "CVE-2023-0005"), helmscanTypes.SourceRef{Kind: "Deployment", Name: "redis-metrics", Container: "metrics"}),
+	}}
+
+	output, err := reports.RenderJSON(NewHelmReportGenerator(CompareHelmCharts(before, after)), reports.ReportOptions{})
+	if err != nil {
+		t.Fatalf("RenderJSON() error = %v", err)
+	}
+	var report reports.JSONReport
+	if err := json.Unmarshal([]byte(output), &report); err != nil {
+		t.Fatalf("RenderJSON() is not JSON: %v", err)
+	}
+	found := make(map[string][]reports.AffectedResource)
+	for _, cves := range [][]reports.CVE{report.AddedCVEs, report.RemovedCVEs, report.UnchangedCVEs} {
+		for _, cve := range cves {
+			found[cve.ID] = cve.AffectedResources
+		}
+	}
+
+	tests := []struct {
+		name string
+		cve  string
+		want []reports.AffectedResource
+	}{
+		{
+			name: "added in a changed image",
+			cve:  "CVE-2023-0002",
+			want: []reports.AffectedResource{
+				{Kind: "StatefulSet", Name: "redis-master", Container: "redis", Image: "docker.io/bitnami/redis:7.2.5"},
+				{Kind: "StatefulSet", Name: "redis-replicas", Container: "redis", Image: "docker.io/bitnami/redis:7.2.5"},
+			},
+		},
+		{
+			name: "added in an added image",
+			cve:  "CVE-2023-0005",
+			want: []reports.AffectedResource{{Kind: "Deployment", Name: "redis-metrics", Container: "metrics", Image: "docker.io/bitnami/redis-exporter:1.58.0"}},
+		},
+		{
+			name: "removed from a changed image",
+			cve:  "CVE-2023-0001",
+			want: []reports.AffectedResource{{Kind: "StatefulSet", Name: "redis-master", Container: "redis", Image: "docker.io/bitnami/redis:7.2.4"}},
+		},
+		{
+			name: "removed with a removed image",
+			cve:  "CVE-2023-0004",
+			want: []reports.AffectedResource{{Kind: "StatefulSet", Name: "redis-master", Container: "volume-permissions", Image: "docker.io/bitnami/os-shell:12"}},
+		},
+		{
+			name: "unchanged uses the after chart",
+			cve:  "CVE-2023-0003",
+			want: []reports.AffectedResource{
+				{Kind: "StatefulSet", Name: "redis-master", Container: "redis", Image: "docker.io/bitnami/redis:7.2.5"},
+				{Kind: "StatefulSet", Name: "redis-replicas", Container: "redis", Image: "docker.io/bitnami/redis:7.2.5"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := found[tt.cve]
+			if !ok {
+				t.Fatalf("%s is not in the report", tt.cve)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("affected resources of %s = %+v, want %+v", tt.cve, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrossRepoComparison(t *testing.T) {
+	chart := func(repo, version string) helmscanTypes.HelmChart {
+		return helmscanTypes.HelmChart{Name: "app", Version: version, HelmRepo: repo, ContainsImages: []*helmscanTypes.ContainerImage{
+			scannedImage("bitnami", "redis", "7.2.4", "CVE-2023-45853"),
+		}}
+	}
+	tests := []struct {
+		name          string
+		before, after helmscanTypes.HelmChart
+		want          map[string]string
+	}{
+		{
+			name:   "same repo",
+			before: chart("oldrepo", "1.0.0"),
+			after:  chart("oldrepo", "1.1.0"),
+			want:   map[string]string{"Before Chart": "oldrepo/app@1.0.0", "After Chart": "oldrepo/app@1.1.0"},
+		},
+		{
+			name:   "repo only",
+			before: chart("oldrepo", "1.0.0"),
+			after:  chart("newrepo", "1.0.0"),
+			want: map[string]string{
+				"Before Chart":      "oldrepo/app@1.0.0",
+				"After Chart":       "newrepo/app@1.0.0",
+				"Repository Change": "oldrepo → newrepo",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator := NewHelmReportGenerator(CompareHelmCharts(tt.before, tt.after))
+			if got := generator.GetComparison(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetComparison() = %v, want %v", got, tt.want)
+			}
+			markdown := reports.RenderMarkdown(generator, reports.ReportOptions{})
+			for key, value := range tt.want {
+				if header := "### " + key + ": " + value + "\n"; !strings.Contains(markdown, header) {
+					t.Errorf("report header is missing %q:\n%s", header, markdown)
+				}
+			}
+		})
+	}
+
+	// Comparisons differing only by repo are saved under different names.
+	filenames := make(map[string]string)
+	for _, comparison := range [][2]string{{"oldrepo", "newrepo"}, {"newrepo", "oldrepo"}, {"oldrepo", "oldrepo"}, {"newrepo", "newrepo"}} {
+		generator := NewHelmReportGenerator(CompareHelmCharts(chart(comparison[0], "1.0.0"), chart(comparison[1], "1.0.0")))
+		for _, opts := range []reports.ReportOptions{{}, {HashedFilenames: true}} {
+			filename := reports.GeneratorFilename(generator, opts)
+			if other, ok := filenames[filename]; ok {
+				t.Errorf("%s → %s and %s are both saved as %s", comparison[0], comparison[1], other, filename)
+			}
+			filenames[filename] = comparison[0] + " → " + comparison[1]
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package imageScan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+	"github.com/cliffcolvin/helmscan/internal/reports"
+)
+
+const filesystemScheme = "fs:"
+
+// FilesystemPath returns the local path of an fs: target, a directory such as a built rootfs
+// scanned with trivy fs instead of as an image.
+func FilesystemPath(target string) (string, bool) {
+	if !strings.HasPrefix(target, filesystemScheme) {
+		return "", false
+	}
+	return strings.TrimPrefix(target, filesystemScheme), true
+}
+
+// ScanFilesystemContext scans the directory at path with trivy fs. The result records the fs:
+// target as its image, so reports and output files name it as given.
+func ScanFilesystemContext(ctx context.Context, path string, opts helmscanTypes.ScanOptions) (helmscanTypes.ScanResult, error) {
+	if _, err := os.Stat(path); err != nil {
+		return helmscanTypes.ScanResult{}, fmt.Errorf("error reading filesystem target: %w", err)
+	}
+	return runTrivyScan(ctx, filesystemScheme+path, opts, func(outputFile string) []string {
+		return append(trivyScanArgs("fs", outputFile, opts), path)
+	})
+}
+
+// GenerateFilesystemReport renders a single scan report of a filesystem target.
+func GenerateFilesystemReport(result helmscanTypes.ScanResult, jsonOutput bool, ignoreUnfixed bool, opts reports.ReportOptions) string {
+	return generateSingleScanReport("filesystem", result, jsonOutput, ignoreUnfixed, opts)
+}
@@ -0,0 +1,87 @@
+package imageScan
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cliffcolvin/helmscan/internal/fakeexec"
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+	"github.com/cliffcolvin/helmscan/internal/reports"
+)
+
+func TestFilesystemPath(t *testing.T) {
+	tests := []struct {
+		target   string
+		wantPath string
+		wantOK   bool
+	}{
+		{target: "fs:/build/rootfs", wantPath: "/build/rootfs", wantOK: true},
+		{target: "fs:rootfs", wantPath: "rootfs", wantOK: true},
+		{target: "fs:", wantOK: true},
+		{target: "/build/rootfs"},
+		{target: "cluster"},
+		{target: "docker.io/bitnami/redis:7.2.4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			path, ok := FilesystemPath(tt.target)
+			if path != tt.wantPath || ok != tt.wantOK {
+				t.Errorf("FilesystemPath(%q) = %q, %v, want %q, %v", tt.target, path, ok, tt.wantPath, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestScanFilesystemContext(t *testing.T) {
+	rootfs := t.TempDir()
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{name: "directory", path: rootfs},
+		{name: "missing directory", path: filepath.Join(rootfs, "missing"), wantErr: "error reading filesystem target"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := useFakeTrivy(t, "trivy_image.json")
+			result, err := ScanFilesystemContext(context.Background(), tt.path, helmscanTypes.ScanOptions{})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ScanFilesystemContext() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				if calls := fakeexec.Calls(t, log); len(calls) != 0 {
+					t.Errorf("trivy was run %d times, want it not to run", len(calls))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ScanFilesystemContext() error = %v", err)
+			}
+			if want := "fs:" + tt.path; result.Image != want {
+				t.Errorf("Image = %q, want %q", result.Image, want)
+			}
+			if want := (helmscanTypes.SeverityCounts{Critical: 1, High: 1, Medium: 2, Low: 1}); result.Vulnerabilities != want {
+				t.Errorf("Vulnerabilities = %+v, want %+v", result.Vulnerabilities, want)
+			}
+			calls := fakeexec.Calls(t, log)
+			if len(calls) != 1 {
+				t.Fatalf("trivy was run %d times, want 1", len(calls))
+			}
+			args := calls[0]
+			if args[0] != "fs" || args[len(args)-1] != tt.path {
+				t.Errorf("trivy args = %v, want trivy fs ending with %s", args, tt.path)
+			}
+
+			report := GenerateFilesystemReport(result, true, false, reports.ReportOptions{})
+			if !strings.Contains(report, `"ArtifactType": "filesystem"`) {
+				t.Errorf("report does not record the filesystem artifact type:\n%s", report)
+			}
+			if !strings.Contains(report, `"ArtifactRef": "fs:`+tt.path+`"`) {
+				t.Errorf("report does not name the fs: target:\n%s", report)
+			}
+		})
+	}
+}
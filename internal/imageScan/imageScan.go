@@ -1,13 +1,19 @@
 package imageScan
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/Masterminds/semver/v3"
+	"github.com/cliffcolvin/helmscan/internal/epss"
 	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+	"github.com/cliffcolvin/helmscan/internal/kev"
 	"github.com/cliffcolvin/helmscan/internal/reports"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -15,6 +21,14 @@ import (
 
 var logger *zap.SugaredLogger
 
+const tarballScheme = "file://"
+
+// execCommand and lookPath are variables so a fake trivy binary can be substituted.
+var (
+	execCommand = exec.CommandContext
+	lookPath    = exec.LookPath
+)
+
 func init() {
 	encoderConfig := zap.NewProductionEncoderConfig()
 	encoderConfig.TimeKey = "timestamp"
@@ -23,7 +37,7 @@ func init() {
 
 	core := zapcore.NewCore(
 		zapcore.NewConsoleEncoder(encoderConfig),
-		zapcore.AddSync(os.Stdout),
+		zapcore.AddSync(os.Stderr),
 		zap.InfoLevel,
 	)
 
@@ -33,29 +47,50 @@ func init() {
 	logger = zapLogger.Sugar()
 }
 
+// SetLogger replaces the package logger, e.g. to apply the --log-format and --log-level flags.
+func SetLogger(l *zap.SugaredLogger) {
+	logger = l
+}
+
 func ScanImage(imageName string, ignoreUnfixed bool) (helmscanTypes.ScanResult, error) {
-	if err := os.MkdirAll("working-files/tmp/trivy_output", 0755); err != nil {
+	return ScanImageContext(context.Background(), imageName, helmscanTypes.ScanOptions{IgnoreUnfixed: ignoreUnfixed})
+}
+
+// ScanImageContext scans an image from a registry, or from a tarball written by docker save when
+// imageName is a file:// reference. The result records imageName as given.
+func ScanImageContext(ctx context.Context, imageName string, opts helmscanTypes.ScanOptions) (helmscanTypes.ScanResult, error) {
+	if path, ok := TarballPath(imageName); ok {
+		if _, err := os.Stat(path); err != nil {
+			return helmscanTypes.ScanResult{}, fmt.Errorf("error reading image tarball: %w", err)
+		}
+	}
+	return runTrivyScan(ctx, imageName, opts, func(outputFile string) []string {
+		return trivyImageArgs(imageName, outputFile, opts)
+	})
+}
+
+// runTrivyScan runs Trivy with the arguments args returns for its JSON output file and parses
+// the results of target, the image or other artifact being scanned.
+func runTrivyScan(ctx context.Context, target string, opts helmscanTypes.ScanOptions, args func(outputFile string) []string) (helmscanTypes.ScanResult, error) {
+	outputDir := opts.WorkPath("tmp", "trivy_output")
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return helmscanTypes.ScanResult{}, fmt.Errorf("failed to create working directory: %w", err)
 	}
 
-	safeFileName := reports.CreateSafeFileName(imageName)
-	outputFile := fmt.Sprintf("working-files/tmp/trivy_output/%s_trivy_output.json", safeFileName)
+	safeFileName := reports.CreateSafeFileName(target)
+	outputFile := filepath.Join(outputDir, safeFileName+"_trivy_output.json")
+	defer lockOutputFile(outputFile)()
 
-	args := []string{"image",
-		"-f", "json",
-		"-o", outputFile,
-		"--severity", "HIGH,MEDIUM,LOW,CRITICAL",
-		"--pkg-types", "os,library",
-		"--scanners", "vuln,secret,misconfig"}
-	
-	if ignoreUnfixed {
-		args = append(args, "--ignore-unfixed")
+	release, err := acquireTrivySlot(ctx, opts)
+	if err != nil {
+		return helmscanTypes.ScanResult{}, err
 	}
-	
-	args = append(args, imageName)
-	cmd := exec.Command("trivy", args...)
+	cmd := execCommand(ctx, "trivy", args(outputFile)...)
+	cmd.Env = opts.CommandEnv()
 
 	combinedOutput, err := cmd.CombinedOutput()
+	release()
+	markTrivyDBStale()
 	if err != nil {
 		return helmscanTypes.ScanResult{}, fmt.Errorf("error running command: %w\nOutput: %s", err, string(combinedOutput))
 	}
@@ -65,18 +100,223 @@ func ScanImage(imageName string, ignoreUnfixed bool) (helmscanTypes.ScanResult,
 		return helmscanTypes.ScanResult{}, fmt.Errorf("error reading %s: %w", outputFile, err)
 	}
 
-	vulns := extractVulnerabilities(string(jsonData))
+	var rawOutput string
+	if opts.IncludeRaw {
+		if rawOutput, err = saveRawOutput(opts.WorkPath("raw"), safeFileName, jsonData); err != nil {
+			return helmscanTypes.ScanResult{}, err
+		}
+	}
+
+	trivyResults, err := parseTrivyOutput(jsonData)
+	if err != nil {
+		return helmscanTypes.ScanResult{}, fmt.Errorf("parsing trivy output %s: %w", outputFile, err)
+	}
+	vulns := trivyResults.vulnerabilities()
+	applySeverityOverrides(target, vulns, opts.SeverityOverrides)
+	if opts.EPSS {
+		if err := addEPSSScores(ctx, vulns); err != nil {
+			return helmscanTypes.ScanResult{}, err
+		}
+	}
+	if opts.KEV {
+		if err := markKnownExploited(ctx, vulns, opts.KEVCacheTTL); err != nil {
+			return helmscanTypes.ScanResult{}, err
+		}
+	}
 
 	result := helmscanTypes.ScanResult{
-		Image:           imageName,
-		Vulnerabilities: countVulnerabilities(vulns),
-		VulnsByLevel:    groupVulnerabilitiesByLevel(vulns),
-		VulnList:        vulns,
+		Image:             target,
+		Vulnerabilities:   countVulnerabilities(vulns),
+		VulnsByLevel:      groupVulnerabilitiesByLevel(vulns),
+		VulnList:          vulns,
+		Secrets:           trivyResults.secrets(target),
+		Misconfigurations: trivyResults.misconfigurations(target),
+		RawOutput:         rawOutput,
+		OS:                trivyResults.Metadata.OS,
+		RepoDigest:        trivyResults.Metadata.repoDigest(),
 	}
 
 	return result, nil
 }
 
+// saveRawOutput keeps a copy of an image's unprocessed Trivy JSON in dir, where the next scan of
+// another image does not overwrite it, and returns its path.
+func saveRawOutput(dir, safeFileName string, jsonData []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create raw output directory: %w", err)
+	}
+	path := filepath.Join(dir, safeFileName+"_trivy.json")
+	if err := os.WriteFile(path, jsonData, 0644); err != nil {
+		return "", fmt.Errorf("error saving raw Trivy output: %w", err)
+	}
+	return path, nil
+}
+
+// outputLocks holds a mutex per Trivy output file, so an image shared by two charts scanned
+// concurrently is not written and read by both scans at once.
+var outputLocks sync.Map
+
+func lockOutputFile(path string) func() {
+	lock, _ := outputLocks.LoadOrStore(path, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+func addEPSSScores(ctx context.Context, vulns []helmscanTypes.Vulnerability) error {
+	ids := make([]string, 0, len(vulns))
+	for _, vuln := range vulns {
+		ids = append(ids, vuln.ID)
+	}
+	scores, err := epss.Scores(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for i := range vulns {
+		if score, ok := scores[vulns[i].ID]; ok {
+			vulns[i].EPSS = &score
+		}
+	}
+	return nil
+}
+
+func markKnownExploited(ctx context.Context, vulns []helmscanTypes.Vulnerability, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = kev.DefaultCacheTTL
+	}
+	catalog, err := kev.Catalog(ctx, ttl)
+	if err != nil {
+		return err
+	}
+	for i := range vulns {
+		vulns[i].KEV = catalog[vulns[i].ID]
+	}
+	return nil
+}
+
+// VulnerabilitiesByCVE groups the scan's vulnerabilities by CVE ID and then by image.
+func VulnerabilitiesByCVE(result helmscanTypes.ScanResult) map[string]map[string]helmscanTypes.Vulnerability {
+	cves := make(map[string]map[string]helmscanTypes.Vulnerability)
+	for _, v := range result.VulnList {
+		cves[v.ID] = map[string]helmscanTypes.Vulnerability{result.Image: v}
+	}
+	return cves
+}
+
+// SeverityCounts counts the vulnerabilities of result once per CVE, as its reports list them, where
+// Trivy lists a CVE once for each package it affects.
+func SeverityCounts(result helmscanTypes.ScanResult) helmscanTypes.SeverityCounts {
+	var vulns []helmscanTypes.Vulnerability
+	for _, byImage := range VulnerabilitiesByCVE(result) {
+		for _, vuln := range byImage {
+			vulns = append(vulns, vuln)
+		}
+	}
+	return countVulnerabilities(vulns)
+}
+
+func ScanConfigContext(ctx context.Context, path string, opts helmscanTypes.ScanOptions) ([]helmscanTypes.Misconfiguration, error) {
+	outputDir := opts.WorkPath("tmp", "trivy_output")
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create working directory: %w", err)
+	}
+
+	safeFileName := reports.CreateSafeFileName(path)
+	outputFile := filepath.Join(outputDir, safeFileName+"_trivy_config_output.json")
+
+	release, err := acquireTrivySlot(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	cmd := execCommand(ctx, "trivy", trivyConfigArgs(path, outputFile, opts)...)
+	cmd.Env = opts.CommandEnv()
+
+	combinedOutput, err := cmd.CombinedOutput()
+	release()
+	if err != nil {
+		return nil, fmt.Errorf("error running command: %w\nOutput: %s", err, string(combinedOutput))
+	}
+
+	jsonData, err := os.ReadFile(outputFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", outputFile, err)
+	}
+
+	trivyResults, err := parseTrivyOutput(jsonData)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", outputFile, err)
+	}
+
+	return trivyResults.misconfigurations(""), nil
+}
+
+func trivyConfigArgs(path string, outputFile string, opts helmscanTypes.ScanOptions) []string {
+	var args []string
+	if opts.TrivyConfig != "" {
+		args = append(args, "--config", opts.TrivyConfig)
+	}
+
+	return append(args, "config",
+		"-f", "json",
+		"-o", outputFile,
+		"--severity", "HIGH,MEDIUM,LOW,CRITICAL",
+		path)
+}
+
+func trivyImageArgs(imageName string, outputFile string, opts helmscanTypes.ScanOptions) []string {
+	args := trivyScanArgs("image", outputFile, opts)
+	if path, ok := TarballPath(imageName); ok {
+		return append(args, "--input", path)
+	}
+	return append(args, imageName)
+}
+
+// trivyScanArgs returns the arguments of a trivy vulnerability scan subcommand, such as image or
+// fs, up to but not including its target.
+func trivyScanArgs(subcommand string, outputFile string, opts helmscanTypes.ScanOptions) []string {
+	var args []string
+	if opts.TrivyConfig != "" {
+		args = append(args, "--config", opts.TrivyConfig)
+	}
+
+	args = append(args, subcommand,
+		"-f", "json",
+		"-o", outputFile,
+		"--severity", "HIGH,MEDIUM,LOW,CRITICAL",
+		"--pkg-types", "os,library",
+		"--scanners", scanners(opts))
+
+	if opts.IgnoreUnfixed {
+		args = append(args, "--ignore-unfixed")
+	}
+	if opts.DBRepository != "" {
+		args = append(args, "--db-repository", opts.DBRepository)
+	}
+	if opts.SkipDBUpdate {
+		args = append(args, "--skip-db-update")
+	}
+	if opts.OfflineScan {
+		args = append(args, "--offline-scan")
+	}
+	return args
+}
+
+// TarballPath returns the local path of a file:// image reference, used for images shipped as
+// docker save tarballs instead of through a registry.
+func TarballPath(imageName string) (string, bool) {
+	if !strings.HasPrefix(imageName, tarballScheme) {
+		return "", false
+	}
+	return strings.TrimPrefix(imageName, tarballScheme), true
+}
+
+func scanners(opts helmscanTypes.ScanOptions) string {
+	if opts.Scanners == "" {
+		return helmscanTypes.DefaultScanners
+	}
+	return opts.Scanners
+}
+
 func countVulnerabilities(vulns []helmscanTypes.Vulnerability) helmscanTypes.SeverityCounts {
 	counts := helmscanTypes.SeverityCounts{}
 	for _, vuln := range vulns {
@@ -93,71 +333,38 @@ func groupVulnerabilitiesByLevel(vulns []helmscanTypes.Vulnerability) map[string
 	return grouped
 }
 
+// CompareScans diffs two image scans into the same CVE ID to image structure as a chart
+// comparison. Removed CVEs are attributed to the first image; added and unchanged CVEs to the
+// second, with the second scan's details (such as the fixed version) for unchanged ones.
 func CompareScans(firstScan, secondScan helmscanTypes.ScanResult) *helmscanTypes.ImageComparisonReport {
 	comparison := &helmscanTypes.ImageComparisonReport{
 		Image1:        firstScan,
 		Image2:        secondScan,
-		RemovedCVEs:   make(map[string][]helmscanTypes.Vulnerability),
-		AddedCVEs:     make(map[string][]helmscanTypes.Vulnerability),
-		UnchangedCVEs: make(map[string][]helmscanTypes.Vulnerability),
+		RemovedCVEs:   make(map[string]map[string]helmscanTypes.Vulnerability),
+		AddedCVEs:     make(map[string]map[string]helmscanTypes.Vulnerability),
+		UnchangedCVEs: make(map[string]map[string]helmscanTypes.Vulnerability),
 	}
 
-	firstVulns := make(map[string]helmscanTypes.Vulnerability)
-	for _, vuln := range firstScan.VulnList {
-		firstVulns[vuln.ID] = vuln
-	}
+	firstVulns := VulnerabilitiesByCVE(firstScan)
+	secondVulns := VulnerabilitiesByCVE(secondScan)
 
-	secondVulns := make(map[string]helmscanTypes.Vulnerability)
-	for _, vuln := range secondScan.VulnList {
-		secondVulns[vuln.ID] = vuln
-	}
-
-	for ID, vuln := range firstVulns {
-		if _, exists := secondVulns[ID]; exists {
-			comparison.UnchangedCVEs[vuln.Severity] = append(comparison.UnchangedCVEs[vuln.Severity], vuln)
-		} else {
-			comparison.RemovedCVEs[vuln.Severity] = append(comparison.RemovedCVEs[vuln.Severity], vuln)
+	for ID, images := range firstVulns {
+		if _, exists := secondVulns[ID]; !exists {
+			comparison.RemovedCVEs[ID] = images
 		}
 	}
 
-	for ID, vuln := range secondVulns {
-		if _, exists := firstVulns[ID]; !exists {
-			comparison.AddedCVEs[vuln.Severity] = append(comparison.AddedCVEs[vuln.Severity], vuln)
+	for ID, images := range secondVulns {
+		if _, exists := firstVulns[ID]; exists {
+			comparison.UnchangedCVEs[ID] = images
+		} else {
+			comparison.AddedCVEs[ID] = images
 		}
 	}
 
 	return comparison
 }
 
-func extractVulnerabilities(scan string) []helmscanTypes.Vulnerability {
-	var result struct {
-		Results []struct {
-			Vulnerabilities []struct {
-				VulnerabilityID string `json:"VulnerabilityID"`
-				Severity        string `json:"Severity"`
-			} `json:"Vulnerabilities"`
-		} `json:"Results"`
-	}
-
-	err := json.Unmarshal([]byte(scan), &result)
-	if err != nil {
-		fmt.Printf("Error parsing JSON: %v\n", err)
-		return nil
-	}
-
-	var vulns []helmscanTypes.Vulnerability
-	for _, res := range result.Results {
-		for _, vuln := range res.Vulnerabilities {
-			vulns = append(vulns, helmscanTypes.Vulnerability{
-				ID:       vuln.VulnerabilityID,
-				Severity: strings.ToLower(vuln.Severity),
-			})
-		}
-	}
-
-	return vulns
-}
-
 func incrementSeverityCount(counts *helmscanTypes.SeverityCounts, severity string) {
 	switch severity {
 	case "low":
@@ -186,24 +393,66 @@ func difference(a, b []string) []string {
 	return diff
 }
 
-func CheckTrivyInstallation() error {
-	_, err := exec.LookPath("trivy")
+// MinTrivyVersion is the oldest Trivy release whose CLI flags (--pkg-types) and
+// JSON output this package is known to work with.
+const MinTrivyVersion = "0.52.0"
+
+var trivyVersion string
+
+func TrivyVersion() string {
+	return trivyVersion
+}
+
+func CheckTrivyInstallation(strict bool) error {
+	_, err := lookPath("trivy")
 	if err != nil {
 		return fmt.Errorf("Trivy is not installed. Please install Trivy and ensure it's in your PATH")
 	}
 
-	cmd := exec.Command("trivy", "--version")
+	cmd := execCommand(context.Background(), "trivy", "--version")
 	output, err := cmd.Output()
 	if err != nil {
 		return fmt.Errorf("Failed to get Trivy version: %v", err)
 	}
 
-	version := strings.TrimSpace(strings.TrimPrefix(string(output), "Version: "))
-	fmt.Printf("Trivy version %s is installed.\n", version)
+	version, err := parseTrivyVersion(string(output))
+	if err != nil {
+		return err
+	}
+	trivyVersion = version
+	logger.Infof("Trivy version %s is installed.", version)
+
+	if err := checkTrivyVersion(version); err != nil {
+		if strict {
+			return err
+		}
+		logger.Warnf("%v; scan results may be incomplete", err)
+	}
 
 	return nil
 }
 
+func parseTrivyVersion(output string) (string, error) {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "Version:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "Version:")), nil
+		}
+	}
+	return "", fmt.Errorf("Failed to parse Trivy version from output: %q", strings.TrimSpace(output))
+}
+
+func checkTrivyVersion(version string) error {
+	installed, err := semver.NewVersion(version)
+	if err != nil {
+		return fmt.Errorf("unrecognized Trivy version %q", version)
+	}
+	if installed.LessThan(semver.MustParse(MinTrivyVersion)) {
+		return fmt.Errorf("Trivy version %s is older than the minimum supported version %s", version, MinTrivyVersion)
+	}
+	return nil
+}
+
 func calculateDifference(before, after map[string][]string) map[string][]helmscanTypes.Vulnerability {
 	diff := make(map[string][]helmscanTypes.Vulnerability)
 
@@ -230,9 +479,29 @@ func contains(slice []string, item string) bool {
 	return false
 }
 
-func GenerateReport(comparison *helmscanTypes.ImageComparisonReport, generateJSON bool, generateMD bool) string {
+func GenerateReport(comparison *helmscanTypes.ImageComparisonReport, generateJSON bool, generateMD bool, opts reports.ReportOptions) (string, error) {
 	generator := NewImageReportGenerator(comparison)
-	return reports.GenerateReport(generator, generateJSON, generateMD)
+	return reports.GenerateReport(generator, generateJSON, generateMD, opts)
+}
+
+func RenderMarkdown(comparison *helmscanTypes.ImageComparisonReport, opts reports.ReportOptions) string {
+	return reports.RenderMarkdown(NewImageReportGenerator(comparison), opts)
+}
+
+func RenderJSON(comparison *helmscanTypes.ImageComparisonReport, opts reports.ReportOptions) (string, error) {
+	return reports.RenderJSON(NewImageReportGenerator(comparison), opts)
+}
+
+func GenerateSummary(comparison *helmscanTypes.ImageComparisonReport) string {
+	return reports.GenerateComparisonSummary(NewImageReportGenerator(comparison))
+}
+
+func GenerateSingleScanSummary(result helmscanTypes.ScanResult) string {
+	vulns := make(map[string]helmscanTypes.Vulnerability)
+	for _, v := range result.VulnList {
+		vulns[v.ID] = v
+	}
+	return reports.GenerateSingleScanSummary(vulns)
 }
 
 func scanSingleImage(imageURL string, saveReport bool, jsonOutput bool, ignoreUnfixed bool) {
@@ -243,12 +512,7 @@ func scanSingleImage(imageURL string, saveReport bool, jsonOutput bool, ignoreUn
 		return
 	}
 
-	vulns := make(map[string]helmscanTypes.Vulnerability)
-	for _, v := range result.VulnList {
-		vulns[v.ID] = v
-	}
-
-	report := reports.GenerateSingleScanReport("image", imageURL, vulns, jsonOutput, ignoreUnfixed)
+	report := GenerateSingleScanReport(result, jsonOutput, ignoreUnfixed, reports.ReportOptions{})
 
 	if saveReport {
 		ext := ".md"
@@ -263,3 +527,31 @@ func scanSingleImage(imageURL string, saveReport bool, jsonOutput bool, ignoreUn
 
 	fmt.Println(report)
 }
+
+// GenerateSingleScanReport renders the report of a single image scan, in the same form as a
+// single Helm chart scan.
+func GenerateSingleScanReport(result helmscanTypes.ScanResult, jsonOutput bool, ignoreUnfixed bool, opts reports.ReportOptions) string {
+	return generateSingleScanReport("image", result, jsonOutput, ignoreUnfixed, opts)
+}
+
+func generateSingleScanReport(artifactType string, result helmscanTypes.ScanResult, jsonOutput bool, ignoreUnfixed bool, opts reports.ReportOptions) string {
+	result.VulnList = opts.DisplayedVulnerabilities(result.VulnList)
+	vulns := make(map[string]helmscanTypes.Vulnerability)
+	for _, v := range result.VulnList {
+		vulns[v.ID] = v
+	}
+
+	report := reports.NewSingleScanReport(artifactType, result.Image, vulns)
+	report.Secrets = result.Secrets
+	report.Misconfigurations = result.Misconfigurations
+	if opts.Remediation {
+		report.Remediation = reports.NewRemediationPlan(map[string][]helmscanTypes.Vulnerability{result.Image: result.VulnList})
+	}
+	if result.OS.Family != "" {
+		report.OperatingSystems = map[string]helmscanTypes.OperatingSystem{result.Image: result.OS}
+	}
+	if result.RawOutput != "" {
+		report.RawOutputs = map[string]string{result.Image: result.RawOutput}
+	}
+	return reports.GenerateSingleScanReport(report, jsonOutput, ignoreUnfixed, opts)
+}
@@ -0,0 +1,875 @@
+package imageScan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cliffcolvin/helmscan/internal/fakeexec"
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+	"github.com/cliffcolvin/helmscan/internal/reports"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// The fake trivy is configured through the environment, which its child process inherits.
+const (
+	fakeTrivyTestdataEnv = "HELMSCAN_FAKE_TRIVY_TESTDATA"
+	fakeTrivyFixtureEnv  = "HELMSCAN_FAKE_TRIVY_FIXTURE"
+	fakeTrivyVersionEnv  = "HELMSCAN_FAKE_TRIVY_VERSION"
+	fakeTrivyDBEnv       = "HELMSCAN_FAKE_TRIVY_DB"
+	fakeTrivyFailEnv     = "HELMSCAN_FAKE_TRIVY_FAIL"
+	fakeTrivyDelayEnv    = "HELMSCAN_FAKE_TRIVY_DELAY"
+	fakeTrivyLogEnv      = "HELMSCAN_FAKE_TRIVY_LOG"
+	fakeTrivyEventsEnv   = "HELMSCAN_FAKE_TRIVY_EVENTS"
+	fakeTrivyCacheLogEnv = "HELMSCAN_FAKE_TRIVY_CACHE_LOG"
+)
+
+func TestMain(m *testing.M) {
+	fakeexec.Register("trivy", fakeTrivy)
+	fakeexec.Register("cosign", fakeCosign)
+	fakeexec.Main(m)
+}
+
+// fakeTrivy answers trivy --version and trivy version --format json from testdata, or from
+// fakeTrivyDBEnv for the latter when it is set, and writes the fixture to the -o file of every scan. A scan of the target named by fakeTrivyFailEnv fails, and
+// every scan first sleeps for the duration in fakeTrivyDelayEnv. Scans log when they start and end
+// to fakeTrivyEventsEnv, so tests can tell how many ran at once. Every call logs its
+// TRIVY_CACHE_DIR to fakeTrivyCacheLogEnv.
+func fakeTrivy(args []string) int {
+	fakeexec.LogArgs(os.Getenv(fakeTrivyLogEnv), args)
+	fakeexec.LogArgs(os.Getenv(fakeTrivyCacheLogEnv), []string{os.Getenv("TRIVY_CACHE_DIR")})
+	testdata := os.Getenv(fakeTrivyTestdataEnv)
+	switch {
+	case slices.Equal(args, []string{"--version"}):
+		output, err := os.ReadFile(filepath.Join(testdata, "trivy_version.txt"))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if version := os.Getenv(fakeTrivyVersionEnv); version != "" {
+			output = []byte(strings.Replace(string(output), "0.56.2", version, 1))
+		}
+		os.Stdout.Write(output)
+		return 0
+	case slices.Equal(args, []string{"version", "--format", "json"}):
+		if output := os.Getenv(fakeTrivyDBEnv); output != "" {
+			fmt.Print(output)
+			return 0
+		}
+		output, err := os.ReadFile(filepath.Join(testdata, "trivy_version.json"))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		os.Stdout.Write(output)
+		return 0
+	}
+
+	fakeexec.LogArgs(os.Getenv(fakeTrivyEventsEnv), []string{"start"})
+	defer fakeexec.LogArgs(os.Getenv(fakeTrivyEventsEnv), []string{"end"})
+	if delay, err := time.ParseDuration(os.Getenv(fakeTrivyDelayEnv)); err == nil {
+		time.Sleep(delay)
+	}
+	if target := args[len(args)-1]; target == os.Getenv(fakeTrivyFailEnv) {
+		fmt.Fprintf(os.Stderr, "FATAL\tFatal error\timage scan error: scan error: unable to initialize a scanner: unable to find the specified image %q\n", target)
+		return 1
+	}
+	report, err := os.ReadFile(os.Getenv(fakeTrivyFixtureEnv))
+	if err == nil {
+		err = os.WriteFile(fakeexec.Arg(args, "-o"), report, 0644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// useFakeTrivy runs trivy as a fake that reports testdata/fixture for every scan, from a temporary
+// working directory. It returns the file the fake logs its arguments to.
+func useFakeTrivy(t *testing.T, fixture string) string {
+	t.Helper()
+	testdata, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	originalCommand, originalLookPath := execCommand, lookPath
+	execCommand, lookPath = fakeexec.CommandContext, fakeexec.LookPath
+	t.Cleanup(func() { execCommand, lookPath = originalCommand, originalLookPath })
+
+	log := filepath.Join(t.TempDir(), "trivy.log")
+	t.Setenv(fakeTrivyTestdataEnv, testdata)
+	t.Setenv(fakeTrivyFixtureEnv, filepath.Join(testdata, fixture))
+	t.Setenv(fakeTrivyLogEnv, log)
+	t.Chdir(t.TempDir())
+	return log
+}
+
+func TestScanImageParsesTrivyJSON(t *testing.T) {
+	log := useFakeTrivy(t, "trivy_image.json")
+
+	const image = "docker.io/bitnami/redis:7.2.4"
+	result, err := ScanImage(image, true)
+	if err != nil {
+		t.Fatalf("ScanImage() error = %v", err)
+	}
+
+	if result.Image != image {
+		t.Errorf("Image = %q, want %q", result.Image, image)
+	}
+	wantCounts := helmscanTypes.SeverityCounts{Critical: 1, High: 1, Medium: 2, Low: 1}
+	if result.Vulnerabilities != wantCounts {
+		t.Errorf("Vulnerabilities = %+v, want %+v", result.Vulnerabilities, wantCounts)
+	}
+	if want := (helmscanTypes.OperatingSystem{Family: "debian", Name: "12.5"}); result.OS != want {
+		t.Errorf("OS = %+v, want %+v", result.OS, want)
+	}
+	if want := "sha256:3127bd2ec9e8a1b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f9012345ab"; result.RepoDigest != want {
+		t.Errorf("RepoDigest = %q, want %q", result.RepoDigest, want)
+	}
+	if want := []string{"CVE-2023-50495", "CVE-2023-45288"}; !reflect.DeepEqual(result.VulnsByLevel["medium"], want) {
+		t.Errorf("VulnsByLevel[medium] = %v, want %v", result.VulnsByLevel["medium"], want)
+	}
+
+	tests := []struct {
+		id        string
+		severity  string
+		pkg       string
+		installed string
+		fixed     string
+		cvss      float64
+	}{
+		// The highest v3 score of any source is used.
+		{"CVE-2023-45853", "critical", "zlib1g", "1:1.2.13.dfsg-1", "", 9.8},
+		{"CVE-2024-2961", "high", "libc6", "2.36-9+deb12u4", "2.36-9+deb12u7", 7.3},
+		{"CVE-2023-50495", "medium", "libtinfo6", "6.4-4", "", 6.5},
+		// Vulnerabilities with only a v2 score fall back to it.
+		{"CVE-2011-3374", "low", "apt", "2.6.1", "", 4.3},
+		// Vulnerabilities of language packages are included with the OS packages.
+		{"CVE-2023-45288", "medium", "golang.org/x/net", "v0.17.0", "0.23.0", 7.5},
+	}
+	if len(result.VulnList) != len(tests) {
+		t.Fatalf("VulnList has %d vulnerabilities, want %d", len(result.VulnList), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			vuln := result.VulnList[i]
+			got := fmt.Sprintf("%s %s %s %s %s %.1f", vuln.ID, vuln.Severity, vuln.PkgName, vuln.InstalledVersion, vuln.FixedVersion, vuln.CVSS)
+			want := fmt.Sprintf("%s %s %s %s %s %.1f", tt.id, tt.severity, tt.pkg, tt.installed, tt.fixed, tt.cvss)
+			if got != want {
+				t.Errorf("VulnList[%d] = %s, want %s", i, got, want)
+			}
+			if wantURL := "https://avd.aquasec.com/nvd/" + strings.ToLower(tt.id); vuln.PrimaryURL != wantURL {
+				t.Errorf("PrimaryURL = %q, want %q", vuln.PrimaryURL, wantURL)
+			}
+		})
+	}
+
+	wantSecrets := []helmscanTypes.Secret{{
+		Image:    image,
+		Target:   "/opt/bitnami/redis/etc/redis-default.conf",
+		RuleID:   "private-key",
+		Category: "AsymmetricPrivateKey",
+		Severity: "high",
+		Title:    "Asymmetric Private Key",
+		Line:     12,
+	}}
+	if !reflect.DeepEqual(result.Secrets, wantSecrets) {
+		t.Errorf("Secrets = %+v, want %+v", result.Secrets, wantSecrets)
+	}
+	// Passed checks are not misconfigurations.
+	if len(result.Misconfigurations) != 1 || result.Misconfigurations[0].ID != "DS002" || result.Misconfigurations[0].Severity != "high" {
+		t.Errorf("Misconfigurations = %+v, want only the failed DS002 check", result.Misconfigurations)
+	}
+
+	calls := fakeexec.Calls(t, log)
+	if len(calls) != 1 {
+		t.Fatalf("trivy was run %d times, want 1", len(calls))
+	}
+	args := calls[0]
+	if args[0] != "image" || args[len(args)-1] != image {
+		t.Errorf("trivy args = %v, want an image scan of %s", args, image)
+	}
+	if !slices.Contains(args, "--ignore-unfixed") {
+		t.Errorf("trivy args = %v, want --ignore-unfixed", args)
+	}
+}
+
+func TestScanImageErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		image   string
+		fixture string
+		fail    string
+		wantErr string
+	}{
+		{
+			name:    "trivy fails",
+			image:   "docker.io/bitnami/redis:0.0.0-missing",
+			fixture: "trivy_image.json",
+			fail:    "docker.io/bitnami/redis:0.0.0-missing",
+			wantErr: "unable to find the specified image",
+		},
+		{
+			// A truncated report, as when trivy is killed while writing it, fails the scan rather
+			// than passing as an image without vulnerabilities.
+			name:    "corrupt output",
+			image:   "docker.io/bitnami/redis:7.2.4",
+			fixture: "trivy_truncated.json",
+			wantErr: "parsing trivy output",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeTrivy(t, tt.fixture)
+			t.Setenv(fakeTrivyFailEnv, tt.fail)
+
+			_, err := ScanImage(tt.image, false)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ScanImage() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScanImageContextCancellation(t *testing.T) {
+	tests := []struct {
+		name string
+		// ctx returns the context of the scan and the error it ends with, if the scan has to end with
+		// that error rather than with trivy being killed.
+		ctx      func(t *testing.T) (context.Context, error)
+		delay    string
+		wantRuns int
+	}{
+		{
+			name: "cancelled before the scan",
+			ctx: func(t *testing.T) (context.Context, error) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, context.Canceled
+			},
+		},
+		{
+			name: "deadline passed before the scan",
+			ctx: func(t *testing.T) (context.Context, error) {
+				ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+				t.Cleanup(cancel)
+				return ctx, context.DeadlineExceeded
+			},
+		},
+		{
+			name: "deadline passes while trivy runs",
+			ctx: func(t *testing.T) (context.Context, error) {
+				ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+				t.Cleanup(cancel)
+				return ctx, nil
+			},
+			delay:    "1m",
+			wantRuns: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := useFakeTrivy(t, "trivy_image.json")
+			t.Setenv(fakeTrivyDelayEnv, tt.delay)
+			ctx, wantErr := tt.ctx(t)
+
+			start := time.Now()
+			_, err := ScanImageContext(ctx, "docker.io/bitnami/redis:7.2.4", helmscanTypes.ScanOptions{})
+			if err == nil {
+				t.Fatal("ScanImageContext() error = nil, want the scan to be aborted")
+			}
+			if wantErr != nil && !errors.Is(err, wantErr) {
+				t.Errorf("ScanImageContext() error = %v, want %v", err, wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > 30*time.Second {
+				t.Errorf("ScanImageContext() returned after %v, want trivy to be killed when the context ends", elapsed)
+			}
+			if runs := len(fakeexec.Calls(t, log)); runs != tt.wantRuns {
+				t.Errorf("trivy was run %d times, want %d", runs, tt.wantRuns)
+			}
+		})
+	}
+}
+
+func TestScanImageForwardsDBOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts helmscanTypes.ScanOptions
+		// want holds flags that must be passed, each with its value if it takes one.
+		want     [][]string
+		wantNone []string
+	}{
+		{
+			name:     "defaults",
+			wantNone: []string{"--db-repository", "--skip-db-update", "--offline-scan"},
+		},
+		{
+			name:     "DB mirror",
+			opts:     helmscanTypes.ScanOptions{DBRepository: "registry.internal/aquasec/trivy-db:2"},
+			want:     [][]string{{"--db-repository", "registry.internal/aquasec/trivy-db:2"}},
+			wantNone: []string{"--skip-db-update", "--offline-scan"},
+		},
+		{
+			name:     "air-gapped",
+			opts:     helmscanTypes.ScanOptions{SkipDBUpdate: true, OfflineScan: true},
+			want:     [][]string{{"--skip-db-update"}, {"--offline-scan"}},
+			wantNone: []string{"--db-repository"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := useFakeTrivy(t, "trivy_image.json")
+			const image = "docker.io/bitnami/redis:7.2.4"
+			if _, err := ScanImageContext(context.Background(), image, tt.opts); err != nil {
+				t.Fatalf("ScanImageContext() error = %v", err)
+			}
+			calls := fakeexec.Calls(t, log)
+			if len(calls) != 1 {
+				t.Fatalf("trivy was run %d times, want 1", len(calls))
+			}
+			args := calls[0]
+			if args[len(args)-1] != image {
+				t.Errorf("trivy args = %v, want the image last", args)
+			}
+			for _, flag := range tt.want {
+				if !containsArgs(args, flag) {
+					t.Errorf("trivy args = %v, want %v", args, flag)
+				}
+			}
+			for _, arg := range tt.wantNone {
+				if slices.Contains(args, arg) {
+					t.Errorf("trivy args = %v, want no %s", args, arg)
+				}
+			}
+		})
+	}
+}
+
+func TestScanImageMarksKnownExploited(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    helmscanTypes.ScanOptions
+		catalog string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:    "KEV disabled",
+			catalog: `{"vulnerabilities": [{"cveID": "CVE-2024-2961"}]}`,
+		},
+		{
+			name:    "KEV enabled",
+			opts:    helmscanTypes.ScanOptions{KEV: true},
+			catalog: `{"vulnerabilities": [{"cveID": "CVE-2024-2961"}, {"cveID": "CVE-2021-44228"}]}`,
+			want:    []string{"CVE-2024-2961"},
+		},
+		{
+			name:    "unreadable catalog",
+			opts:    helmscanTypes.ScanOptions{KEV: true},
+			catalog: `not json`,
+			wantErr: "error parsing KEV catalog",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeTrivy(t, "trivy_image.json")
+			// A fresh copy of the catalog in the kev package's cache is used without downloading it.
+			cache := filepath.Join("working-files", "tmp", "kev_cache", "known_exploited_vulnerabilities.json")
+			if err := os.MkdirAll(filepath.Dir(cache), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(cache, []byte(tt.catalog), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := ScanImageContext(context.Background(), "docker.io/bitnami/redis:7.2.4", tt.opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ScanImageContext() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ScanImageContext() error = %v", err)
+			}
+			var got []string
+			for _, vuln := range result.VulnList {
+				if vuln.KEV {
+					got = append(got, vuln.ID)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("known exploited CVEs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrivyConfigIsForwarded(t *testing.T) {
+	scans := []struct {
+		subcommand string
+		scan       func(target string, opts helmscanTypes.ScanOptions) error
+	}{
+		{"image", func(target string, opts helmscanTypes.ScanOptions) error {
+			_, err := ScanImageContext(context.Background(), "docker.io/bitnami/redis:7.2.4", opts)
+			return err
+		}},
+		{"fs", func(target string, opts helmscanTypes.ScanOptions) error {
+			_, err := ScanFilesystemContext(context.Background(), target, opts)
+			return err
+		}},
+		{"config", func(target string, opts helmscanTypes.ScanOptions) error {
+			_, err := ScanConfigContext(context.Background(), target, opts)
+			return err
+		}},
+	}
+	for _, scan := range scans {
+		for _, config := range []string{"", "/etc/trivy/trivy.yaml"} {
+			t.Run(fmt.Sprintf("%s config=%q", scan.subcommand, config), func(t *testing.T) {
+				log := useFakeTrivy(t, "trivy_image.json")
+				if err := scan.scan(t.TempDir(), helmscanTypes.ScanOptions{TrivyConfig: config}); err != nil {
+					t.Fatal(err)
+				}
+				calls := fakeexec.Calls(t, log)
+				if len(calls) != 1 {
+					t.Fatalf("trivy was run %d times, want 1", len(calls))
+				}
+				args := calls[0]
+				// --config is a global flag, so it comes before the subcommand and its flags.
+				want := []string{scan.subcommand}
+				if config != "" {
+					want = []string{"--config", config, scan.subcommand}
+				}
+				if len(args) < len(want) || !slices.Equal(args[:len(want)], want) {
+					t.Errorf("trivy args = %v, want them to start with %v", args, want)
+				}
+				if config == "" && slices.Contains(args, "--config") {
+					t.Errorf("trivy args = %v, want no --config", args)
+				}
+			})
+		}
+	}
+}
+
+func TestTrivyCacheDir(t *testing.T) {
+	calls := []struct {
+		name string
+		run  func(opts helmscanTypes.ScanOptions) error
+	}{
+		{"image", func(opts helmscanTypes.ScanOptions) error {
+			_, err := ScanImageContext(context.Background(), "docker.io/bitnami/redis:7.2.4", opts)
+			return err
+		}},
+		{"fs", func(opts helmscanTypes.ScanOptions) error {
+			_, err := ScanFilesystemContext(context.Background(), ".", opts)
+			return err
+		}},
+		{"version", func(opts helmscanTypes.ScanOptions) error {
+			_, err := readTrivyDBMetadata(opts)
+			return err
+		}},
+	}
+	for _, call := range calls {
+		for _, cacheDir := range []string{"", "/var/cache/trivy-job-1"} {
+			t.Run(fmt.Sprintf("%s cache=%q", call.name, cacheDir), func(t *testing.T) {
+				useFakeTrivy(t, "trivy_image.json")
+				cacheLog := filepath.Join(t.TempDir(), "cache.log")
+				t.Setenv(fakeTrivyCacheLogEnv, cacheLog)
+				// An ambient TRIVY_CACHE_DIR is inherited unless --trivy-cache-dir overrides it.
+				t.Setenv("TRIVY_CACHE_DIR", "/home/ci/.cache/trivy")
+
+				if err := call.run(helmscanTypes.ScanOptions{TrivyCacheDir: cacheDir}); err != nil {
+					t.Fatal(err)
+				}
+				want := cacheDir
+				if want == "" {
+					want = "/home/ci/.cache/trivy"
+				}
+				got := fakeexec.Calls(t, cacheLog)
+				if len(got) != 1 || !slices.Equal(got[0], []string{want}) {
+					t.Errorf("trivy TRIVY_CACHE_DIR = %v, want [[%s]]", got, want)
+				}
+			})
+		}
+	}
+}
+
+// containsArgs reports whether want appears in args as consecutive arguments.
+func containsArgs(args, want []string) bool {
+	for i := range args {
+		if len(args)-i >= len(want) && slices.Equal(args[i:i+len(want)], want) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCheckTrivyInstallation(t *testing.T) {
+	tests := []struct {
+		name        string
+		notFound    bool
+		version     string
+		strict      bool
+		wantErr     string
+		wantWarning string
+		wantVersion string
+	}{
+		{name: "installed", version: "0.56.2", strict: true, wantVersion: "0.56.2"},
+		{name: "minimum version", version: MinTrivyVersion, strict: true, wantVersion: MinTrivyVersion},
+		{name: "too old", version: "0.48.3", wantWarning: "older than the minimum supported version", wantVersion: "0.48.3"},
+		{name: "too old with strict", version: "0.48.3", strict: true, wantErr: "Trivy version 0.48.3 is older than the minimum supported version " + MinTrivyVersion},
+		{name: "unrecognized version", version: "dev", wantWarning: `unrecognized Trivy version "dev"`, wantVersion: "dev"},
+		{name: "unrecognized version with strict", version: "dev", strict: true, wantErr: `unrecognized Trivy version "dev"`},
+		{name: "not installed", notFound: true, wantErr: "Trivy is not installed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeTrivy(t, "trivy_image.json")
+			t.Setenv(fakeTrivyVersionEnv, tt.version)
+			if tt.notFound {
+				lookPath = func(file string) (string, error) { return "", errors.New("not found") }
+			}
+			trivyVersion = ""
+			core, logs := observer.New(zapcore.WarnLevel)
+			originalLogger := logger
+			logger = zap.New(core).Sugar()
+			t.Cleanup(func() { logger = originalLogger })
+
+			err := CheckTrivyInstallation(tt.strict)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("CheckTrivyInstallation() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CheckTrivyInstallation() error = %v", err)
+			}
+			if TrivyVersion() != tt.wantVersion {
+				t.Errorf("TrivyVersion() = %q, want %q", TrivyVersion(), tt.wantVersion)
+			}
+			warnings := logs.All()
+			if tt.wantWarning == "" {
+				if len(warnings) != 0 {
+					t.Errorf("logged %q, want no warning", warnings[0].Message)
+				}
+			} else if len(warnings) != 1 || !strings.Contains(warnings[0].Message, tt.wantWarning) {
+				t.Errorf("warnings = %v, want one containing %q", warnings, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestParseTrivyVersion(t *testing.T) {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "trivy_version.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		output  string
+		want    string
+		wantErr bool
+	}{
+		{name: "release", output: string(fixture), want: "0.56.2"},
+		{name: "version line only", output: "Version: 0.52.0\n", want: "0.52.0"},
+		{name: "indented", output: "  Version:   0.57.1  \n", want: "0.57.1"},
+		{name: "no version line", output: "trivy: command not found\n", wantErr: true},
+		{name: "empty", output: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTrivyVersion(tt.output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTrivyVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseTrivyVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeverityCounts(t *testing.T) {
+	tests := []struct {
+		name      string
+		fixture   string
+		wantVulns int
+		want      helmscanTypes.SeverityCounts
+	}{
+		{name: "one package per CVE", fixture: "trivy_image.json", wantVulns: 5, want: helmscanTypes.SeverityCounts{Critical: 1, High: 1, Medium: 2, Low: 1}},
+		{
+			// CVE-2024-2961 affects both libc6 and libc-bin, and is counted once.
+			name:      "CVE in two packages",
+			fixture:   "trivy_duplicate_cve.json",
+			wantVulns: 3,
+			want:      helmscanTypes.SeverityCounts{Critical: 1, High: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeTrivy(t, tt.fixture)
+			result, err := ScanImageContext(context.Background(), "docker.io/bitnami/redis:7.2.4", helmscanTypes.ScanOptions{})
+			if err != nil {
+				t.Fatalf("ScanImageContext() error = %v", err)
+			}
+			if len(result.VulnList) != tt.wantVulns {
+				t.Errorf("VulnList has %d vulnerabilities, want %d", len(result.VulnList), tt.wantVulns)
+			}
+			if got := SeverityCounts(result); got != tt.want {
+				t.Errorf("SeverityCounts() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareScans(t *testing.T) {
+	var (
+		zlib  = helmscanTypes.Vulnerability{ID: "CVE-2023-45853", Severity: "critical", PkgName: "zlib1g"}
+		apt   = helmscanTypes.Vulnerability{ID: "CVE-2011-3374", Severity: "low", PkgName: "apt"}
+		ssh   = helmscanTypes.Vulnerability{ID: "CVE-2024-6387", Severity: "high", PkgName: "openssh-client"}
+		sshed = helmscanTypes.Vulnerability{ID: "CVE-2024-6387", Severity: "high", PkgName: "openssh-client", FixedVersion: "1:9.2p1-2+deb12u3"}
+	)
+	const before, after = "docker.io/bitnami/redis:7.2.4", "docker.io/bitnami/redis:7.4.0"
+	tests := []struct {
+		name          string
+		first, second []helmscanTypes.Vulnerability
+		wantAdded     map[string]map[string]helmscanTypes.Vulnerability
+		wantRemoved   map[string]map[string]helmscanTypes.Vulnerability
+		wantUnchanged map[string]map[string]helmscanTypes.Vulnerability
+	}{
+		{
+			name:   "upgrade",
+			first:  []helmscanTypes.Vulnerability{zlib, apt},
+			second: []helmscanTypes.Vulnerability{apt, ssh},
+			wantAdded: map[string]map[string]helmscanTypes.Vulnerability{
+				ssh.ID: {after: ssh},
+			},
+			wantRemoved: map[string]map[string]helmscanTypes.Vulnerability{
+				zlib.ID: {before: zlib},
+			},
+			wantUnchanged: map[string]map[string]helmscanTypes.Vulnerability{
+				apt.ID: {after: apt},
+			},
+		},
+		{
+			// Unchanged CVEs carry the details of the second scan, such as a newly published fix.
+			name:          "fix published",
+			first:         []helmscanTypes.Vulnerability{ssh},
+			second:        []helmscanTypes.Vulnerability{sshed},
+			wantAdded:     map[string]map[string]helmscanTypes.Vulnerability{},
+			wantRemoved:   map[string]map[string]helmscanTypes.Vulnerability{},
+			wantUnchanged: map[string]map[string]helmscanTypes.Vulnerability{ssh.ID: {after: sshed}},
+		},
+		{
+			name:          "no vulnerabilities",
+			wantAdded:     map[string]map[string]helmscanTypes.Vulnerability{},
+			wantRemoved:   map[string]map[string]helmscanTypes.Vulnerability{},
+			wantUnchanged: map[string]map[string]helmscanTypes.Vulnerability{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparison := CompareScans(
+				helmscanTypes.ScanResult{Image: before, VulnList: tt.first},
+				helmscanTypes.ScanResult{Image: after, VulnList: tt.second},
+			)
+			if !reflect.DeepEqual(comparison.AddedCVEs, tt.wantAdded) {
+				t.Errorf("AddedCVEs = %v, want %v", comparison.AddedCVEs, tt.wantAdded)
+			}
+			if !reflect.DeepEqual(comparison.RemovedCVEs, tt.wantRemoved) {
+				t.Errorf("RemovedCVEs = %v, want %v", comparison.RemovedCVEs, tt.wantRemoved)
+			}
+			if !reflect.DeepEqual(comparison.UnchangedCVEs, tt.wantUnchanged) {
+				t.Errorf("UnchangedCVEs = %v, want %v", comparison.UnchangedCVEs, tt.wantUnchanged)
+			}
+		})
+	}
+}
+
+func TestGenerateSingleScanReport(t *testing.T) {
+	const image = "docker.io/bitnami/redis:7.2.4"
+	tests := []struct {
+		name          string
+		opts          reports.ReportOptions
+		wantSummary   reports.SeveritySummary
+		wantMarkdown  []string
+		wantRemediate bool
+	}{
+		{
+			name:         "default",
+			wantSummary:  reports.SeveritySummary{Critical: 1, High: 1, Medium: 2, Low: 1},
+			wantMarkdown: []string{"# Image Scan Report", image, "CVE-2023-45853", "debian 12.5"},
+		},
+		{
+			name:          "remediation",
+			opts:          reports.ReportOptions{Remediation: true},
+			wantSummary:   reports.SeveritySummary{Critical: 1, High: 1, Medium: 2, Low: 1},
+			wantMarkdown:  []string{"Remediation Plan", "2.36-9+deb12u7"},
+			wantRemediate: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeTrivy(t, "trivy_image.json")
+			result, err := ScanImageContext(context.Background(), image, helmscanTypes.ScanOptions{})
+			if err != nil {
+				t.Fatalf("ScanImageContext() error = %v", err)
+			}
+
+			var report reports.SingleScanReport
+			if err := json.Unmarshal([]byte(GenerateSingleScanReport(result, true, false, tt.opts)), &report); err != nil {
+				t.Fatalf("GenerateSingleScanReport() is not JSON: %v", err)
+			}
+			if report.ArtifactType != "image" || report.ArtifactRef != image {
+				t.Errorf("artifact = %s %s, want image %s", report.ArtifactType, report.ArtifactRef, image)
+			}
+			if report.Summary != tt.wantSummary {
+				t.Errorf("Summary = %+v, want %+v", report.Summary, tt.wantSummary)
+			}
+			if len(report.CVEs) != 5 {
+				t.Errorf("report lists %d CVEs, want 5", len(report.CVEs))
+			}
+			if got := report.OperatingSystems[image]; got.Family != "debian" {
+				t.Errorf("OperatingSystems[%s] = %+v, want debian", image, got)
+			}
+			if got := len(report.Remediation) > 0; got != tt.wantRemediate {
+				t.Errorf("report has a remediation plan: %v, want %v", got, tt.wantRemediate)
+			}
+
+			markdown := GenerateSingleScanReport(result, false, false, tt.opts)
+			for _, want := range tt.wantMarkdown {
+				if !strings.Contains(markdown, want) {
+					t.Errorf("markdown report is missing %q:\n%s", want, markdown)
+				}
+			}
+		})
+	}
+}
+
+func TestScanImageTarball(t *testing.T) {
+	tarball := filepath.Join(t.TempDir(), "redis.tar")
+	if err := os.WriteFile(tarball, []byte("docker save output"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		image string
+		// wantTarget are the last arguments trivy is run with, or nil if it must not run.
+		wantTarget []string
+		wantErr    string
+	}{
+		{
+			name:       "registry image",
+			image:      "docker.io/bitnami/redis:7.2.4",
+			wantTarget: []string{"docker.io/bitnami/redis:7.2.4"},
+		},
+		{
+			name:       "tarball",
+			image:      "file://" + tarball,
+			wantTarget: []string{"--input", tarball},
+		},
+		{
+			name:    "missing tarball",
+			image:   "file://" + filepath.Join(filepath.Dir(tarball), "missing.tar"),
+			wantErr: "error reading image tarball",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := useFakeTrivy(t, "trivy_image.json")
+			result, err := ScanImageContext(context.Background(), tt.image, helmscanTypes.ScanOptions{})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ScanImageContext() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				if calls := fakeexec.Calls(t, log); len(calls) != 0 {
+					t.Errorf("trivy was run %d times, want it not to run", len(calls))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ScanImageContext() error = %v", err)
+			}
+			// The report records the reference as given, including the tarball path.
+			if result.Image != tt.image {
+				t.Errorf("Image = %q, want %q", result.Image, tt.image)
+			}
+			calls := fakeexec.Calls(t, log)
+			if len(calls) != 1 {
+				t.Fatalf("trivy was run %d times, want 1", len(calls))
+			}
+			args := calls[0]
+			if !slices.Equal(args[len(args)-len(tt.wantTarget):], tt.wantTarget) {
+				t.Errorf("trivy args = %v, want them to end with %v", args, tt.wantTarget)
+			}
+			if slices.Contains(args, tt.image) && len(tt.wantTarget) == 2 {
+				t.Errorf("trivy args = %v, want the file:// reference replaced by --input", args)
+			}
+		})
+	}
+}
+
+func TestScanImageIncludeRaw(t *testing.T) {
+	const image = "docker.io/bitnami/redis:7.2.4"
+	fixture, err := os.ReadFile(filepath.Join("testdata", "trivy_image.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		opts    helmscanTypes.ScanOptions
+		wantRaw string
+	}{
+		{name: "not requested"},
+		{
+			name:    "default work directory",
+			opts:    helmscanTypes.ScanOptions{IncludeRaw: true},
+			wantRaw: filepath.Join("working-files", "raw", "docker-io-bitnami-redis-7-2-4_trivy.json"),
+		},
+		{
+			name:    "work directory",
+			opts:    helmscanTypes.ScanOptions{IncludeRaw: true, WorkDir: "scratch"},
+			wantRaw: filepath.Join("scratch", "raw", "docker-io-bitnami-redis-7-2-4_trivy.json"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeTrivy(t, "trivy_image.json")
+
+			result, err := ScanImageContext(context.Background(), image, tt.opts)
+			if err != nil {
+				t.Fatalf("ScanImageContext() error = %v", err)
+			}
+			if result.RawOutput != tt.wantRaw {
+				t.Errorf("RawOutput = %q, want %q", result.RawOutput, tt.wantRaw)
+			}
+			if tt.wantRaw == "" {
+				if _, err := os.Stat(tt.opts.WorkPath("raw")); !os.IsNotExist(err) {
+					t.Errorf("raw output directory exists without IncludeRaw: %v", err)
+				}
+				return
+			}
+			// The raw file is Trivy's output, byte for byte.
+			raw, err := os.ReadFile(tt.wantRaw)
+			if err != nil {
+				t.Fatalf("reading raw output: %v", err)
+			}
+			if !bytes.Equal(raw, fixture) {
+				t.Errorf("raw output differs from Trivy's JSON")
+			}
+		})
+	}
+}
@@ -20,9 +20,13 @@ func (g *ImageReportGenerator) GetTitle() string {
 }
 
 func (g *ImageReportGenerator) GetComparison() map[string]string {
-	return map[string]string{
-		"Comparing images": fmt.Sprintf("%s and %s", g.comparison.Image1.Image, g.comparison.Image2.Image),
+	comparison := map[string]string{
+		"After Image": g.comparison.Image2.Image,
 	}
+	if g.comparison.Image1.Image != "" {
+		comparison["Before Image"] = g.comparison.Image1.Image
+	}
+	return comparison
 }
 
 func (g *ImageReportGenerator) GetSeverityCounts() []reports.SeverityCount {
@@ -32,11 +36,16 @@ func (g *ImageReportGenerator) GetSeverityCounts() []reports.SeverityCount {
 	prevCounts := make(map[string]int)
 	currentCounts := make(map[string]int)
 
-	for _, vuln := range g.comparison.Image1.VulnList {
-		prevCounts[vuln.Severity]++
+	// Count each CVE once per image, as chart comparisons do.
+	for _, images := range VulnerabilitiesByCVE(g.comparison.Image1) {
+		for _, vuln := range images {
+			prevCounts[vuln.Severity]++
+		}
 	}
-	for _, vuln := range g.comparison.Image2.VulnList {
-		currentCounts[vuln.Severity]++
+	for _, images := range VulnerabilitiesByCVE(g.comparison.Image2) {
+		for _, vuln := range images {
+			currentCounts[vuln.Severity]++
+		}
 	}
 
 	for _, severity := range severities {
@@ -54,36 +63,53 @@ func (g *ImageReportGenerator) GetSeverityCounts() []reports.SeverityCount {
 }
 
 func (g *ImageReportGenerator) GetAddedCVEs() map[string]map[string]helmscanTypes.Vulnerability {
-	result := make(map[string]map[string]helmscanTypes.Vulnerability)
-	for severity, vulns := range g.comparison.AddedCVEs {
-		result[severity] = make(map[string]helmscanTypes.Vulnerability)
-		for _, vuln := range vulns {
-			result[severity][vuln.ID] = vuln
-		}
-	}
-	return result
+	return g.comparison.AddedCVEs
 }
 
 func (g *ImageReportGenerator) GetRemovedCVEs() map[string]map[string]helmscanTypes.Vulnerability {
-	result := make(map[string]map[string]helmscanTypes.Vulnerability)
-	for severity, vulns := range g.comparison.RemovedCVEs {
-		result[severity] = make(map[string]helmscanTypes.Vulnerability)
-		for _, vuln := range vulns {
-			result[severity][vuln.ID] = vuln
-		}
-	}
-	return result
+	return g.comparison.RemovedCVEs
 }
 
 func (g *ImageReportGenerator) GetUnchangedCVEs() map[string]map[string]helmscanTypes.Vulnerability {
-	result := make(map[string]map[string]helmscanTypes.Vulnerability)
-	for severity, vulns := range g.comparison.UnchangedCVEs {
-		result[severity] = make(map[string]helmscanTypes.Vulnerability)
-		for _, vuln := range vulns {
-			result[severity][vuln.ID] = vuln
+	return g.comparison.UnchangedCVEs
+}
+
+func (g *ImageReportGenerator) GetSkippedImages() []helmscanTypes.SkippedImage {
+	return nil
+}
+
+func (g *ImageReportGenerator) GetRepositoryChanges() []helmscanTypes.RepositoryChange {
+	return nil
+}
+
+func (g *ImageReportGenerator) GetImageChanges() []helmscanTypes.ImageChange {
+	return nil
+}
+
+func (g *ImageReportGenerator) GetAffectedResources() (map[string][]reports.AffectedResource, map[string][]reports.AffectedResource) {
+	return nil, nil
+}
+
+func (g *ImageReportGenerator) GetOperatingSystems() (map[string]helmscanTypes.OperatingSystem, map[string]helmscanTypes.OperatingSystem) {
+	before := make(map[string]helmscanTypes.OperatingSystem)
+	after := make(map[string]helmscanTypes.OperatingSystem)
+	if g.comparison.Image1.OS.Family != "" {
+		before[g.comparison.Image1.Image] = g.comparison.Image1.OS
+	}
+	if g.comparison.Image2.OS.Family != "" {
+		after[g.comparison.Image2.Image] = g.comparison.Image2.OS
+	}
+	return before, after
+}
+
+func (g *ImageReportGenerator) GetRawOutputs() map[string]string {
+	raw := make(map[string]string)
+	for _, result := range []helmscanTypes.ScanResult{g.comparison.Image1, g.comparison.Image2} {
+		if result.RawOutput != "" {
+			raw[result.Image] = result.RawOutput
 		}
 	}
-	return result
+	return raw
 }
 
 func (g *ImageReportGenerator) GetBaseFilename() string {
@@ -91,3 +117,7 @@ func (g *ImageReportGenerator) GetBaseFilename() string {
 		g.comparison.Image1.Image,
 		g.comparison.Image2.Image)
 }
+
+func (g *ImageReportGenerator) GetReportKind() string {
+	return "image_cmp"
+}
@@ -0,0 +1,74 @@
+package imageScan
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+	"github.com/cliffcolvin/helmscan/internal/reports"
+	"gopkg.in/yaml.v3"
+)
+
+type severityPolicy struct {
+	Overrides []helmscanTypes.SeverityOverride `yaml:"overrides"`
+}
+
+// LoadSeverityPolicy reads a YAML or JSON file of severity overrides. Each override names either a
+// CVE ID or a package, which may be a path.Match glob, and the severity its findings are given.
+func LoadSeverityPolicy(policyPath string) ([]helmscanTypes.SeverityOverride, error) {
+	data, err := os.ReadFile(policyPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading severity policy: %w", err)
+	}
+
+	var policy severityPolicy
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("error parsing severity policy %s: %w", policyPath, err)
+	}
+
+	for i, override := range policy.Overrides {
+		if (override.CVE == "") == (override.Package == "") {
+			return nil, fmt.Errorf("override %d in %s must set exactly one of cve and package", i+1, policyPath)
+		}
+		if _, err := path.Match(override.Package, ""); err != nil {
+			return nil, fmt.Errorf("override %d in %s has an invalid package pattern %q: %w", i+1, policyPath, override.Package, err)
+		}
+		severity := strings.ToLower(override.Severity)
+		if reports.SeverityValue(severity) == 0 {
+			return nil, fmt.Errorf("override %d in %s has severity %q, expected critical, high, medium or low", i+1, policyPath, override.Severity)
+		}
+		policy.Overrides[i].Severity = severity
+	}
+	return policy.Overrides, nil
+}
+
+// applySeverityOverrides gives each vulnerability the severity of the first override matching it,
+// logging every change so reclassified findings can be traced back to the policy.
+func applySeverityOverrides(imageName string, vulns []helmscanTypes.Vulnerability, overrides []helmscanTypes.SeverityOverride) {
+	for i := range vulns {
+		for _, override := range overrides {
+			if !overrideMatches(override, vulns[i]) {
+				continue
+			}
+			if vulns[i].Severity != override.Severity {
+				logger.Infof("Severity policy changed %s in package %s of %s from %s to %s",
+					vulns[i].ID, vulns[i].PkgName, imageName, vulns[i].Severity, override.Severity)
+				vulns[i].Severity = override.Severity
+			}
+			break
+		}
+	}
+}
+
+func overrideMatches(override helmscanTypes.SeverityOverride, vuln helmscanTypes.Vulnerability) bool {
+	if override.CVE != "" {
+		return strings.EqualFold(override.CVE, vuln.ID)
+	}
+	matched, _ := path.Match(override.Package, vuln.PkgName)
+	return matched
+}
@@ -0,0 +1,192 @@
+package imageScan
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func TestLoadSeverityPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		want    []helmscanTypes.SeverityOverride
+		wantErr string
+	}{
+		{
+			name: "yaml",
+			policy: `overrides:
+  - cve: CVE-2023-45853
+    severity: LOW
+    reason: zlib is not reachable
+  - package: "golang.org/x/*"
+    severity: medium
+`,
+			want: []helmscanTypes.SeverityOverride{
+				{CVE: "CVE-2023-45853", Severity: "low", Reason: "zlib is not reachable"},
+				{Package: "golang.org/x/*", Severity: "medium"},
+			},
+		},
+		{
+			name:   "json",
+			policy: `{"overrides": [{"cve": "CVE-2024-2961", "severity": "critical"}]}`,
+			want:   []helmscanTypes.SeverityOverride{{CVE: "CVE-2024-2961", Severity: "critical"}},
+		},
+		{name: "empty", policy: "overrides: []\n", want: []helmscanTypes.SeverityOverride{}},
+		{
+			name:    "unknown field",
+			policy:  "overrides:\n  - cve: CVE-2024-2961\n    level: low\n",
+			wantErr: "error parsing severity policy",
+		},
+		{
+			name:    "cve and package",
+			policy:  "overrides:\n  - cve: CVE-2024-2961\n    package: libc6\n    severity: low\n",
+			wantErr: "override 1 in policy.yaml must set exactly one of cve and package",
+		},
+		{
+			name:    "neither cve nor package",
+			policy:  "overrides:\n  - severity: low\n",
+			wantErr: "must set exactly one of cve and package",
+		},
+		{
+			name:    "invalid pattern",
+			policy:  "overrides:\n  - package: \"lib[\"\n    severity: low\n",
+			wantErr: `invalid package pattern "lib["`,
+		},
+		{
+			name:    "unknown severity",
+			policy:  "overrides:\n  - cve: CVE-2024-2961\n    severity: negligible\n",
+			wantErr: `has severity "negligible", expected critical, high, medium or low`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			if err := os.WriteFile("policy.yaml", []byte(tt.policy), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := LoadSeverityPolicy("policy.yaml")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadSeverityPolicy() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadSeverityPolicy() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadSeverityPolicy() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := LoadSeverityPolicy(filepath.Join(t.TempDir(), "missing.yaml"))
+		if err == nil || !strings.Contains(err.Error(), "error reading severity policy") {
+			t.Errorf("LoadSeverityPolicy() error = %v, want a read error", err)
+		}
+	})
+}
+
+func TestApplySeverityOverrides(t *testing.T) {
+	vulns := []helmscanTypes.Vulnerability{
+		{ID: "CVE-2023-45853", PkgName: "zlib1g", Severity: "critical"},
+		{ID: "CVE-2024-2961", PkgName: "libc6", Severity: "high"},
+		{ID: "CVE-2023-45288", PkgName: "golang.org/x/net", Severity: "medium"},
+	}
+	tests := []struct {
+		name      string
+		overrides []helmscanTypes.SeverityOverride
+		want      []string
+	}{
+		{name: "no overrides", want: []string{"critical", "high", "medium"}},
+		{
+			name:      "cve ignores case",
+			overrides: []helmscanTypes.SeverityOverride{{CVE: "cve-2023-45853", Severity: "low"}},
+			want:      []string{"low", "high", "medium"},
+		},
+		{
+			name:      "package glob",
+			overrides: []helmscanTypes.SeverityOverride{{Package: "golang.org/x/*", Severity: "critical"}},
+			want:      []string{"critical", "high", "critical"},
+		},
+		{
+			name: "first match wins",
+			overrides: []helmscanTypes.SeverityOverride{
+				{Package: "lib*", Severity: "medium"},
+				{CVE: "CVE-2024-2961", Severity: "low"},
+			},
+			want: []string{"critical", "medium", "medium"},
+		},
+		{
+			name:      "no match",
+			overrides: []helmscanTypes.SeverityOverride{{Package: "openssl", Severity: "low"}},
+			want:      []string{"critical", "high", "medium"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := slices.Clone(vulns)
+			applySeverityOverrides("redis", got, tt.overrides)
+
+			severities := make([]string, len(got))
+			for i, vuln := range got {
+				severities[i] = vuln.Severity
+			}
+			if !reflect.DeepEqual(severities, tt.want) {
+				t.Errorf("severities = %v, want %v", severities, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanImageSeverityOverrides(t *testing.T) {
+	tests := []struct {
+		name       string
+		overrides  []helmscanTypes.SeverityOverride
+		wantCounts helmscanTypes.SeverityCounts
+		wantLow    []string
+	}{
+		{
+			name:       "no policy",
+			wantCounts: helmscanTypes.SeverityCounts{Critical: 1, High: 1, Medium: 2, Low: 1},
+			wantLow:    []string{"CVE-2011-3374"},
+		},
+		{
+			name:       "critical downgraded",
+			overrides:  []helmscanTypes.SeverityOverride{{CVE: "CVE-2023-45853", Severity: "low"}},
+			wantCounts: helmscanTypes.SeverityCounts{High: 1, Medium: 2, Low: 2},
+			wantLow:    []string{"CVE-2023-45853", "CVE-2011-3374"},
+		},
+		{
+			name:       "package upgraded",
+			overrides:  []helmscanTypes.SeverityOverride{{Package: "apt", Severity: "high"}},
+			wantCounts: helmscanTypes.SeverityCounts{Critical: 1, High: 2, Medium: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeTrivy(t, "trivy_image.json")
+
+			result, err := ScanImageContext(context.Background(), "docker.io/bitnami/redis:7.2.4",
+				helmscanTypes.ScanOptions{SeverityOverrides: tt.overrides})
+			if err != nil {
+				t.Fatalf("ScanImageContext() error = %v", err)
+			}
+			if result.Vulnerabilities != tt.wantCounts {
+				t.Errorf("Vulnerabilities = %+v, want %+v", result.Vulnerabilities, tt.wantCounts)
+			}
+			if got := result.VulnsByLevel["low"]; !reflect.DeepEqual(got, tt.wantLow) {
+				t.Errorf("VulnsByLevel[low] = %v, want %v", got, tt.wantLow)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package imageScan
+
+import (
+	"context"
+	"strings"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+// VerifySignatureContext checks the signature of reference with cosign verify, against
+// opts.CosignKey or, without a key, the keyless opts.CosignIdentity and opts.CosignIssuer. A
+// failure to verify, including cosign itself failing to run, is recorded in the result rather
+// than returned, so an unsigned image never stops a scan.
+func VerifySignatureContext(ctx context.Context, reference string, opts helmscanTypes.ScanOptions) helmscanTypes.SignatureVerification {
+	args := []string{"verify"}
+	if opts.CosignKey != "" {
+		args = append(args, "--key", opts.CosignKey)
+	} else {
+		args = append(args, "--certificate-identity-regexp", opts.CosignIdentity, "--certificate-oidc-issuer-regexp", opts.CosignIssuer)
+	}
+	args = append(args, reference)
+
+	cmd := execCommand(ctx, "cosign", args...)
+	cmd.Env = opts.CommandEnv()
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return helmscanTypes.SignatureVerification{Error: cosignError(output, err)}
+	}
+	return helmscanTypes.SignatureVerification{Verified: true}
+}
+
+// cosignError returns the last "Error:" line cosign printed, which says why verification failed,
+// or err when there is none.
+func cosignError(output []byte, err error) string {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if message, ok := strings.CutPrefix(strings.TrimSpace(lines[i]), "Error: "); ok {
+			return message
+		}
+	}
+	return err.Error()
+}
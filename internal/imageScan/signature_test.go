@@ -0,0 +1,135 @@
+package imageScan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cliffcolvin/helmscan/internal/fakeexec"
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+// The fake cosign is configured through the environment, which its child process inherits.
+const (
+	fakeCosignSignedEnv = "HELMSCAN_FAKE_COSIGN_SIGNED"
+	fakeCosignLogEnv    = "HELMSCAN_FAKE_COSIGN_LOG"
+)
+
+// fakeCosign verifies the image references listed in fakeCosignSignedEnv, fails like cosign for an
+// unsigned image, and crashes without an error line for a reference named "crash".
+func fakeCosign(args []string) int {
+	fakeexec.LogArgs(os.Getenv(fakeCosignLogEnv), args)
+	reference := args[len(args)-1]
+	switch {
+	case slices.Contains(strings.Split(os.Getenv(fakeCosignSignedEnv), ","), reference):
+		fmt.Fprintf(os.Stderr, "\nVerification for %s --\nThe following checks were performed on each of these signatures:\n", reference)
+		fmt.Println(`[{"critical":{"type":"cosign container image signature"}}]`)
+		return 0
+	case reference == "crash":
+		fmt.Fprintln(os.Stderr, "panic: runtime error")
+		return 2
+	}
+	fmt.Fprintf(os.Stderr, "Error: no signatures found\nmain.go:74: error during command execution: no signatures found\n")
+	return 1
+}
+
+// useFakeCosign runs cosign as a fake that verifies the signed references, and returns the file it
+// logs its arguments to.
+func useFakeCosign(t *testing.T, signed ...string) string {
+	t.Helper()
+	original := execCommand
+	execCommand = fakeexec.CommandContext
+	t.Cleanup(func() { execCommand = original })
+
+	log := filepath.Join(t.TempDir(), "cosign.log")
+	t.Setenv(fakeCosignSignedEnv, strings.Join(signed, ","))
+	t.Setenv(fakeCosignLogEnv, log)
+	return log
+}
+
+func TestVerifySignatureContext(t *testing.T) {
+	const signed, unsigned = "docker.io/bitnami/redis:7.2.4", "docker.io/library/busybox:latest"
+	keyless := helmscanTypes.ScanOptions{CosignIdentity: "https://github.com/bitnami/.*", CosignIssuer: "https://token.actions.githubusercontent.com"}
+	tests := []struct {
+		name      string
+		reference string
+		opts      helmscanTypes.ScanOptions
+		wantArgs  []string
+		want      helmscanTypes.SignatureVerification
+	}{
+		{
+			name:      "key",
+			reference: signed,
+			opts:      helmscanTypes.ScanOptions{CosignKey: "cosign.pub"},
+			wantArgs:  []string{"verify", "--key", "cosign.pub", signed},
+			want:      helmscanTypes.SignatureVerification{Verified: true},
+		},
+		{
+			name:      "keyless",
+			reference: signed,
+			opts:      keyless,
+			wantArgs: []string{"verify", "--certificate-identity-regexp", "https://github.com/bitnami/.*",
+				"--certificate-oidc-issuer-regexp", "https://token.actions.githubusercontent.com", signed},
+			want: helmscanTypes.SignatureVerification{Verified: true},
+		},
+		{
+			name:      "unsigned",
+			reference: unsigned,
+			opts:      helmscanTypes.ScanOptions{CosignKey: "cosign.pub"},
+			wantArgs:  []string{"verify", "--key", "cosign.pub", unsigned},
+			want:      helmscanTypes.SignatureVerification{Error: "no signatures found"},
+		},
+		{
+			// Failures to run are recorded like unsigned images rather than returned.
+			name:      "cosign fails",
+			reference: "crash",
+			opts:      keyless,
+			want:      helmscanTypes.SignatureVerification{Error: "exit status 2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := useFakeCosign(t, signed)
+
+			if got := VerifySignatureContext(context.Background(), tt.reference, tt.opts); got != tt.want {
+				t.Errorf("VerifySignatureContext() = %+v, want %+v", got, tt.want)
+			}
+			calls := fakeexec.Calls(t, log)
+			if len(calls) != 1 {
+				t.Fatalf("cosign was run %d times, want 1", len(calls))
+			}
+			if tt.wantArgs != nil && !slices.Equal(calls[0], tt.wantArgs) {
+				t.Errorf("cosign args = %q, want %q", calls[0], tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestCosignError(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{
+			name:   "error line",
+			output: "Error: no matching signatures: none of the expected identities matched\nmain.go:74: error during command execution\n",
+			want:   "no matching signatures: none of the expected identities matched",
+		},
+		{name: "last error line", output: "Error: first\nError: second\n", want: "second"},
+		{name: "no error line", output: "panic: runtime error\n", want: "exit status 2"},
+		{name: "no output", want: "exit status 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cosignError([]byte(tt.output), errors.New("exit status 2")); got != tt.want {
+				t.Errorf("cosignError() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package imageScan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+// DefaultDBMaxAge is how old the Trivy vulnerability DB may be before a scan warns about it.
+const DefaultDBMaxAge = 48 * time.Hour
+
+type trivyVersionOutput struct {
+	VulnerabilityDB *trivyDBMetadata `json:"VulnerabilityDB"`
+}
+
+type trivyDBMetadata struct {
+	UpdatedAt  time.Time `json:"UpdatedAt"`
+	NextUpdate time.Time `json:"NextUpdate"`
+}
+
+// trivyDBUpdatedAt caches the DB update time TrivyDBUpdatedAt read, which stays fresh until the
+// next vulnerability scan, the only thing that updates the DB.
+var trivyDBUpdatedAt struct {
+	sync.Mutex
+	value string
+	fresh bool
+}
+
+// markTrivyDBStale makes the next TrivyDBUpdatedAt call read the DB metadata again.
+func markTrivyDBStale() {
+	trivyDBUpdatedAt.Lock()
+	trivyDBUpdatedAt.fresh = false
+	trivyDBUpdatedAt.Unlock()
+}
+
+// CheckTrivyDB warns, or fails when strict is set, if the local Trivy vulnerability DB is older
+// than maxAge and the scan will not refresh it. Trivy downloads a new DB itself once its
+// NextUpdate time has passed unless opts.SkipDBUpdate is set, so a DB due for refresh is not stale.
+// A maxAge of zero disables the check.
+func CheckTrivyDB(maxAge time.Duration, opts helmscanTypes.ScanOptions, strict bool) error {
+	if maxAge <= 0 {
+		return nil
+	}
+
+	db, err := readTrivyDBMetadata(opts)
+	if err != nil {
+		if strict {
+			return err
+		}
+		logger.Warnf("%v; skipping the DB age check", err)
+		return nil
+	}
+	if db == nil {
+		logger.Info("No Trivy vulnerability DB found; Trivy will download it on the first scan")
+		return nil
+	}
+
+	now := time.Now()
+	age := now.Sub(db.UpdatedAt)
+	if age <= maxAge {
+		return nil
+	}
+	if !opts.SkipDBUpdate && now.After(db.NextUpdate) {
+		logger.Infof("Trivy vulnerability DB is %s old and will be updated on the first scan", age.Round(time.Hour))
+		return nil
+	}
+
+	err = fmt.Errorf("Trivy vulnerability DB was last updated %s (%s ago), older than the maximum age of %s",
+		db.UpdatedAt.UTC().Format(time.RFC3339), age.Round(time.Hour), maxAge)
+	if strict {
+		return err
+	}
+	logger.Warnf("%v; recent CVEs may be missing from the results", err)
+	return nil
+}
+
+// TrivyDBUpdatedAt returns when the Trivy vulnerability DB used by the scans was last updated,
+// or "" when it is unknown. It is read again on the first call after each scan, so it reflects
+// any update Trivy made during the scans, and is safe to call from concurrent reports.
+func TrivyDBUpdatedAt(opts helmscanTypes.ScanOptions) string {
+	if trivyVersion == "" {
+		return ""
+	}
+	trivyDBUpdatedAt.Lock()
+	defer trivyDBUpdatedAt.Unlock()
+	if trivyDBUpdatedAt.fresh {
+		return trivyDBUpdatedAt.value
+	}
+	trivyDBUpdatedAt.fresh = true
+	trivyDBUpdatedAt.value = ""
+
+	db, err := readTrivyDBMetadata(opts)
+	if err != nil {
+		logger.Warnf("Could not read Trivy DB metadata: %v", err)
+		return ""
+	}
+	if db != nil {
+		trivyDBUpdatedAt.value = db.UpdatedAt.UTC().Format(time.RFC3339)
+	}
+	return trivyDBUpdatedAt.value
+}
+
+func readTrivyDBMetadata(opts helmscanTypes.ScanOptions) (*trivyDBMetadata, error) {
+	cmd := execCommand(context.Background(), "trivy", "version", "--format", "json")
+	cmd.Env = opts.CommandEnv()
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get Trivy DB metadata: %v", err)
+	}
+	return parseTrivyDBMetadata(output)
+}
+
+func parseTrivyDBMetadata(output []byte) (*trivyDBMetadata, error) {
+	var version trivyVersionOutput
+	if err := json.Unmarshal(output, &version); err != nil {
+		return nil, fmt.Errorf("Failed to parse Trivy DB metadata: %v", err)
+	}
+	if version.VulnerabilityDB == nil || version.VulnerabilityDB.UpdatedAt.IsZero() {
+		return nil, nil
+	}
+	return version.VulnerabilityDB, nil
+}
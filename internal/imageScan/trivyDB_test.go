@@ -0,0 +1,182 @@
+package imageScan
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cliffcolvin/helmscan/internal/fakeexec"
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestTrivyDBUpdatedAt(t *testing.T) {
+	tests := []struct {
+		name         string
+		trivyVersion string
+		// scanBetween scans an image between the first and second round of reports.
+		scanBetween bool
+		want        string
+		wantReads   int
+	}{
+		{name: "trivy not checked", want: "", wantReads: 0},
+		{name: "read once for concurrent reports", trivyVersion: "0.56.2", want: "2024-11-05T00:30:29Z", wantReads: 1},
+		{name: "read again after a scan", trivyVersion: "0.56.2", scanBetween: true, want: "2024-11-05T00:30:29Z", wantReads: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := useFakeTrivy(t, "trivy_image.json")
+			originalVersion := trivyVersion
+			trivyVersion = tt.trivyVersion
+			t.Cleanup(func() { trivyVersion = originalVersion })
+			markTrivyDBStale()
+
+			reports := func() {
+				var wg sync.WaitGroup
+				for range 8 {
+					wg.Go(func() {
+						if got := TrivyDBUpdatedAt(helmscanTypes.ScanOptions{}); got != tt.want {
+							t.Errorf("TrivyDBUpdatedAt() = %q, want %q", got, tt.want)
+						}
+					})
+				}
+				wg.Wait()
+			}
+			reports()
+			if tt.scanBetween {
+				if _, err := ScanImage("docker.io/bitnami/redis:7.2.4", false); err != nil {
+					t.Fatal(err)
+				}
+			}
+			reports()
+
+			reads := 0
+			for _, args := range fakeexec.Calls(t, log) {
+				if slices.Equal(args, []string{"version", "--format", "json"}) {
+					reads++
+				}
+			}
+			if reads != tt.wantReads {
+				t.Errorf("read the DB metadata %d times, want %d", reads, tt.wantReads)
+			}
+		})
+	}
+}
+
+func TestCheckTrivyDB(t *testing.T) {
+	dbMetadata := func(updatedAgo, nextUpdateIn time.Duration) string {
+		now := time.Now()
+		return fmt.Sprintf(`{"Version": "0.56.2", "VulnerabilityDB": {"UpdatedAt": %q, "NextUpdate": %q}}`,
+			now.Add(-updatedAgo).Format(time.RFC3339Nano), now.Add(nextUpdateIn).Format(time.RFC3339Nano))
+	}
+	tests := []struct {
+		name   string
+		maxAge time.Duration
+		// db is the output of trivy version --format json.
+		db           string
+		skipDBUpdate bool
+		strict       bool
+		wantErr      string
+		wantLog      string
+		wantReads    int
+	}{
+		{
+			name:      "fresh",
+			maxAge:    48 * time.Hour,
+			db:        dbMetadata(time.Hour, 23*time.Hour),
+			wantReads: 1,
+		},
+		{
+			name:   "check disabled",
+			maxAge: 0,
+			db:     dbMetadata(30*24*time.Hour, -29*24*time.Hour),
+		},
+		{
+			// Trivy downloads a DB that is past its NextUpdate itself.
+			name:      "old DB due for update",
+			maxAge:    48 * time.Hour,
+			db:        dbMetadata(72*time.Hour, -48*time.Hour),
+			wantLog:   "will be updated on the first scan",
+			wantReads: 1,
+		},
+		{
+			name:         "old DB not updated",
+			maxAge:       48 * time.Hour,
+			db:           dbMetadata(72*time.Hour, -48*time.Hour),
+			skipDBUpdate: true,
+			wantLog:      "older than the maximum age of 48h0m0s; recent CVEs may be missing",
+			wantReads:    1,
+		},
+		{
+			name:         "old DB not updated with strict",
+			maxAge:       48 * time.Hour,
+			db:           dbMetadata(72*time.Hour, -48*time.Hour),
+			skipDBUpdate: true,
+			strict:       true,
+			wantErr:      "older than the maximum age of 48h0m0s",
+			wantReads:    1,
+		},
+		{
+			// A DB Trivy has not yet updated is not stale before its NextUpdate.
+			name:      "old DB before next update",
+			maxAge:    48 * time.Hour,
+			db:        dbMetadata(72*time.Hour, time.Hour),
+			wantLog:   "older than the maximum age",
+			wantReads: 1,
+		},
+		{
+			name:      "no DB",
+			maxAge:    48 * time.Hour,
+			db:        `{"Version": "0.56.2"}`,
+			wantLog:   "No Trivy vulnerability DB found",
+			wantReads: 1,
+		},
+		{
+			name:      "unreadable metadata",
+			maxAge:    48 * time.Hour,
+			db:        "Version: 0.56.2",
+			wantLog:   "Failed to parse Trivy DB metadata",
+			wantReads: 1,
+		},
+		{
+			name:      "unreadable metadata with strict",
+			maxAge:    48 * time.Hour,
+			db:        "Version: 0.56.2",
+			strict:    true,
+			wantErr:   "Failed to parse Trivy DB metadata",
+			wantReads: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := useFakeTrivy(t, "trivy_image.json")
+			t.Setenv(fakeTrivyDBEnv, tt.db)
+			core, logs := observer.New(zapcore.InfoLevel)
+			originalLogger := logger
+			logger = zap.New(core).Sugar()
+			t.Cleanup(func() { logger = originalLogger })
+
+			err := CheckTrivyDB(tt.maxAge, helmscanTypes.ScanOptions{SkipDBUpdate: tt.skipDBUpdate}, tt.strict)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("CheckTrivyDB() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("CheckTrivyDB() error = %v, want %q", err, tt.wantErr)
+			}
+			if tt.wantLog == "" && logs.Len() != 0 {
+				t.Errorf("logs = %v, want none", logs.All())
+			}
+			if tt.wantLog != "" && logs.FilterMessageSnippet(tt.wantLog).Len() == 0 {
+				t.Errorf("logs = %v, want %q", logs.All(), tt.wantLog)
+			}
+			if reads := len(fakeexec.Calls(t, log)); reads != tt.wantReads {
+				t.Errorf("trivy was run %d times, want %d", reads, tt.wantReads)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package imageScan
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+// trivyProcessMemory is roughly what one Trivy process needs once it has loaded the vulnerability DB.
+const trivyProcessMemory = 1 << 30
+
+var (
+	trivySlotsMu sync.Mutex
+	trivySlots   = make(map[int]chan struct{})
+)
+
+// DefaultMaxParallel returns how many Trivy processes fit in the memory available to helmscan, one
+// per trivyProcessMemory, at most one per CPU. It is one per CPU when the memory cannot be detected.
+var DefaultMaxParallel = sync.OnceValue(func() int {
+	limit := runtime.NumCPU()
+	if memory, ok := availableMemory(); ok {
+		limit = min(limit, int(memory/trivyProcessMemory))
+	}
+	return max(limit, 1)
+})
+
+// acquireTrivySlot waits until fewer than opts.MaxParallel Trivy processes are running, across every
+// chart and image being scanned, and returns the function that gives the slot back.
+func acquireTrivySlot(ctx context.Context, opts helmscanTypes.ScanOptions) (func(), error) {
+	limit := opts.MaxParallel
+	if limit <= 0 {
+		limit = DefaultMaxParallel()
+	}
+	trivySlotsMu.Lock()
+	slots, ok := trivySlots[limit]
+	if !ok {
+		slots = make(chan struct{}, limit)
+		trivySlots[limit] = slots
+	}
+	trivySlotsMu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// availableMemory returns the smaller of the memory Linux reports as available and the cgroup
+// memory limit, which is what a CI container is actually allowed to use.
+func availableMemory() (uint64, bool) {
+	memory, ok := memInfoAvailable()
+	for _, path := range []string{"/sys/fs/cgroup/memory.max", "/sys/fs/cgroup/memory/memory.limit_in_bytes"} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		// cgroup v2 writes "max" when unlimited, which fails to parse and is skipped.
+		limit, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			continue
+		}
+		if !ok || limit < memory {
+			memory, ok = limit, true
+		}
+	}
+	return memory, ok
+}
+
+func memInfoAvailable() (uint64, bool) {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemAvailable:" {
+			continue
+		}
+		kilobytes, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		return kilobytes * 1024, true
+	}
+	return 0, false
+}
@@ -0,0 +1,112 @@
+package imageScan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cliffcolvin/helmscan/internal/fakeexec"
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func TestMaxParallelLimitsTrivyProcesses(t *testing.T) {
+	const images = 6
+	tests := []struct {
+		name        string
+		maxParallel int
+		want        int
+	}{
+		{name: "one at a time", maxParallel: 1, want: 1},
+		{name: "two at a time", maxParallel: 2, want: 2},
+		{name: "three at a time", maxParallel: 3, want: 3},
+		{name: "more slots than images", maxParallel: 10, want: images},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeTrivy(t, "trivy_image.json")
+			events := filepath.Join(t.TempDir(), "events.log")
+			t.Setenv(fakeTrivyEventsEnv, events)
+			t.Setenv(fakeTrivyDelayEnv, "200ms")
+
+			var wg sync.WaitGroup
+			errs := make([]error, images)
+			for i := range images {
+				wg.Go(func() {
+					image := fmt.Sprintf("docker.io/bitnami/app-%d:1.0.0", i)
+					_, errs[i] = ScanImageContext(context.Background(), image, helmscanTypes.ScanOptions{MaxParallel: tt.maxParallel})
+				})
+			}
+			wg.Wait()
+			if err := errors.Join(errs...); err != nil {
+				t.Fatalf("ScanImageContext() error = %v", err)
+			}
+
+			running, most, started := 0, 0, 0
+			for _, event := range fakeexec.Calls(t, events) {
+				switch event[0] {
+				case "start":
+					running++
+					started++
+				case "end":
+					running--
+				}
+				most = max(most, running)
+			}
+			if started != images {
+				t.Fatalf("trivy scanned %d images, want %d", started, images)
+			}
+			if most != tt.want {
+				t.Errorf("at most %d trivy processes ran at once, want %d", most, tt.want)
+			}
+		})
+	}
+}
+
+func TestAcquireTrivySlotCancellation(t *testing.T) {
+	opts := helmscanTypes.ScanOptions{MaxParallel: 1}
+	release, err := acquireTrivySlot(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("acquireTrivySlot() error = %v", err)
+	}
+	defer release()
+
+	tests := []struct {
+		name string
+		ctx  func() (context.Context, context.CancelFunc)
+		want error
+	}{
+		{name: "canceled", ctx: func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			return ctx, cancel
+		}, want: context.Canceled},
+		{name: "deadline while waiting", ctx: func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 50*time.Millisecond)
+		}, want: context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			// The only slot is taken, so the wait ends only with the context.
+			if _, err := acquireTrivySlot(ctx, opts); !errors.Is(err, tt.want) {
+				t.Errorf("acquireTrivySlot() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultMaxParallel(t *testing.T) {
+	got := DefaultMaxParallel()
+	if got < 1 || got > runtime.NumCPU() {
+		t.Errorf("DefaultMaxParallel() = %d, want between 1 and the %d CPUs", got, runtime.NumCPU())
+	}
+	if memory, ok := availableMemory(); ok && memory >= trivyProcessMemory && uint64(got) > memory/trivyProcessMemory {
+		t.Errorf("DefaultMaxParallel() = %d, more Trivy processes than fit in %d bytes", got, memory)
+	}
+}
@@ -0,0 +1,149 @@
+package imageScan
+
+import (
+	"encoding/json"
+	"strings"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+type trivyOutput struct {
+	Metadata trivyMetadata `json:"Metadata"`
+	Results  []trivyResult `json:"Results"`
+}
+
+type trivyMetadata struct {
+	OS          helmscanTypes.OperatingSystem `json:"OS"`
+	RepoDigests []string                      `json:"RepoDigests"`
+}
+
+// repoDigest returns the digest the scanned image resolved to in its registry, or "" for images
+// Trivy could not tie to one, such as tarballs.
+func (m trivyMetadata) repoDigest() string {
+	for _, repoDigest := range m.RepoDigests {
+		if _, digest, found := strings.Cut(repoDigest, "@"); found {
+			return digest
+		}
+	}
+	return ""
+}
+
+type trivyResult struct {
+	Target            string                  `json:"Target"`
+	Vulnerabilities   []trivyVulnerability    `json:"Vulnerabilities"`
+	Secrets           []trivySecret           `json:"Secrets"`
+	Misconfigurations []trivyMisconfiguration `json:"Misconfigurations"`
+}
+
+type trivyVulnerability struct {
+	VulnerabilityID  string               `json:"VulnerabilityID"`
+	PkgName          string               `json:"PkgName"`
+	InstalledVersion string               `json:"InstalledVersion"`
+	FixedVersion     string               `json:"FixedVersion"`
+	Severity         string               `json:"Severity"`
+	CVSS             map[string]trivyCVSS `json:"CVSS"`
+	Title            string               `json:"Title"`
+	Description      string               `json:"Description"`
+	PrimaryURL       string               `json:"PrimaryURL"`
+	References       []string             `json:"References"`
+}
+
+type trivyCVSS struct {
+	V2Score float64 `json:"V2Score"`
+	V3Score float64 `json:"V3Score"`
+}
+
+// cvssScore returns the highest CVSS v3 score any source gives the vulnerability, falling back to
+// the highest v2 score, or 0 when no source scored it.
+func (v trivyVulnerability) cvssScore() float64 {
+	var v2, v3 float64
+	for _, cvss := range v.CVSS {
+		v2 = max(v2, cvss.V2Score)
+		v3 = max(v3, cvss.V3Score)
+	}
+	if v3 > 0 {
+		return v3
+	}
+	return v2
+}
+
+type trivySecret struct {
+	RuleID    string `json:"RuleID"`
+	Category  string `json:"Category"`
+	Severity  string `json:"Severity"`
+	Title     string `json:"Title"`
+	StartLine int    `json:"StartLine"`
+}
+
+type trivyMisconfiguration struct {
+	ID       string `json:"ID"`
+	Title    string `json:"Title"`
+	Message  string `json:"Message"`
+	Severity string `json:"Severity"`
+	Status   string `json:"Status"`
+}
+
+func parseTrivyOutput(data []byte) (trivyOutput, error) {
+	var output trivyOutput
+	err := json.Unmarshal(data, &output)
+	return output, err
+}
+
+func (o trivyOutput) vulnerabilities() []helmscanTypes.Vulnerability {
+	var vulns []helmscanTypes.Vulnerability
+	for _, res := range o.Results {
+		for _, vuln := range res.Vulnerabilities {
+			vulns = append(vulns, helmscanTypes.Vulnerability{
+				ID:               vuln.VulnerabilityID,
+				Severity:         strings.ToLower(vuln.Severity),
+				PkgName:          vuln.PkgName,
+				InstalledVersion: vuln.InstalledVersion,
+				FixedVersion:     vuln.FixedVersion,
+				CVSS:             vuln.cvssScore(),
+				Title:            vuln.Title,
+				Description:      vuln.Description,
+				PrimaryURL:       vuln.PrimaryURL,
+				References:       vuln.References,
+			})
+		}
+	}
+	return vulns
+}
+
+func (o trivyOutput) secrets(imageName string) []helmscanTypes.Secret {
+	var secrets []helmscanTypes.Secret
+	for _, res := range o.Results {
+		for _, secret := range res.Secrets {
+			secrets = append(secrets, helmscanTypes.Secret{
+				Image:    imageName,
+				Target:   res.Target,
+				RuleID:   secret.RuleID,
+				Category: secret.Category,
+				Severity: strings.ToLower(secret.Severity),
+				Title:    secret.Title,
+				Line:     secret.StartLine,
+			})
+		}
+	}
+	return secrets
+}
+
+func (o trivyOutput) misconfigurations(imageName string) []helmscanTypes.Misconfiguration {
+	var misconfigs []helmscanTypes.Misconfiguration
+	for _, res := range o.Results {
+		for _, misconfig := range res.Misconfigurations {
+			if misconfig.Status != "" && misconfig.Status != "FAIL" {
+				continue
+			}
+			misconfigs = append(misconfigs, helmscanTypes.Misconfiguration{
+				Image:    imageName,
+				Target:   res.Target,
+				ID:       misconfig.ID,
+				Title:    misconfig.Title,
+				Message:  misconfig.Message,
+				Severity: strings.ToLower(misconfig.Severity),
+			})
+		}
+	}
+	return misconfigs
+}
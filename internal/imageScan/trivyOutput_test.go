@@ -0,0 +1,113 @@
+package imageScan
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRepoDigest(t *testing.T) {
+	const digest = "sha256:3127bd2ec9e8a1b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f9012345ab"
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{name: "registry image", output: `{"Metadata": {"RepoDigests": ["bitnami/redis@` + digest + `"]}}`, want: digest},
+		{
+			name:   "first of several registries",
+			output: `{"Metadata": {"RepoDigests": ["bitnami/redis@` + digest + `", "registry.internal/bitnami/redis@sha256:9f86d0"]}}`,
+			want:   digest,
+		},
+		{name: "entry without a digest", output: `{"Metadata": {"RepoDigests": ["bitnami/redis", "mirror/redis@` + digest + `"]}}`, want: digest},
+		// Tarballs are not tied to a registry, so Trivy reports no repo digests.
+		{name: "tarball", output: `{"Metadata": {"RepoDigests": []}}`},
+		{name: "no metadata", output: `{}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := parseTrivyOutput([]byte(tt.output))
+			if err != nil {
+				t.Fatalf("parseTrivyOutput() error = %v", err)
+			}
+			if got := output.Metadata.repoDigest(); got != tt.want {
+				t.Errorf("repoDigest() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVulnerabilityDescriptions(t *testing.T) {
+	tests := []struct {
+		name            string
+		vulnerability   string
+		wantTitle       string
+		wantDescription string
+		wantPrimaryURL  string
+	}{
+		{
+			name:            "title and description",
+			vulnerability:   `{"VulnerabilityID": "CVE-2024-2961", "Severity": "HIGH", "PrimaryURL": "https://avd.aquasec.com/nvd/cve-2024-2961", "Title": "glibc: Out of bounds write in iconv", "Description": "The iconv() function may overflow the output buffer."}`,
+			wantTitle:       "glibc: Out of bounds write in iconv",
+			wantDescription: "The iconv() function may overflow the output buffer.",
+			wantPrimaryURL:  "https://avd.aquasec.com/nvd/cve-2024-2961",
+		},
+		{
+			name:           "title only",
+			vulnerability:  `{"VulnerabilityID": "CVE-2023-50495", "Severity": "MEDIUM", "PrimaryURL": "https://avd.aquasec.com/nvd/cve-2023-50495", "Title": "ncurses: segmentation fault"}`,
+			wantTitle:      "ncurses: segmentation fault",
+			wantPrimaryURL: "https://avd.aquasec.com/nvd/cve-2023-50495",
+		},
+		{name: "neither", vulnerability: `{"VulnerabilityID": "CVE-2011-3374", "Severity": "LOW"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := parseTrivyOutput([]byte(`{"Results": [{"Target": "redis", "Vulnerabilities": [` + tt.vulnerability + `]}]}`))
+			if err != nil {
+				t.Fatalf("parseTrivyOutput() error = %v", err)
+			}
+			vulns := output.vulnerabilities()
+			if len(vulns) != 1 {
+				t.Fatalf("vulnerabilities() = %+v, want 1 vulnerability", vulns)
+			}
+			if vuln := vulns[0]; vuln.Title != tt.wantTitle || vuln.Description != tt.wantDescription || vuln.PrimaryURL != tt.wantPrimaryURL {
+				t.Errorf("Title, Description, PrimaryURL = %q, %q, %q, want %q, %q, %q",
+					vuln.Title, vuln.Description, vuln.PrimaryURL, tt.wantTitle, tt.wantDescription, tt.wantPrimaryURL)
+			}
+		})
+	}
+}
+
+func TestVulnerabilityReferences(t *testing.T) {
+	tests := []struct {
+		name           string
+		vulnerability  string
+		wantPrimaryURL string
+		wantReferences []string
+	}{
+		{
+			name:           "references",
+			vulnerability:  `{"VulnerabilityID": "CVE-2023-45853", "Severity": "CRITICAL", "PrimaryURL": "https://avd.aquasec.com/nvd/cve-2023-45853", "References": ["https://github.com/madler/zlib/pull/843", "https://nvd.nist.gov/vuln/detail/CVE-2023-45853"]}`,
+			wantPrimaryURL: "https://avd.aquasec.com/nvd/cve-2023-45853",
+			wantReferences: []string{"https://github.com/madler/zlib/pull/843", "https://nvd.nist.gov/vuln/detail/CVE-2023-45853"},
+		},
+		{name: "none", vulnerability: `{"VulnerabilityID": "CVE-2011-3374", "Severity": "LOW"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := parseTrivyOutput([]byte(`{"Results": [{"Target": "redis", "Vulnerabilities": [` + tt.vulnerability + `]}]}`))
+			if err != nil {
+				t.Fatalf("parseTrivyOutput() error = %v", err)
+			}
+			vulns := output.vulnerabilities()
+			if len(vulns) != 1 {
+				t.Fatalf("vulnerabilities() = %+v, want 1 vulnerability", vulns)
+			}
+			if vulns[0].PrimaryURL != tt.wantPrimaryURL {
+				t.Errorf("PrimaryURL = %q, want %q", vulns[0].PrimaryURL, tt.wantPrimaryURL)
+			}
+			if !slices.Equal(vulns[0].References, tt.wantReferences) {
+				t.Errorf("References = %v, want %v", vulns[0].References, tt.wantReferences)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package kev
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const DefaultCacheTTL = 24 * time.Hour
+
+// catalogURL, httpClient and cachePath are variables so tests can point them at a fake catalog.
+var (
+	catalogURL = "https://www.cisa.gov/sites/default/files/feeds/known_exploited_vulnerabilities.json"
+	httpClient = &http.Client{Timeout: 60 * time.Second}
+	cachePath  = "working-files/tmp/kev_cache/known_exploited_vulnerabilities.json"
+)
+
+// cacheMu serializes Catalog so concurrent scans download the catalog once and never read a
+// partially written cache file.
+var cacheMu sync.Mutex
+
+type catalog struct {
+	Vulnerabilities []struct {
+		CVEID string `json:"cveID"`
+	} `json:"vulnerabilities"`
+}
+
+// Catalog returns the set of CVE IDs in the CISA Known Exploited Vulnerabilities catalog. The
+// catalog is downloaded when the cached copy is missing or older than ttl.
+func Catalog(ctx context.Context, ttl time.Duration) (map[string]bool, error) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	data, err := cachedCatalog(ttl)
+	if err != nil {
+		data, err = download(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var parsed catalog
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("error parsing KEV catalog: %w", err)
+	}
+
+	ids := make(map[string]bool, len(parsed.Vulnerabilities))
+	for _, vuln := range parsed.Vulnerabilities {
+		ids[vuln.CVEID] = true
+	}
+	return ids, nil
+}
+
+func cachedCatalog(ttl time.Duration) ([]byte, error) {
+	info, err := os.Stat(cachePath)
+	if err != nil {
+		return nil, err
+	}
+	if time.Since(info.ModTime()) > ttl {
+		return nil, fmt.Errorf("cached KEV catalog is older than %s", ttl)
+	}
+	return os.ReadFile(cachePath)
+}
+
+func download(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, catalogURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating KEV catalog request: %w", err)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading KEV catalog: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error downloading KEV catalog: unexpected status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading KEV catalog: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err == nil {
+		_ = os.WriteFile(cachePath, data, 0644)
+	}
+	return data, nil
+}
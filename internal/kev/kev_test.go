@@ -0,0 +1,126 @@
+package kev
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testCatalog = `{
+  "title": "CISA Catalog of Known Exploited Vulnerabilities",
+  "catalogVersion": "2024.11.05",
+  "count": 2,
+  "vulnerabilities": [
+    {"cveID": "CVE-2021-44228", "vendorProject": "Apache", "product": "Log4j2"},
+    {"cveID": "CVE-2024-2961", "vendorProject": "GNU", "product": "GNU C Library"}
+  ]
+}`
+
+func TestCatalog(t *testing.T) {
+	tests := []struct {
+		name          string
+		cache         string
+		cacheAge      time.Duration
+		status        int
+		body          string
+		want          []string
+		wantDownloads int32
+		wantErr       string
+	}{
+		{
+			name:          "download",
+			body:          testCatalog,
+			want:          []string{"CVE-2021-44228", "CVE-2024-2961"},
+			wantDownloads: 1,
+		},
+		{
+			name:     "fresh cache",
+			cache:    `{"vulnerabilities": [{"cveID": "CVE-2023-45853"}]}`,
+			cacheAge: time.Hour,
+			want:     []string{"CVE-2023-45853"},
+		},
+		{
+			name:          "stale cache",
+			cache:         `{"vulnerabilities": [{"cveID": "CVE-2023-45853"}]}`,
+			cacheAge:      25 * time.Hour,
+			body:          testCatalog,
+			want:          []string{"CVE-2021-44228", "CVE-2024-2961"},
+			wantDownloads: 1,
+		},
+		{
+			name:          "download fails",
+			status:        http.StatusServiceUnavailable,
+			wantDownloads: 1,
+			wantErr:       "unexpected status 503",
+		},
+		{
+			name:          "not a catalog",
+			body:          "<html>maintenance</html>",
+			wantDownloads: 1,
+			wantErr:       "error parsing KEV catalog",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var downloads atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				downloads.Add(1)
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+					return
+				}
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			originalURL, originalClient, originalPath := catalogURL, httpClient, cachePath
+			catalogURL, httpClient, cachePath = server.URL, server.Client(), filepath.Join(t.TempDir(), "kev_cache", "catalog.json")
+			t.Cleanup(func() { catalogURL, httpClient, cachePath = originalURL, originalClient, originalPath })
+
+			if tt.cache != "" {
+				if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(cachePath, []byte(tt.cache), 0644); err != nil {
+					t.Fatal(err)
+				}
+				modified := time.Now().Add(-tt.cacheAge)
+				if err := os.Chtimes(cachePath, modified, modified); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := Catalog(context.Background(), DefaultCacheTTL)
+			if downloads.Load() != tt.wantDownloads {
+				t.Errorf("downloaded the catalog %d times, want %d", downloads.Load(), tt.wantDownloads)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Catalog() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Catalog() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("Catalog() = %v, want %v", got, tt.want)
+			}
+			for _, id := range tt.want {
+				if !got[id] {
+					t.Errorf("Catalog() is missing %s", id)
+				}
+			}
+			if tt.wantDownloads > 0 {
+				if cached, err := os.ReadFile(cachePath); err != nil || string(cached) != tt.body {
+					t.Errorf("cached catalog = %q, %v, want the downloaded catalog", cached, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPostJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr string
+	}{
+		{name: "ok", status: http.StatusOK},
+		{name: "no content", status: http.StatusNoContent},
+		{name: "rejected", status: http.StatusBadRequest, wantErr: "unexpected status 400"},
+		{name: "server error", status: http.StatusInternalServerError, wantErr: "unexpected status 500"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var method, contentType string
+			var body map[string]any
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				method, contentType = r.Method, r.Header.Get("Content-Type")
+				data, _ := io.ReadAll(r.Body)
+				json.Unmarshal(data, &body)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			err := PostJSON(context.Background(), server.URL, map[string]any{"new_cve_ids": []string{"CVE-2024-6387"}})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("PostJSON() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("PostJSON() error = %v", err)
+			}
+			if method != http.MethodPost || contentType != "application/json" {
+				t.Errorf("request was %s with Content-Type %q, want a JSON POST", method, contentType)
+			}
+			if ids, _ := body["new_cve_ids"].([]any); len(ids) != 1 || ids[0] != "CVE-2024-6387" {
+				t.Errorf("body = %v, want the payload", body)
+			}
+		})
+	}
+}
+
+func TestPostJSONUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+	if err := PostJSON(context.Background(), url, map[string]any{}); err == nil || !strings.Contains(err.Error(), "error sending notification") {
+		t.Errorf("PostJSON() error = %v, want a send error", err)
+	}
+}
@@ -0,0 +1,225 @@
+package reports
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+// Baseline is the set of vulnerabilities recorded by a previous JSON report, keyed by CVE ID and
+// then by image.
+type Baseline struct {
+	Path string
+	CVEs map[string]map[string]helmscanTypes.Vulnerability
+}
+
+// LoadBaseline reads a previous JSON report of artifactRef: a chart scan, an image scan, or a
+// comparison whose added and unchanged CVEs describe the state after the comparison.
+func LoadBaseline(path string, artifactRef string) (Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Baseline{}, fmt.Errorf("error reading baseline: %w", err)
+	}
+
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return Baseline{}, fmt.Errorf("error parsing baseline %s: %w", path, err)
+	}
+
+	baseline := Baseline{Path: path, CVEs: make(map[string]map[string]helmscanTypes.Vulnerability)}
+	if _, ok := probe["report_type"]; ok {
+		var report JSONReport
+		if err := json.Unmarshal(data, &report); err != nil {
+			return Baseline{}, fmt.Errorf("error parsing baseline %s: %w", path, err)
+		}
+		for _, cve := range append(report.AddedCVEs, report.UnchangedCVEs...) {
+			if report.ReportType == "Image Comparison Report" {
+				// The baseline may be a scan of another tag of the image, so key by the current ref.
+				if SeverityValue(cve.ID) > 0 {
+					// Older image reports grouped CVEs by severity, listing the CVE IDs as affected images.
+					for _, id := range cve.AffectedImages {
+						baseline.add(CVE{ID: id, Severity: cve.Severity}, artifactRef)
+					}
+					continue
+				}
+				baseline.add(cve, artifactRef)
+				continue
+			}
+			for _, image := range cve.AffectedImages {
+				baseline.add(cve, image)
+			}
+		}
+		return baseline, nil
+	}
+
+	var report SingleScanReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return Baseline{}, fmt.Errorf("error parsing baseline %s: %w", path, err)
+	}
+	if report.ArtifactType == "" {
+		return Baseline{}, fmt.Errorf("baseline %s is not a helmscan JSON report", path)
+	}
+	for _, cve := range report.CVEs {
+		// Like image comparisons, an image scan may be of another tag, so it is keyed by the current ref.
+		image := artifactRef
+		// Chart scans key CVEs as "image:CVE-ID"; CVE IDs never contain a colon.
+		if i := strings.LastIndex(cve.ID, ":"); i >= 0 {
+			image, cve.ID = cve.ID[:i], cve.ID[i+1:]
+		}
+		baseline.add(cve, image)
+	}
+	return baseline, nil
+}
+
+func (b Baseline) add(cve CVE, image string) {
+	if _, exists := b.CVEs[cve.ID]; !exists {
+		b.CVEs[cve.ID] = make(map[string]helmscanTypes.Vulnerability)
+	}
+	b.CVEs[cve.ID][image] = helmscanTypes.Vulnerability{ID: cve.ID, Severity: cve.Severity, CVSS: cve.CVSS, FixedVersion: cve.FixedVersion, EPSS: cve.EPSS, KEV: cve.KEV}
+}
+
+// BaselineReportGenerator diffs the vulnerabilities of a fresh scan against a stored baseline.
+type BaselineReportGenerator struct {
+	artifactRef string
+	baseline    Baseline
+	current     map[string]map[string]helmscanTypes.Vulnerability
+}
+
+func NewBaselineReportGenerator(artifactRef string, current map[string]map[string]helmscanTypes.Vulnerability, baseline Baseline) *BaselineReportGenerator {
+	return &BaselineReportGenerator{artifactRef: artifactRef, baseline: baseline, current: current}
+}
+
+func (g *BaselineReportGenerator) GetTitle() string {
+	return "Baseline Comparison Report"
+}
+
+func (g *BaselineReportGenerator) GetComparison() map[string]string {
+	return map[string]string{
+		"Baseline": g.baseline.Path,
+		"Current":  g.artifactRef,
+	}
+}
+
+func (g *BaselineReportGenerator) GetSeverityCounts() []SeverityCount {
+	count := func(cves map[string]map[string]helmscanTypes.Vulnerability) map[string]int {
+		counts := make(map[string]int)
+		for _, images := range cves {
+			for _, vuln := range images {
+				counts[strings.ToLower(vuln.Severity)]++
+			}
+		}
+		return counts
+	}
+	current, previous := count(g.current), count(g.baseline.CVEs)
+
+	var counts []SeverityCount
+	for _, severity := range []string{"critical", "high", "medium", "low"} {
+		counts = append(counts, SeverityCount{
+			Severity:   severity,
+			Current:    current[severity],
+			Previous:   previous[severity],
+			Difference: current[severity] - previous[severity],
+		})
+	}
+	return counts
+}
+
+func (g *BaselineReportGenerator) GetAddedCVEs() map[string]map[string]helmscanTypes.Vulnerability {
+	return diffCVEs(g.current, g.baseline.CVEs)
+}
+
+func (g *BaselineReportGenerator) GetRemovedCVEs() map[string]map[string]helmscanTypes.Vulnerability {
+	return diffCVEs(g.baseline.CVEs, g.current)
+}
+
+func (g *BaselineReportGenerator) GetUnchangedCVEs() map[string]map[string]helmscanTypes.Vulnerability {
+	unchanged := make(map[string]map[string]helmscanTypes.Vulnerability)
+	for id, images := range g.current {
+		for image, vuln := range images {
+			if containsImage(g.baseline.CVEs[id], image) {
+				if _, exists := unchanged[id]; !exists {
+					unchanged[id] = make(map[string]helmscanTypes.Vulnerability)
+				}
+				unchanged[id][image] = vuln
+			}
+		}
+	}
+	return unchanged
+}
+
+func (g *BaselineReportGenerator) GetSkippedImages() []helmscanTypes.SkippedImage {
+	return nil
+}
+
+func (g *BaselineReportGenerator) GetRepositoryChanges() []helmscanTypes.RepositoryChange {
+	return nil
+}
+
+func (g *BaselineReportGenerator) GetImageChanges() []helmscanTypes.ImageChange {
+	return nil
+}
+
+func (g *BaselineReportGenerator) GetAffectedResources() (map[string][]AffectedResource, map[string][]AffectedResource) {
+	return nil, nil
+}
+
+func (g *BaselineReportGenerator) GetOperatingSystems() (map[string]helmscanTypes.OperatingSystem, map[string]helmscanTypes.OperatingSystem) {
+	return nil, nil
+}
+
+func (g *BaselineReportGenerator) GetRawOutputs() map[string]string {
+	return nil
+}
+
+func (g *BaselineReportGenerator) GetBaseFilename() string {
+	return fmt.Sprintf("%s_baseline_comparison", g.artifactRef)
+}
+
+func (g *BaselineReportGenerator) GetReportKind() string {
+	return "baseline_cmp"
+}
+
+// diffCVEs returns the CVE and image pairs in from that are not in other.
+func diffCVEs(from, other map[string]map[string]helmscanTypes.Vulnerability) map[string]map[string]helmscanTypes.Vulnerability {
+	diff := make(map[string]map[string]helmscanTypes.Vulnerability)
+	for id, images := range from {
+		for image, vuln := range images {
+			if containsImage(other[id], image) {
+				continue
+			}
+			if _, exists := diff[id]; !exists {
+				diff[id] = make(map[string]helmscanTypes.Vulnerability)
+			}
+			diff[id][image] = vuln
+		}
+	}
+	return diff
+}
+
+// containsImage reports whether images holds image, or the same repository at another tag or digest,
+// so that a baseline still matches after an image is bumped.
+func containsImage(images map[string]helmscanTypes.Vulnerability, image string) bool {
+	if _, exists := images[image]; exists {
+		return true
+	}
+	for other := range images {
+		if imageRepository(other) == imageRepository(image) {
+			return true
+		}
+	}
+	return false
+}
+
+// imageRepository is image without its tag or digest.
+func imageRepository(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
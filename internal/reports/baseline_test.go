@@ -0,0 +1,231 @@
+package reports
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+// baselineKeys lists the CVE and image pairs of cves as "CVE-ID image", sorted.
+func baselineKeys(cves map[string]map[string]helmscanTypes.Vulnerability) []string {
+	var keys []string
+	for id, images := range cves {
+		for image := range images {
+			keys = append(keys, id+" "+image)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func TestLoadBaseline(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		artifactRef string
+		want        []string
+		wantErr     string
+	}{
+		{
+			name:        "chart scan",
+			file:        "testdata/baseline_chart_scan.json",
+			artifactRef: "bitnami/redis@18.1.0",
+			want: []string{
+				"CVE-2011-3374 docker.io/bitnami/redis",
+				"CVE-2023-45288 docker.io/bitnami/redis-exporter",
+				"CVE-2023-45853 docker.io/bitnami/redis",
+			},
+		},
+		{
+			// The CVEs of an image scan belong to the image scanned now, whatever tag the baseline scanned.
+			name:        "image scan",
+			file:        "testdata/baseline_image_scan.json",
+			artifactRef: "docker.io/bitnami/redis:7.2.4",
+			want: []string{
+				"CVE-2011-3374 docker.io/bitnami/redis:7.2.4",
+				"CVE-2023-45853 docker.io/bitnami/redis:7.2.4",
+			},
+		},
+		{
+			// Only the added and unchanged CVEs are left after a comparison.
+			name:        "chart comparison",
+			file:        "testdata/baseline_helm_comparison.json",
+			artifactRef: "bitnami/redis@18.1.0",
+			want: []string{
+				"CVE-2011-3374 docker.io/bitnami/redis",
+				"CVE-2011-3374 docker.io/bitnami/redis-exporter",
+				"CVE-2023-45853 docker.io/bitnami/redis",
+			},
+		},
+		{
+			// Older image comparisons grouped CVEs by severity.
+			name:        "image comparison",
+			file:        "testdata/baseline_image_comparison.json",
+			artifactRef: "docker.io/bitnami/redis:7.2.4",
+			want: []string{
+				"CVE-2023-45288 docker.io/bitnami/redis:7.2.4",
+				"CVE-2023-45853 docker.io/bitnami/redis:7.2.4",
+			},
+		},
+		{
+			name:    "missing",
+			file:    "testdata/does_not_exist.json",
+			wantErr: "error reading baseline",
+		},
+		{
+			name:    "not a helmscan report",
+			file:    "testdata/not_a_report.json",
+			wantErr: "is not a helmscan JSON report",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseline, err := LoadBaseline(tt.file, tt.artifactRef)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadBaseline() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadBaseline() error = %v", err)
+			}
+			if got := baselineKeys(baseline.CVEs); !slices.Equal(got, tt.want) {
+				t.Errorf("LoadBaseline() CVEs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBaselineReportGenerator(t *testing.T) {
+	baseline, err := LoadBaseline("testdata/baseline_chart_scan.json", "bitnami/redis@18.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := map[string]map[string]helmscanTypes.Vulnerability{
+		"CVE-2023-45853": {"docker.io/bitnami/redis": {ID: "CVE-2023-45853", Severity: "critical"}},
+		"CVE-2011-3374":  {"docker.io/bitnami/redis": {ID: "CVE-2011-3374", Severity: "low"}},
+		"CVE-2024-2961":  {"docker.io/bitnami/redis": {ID: "CVE-2024-2961", Severity: "high"}},
+	}
+	generator := NewBaselineReportGenerator("bitnami/redis@18.1.0", current, baseline)
+
+	for _, tt := range []struct {
+		name string
+		cves map[string]map[string]helmscanTypes.Vulnerability
+		want []string
+	}{
+		{"added", generator.GetAddedCVEs(), []string{"CVE-2024-2961 docker.io/bitnami/redis"}},
+		{"removed", generator.GetRemovedCVEs(), []string{"CVE-2023-45288 docker.io/bitnami/redis-exporter"}},
+		{"unchanged", generator.GetUnchangedCVEs(), []string{"CVE-2011-3374 docker.io/bitnami/redis", "CVE-2023-45853 docker.io/bitnami/redis"}},
+	} {
+		if got := baselineKeys(tt.cves); !slices.Equal(got, tt.want) {
+			t.Errorf("%s CVEs = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	counts := make(map[string]SeverityCount)
+	for _, count := range generator.GetSeverityCounts() {
+		counts[count.Severity] = count
+	}
+	if want := (SeverityCount{Severity: "high", Current: 1, Previous: 1}); counts["high"] != want {
+		t.Errorf("high count = %+v, want %+v", counts["high"], want)
+	}
+	if want := (SeverityCount{Severity: "critical", Current: 1, Previous: 1}); counts["critical"] != want {
+		t.Errorf("critical count = %+v, want %+v", counts["critical"], want)
+	}
+	if comparison := generator.GetComparison(); !maps.Equal(comparison, map[string]string{"Baseline": "testdata/baseline_chart_scan.json", "Current": "bitnami/redis@18.1.0"}) {
+		t.Errorf("GetComparison() = %v", comparison)
+	}
+}
+
+func TestBaselineMatchesBumpedImages(t *testing.T) {
+	vuln := helmscanTypes.Vulnerability{ID: "CVE-2023-45853", Severity: "critical"}
+	tests := []struct {
+		name          string
+		baseline      []string
+		current       []string
+		wantAdded     []string
+		wantRemoved   []string
+		wantUnchanged []string
+	}{
+		{
+			name:          "same tag",
+			baseline:      []string{"docker.io/bitnami/redis:7.2.4"},
+			current:       []string{"docker.io/bitnami/redis:7.2.4"},
+			wantUnchanged: []string{"CVE-2023-45853 docker.io/bitnami/redis:7.2.4"},
+		},
+		{
+			name:          "bumped tag",
+			baseline:      []string{"docker.io/bitnami/redis:7.2.4"},
+			current:       []string{"docker.io/bitnami/redis:7.2.5"},
+			wantUnchanged: []string{"CVE-2023-45853 docker.io/bitnami/redis:7.2.5"},
+		},
+		{
+			name:          "pinned by digest",
+			baseline:      []string{"docker.io/bitnami/redis:7.2.4"},
+			current:       []string{"docker.io/bitnami/redis@sha256:abc"},
+			wantUnchanged: []string{"CVE-2023-45853 docker.io/bitnami/redis@sha256:abc"},
+		},
+		{
+			// Baselines written before images were keyed by tag name them without one.
+			name:          "baseline without tags",
+			baseline:      []string{"docker.io/bitnami/redis"},
+			current:       []string{"docker.io/bitnami/redis:7.2.4", "docker.io/bitnami/redis:7.2.5"},
+			wantUnchanged: []string{"CVE-2023-45853 docker.io/bitnami/redis:7.2.4", "CVE-2023-45853 docker.io/bitnami/redis:7.2.5"},
+		},
+		{
+			name:        "registry port is not a tag",
+			baseline:    []string{"registry.example.com:5000/redis"},
+			current:     []string{"registry.example.com:6000/redis"},
+			wantAdded:   []string{"CVE-2023-45853 registry.example.com:6000/redis"},
+			wantRemoved: []string{"CVE-2023-45853 registry.example.com:5000/redis"},
+		},
+		{
+			name:        "other image",
+			baseline:    []string{"docker.io/bitnami/redis:7.2.4"},
+			current:     []string{"docker.io/bitnami/redis-exporter:1.58.0"},
+			wantAdded:   []string{"CVE-2023-45853 docker.io/bitnami/redis-exporter:1.58.0"},
+			wantRemoved: []string{"CVE-2023-45853 docker.io/bitnami/redis:7.2.4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cves := func(images []string) map[string]map[string]helmscanTypes.Vulnerability {
+				byImage := make(map[string]helmscanTypes.Vulnerability)
+				for _, image := range images {
+					byImage[image] = vuln
+				}
+				return map[string]map[string]helmscanTypes.Vulnerability{vuln.ID: byImage}
+			}
+			generator := NewBaselineReportGenerator("bitnami/redis@18.1.0", cves(tt.current), Baseline{CVEs: cves(tt.baseline)})
+			for _, check := range []struct {
+				name string
+				cves map[string]map[string]helmscanTypes.Vulnerability
+				want []string
+			}{
+				{"added", generator.GetAddedCVEs(), tt.wantAdded},
+				{"removed", generator.GetRemovedCVEs(), tt.wantRemoved},
+				{"unchanged", generator.GetUnchangedCVEs(), tt.wantUnchanged},
+			} {
+				if got := baselineKeys(check.cves); !slices.Equal(got, check.want) {
+					t.Errorf("%s CVEs = %q, want %q", check.name, got, check.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadBaselineRejectsInvalidJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadBaseline(path, "bitnami/redis@18.1.0"); err == nil || !strings.Contains(err.Error(), "error parsing baseline") {
+		t.Errorf("LoadBaseline() error = %v, want a parse error", err)
+	}
+}
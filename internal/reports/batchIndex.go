@@ -0,0 +1,71 @@
+package reports
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+// BatchIndex lists the charts of a --batch run with their vulnerability counts and report files.
+type BatchIndex struct {
+	Metadata *Metadata    `json:"metadata,omitempty"`
+	Charts   []BatchEntry `json:"charts"`
+}
+
+// BatchEntry is one chart of a batch. Report is relative to the reports directory, and Error is set
+// instead when the chart could not be scanned.
+type BatchEntry struct {
+	ChartRef string          `json:"chart"`
+	Images   int             `json:"images"`
+	Summary  SeveritySummary `json:"summary"`
+	Report   string          `json:"report,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+func NewBatchEntry(chartRef string, images int, vulns map[string]helmscanTypes.Vulnerability) BatchEntry {
+	return BatchEntry{
+		ChartRef: chartRef,
+		Images:   images,
+		Summary:  CountVulnerabilities(vulns),
+	}
+}
+
+func GenerateBatchIndex(index BatchIndex, generateJSON bool, opts ReportOptions) (string, error) {
+	index.Metadata = opts.Metadata
+
+	if generateJSON {
+		jsonBytes, err := json.MarshalIndent(index, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("error generating JSON report: %w", err)
+		}
+		return string(jsonBytes), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# Helm Chart Batch Report\n\n")
+	sb.WriteString(formatMetadataSection(index.Metadata))
+
+	var rows [][]string
+	for _, entry := range index.Charts {
+		if entry.Error != "" {
+			rows = append(rows, []string{entry.ChartRef, "-", "-", "-", "-", "-", "Failed: " + entry.Error})
+			continue
+		}
+		rows = append(rows, []string{
+			entry.ChartRef,
+			strconv.Itoa(entry.Images),
+			strconv.Itoa(entry.Summary.Critical),
+			strconv.Itoa(entry.Summary.High),
+			strconv.Itoa(entry.Summary.Medium),
+			strconv.Itoa(entry.Summary.Low),
+			fmt.Sprintf("[%s](%s)", entry.Report, entry.Report),
+		})
+	}
+	sb.WriteString(FormatSection("Charts",
+		FormatMarkdownTable([]string{"Chart", "Images", "Critical", "High", "Medium", "Low", "Report"}, rows)))
+
+	return sb.String(), nil
+}
@@ -0,0 +1,101 @@
+package reports
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func TestNewBatchEntry(t *testing.T) {
+	vulns := map[string]helmscanTypes.Vulnerability{
+		"CVE-2023-45853": {ID: "CVE-2023-45853", Severity: "critical"},
+		"CVE-2024-2961":  {ID: "CVE-2024-2961", Severity: "high"},
+		"CVE-2011-3374":  {ID: "CVE-2011-3374", Severity: "low"},
+	}
+	got := NewBatchEntry("bitnami/redis@18.1.0", 2, vulns)
+	want := BatchEntry{ChartRef: "bitnami/redis@18.1.0", Images: 2, Summary: SeveritySummary{Critical: 1, High: 1, Low: 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NewBatchEntry() = %+v, want %+v", got, want)
+	}
+}
+
+func TestGenerateBatchIndex(t *testing.T) {
+	index := BatchIndex{Charts: []BatchEntry{
+		{ChartRef: "bitnami/redis@18.1.0", Images: 2, Summary: SeveritySummary{Critical: 1, High: 2, Low: 3}, Report: "helm_scan_bitnami-redis-18-1-0/helm_scan_bitnami-redis-18-1-0.md"},
+		{ChartRef: "bitnami/redis@9.9.9", Error: "error templating chart"},
+	}}
+	metadata := &Metadata{ToolVersion: "dev", TrivyVersion: "0.56.2", Command: "helmscan --batch -"}
+
+	tests := []struct {
+		name      string
+		json      bool
+		opts      ReportOptions
+		wantLines []string
+	}{
+		{
+			name: "markdown",
+			wantLines: []string{
+				"# Helm Chart Batch Report",
+				"| bitnami/redis@18.1.0 | 2 | 1 | 2 | 0 | 3 | [helm_scan_bitnami-redis-18-1-0/helm_scan_bitnami-redis-18-1-0.md](helm_scan_bitnami-redis-18-1-0/helm_scan_bitnami-redis-18-1-0.md) |",
+				"| bitnami/redis@9.9.9 | - | - | - | - | - | Failed: error templating chart |",
+			},
+		},
+		{name: "markdown with metadata", opts: ReportOptions{Metadata: metadata}, wantLines: []string{"0.56.2", "helmscan --batch -"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateBatchIndex(index, false, tt.opts)
+			if err != nil {
+				t.Fatalf("GenerateBatchIndex() error = %v", err)
+			}
+			for _, line := range tt.wantLines {
+				if !strings.Contains(got, line) {
+					t.Errorf("index is missing %q:\n%s", line, got)
+				}
+			}
+		})
+	}
+
+	t.Run("json", func(t *testing.T) {
+		got, err := GenerateBatchIndex(index, true, ReportOptions{Metadata: metadata})
+		if err != nil {
+			t.Fatalf("GenerateBatchIndex() error = %v", err)
+		}
+		var decoded BatchIndex
+		if err := json.Unmarshal([]byte(got), &decoded); err != nil {
+			t.Fatalf("index is not JSON: %v", err)
+		}
+		want := index
+		want.Metadata = metadata
+		if !reflect.DeepEqual(decoded, want) {
+			t.Errorf("index = %+v, want %+v", decoded, want)
+		}
+		// Failed charts have no report to link to.
+		if strings.Count(got, `"report"`) != 1 {
+			t.Errorf("index lists %d reports, want 1:\n%s", strings.Count(got, `"report"`), got)
+		}
+	})
+}
+
+func TestReportSubpath(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{filename: "helm_scan_bitnami-redis-18-1-0.md", want: filepath.Join("helm-scan-bitnami-redis-18-1-0", "helm_scan_bitnami-redis-18-1-0.md")},
+		{filename: "helm_scan_bitnami-redis-18-1-0.json", want: filepath.Join("helm-scan-bitnami-redis-18-1-0", "helm_scan_bitnami-redis-18-1-0.json")},
+		// The directory name is made safe; the file keeps its name.
+		{filename: "image_scan_redis:7.2.4.md", want: filepath.Join("image-scan-redis-7-2-4", "image_scan_redis:7.2.4.md")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			if got := ReportSubpath(tt.filename); got != tt.want {
+				t.Errorf("ReportSubpath(%q) = %q, want %q", tt.filename, got, tt.want)
+			}
+		})
+	}
+}
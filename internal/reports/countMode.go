@@ -0,0 +1,59 @@
+package reports
+
+import (
+	"strings"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+const (
+	// CountModeImage counts a CVE once for every image it is found in.
+	CountModeImage = "image"
+	// CountModeChart counts each CVE once however many images it is found in.
+	CountModeChart = "chart"
+)
+
+// severityCounts returns the generator's severity counts, or with opts.CountMode set to
+// CountModeChart the number of distinct CVEs of each severity in the current and previous artifacts.
+func severityCounts(generator ReportGenerator, opts ReportOptions) []SeverityCount {
+	if opts.CountMode != CountModeChart {
+		return generator.GetSeverityCounts()
+	}
+
+	current := uniqueCVESeverities(generator.GetAddedCVEs(), generator.GetUnchangedCVEs())
+	previous := uniqueCVESeverities(generator.GetRemovedCVEs(), generator.GetUnchangedCVEs())
+	var counts []SeverityCount
+	for _, severity := range []string{"critical", "high", "medium", "low"} {
+		if !opts.DisplaysSeverity(severity) {
+			continue
+		}
+		counts = append(counts, SeverityCount{
+			Severity:   severity,
+			Current:    current[severity],
+			Previous:   previous[severity],
+			Difference: current[severity] - previous[severity],
+		})
+	}
+	return counts
+}
+
+// uniqueCVESeverities counts each CVE ID of the CVE ID to image maps once, at the highest severity
+// any image reports it with.
+func uniqueCVESeverities(cveMaps ...map[string]map[string]helmscanTypes.Vulnerability) map[string]int {
+	severities := make(map[string]string)
+	for _, cves := range cveMaps {
+		for id, imageVulns := range cves {
+			for _, vuln := range imageVulns {
+				if SeverityValue(vuln.Severity) > SeverityValue(severities[id]) {
+					severities[id] = vuln.Severity
+				}
+			}
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, severity := range severities {
+		counts[strings.ToLower(severity)]++
+	}
+	return counts
+}
@@ -0,0 +1,51 @@
+package reports
+
+import (
+	"maps"
+	"testing"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func TestUniqueCVESeverities(t *testing.T) {
+	vuln := func(id, severity string) helmscanTypes.Vulnerability {
+		return helmscanTypes.Vulnerability{ID: id, Severity: severity}
+	}
+	tests := []struct {
+		name    string
+		cveMaps []map[string]map[string]helmscanTypes.Vulnerability
+		want    map[string]int
+	}{
+		{name: "no CVEs", want: map[string]int{}},
+		{
+			name: "CVE in three images",
+			cveMaps: []map[string]map[string]helmscanTypes.Vulnerability{{
+				"CVE-2011-3374":  {"redis": vuln("CVE-2011-3374", "low"), "nginx": vuln("CVE-2011-3374", "low"), "busybox": vuln("CVE-2011-3374", "low")},
+				"CVE-2023-45853": {"redis": vuln("CVE-2023-45853", "critical")},
+			}},
+			want: map[string]int{"low": 1, "critical": 1},
+		},
+		{
+			name: "highest severity wins",
+			cveMaps: []map[string]map[string]helmscanTypes.Vulnerability{{
+				"CVE-2024-2961": {"redis": vuln("CVE-2024-2961", "medium"), "nginx": vuln("CVE-2024-2961", "HIGH")},
+			}},
+			want: map[string]int{"high": 1},
+		},
+		{
+			name: "CVE in added and unchanged maps",
+			cveMaps: []map[string]map[string]helmscanTypes.Vulnerability{
+				{"CVE-2011-3374": {"oauth2-proxy": vuln("CVE-2011-3374", "low")}},
+				{"CVE-2011-3374": {"redis": vuln("CVE-2011-3374", "low")}, "CVE-2023-5678": {"nginx": vuln("CVE-2023-5678", "medium")}},
+			},
+			want: map[string]int{"low": 1, "medium": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := uniqueCVESeverities(tt.cveMaps...); !maps.Equal(got, tt.want) {
+				t.Errorf("uniqueCVESeverities() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
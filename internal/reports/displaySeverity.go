@@ -0,0 +1,91 @@
+package reports
+
+import (
+	"strings"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+// DisplaysSeverity reports whether reports show vulnerabilities of severity: every severity unless
+// opts.DisplaySeverity is set.
+func (opts ReportOptions) DisplaysSeverity(severity string) bool {
+	if len(opts.DisplaySeverity) == 0 {
+		return true
+	}
+	for _, displayed := range opts.DisplaySeverity {
+		if strings.EqualFold(displayed, severity) {
+			return true
+		}
+	}
+	return false
+}
+
+// DisplayedVulnerabilities returns the vulnerabilities of vulns that reports show.
+func (opts ReportOptions) DisplayedVulnerabilities(vulns []helmscanTypes.Vulnerability) []helmscanTypes.Vulnerability {
+	if len(opts.DisplaySeverity) == 0 {
+		return vulns
+	}
+	var displayed []helmscanTypes.Vulnerability
+	for _, vuln := range vulns {
+		if opts.DisplaysSeverity(vuln.Severity) {
+			displayed = append(displayed, vuln)
+		}
+	}
+	return displayed
+}
+
+// displayedGenerator hides the CVEs and severity counts of the severities reports do not show.
+type displayedGenerator struct {
+	ReportGenerator
+	opts ReportOptions
+}
+
+// withDisplayedSeverities wraps generator so a comparison report only shows the severities in
+// opts.DisplaySeverity.
+func withDisplayedSeverities(generator ReportGenerator, opts ReportOptions) ReportGenerator {
+	if len(opts.DisplaySeverity) == 0 {
+		return generator
+	}
+	if _, wrapped := generator.(displayedGenerator); wrapped {
+		return generator
+	}
+	return displayedGenerator{ReportGenerator: generator, opts: opts}
+}
+
+func (g displayedGenerator) GetSeverityCounts() []SeverityCount {
+	var counts []SeverityCount
+	for _, count := range g.ReportGenerator.GetSeverityCounts() {
+		if g.opts.DisplaysSeverity(count.Severity) {
+			counts = append(counts, count)
+		}
+	}
+	return counts
+}
+
+func (g displayedGenerator) GetAddedCVEs() map[string]map[string]helmscanTypes.Vulnerability {
+	return g.displayedCVEs(g.ReportGenerator.GetAddedCVEs())
+}
+
+func (g displayedGenerator) GetRemovedCVEs() map[string]map[string]helmscanTypes.Vulnerability {
+	return g.displayedCVEs(g.ReportGenerator.GetRemovedCVEs())
+}
+
+func (g displayedGenerator) GetUnchangedCVEs() map[string]map[string]helmscanTypes.Vulnerability {
+	return g.displayedCVEs(g.ReportGenerator.GetUnchangedCVEs())
+}
+
+func (g displayedGenerator) displayedCVEs(cves map[string]map[string]helmscanTypes.Vulnerability) map[string]map[string]helmscanTypes.Vulnerability {
+	displayed := make(map[string]map[string]helmscanTypes.Vulnerability)
+	for id, imageVulns := range cves {
+		for image, vuln := range imageVulns {
+			if !g.opts.DisplaysSeverity(vuln.Severity) {
+				continue
+			}
+			if displayed[id] == nil {
+				displayed[id] = make(map[string]helmscanTypes.Vulnerability)
+			}
+			displayed[id][image] = vuln
+		}
+	}
+	return displayed
+}
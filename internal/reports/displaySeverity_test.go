@@ -0,0 +1,99 @@
+package reports
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func TestDisplayedVulnerabilities(t *testing.T) {
+	vulns := []helmscanTypes.Vulnerability{
+		{ID: "CVE-2023-45853", Severity: "CRITICAL"},
+		{ID: "CVE-2024-2961", Severity: "high"},
+		{ID: "CVE-2011-3374", Severity: "low"},
+	}
+	tests := []struct {
+		name            string
+		displaySeverity []string
+		want            []string
+	}{
+		{name: "all by default", want: []string{"CVE-2023-45853", "CVE-2024-2961", "CVE-2011-3374"}},
+		{name: "ignores case", displaySeverity: []string{"critical", "high"}, want: []string{"CVE-2023-45853", "CVE-2024-2961"}},
+		{name: "none of the severities", displaySeverity: []string{"medium"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := ReportOptions{DisplaySeverity: tt.displaySeverity}
+			var got []string
+			for _, vuln := range opts.DisplayedVulnerabilities(vulns) {
+				got = append(got, vuln.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("DisplayedVulnerabilities() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisplaySeverityReports(t *testing.T) {
+	zlib := helmscanTypes.Vulnerability{ID: "CVE-2023-45853", Severity: "critical"}
+	apt := helmscanTypes.Vulnerability{ID: "CVE-2011-3374", Severity: "low"}
+	cves := map[string]map[string]helmscanTypes.Vulnerability{
+		zlib.ID: {"docker.io/bitnami/redis": zlib},
+		apt.ID:  {"docker.io/bitnami/redis": apt},
+	}
+	generator := NewBaselineReportGenerator("bitnami/redis@18.1.0", cves, Baseline{CVEs: map[string]map[string]helmscanTypes.Vulnerability{}})
+	single := NewSingleScanReport("image", "docker.io/bitnami/redis:7.2.4", map[string]helmscanTypes.Vulnerability{zlib.ID: zlib, apt.ID: apt})
+
+	tests := []struct {
+		name            string
+		displaySeverity []string
+		wantCVEs        []string
+		wantSeverities  []string
+	}{
+		{name: "all", wantCVEs: []string{zlib.ID, apt.ID}, wantSeverities: []string{"critical", "high", "medium", "low"}},
+		{name: "critical only", displaySeverity: []string{"critical"}, wantCVEs: []string{zlib.ID}, wantSeverities: []string{"critical"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := ReportOptions{DisplaySeverity: tt.displaySeverity}
+
+			output, err := RenderJSON(generator, opts)
+			if err != nil {
+				t.Fatalf("RenderJSON() error = %v", err)
+			}
+			var report JSONReport
+			if err := json.Unmarshal([]byte(output), &report); err != nil {
+				t.Fatal(err)
+			}
+			var ids, severities []string
+			for _, cve := range report.AddedCVEs {
+				ids = append(ids, cve.ID)
+			}
+			for _, count := range report.Summary.SeverityCounts {
+				severities = append(severities, strings.ToLower(count.Severity))
+			}
+			slices.Sort(ids)
+			wantCVEs := slices.Sorted(slices.Values(tt.wantCVEs))
+			if !slices.Equal(ids, wantCVEs) {
+				t.Errorf("AddedCVEs = %v, want %v", ids, wantCVEs)
+			}
+			if !slices.Equal(severities, tt.wantSeverities) {
+				t.Errorf("severity counts = %v, want %v", severities, tt.wantSeverities)
+			}
+
+			markdown := RenderMarkdown(generator, opts)
+			if got, want := strings.Contains(markdown, apt.ID), slices.Contains(tt.wantCVEs, apt.ID); got != want {
+				t.Errorf("markdown lists %s: %v, want %v\n%s", apt.ID, got, want, markdown)
+			}
+			// Single scans are given only the displayed CVEs; the report leaves out the other counts.
+			singleMarkdown := GenerateMarkdownSingleReport(single, false, opts)
+			if got, want := strings.Contains(singleMarkdown, "| Low | 1 |"), slices.Contains(tt.wantSeverities, "low"); got != want {
+				t.Errorf("single scan markdown has a Low count: %v, want %v\n%s", got, want, singleMarkdown)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package reports
+
+import "strings"
+
+// maxExplanationLength is how many characters of a CVE description --explain shows in markdown.
+const maxExplanationLength = 120
+
+// formatExplanation returns a CVE's description, or its title when it has none, cut to
+// maxExplanationLength.
+func formatExplanation(title, description string) string {
+	text := description
+	if text == "" {
+		text = title
+	}
+	return truncateText(strings.Join(strings.Fields(text), " "), maxExplanationLength)
+}
+
+// truncateText shortens text to at most limit characters, ending at a word boundary with an
+// ellipsis when it had to be cut.
+func truncateText(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	cut := string(runes[:limit-1])
+	if space := strings.LastIndex(cut, " "); space > 0 {
+		cut = cut[:space]
+	}
+	return strings.TrimRight(cut, " ,.;:") + "…"
+}
+
+// withoutExplanations clears the CVE titles and descriptions that JSON reports only include
+// with --explain.
+func withoutExplanations(cves []CVE) []CVE {
+	for i := range cves {
+		cves[i].Title = ""
+		cves[i].Description = ""
+	}
+	return cves
+}
@@ -0,0 +1,135 @@
+package reports
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+// zlibDescription is longer than maxExplanationLength.
+const zlibDescription = "MiniZip in zlib through 1.3 has an integer overflow and resultant heap-based buffer overflow in zipOpenNewFileInZip4_64 via a long filename, comment, or extra field."
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  string
+	}{
+		{name: "short", text: "glibc: out of bounds write", limit: 40, want: "glibc: out of bounds write"},
+		{name: "exactly the limit", text: "0123456789", limit: 10, want: "0123456789"},
+		{name: "cut at a word", text: "an integer overflow and buffer overflow", limit: 20, want: "an integer…"},
+		{name: "trailing punctuation", text: "overflow, comment, or extra field", limit: 20, want: "overflow, comment…"},
+		{name: "no spaces", text: "0123456789abcdef", limit: 10, want: "012345678…"},
+		{name: "multibyte", text: "ééééééééééé", limit: 5, want: "éééé…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateText(tt.text, tt.limit)
+			if got != tt.want {
+				t.Errorf("truncateText(%q, %d) = %q, want %q", tt.text, tt.limit, got, tt.want)
+			}
+			if n := len([]rune(got)); n > tt.limit {
+				t.Errorf("truncateText() is %d characters, want at most %d", n, tt.limit)
+			}
+		})
+	}
+}
+
+func TestFormatExplanation(t *testing.T) {
+	tests := []struct {
+		name        string
+		title       string
+		description string
+		want        string
+	}{
+		{name: "neither", want: ""},
+		{name: "title only", title: "ncurses: segmentation fault via _nc_wrap_entry()", want: "ncurses: segmentation fault via _nc_wrap_entry()"},
+		{name: "description preferred", title: "zlib: overflow", description: "MiniZip has an overflow.", want: "MiniZip has an overflow."},
+		{name: "whitespace collapsed", description: "The iconv() function\n  may overflow\tthe buffer.", want: "The iconv() function may overflow the buffer."},
+		{
+			name:        "long description",
+			description: zlibDescription,
+			want:        "MiniZip in zlib through 1.3 has an integer overflow and resultant heap-based buffer overflow in…",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatExplanation(tt.title, tt.description); got != tt.want {
+				t.Errorf("formatExplanation() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExplainReports(t *testing.T) {
+	zlib := helmscanTypes.Vulnerability{
+		ID:          "CVE-2023-45853",
+		Severity:    "critical",
+		Title:       "zlib: integer overflow and resultant heap-based buffer overflow",
+		Description: zlibDescription,
+		PrimaryURL:  "https://avd.aquasec.com/nvd/cve-2023-45853",
+	}
+	vulns := map[string]helmscanTypes.Vulnerability{zlib.ID: zlib}
+	cves := map[string]map[string]helmscanTypes.Vulnerability{zlib.ID: {"docker.io/bitnami/redis": zlib}}
+	generator := NewBaselineReportGenerator("bitnami/redis@18.1.0", cves, Baseline{CVEs: map[string]map[string]helmscanTypes.Vulnerability{}})
+	truncated := formatExplanation(zlib.Title, zlib.Description)
+
+	tests := []struct {
+		name    string
+		explain bool
+	}{
+		{name: "without --explain"},
+		{name: "with --explain", explain: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := ReportOptions{Explain: tt.explain}
+
+			markdown := map[string]string{
+				"single scan": GenerateSingleScanReport(NewSingleScanReport("image", "docker.io/bitnami/redis:7.2.4", vulns), false, false, opts),
+				"comparison":  RenderMarkdown(generator, opts),
+			}
+			for name, report := range markdown {
+				if got := strings.Contains(report, "| Description |"); got != tt.explain {
+					t.Errorf("%s markdown has a Description column: %v, want %v", name, got, tt.explain)
+				}
+				if got := strings.Contains(report, truncated); got != tt.explain {
+					t.Errorf("%s markdown has the truncated description: %v, want %v", name, got, tt.explain)
+				}
+				// Markdown never includes the full description.
+				if strings.Contains(report, zlibDescription) {
+					t.Errorf("%s markdown has the full description:\n%s", name, report)
+				}
+			}
+
+			var single SingleScanReport
+			if err := json.Unmarshal([]byte(GenerateSingleScanReport(NewSingleScanReport("image", "docker.io/bitnami/redis:7.2.4", vulns), true, false, opts)), &single); err != nil {
+				t.Fatal(err)
+			}
+			output, err := RenderJSON(generator, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var comparison JSONReport
+			if err := json.Unmarshal([]byte(output), &comparison); err != nil {
+				t.Fatal(err)
+			}
+			jsonCVEs := map[string][]CVE{"single scan": single.CVEs, "comparison": comparison.AddedCVEs}
+			for name, cves := range jsonCVEs {
+				if len(cves) != 1 {
+					t.Fatalf("%s JSON has %d CVEs, want 1", name, len(cves))
+				}
+				want := CVE{}
+				if tt.explain {
+					want = CVE{Title: zlib.Title, Description: zlib.Description}
+				}
+				if cves[0].Title != want.Title || cves[0].Description != want.Description {
+					t.Errorf("%s JSON title, description = %q, %q, want %q, %q", name, cves[0].Title, cves[0].Description, want.Title, want.Description)
+				}
+			}
+		})
+	}
+}
@@ -9,89 +9,227 @@ import (
 	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
 )
 
-func GenerateReport(generator ReportGenerator, generateJSON bool, generateMD bool) string {
+func GenerateReport(generator ReportGenerator, generateJSON bool, generateMD bool, opts ReportOptions) (string, error) {
+	if opts.ReportFile != "" {
+		// An explicit report file holds a single format: JSON when requested, otherwise markdown.
+		report := RenderMarkdown(generator, opts)
+		if generateJSON {
+			jsonReport, err := RenderJSON(generator, opts)
+			if err != nil {
+				return "", fmt.Errorf("error generating JSON report: %w", err)
+			}
+			report = jsonReport
+		}
+		return report, WriteReport(report, "", opts)
+	}
+
 	var lastReport string
-	baseFilename := CreateSafeFileName(generator.GetBaseFilename())
+	baseFilename := GeneratorFilename(generator, opts)
 
 	if generateMD {
-		lastReport = generateMarkdownReport(generator)
-		if err := SaveToFile(lastReport, baseFilename+".md"); err != nil {
-			fmt.Printf("Error saving markdown report: %v\n", err)
+		lastReport = RenderMarkdown(generator, opts)
+		if err := saveToDir(lastReport, opts.reportsDir(), baseFilename+".md"); err != nil {
+			return lastReport, fmt.Errorf("error saving markdown report: %w", err)
 		}
 	}
 
 	if generateJSON {
-		lastReport = generateJSONReport(generator)
-		if err := SaveToFile(lastReport, baseFilename+".json"); err != nil {
-			fmt.Printf("Error saving JSON report: %v\n", err)
+		jsonReport, err := RenderJSON(generator, opts)
+		if err != nil {
+			return lastReport, fmt.Errorf("error generating JSON report: %w", err)
+		}
+		lastReport = jsonReport
+		if err := saveToDir(lastReport, opts.reportsDir(), baseFilename+".json"); err != nil {
+			return lastReport, fmt.Errorf("error saving JSON report: %w", err)
 		}
 	}
 
-	return lastReport
+	return lastReport, nil
 }
 
-func generateMarkdownReport(generator ReportGenerator) string {
-	var sb strings.Builder
+func RenderMarkdown(generator ReportGenerator, opts ReportOptions) string {
+	generator = withDisplayedSeverities(generator, opts)
+	var header strings.Builder
 
-	sb.WriteString(fmt.Sprintf("## %s\n", generator.GetTitle()))
+	header.WriteString(fmt.Sprintf("## %s\n", generator.GetTitle()))
 
 	comparison := generator.GetComparison()
 	if len(comparison) > 0 {
-		for key, value := range comparison {
-			sb.WriteString(fmt.Sprintf("### %s: %s\n", key, value))
+		for _, key := range comparisonKeys(comparison) {
+			header.WriteString(fmt.Sprintf("### %s: %s\n", key, comparison[key]))
 		}
-		sb.WriteString("\n")
+		header.WriteString("\n")
 	}
+	changes := withoutInformationalChanges(generator, opts)
+	header.WriteString(formatStatusBanner(comparisonStatus(changes, opts)))
+
+	var sb strings.Builder
+	sb.WriteString(formatMetadataSection(opts.Metadata))
 
-	headers := []string{"Severity", "Count", "Prev Count", "Difference"}
-	rows := formatSeverityRows(generator.GetSeverityCounts())
+	headers := []string{"Severity", "Count", "Prev Count", "Difference", "Images Affected"}
+	counts := severityCounts(generator, opts)
+	rows := formatSeverityRows(counts, imagesAffectedBySeverity(generator.GetAddedCVEs(), generator.GetUnchangedCVEs()))
 	sb.WriteString(FormatSection("CVE by Severity",
-		FormatMarkdownTable(headers, rows)))
+		FormatMarkdownTable(headers, rows)+"\n"+formatRiskScore(NewRiskScore(counts, opts.riskWeights()))))
 
-	sb.WriteString("### Unchanged CVEs\n\n")
-	if unchangedCVEs := generator.GetUnchangedCVEs(); len(unchangedCVEs) == 0 {
-		sb.WriteString("No unchanged vulnerabilities found.\n\n")
-	} else {
-		sb.WriteString(formatVulnerabilitySection(unchangedCVEs))
+	sb.WriteString(formatRepositoryChangesSection(generator.GetRepositoryChanges()))
+	sb.WriteString(formatImageChangesSection(generator.GetImageChanges()))
+	if before, after := generator.GetOperatingSystems(); len(before) > 0 || len(after) > 0 {
+		sb.WriteString(formatOperatingSystemChangesSection(before, after))
 	}
 
-	sb.WriteString("### Added CVEs\n\n")
-	if addedCVEs := generator.GetAddedCVEs(); len(addedCVEs) == 0 {
-		sb.WriteString("No new vulnerabilities found.\n\n")
+	if opts.CompareOutput == CompareOutputInlineDiff {
+		rows := inlineDiffRows(changes)
+		sb.WriteString("### CVE Changes\n\n")
+		sb.WriteString(collapseCVEs(formatInlineDiffSection(rows, opts), len(rows), "", opts))
 	} else {
-		sb.WriteString(formatVulnerabilitySection(addedCVEs))
+		sb.WriteString("### Unchanged CVEs\n\n")
+		if unchangedCVEs := generator.GetUnchangedCVEs(); len(unchangedCVEs) == 0 {
+			sb.WriteString("No unchanged vulnerabilities found.\n\n")
+		} else {
+			sb.WriteString(collapseCVEs(formatVulnerabilitySection(unchangedCVEs, opts), len(unchangedCVEs), "unchanged", opts))
+		}
+
+		sb.WriteString("### Added CVEs\n\n")
+		if addedCVEs := changes.GetAddedCVEs(); len(addedCVEs) == 0 {
+			sb.WriteString("No new vulnerabilities found.\n\n")
+		} else {
+			sb.WriteString(collapseCVEs(formatVulnerabilitySection(addedCVEs, opts), len(addedCVEs), "added", opts))
+		}
+
+		sb.WriteString("### Removed CVEs\n\n")
+		if removedCVEs := changes.GetRemovedCVEs(); len(removedCVEs) == 0 {
+			sb.WriteString("No removed vulnerabilities found.\n\n")
+		} else {
+			sb.WriteString(collapseCVEs(formatVulnerabilitySection(removedCVEs, opts), len(removedCVEs), "removed", opts))
+		}
 	}
 
-	sb.WriteString("### Removed CVEs\n\n")
-	if removedCVEs := generator.GetRemovedCVEs(); len(removedCVEs) == 0 {
-		sb.WriteString("No removed vulnerabilities found.\n\n")
-	} else {
-		sb.WriteString(formatVulnerabilitySection(removedCVEs))
+	if opts.IgnoreBelow != "" {
+		sb.WriteString(formatInformationalSection(informationalChanges(generator, opts), opts))
 	}
 
-	return sb.String()
+	if opts.Remediation {
+		sb.WriteString(formatRemediationSection(NewRemediationPlan(currentVulnerabilitiesByImage(generator))))
+	}
+	sb.WriteString(formatSkippedImagesSection(generator.GetSkippedImages()))
+	if rawOutputs := generator.GetRawOutputs(); len(rawOutputs) > 0 {
+		sb.WriteString(formatRawOutputsSection(rawOutputs))
+	}
+
+	return header.String() + formatTableOfContents(header.String(), sb.String()) + sb.String()
+}
+
+// comparisonKeys orders the artifacts in the header of a comparison report: the before artifact,
+// then the after artifact, then any others such as a repository change alphabetically.
+func comparisonKeys(comparison map[string]string) []string {
+	rank := func(key string) int {
+		switch {
+		case strings.HasPrefix(key, "Before"):
+			return 0
+		case strings.HasPrefix(key, "After"):
+			return 1
+		}
+		return 2
+	}
+	keys := sortedKeys(comparison)
+	sort.SliceStable(keys, func(i, j int) bool {
+		return rank(keys[i]) < rank(keys[j])
+	})
+	return keys
 }
 
-func generateJSONReport(generator ReportGenerator) string {
+func RenderJSON(generator ReportGenerator, opts ReportOptions) (string, error) {
+	generator = withDisplayedSeverities(generator, opts)
+	counts := severityCounts(generator, opts)
+	riskScore := NewRiskScore(counts, opts.riskWeights())
+	beforeResources, afterResources := generator.GetAffectedResources()
+	var operatingSystems *OperatingSystems
+	if before, after := generator.GetOperatingSystems(); len(before) > 0 || len(after) > 0 {
+		operatingSystems = &OperatingSystems{Before: before, After: after}
+	}
+	changes := withoutInformationalChanges(generator, opts)
+	status := comparisonStatus(changes, opts)
 	report := JSONReport{
 		ReportType: generator.GetTitle(),
+		Metadata:   opts.Metadata,
+		Status:     &status,
 		Comparison: generator.GetComparison(),
 		Summary: Summary{
-			SeverityCounts: generator.GetSeverityCounts(),
+			SeverityCounts:           counts,
+			RiskScore:                &riskScore,
+			ImagesAffectedBySeverity: imagesAffectedBySeverity(generator.GetAddedCVEs(), generator.GetUnchangedCVEs()),
 		},
-		AddedCVEs:     ConvertToJSONCVEs(generator.GetAddedCVEs()),
-		RemovedCVEs:   ConvertToJSONCVEs(generator.GetRemovedCVEs()),
-		UnchangedCVEs: ConvertToJSONCVEs(generator.GetUnchangedCVEs()),
+		AddedCVEs:         ConvertToJSONCVEs(changes.GetAddedCVEs(), afterResources),
+		RemovedCVEs:       ConvertToJSONCVEs(changes.GetRemovedCVEs(), beforeResources),
+		UnchangedCVEs:     ConvertToJSONCVEs(generator.GetUnchangedCVEs(), afterResources),
+		SkippedImages:     generator.GetSkippedImages(),
+		RepositoryChanges: generator.GetRepositoryChanges(),
+		ImageChanges:      generator.GetImageChanges(),
+		OperatingSystems:  operatingSystems,
+		RawOutputs:        generator.GetRawOutputs(),
+	}
+	if opts.IgnoreBelow != "" {
+		informational := thresholdGenerator{ReportGenerator: generator, minSeverity: opts.IgnoreBelow, below: true}
+		report.InformationalAddedCVEs = ConvertToJSONCVEs(informational.GetAddedCVEs(), afterResources)
+		report.InformationalRemovedCVEs = ConvertToJSONCVEs(informational.GetRemovedCVEs(), beforeResources)
+	}
+	if opts.Remediation {
+		report.Remediation = NewRemediationPlan(currentVulnerabilitiesByImage(generator))
+	}
+
+	if !opts.Explain {
+		withoutExplanations(report.AddedCVEs)
+		withoutExplanations(report.RemovedCVEs)
+		withoutExplanations(report.UnchangedCVEs)
+		withoutExplanations(report.InformationalAddedCVEs)
+		withoutExplanations(report.InformationalRemovedCVEs)
 	}
 
 	jsonBytes, err := json.MarshalIndent(report, "", "  ")
 	if err != nil {
-		return fmt.Sprintf("Error generating JSON report: %v", err)
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+func GenerateComparisonSummary(generator ReportGenerator) string {
+	summary := ExitSummary{SchemaVersion: SummarySchemaVersion}
+	for _, count := range generator.GetSeverityCounts() {
+		switch count.Severity {
+		case "critical":
+			summary.Critical = count.Current
+		case "high":
+			summary.High = count.Current
+		case "medium":
+			summary.Medium = count.Current
+		case "low":
+			summary.Low = count.Current
+		}
+	}
+	summary.Total = summary.Critical + summary.High + summary.Medium + summary.Low
+
+	newIDs := make(map[string]bool)
+	for _, vulns := range generator.GetAddedCVEs() {
+		for _, vuln := range vulns {
+			newIDs[vuln.GetID()] = true
+		}
+	}
+	newSinceBefore := len(newIDs)
+	summary.NewSinceBefore = &newSinceBefore
+
+	return marshalExitSummary(summary)
+}
+
+func marshalExitSummary(summary ExitSummary) string {
+	jsonBytes, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Sprintf("Error generating JSON summary: %v", err)
 	}
 	return string(jsonBytes)
 }
 
-func formatSeverityRows(counts []SeverityCount) [][]string {
+func formatSeverityRows(counts []SeverityCount, imagesAffected map[string]int) [][]string {
 	var rows [][]string
 	for _, count := range counts {
 		rows = append(rows, []string{
@@ -99,46 +237,113 @@ func formatSeverityRows(counts []SeverityCount) [][]string {
 			fmt.Sprintf("%d", count.Current),
 			fmt.Sprintf("%d", count.Previous),
 			fmt.Sprintf("%+d", count.Difference),
+			fmt.Sprintf("%d", imagesAffected[count.Severity]),
 		})
 	}
 	return rows
 }
 
-func formatVulnerabilitySection(cves map[string]map[string]helmscanTypes.Vulnerability) string {
+// imagesAffectedBySeverity counts, for each severity, the distinct images with at least one CVE of
+// that severity in the given CVE ID to image maps. Severities without affected images count 0.
+func imagesAffectedBySeverity(cveMaps ...map[string]map[string]helmscanTypes.Vulnerability) map[string]int {
+	images := make(map[string]map[string]bool)
+	for _, cves := range cveMaps {
+		for _, imageVulns := range cves {
+			for image, vuln := range imageVulns {
+				severity := strings.ToLower(vuln.Severity)
+				if images[severity] == nil {
+					images[severity] = make(map[string]bool)
+				}
+				images[severity][image] = true
+			}
+		}
+	}
+
+	counts := map[string]int{"critical": 0, "high": 0, "medium": 0, "low": 0}
+	for severity, affected := range images {
+		counts[severity] = len(affected)
+	}
+	return counts
+}
+
+// collapseCVEs wraps a CVE table in a <details> block summarised by its CVE count when
+// opts.CollapsibleCVEs is set, keeping long tables out of the way in GitHub comments.
+func collapseCVEs(table string, count int, label string, opts ReportOptions) string {
+	if !opts.CollapsibleCVEs || count == 0 {
+		return table
+	}
+	summary := []string{fmt.Sprint(count)}
+	if label != "" {
+		summary = append(summary, label)
+	}
+	if count == 1 {
+		summary = append(summary, "CVE")
+	} else {
+		summary = append(summary, "CVEs")
+	}
+	return fmt.Sprintf("<details>\n<summary>%s</summary>\n\n%s\n</details>\n\n", strings.Join(summary, " "), table)
+}
+
+func formatVulnerabilitySection(cves map[string]map[string]helmscanTypes.Vulnerability, opts ReportOptions) string {
 	if len(cves) == 0 {
 		return "No CVEs found.\n\n"
 	}
 
 	var sortedCVEs SortableCVEList
 	for cveID, imageVulns := range cves {
-		var images []string
-		var severity string
-		for imageName, vuln := range imageVulns {
-			images = append(images, imageName)
-			severity = vuln.GetSeverity()
-		}
-		sortedCVEs = append(sortedCVEs, SortableCVE{
-			ID:       cveID,
-			Severity: severity,
-			Images:   images,
-		})
+		sortedCVEs = append(sortedCVEs, newSortableCVE(cveID, imageVulns))
 	}
 
 	sort.Sort(sortedCVEs)
+	sortedCVEs, hidden := topPerSeverity(sortedCVEs, opts.Top, func(cve SortableCVE) (string, float64, string) {
+		return cve.Severity, cve.CVSS, cve.ID
+	})
+
+	showEPSS, showFixed := false, false
+	for _, cve := range sortedCVEs {
+		showEPSS = showEPSS || cve.EPSS != nil
+		showFixed = showFixed || cve.FixedVersion != ""
+	}
+
+	header, separator := "| CVE ID | Severity |", "|--------|----------|"
+	if showEPSS {
+		header, separator = header+" EPSS |", separator+"------|"
+	}
+	if showFixed {
+		header, separator = header+" Fixed Version |", separator+"---------------|"
+	}
+	header, separator = header+" Affected Images |", separator+"------------------|"
+	if opts.Explain {
+		header, separator = header+" Description |", separator+"-------------|"
+	}
+	header, separator = header+"\n", separator+"\n"
 
 	var sb strings.Builder
 	currentSeverity := ""
 	for _, cve := range sortedCVEs {
-		if cve.Severity != currentSeverity {
+		if !strings.EqualFold(cve.Severity, currentSeverity) {
 			if currentSeverity != "" {
+				sb.WriteString(formatMoreCVEs(hidden, currentSeverity))
 				sb.WriteString("\n")
 			}
 			sb.WriteString(fmt.Sprintf("#### %s\n", strings.Title(cve.Severity)))
-			sb.WriteString("| CVE ID | Severity | Affected Images |\n")
-			sb.WriteString("|--------|----------|------------------|\n")
+			sb.WriteString(header)
+			sb.WriteString(separator)
 			currentSeverity = cve.Severity
 		}
-		sb.WriteString(fmt.Sprintf("| %s | %s | %s |\n", cve.ID, cve.Severity, strings.Join(cve.Images, ", ")))
+		row := fmt.Sprintf("| %s | %s |", escapeTableCell(formatCVEID(cve.ID, cve.KEV, cve.PrimaryURL)), escapeTableCell(cve.Severity))
+		if showEPSS {
+			row += fmt.Sprintf(" %s |", formatEPSS(cve.EPSS))
+		}
+		if showFixed {
+			row += fmt.Sprintf(" %s |", escapeTableCell(formatFixedVersion(cve.FixedVersion)))
+		}
+		row += fmt.Sprintf(" %s |", escapeTableCell(strings.Join(cve.Images, ", ")))
+		if opts.Explain {
+			row += fmt.Sprintf(" %s |", escapeTableCell(formatExplanation(cve.Title, cve.Description)))
+		}
+		sb.WriteString(row + "\n")
 	}
+	sb.WriteString(formatMoreCVEs(hidden, currentSeverity))
 	return sb.String()
 }
@@ -0,0 +1,965 @@
+package reports_test
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+	"github.com/cliffcolvin/helmscan/internal/helmscan"
+	"github.com/cliffcolvin/helmscan/internal/imageScan"
+	"github.com/cliffcolvin/helmscan/internal/reports"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata with the current output")
+
+// checkGolden compares got with testdata/name, or rewrites the file with got when -update is set.
+func checkGolden(t *testing.T, name string, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file: %v (run go test -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s (run go test -update if the change is intended):\n--- got ---\n%s\n--- want ---\n%s", path, got, want)
+	}
+}
+
+func image(repository, name, tag string, vulns ...helmscanTypes.Vulnerability) *helmscanTypes.ContainerImage {
+	img := &helmscanTypes.ContainerImage{
+		Repository:      repository,
+		ImageName:       name,
+		Tag:             tag,
+		Vulnerabilities: make(map[string]helmscanTypes.Vulnerability),
+		SourceRefs:      []helmscanTypes.SourceRef{{Kind: "Deployment", Name: "release-name-" + name, Container: name, Path: "spec.template.spec.containers[0].image"}},
+	}
+	for _, vuln := range vulns {
+		img.Vulnerabilities[vuln.ID] = vuln
+	}
+	return img
+}
+
+func cves(entries ...any) map[string]map[string]helmscanTypes.Vulnerability {
+	result := make(map[string]map[string]helmscanTypes.Vulnerability)
+	for i := 0; i < len(entries); i += 2 {
+		name, vuln := entries[i].(string), entries[i+1].(helmscanTypes.Vulnerability)
+		if result[vuln.ID] == nil {
+			result[vuln.ID] = make(map[string]helmscanTypes.Vulnerability)
+		}
+		result[vuln.ID][name] = vuln
+	}
+	return result
+}
+
+// goldenComparison is a chart upgrade with a changed (nginx), unchanged (redis), removed (legacy
+// sidecar) and added (oauth2-proxy) image, and CVEs of every severity.
+func goldenComparison(afterRepo string) helmscanTypes.HelmComparison {
+	var (
+		httpReset   = helmscanTypes.Vulnerability{ID: "CVE-2023-44487", Severity: "high", PkgName: "nginx", InstalledVersion: "1.24.0", FixedVersion: "1.25.3", CVSS: 7.5}
+		resolver    = helmscanTypes.Vulnerability{ID: "CVE-2023-5678", Severity: "medium", PkgName: "openssl", InstalledVersion: "3.0.11", FixedVersion: "3.0.13", CVSS: 5.3}
+		mp4         = helmscanTypes.Vulnerability{ID: "CVE-2024-7347", Severity: "medium", PkgName: "nginx", InstalledVersion: "1.25.0", FixedVersion: "1.27.1", CVSS: 5.9}
+		zlib        = helmscanTypes.Vulnerability{ID: "CVE-2023-45853", Severity: "critical", PkgName: "zlib1g", InstalledVersion: "1:1.2.13.dfsg-1", CVSS: 9.8}
+		apt         = helmscanTypes.Vulnerability{ID: "CVE-2011-3374", Severity: "low", PkgName: "apt", InstalledVersion: "2.6.1", CVSS: 3.7}
+		busybox     = helmscanTypes.Vulnerability{ID: "CVE-2022-48174", Severity: "critical", PkgName: "busybox", InstalledVersion: "1.35.0", FixedVersion: "1.36.1", CVSS: 9.8}
+		regreSSHion = helmscanTypes.Vulnerability{ID: "CVE-2024-6387", Severity: "high", PkgName: "openssh-client", InstalledVersion: "1:9.2p1-2", FixedVersion: "1:9.2p1-2+deb12u3", CVSS: 8.1}
+		ncurses     = helmscanTypes.Vulnerability{ID: "CVE-2023-50495", Severity: "low", PkgName: "libtinfo6", InstalledVersion: "6.4-4", CVSS: 6.5}
+	)
+
+	const (
+		nginx  = "docker.io/bitnami/nginx"
+		redis  = "docker.io/bitnami/redis"
+		legacy = "docker.io/library/busybox"
+		proxy  = "quay.io/oauth2-proxy/oauth2-proxy"
+	)
+	nginxBefore := image("docker.io/bitnami", "nginx", "1.24.0", httpReset, resolver)
+	nginxAfter := image("docker.io/bitnami", "nginx", "1.25.0", resolver, mp4, zlib)
+	redisBefore := image("docker.io/bitnami", "redis", "7.2.4", apt)
+	redisAfter := image("docker.io/bitnami", "redis", "7.2.4", apt)
+	legacySidecar := image("docker.io/library", "busybox", "1.35.0", busybox)
+	oauth2Proxy := image("quay.io/oauth2-proxy", "oauth2-proxy", "v7.6.0", regreSSHion, ncurses)
+
+	return helmscanTypes.HelmComparison{
+		Before: helmscanTypes.HelmChart{
+			Name: "web", Version: "1.0.0", HelmRepo: "bitnami",
+			ContainsImages: []*helmscanTypes.ContainerImage{nginxBefore, redisBefore, legacySidecar},
+		},
+		After: helmscanTypes.HelmChart{
+			Name: "web", Version: "2.0.0", HelmRepo: afterRepo,
+			ContainsImages: []*helmscanTypes.ContainerImage{nginxAfter, redisAfter, oauth2Proxy},
+			SkippedImages:  []helmscanTypes.SkippedImage{{Reference: "REPLACE_ME", Reason: "placeholder value"}},
+		},
+		AddedImages:     map[string][]*helmscanTypes.ContainerImage{proxy: {oauth2Proxy}},
+		RemovedImages:   map[string][]*helmscanTypes.ContainerImage{legacy: {legacySidecar}},
+		ChangedImages:   map[string][]*helmscanTypes.ContainerImage{nginx: {nginxBefore, nginxAfter}},
+		UnChangedImages: map[string][]*helmscanTypes.ContainerImage{redis: {redisBefore, redisAfter}},
+		AddedCVEs:       cves(nginx, mp4, nginx, zlib, proxy, regreSSHion, proxy, ncurses),
+		RemovedCVEs:     cves(nginx, httpReset, legacy, busybox),
+		UnchangedCVEs:   cves(nginx, resolver, redis, apt),
+	}
+}
+
+func TestRenderMarkdownGolden(t *testing.T) {
+	tests := []struct {
+		name       string
+		golden     string
+		comparison helmscanTypes.HelmComparison
+		opts       reports.ReportOptions
+	}{
+		{
+			name:       "chart upgrade",
+			golden:     "helm_comparison.golden",
+			comparison: goldenComparison("bitnami"),
+		},
+		{
+			// The chart moved repos, so the header also calls out the repository change, after the
+			// before and after charts.
+			name:       "repository change",
+			golden:     "helm_comparison_repository_change.golden",
+			comparison: goldenComparison("bitnami-mirror"),
+		},
+		{
+			// Only the CVE tables fold away; the summary and image tables stay visible.
+			name:       "collapsible CVEs",
+			golden:     "helm_comparison_collapsible.golden",
+			comparison: goldenComparison("bitnami"),
+			opts:       reports.ReportOptions{CollapsibleCVEs: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := reports.RenderMarkdown(helmscan.NewHelmReportGenerator(tt.comparison), tt.opts)
+			checkGolden(t, tt.golden, got)
+		})
+	}
+}
+
+// goldenImageComparison is an image upgrade with an added, a removed and an unchanged CVE, with
+// and without fixed versions.
+func goldenImageComparison() *helmscanTypes.ImageComparisonReport {
+	var (
+		zlib = helmscanTypes.Vulnerability{ID: "CVE-2023-45853", Severity: "critical", PkgName: "zlib1g", InstalledVersion: "1:1.2.13.dfsg-1"}
+		apt  = helmscanTypes.Vulnerability{ID: "CVE-2011-3374", Severity: "low", PkgName: "apt", InstalledVersion: "2.6.1"}
+		ssh  = helmscanTypes.Vulnerability{ID: "CVE-2024-6387", Severity: "high", PkgName: "openssh-client", InstalledVersion: "1:9.2p1-2", FixedVersion: "1:9.2p1-2+deb12u3"}
+		mp4  = helmscanTypes.Vulnerability{ID: "CVE-2024-7347", Severity: "medium", PkgName: "nginx", InstalledVersion: "1.25.0", FixedVersion: "1.27.1"}
+	)
+	return imageScan.CompareScans(
+		helmscanTypes.ScanResult{Image: "docker.io/bitnami/nginx:1.25.0", VulnList: []helmscanTypes.Vulnerability{zlib, apt, mp4}},
+		helmscanTypes.ScanResult{Image: "docker.io/bitnami/nginx:1.27.1", VulnList: []helmscanTypes.Vulnerability{apt, ssh}},
+	)
+}
+
+func TestRenderImageComparisonMarkdownGolden(t *testing.T) {
+	got := reports.RenderMarkdown(imageScan.NewImageReportGenerator(goldenImageComparison()), reports.ReportOptions{})
+	checkGolden(t, "image_comparison.golden", got)
+}
+
+func TestRenderImageComparisonJSON(t *testing.T) {
+	output, err := reports.RenderJSON(imageScan.NewImageReportGenerator(goldenImageComparison()), reports.ReportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report reports.JSONReport
+	if err := json.Unmarshal([]byte(output), &report); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		cves []reports.CVE
+		want []reports.CVE
+	}{
+		{
+			name: "added",
+			cves: report.AddedCVEs,
+			want: []reports.CVE{{ID: "CVE-2024-6387", Severity: "high", FixedVersion: "1:9.2p1-2+deb12u3", AffectedImages: []string{"docker.io/bitnami/nginx:1.27.1"}}},
+		},
+		{
+			name: "removed",
+			cves: report.RemovedCVEs,
+			want: []reports.CVE{
+				{ID: "CVE-2023-45853", Severity: "critical", AffectedImages: []string{"docker.io/bitnami/nginx:1.25.0"}},
+				{ID: "CVE-2024-7347", Severity: "medium", FixedVersion: "1.27.1", AffectedImages: []string{"docker.io/bitnami/nginx:1.25.0"}},
+			},
+		},
+		{
+			name: "unchanged",
+			cves: report.UnchangedCVEs,
+			want: []reports.CVE{{ID: "CVE-2011-3374", Severity: "low", AffectedImages: []string{"docker.io/bitnami/nginx:1.27.1"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.cves, tt.want) {
+				t.Errorf("%s CVEs = %+v, want %+v", tt.name, tt.cves, tt.want)
+			}
+		})
+	}
+}
+
+func TestTableOfContentsLinksResolve(t *testing.T) {
+	metadata := &reports.Metadata{ToolVersion: "dev", TrivyVersion: "0.56.2", Command: "helmscan"}
+	mirrored := goldenComparison("bitnami-mirror")
+	mirrored.RepositoryChanges = []helmscanTypes.RepositoryChange{
+		{ImageName: "nginx", BeforeRepository: "docker.io/bitnami", AfterRepository: "registry.example.com/bitnami"},
+	}
+	tests := []struct {
+		name      string
+		generator reports.ReportGenerator
+		// wantLinks are the sections the table of contents must list.
+		wantLinks []string
+	}{
+		{
+			name:      "chart comparison",
+			generator: helmscan.NewHelmReportGenerator(goldenComparison("bitnami")),
+			wantLinks: []string{"#report-metadata", "#cve-by-severity", "#unchanged-cves", "#added-cves", "#removed-cves", "#skipped-images"},
+		},
+		{
+			name:      "repository change",
+			generator: helmscan.NewHelmReportGenerator(mirrored),
+			wantLinks: []string{"#repository-changes"},
+		},
+		{
+			name:      "image comparison",
+			generator: imageScan.NewImageReportGenerator(goldenImageComparison()),
+			wantLinks: []string{"#cve-by-severity", "#added-cves"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			markdown := reports.RenderMarkdown(tt.generator, reports.ReportOptions{Metadata: metadata})
+
+			// Collect the anchors GitHub generates for the headings: lowercase, spaces as dashes,
+			// other punctuation dropped and -N suffixes for repeats.
+			anchors := make(map[string]bool)
+			seen := make(map[string]int)
+			for _, line := range strings.Split(markdown, "\n") {
+				text, ok := strings.CutPrefix(strings.TrimLeft(line, "#"), " ")
+				if !ok || !strings.HasPrefix(line, "#") {
+					continue
+				}
+				slug := strings.Map(func(r rune) rune {
+					switch {
+					case r == ' ':
+						return '-'
+					case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+						return unicode.ToLower(r)
+					}
+					return -1
+				}, strings.TrimSpace(text))
+				if n := seen[slug]; n > 0 {
+					anchors[fmt.Sprintf("%s-%d", slug, n)] = true
+				} else {
+					anchors[slug] = true
+				}
+				seen[slug]++
+			}
+
+			_, contents, ok := strings.Cut(markdown, "### Contents\n\n")
+			if !ok {
+				t.Fatalf("report has no table of contents:\n%s", markdown)
+			}
+			contents, _, _ = strings.Cut(contents, "\n\n")
+			var links []string
+			for _, entry := range strings.Split(contents, "\n") {
+				_, link, ok := strings.Cut(entry, "](")
+				if !ok || !strings.HasSuffix(link, ")") {
+					t.Fatalf("malformed table of contents entry %q", entry)
+				}
+				link = strings.TrimSuffix(link, ")")
+				links = append(links, link)
+				if !anchors[strings.TrimPrefix(link, "#")] {
+					t.Errorf("table of contents link %s does not resolve to a heading", link)
+				}
+			}
+			for _, want := range tt.wantLinks {
+				if !slices.Contains(links, want) {
+					t.Errorf("table of contents links = %v, want %s", links, want)
+				}
+			}
+		})
+	}
+}
+
+func TestComparisonRiskScore(t *testing.T) {
+	generator := helmscan.NewHelmReportGenerator(goldenComparison("bitnami"))
+	tests := []struct {
+		name    string
+		weights reports.RiskWeights
+	}{
+		{name: "default weights", weights: reports.DefaultRiskWeights},
+		{name: "custom weights", weights: reports.RiskWeights{Critical: 20, High: 8, Medium: 3, Low: 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := reports.ReportOptions{RiskWeights: tt.weights}
+			output, err := reports.RenderJSON(generator, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var report reports.JSONReport
+			if err := json.Unmarshal([]byte(output), &report); err != nil {
+				t.Fatal(err)
+			}
+
+			// The score is the weighted sum of the counts in the same report.
+			weight := map[string]int{"critical": tt.weights.Critical, "high": tt.weights.High, "medium": tt.weights.Medium, "low": tt.weights.Low}
+			var want reports.RiskScore
+			for _, count := range report.Summary.SeverityCounts {
+				want.Current += count.Current * weight[count.Severity]
+				want.Previous += count.Previous * weight[count.Severity]
+			}
+			want.Difference = want.Current - want.Previous
+			if report.Summary.RiskScore == nil || *report.Summary.RiskScore != want {
+				t.Fatalf("risk_score = %+v, want %+v", report.Summary.RiskScore, want)
+			}
+
+			markdown := reports.RenderMarkdown(generator, opts)
+			line := fmt.Sprintf("**Risk score:** %d (previous %d, %+d)", want.Current, want.Previous, want.Difference)
+			if !strings.Contains(markdown, line) {
+				t.Errorf("markdown report is missing %q", line)
+			}
+		})
+	}
+}
+
+func TestGeneratorFilename(t *testing.T) {
+	tests := []struct {
+		name      string
+		generator reports.ReportGenerator
+		kind      string
+	}{
+		{name: "chart comparison", generator: helmscan.NewHelmReportGenerator(goldenComparison("bitnami")), kind: "helm_cmp"},
+		{name: "image comparison", generator: imageScan.NewImageReportGenerator(goldenImageComparison()), kind: "image_cmp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readable := reports.GeneratorFilename(tt.generator, reports.ReportOptions{})
+			if want := reports.CreateSafeFileName(tt.generator.GetBaseFilename()); readable != want {
+				t.Errorf("GeneratorFilename() = %q, want %q", readable, want)
+			}
+
+			hashed := reports.GeneratorFilename(tt.generator, reports.ReportOptions{HashedFilenames: true})
+			if hash, ok := strings.CutPrefix(hashed, tt.kind+"_"); !ok || len(hash) != 8 {
+				t.Errorf("GeneratorFilename() = %q, want %s_ and an 8 character hash", hashed, tt.kind)
+			}
+			if again := reports.GeneratorFilename(tt.generator, reports.ReportOptions{HashedFilenames: true}); again != hashed {
+				t.Errorf("GeneratorFilename() = %q, then %q for the same comparison", hashed, again)
+			}
+			if other := reports.GeneratorFilename(tt.generator, reports.ReportOptions{HashedFilenames: true, IgnoreUnfixed: true}); other == hashed {
+				t.Errorf("GeneratorFilename() = %q with and without IgnoreUnfixed", hashed)
+			}
+		})
+	}
+}
+
+func TestGenerateComparisonSummary(t *testing.T) {
+	generator := helmscan.NewHelmReportGenerator(goldenComparison("bitnami"))
+
+	var summary map[string]int
+	if err := json.Unmarshal([]byte(reports.GenerateComparisonSummary(generator)), &summary); err != nil {
+		t.Fatalf("summary is not a JSON object of counts: %v", err)
+	}
+	keys := make([]string, 0, len(summary))
+	for key := range summary {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	wantKeys := []string{"critical", "high", "low", "medium", "newSinceBefore", "schemaVersion", "total"}
+	if !reflect.DeepEqual(keys, wantKeys) {
+		t.Errorf("summary keys = %v, want %v", keys, wantKeys)
+	}
+
+	full, err := reports.RenderJSON(generator, reports.ReportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report reports.JSONReport
+	if err := json.Unmarshal([]byte(full), &report); err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for _, count := range report.Summary.SeverityCounts {
+		if summary[count.Severity] != count.Current {
+			t.Errorf("summary %s = %d, want %d as in the full report", count.Severity, summary[count.Severity], count.Current)
+		}
+		total += count.Current
+	}
+	if summary["total"] != total {
+		t.Errorf("summary total = %d, want %d", summary["total"], total)
+	}
+	if summary["newSinceBefore"] != len(report.AddedCVEs) {
+		t.Errorf("summary newSinceBefore = %d, want the %d added CVEs of the full report", summary["newSinceBefore"], len(report.AddedCVEs))
+	}
+	if summary["schemaVersion"] != reports.SummarySchemaVersion {
+		t.Errorf("summary schemaVersion = %d, want %d", summary["schemaVersion"], reports.SummarySchemaVersion)
+	}
+}
+
+// readTree returns the contents of the files under dir, keyed by their path relative to dir.
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestRenderersHaveNoSideEffects(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	generator := helmscan.NewHelmReportGenerator(goldenComparison("bitnami"))
+
+	tests := []struct {
+		name   string
+		render func() (string, error)
+	}{
+		{"markdown", func() (string, error) { return reports.RenderMarkdown(generator, reports.ReportOptions{}), nil }},
+		{"json", func() (string, error) { return reports.RenderJSON(generator, reports.ReportOptions{}) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, err := tt.render()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first == "" {
+				t.Fatal("rendered an empty report")
+			}
+			if second, _ := tt.render(); second != first {
+				t.Error("rendering the same comparison twice gave different reports")
+			}
+			if files := readTree(t, dir); len(files) != 0 {
+				t.Errorf("rendering wrote %v, want no files", files)
+			}
+		})
+	}
+}
+
+func TestGenerateReportSavesRenderedReports(t *testing.T) {
+	generator := helmscan.NewHelmReportGenerator(goldenComparison("bitnami"))
+	markdown := reports.RenderMarkdown(generator, reports.ReportOptions{})
+	jsonReport, err := reports.RenderJSON(generator, reports.ReportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		generateJSON bool
+		generateMD   bool
+		want         []string
+	}{
+		{name: "markdown", generateMD: true, want: []string{markdown}},
+		{name: "json", generateJSON: true, want: []string{jsonReport}},
+		{name: "both", generateJSON: true, generateMD: true, want: []string{jsonReport, markdown}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if _, err := reports.GenerateReport(generator, tt.generateJSON, tt.generateMD, reports.ReportOptions{OutputDir: dir}); err != nil {
+				t.Fatalf("GenerateReport() error = %v", err)
+			}
+			var got []string
+			for _, content := range readTree(t, dir) {
+				got = append(got, content)
+			}
+			sort.Strings(got)
+			sort.Strings(tt.want)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GenerateReport() saved %d reports that differ from the rendered ones", len(got))
+			}
+		})
+	}
+}
+
+func TestGenerateReportToReportFile(t *testing.T) {
+	generator := helmscan.NewHelmReportGenerator(goldenComparison("bitnami"))
+	markdown := reports.RenderMarkdown(generator, reports.ReportOptions{})
+	jsonReport, err := reports.RenderJSON(generator, reports.ReportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		reportFile   string
+		generateJSON bool
+		want         string
+		wantFiles    []string
+	}{
+		// A report file holds one format, JSON when it is requested.
+		{name: "markdown file", reportFile: "report.md", want: markdown, wantFiles: []string{"report.md"}},
+		{name: "json file", reportFile: "report.json", generateJSON: true, want: jsonReport, wantFiles: []string{"report.json"}},
+		// With - the report is returned for the caller to print, and nothing is saved.
+		{name: "stdout", reportFile: "-", want: markdown},
+		{name: "stdout json", reportFile: "-", generateJSON: true, want: jsonReport},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Chdir(dir)
+			got, err := reports.GenerateReport(generator, tt.generateJSON, true, reports.ReportOptions{ReportFile: tt.reportFile})
+			if err != nil {
+				t.Fatalf("GenerateReport() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GenerateReport() returned a different report than the one rendered")
+			}
+			saved := readTree(t, dir)
+			if files := slices.Sorted(maps.Keys(saved)); !slices.Equal(files, tt.wantFiles) {
+				t.Fatalf("saved files = %v, want %v", files, tt.wantFiles)
+			}
+			for _, file := range tt.wantFiles {
+				if saved[file] != tt.want {
+					t.Errorf("%s differs from the returned report", file)
+				}
+			}
+		})
+	}
+}
+
+func TestReportMetadata(t *testing.T) {
+	generator := helmscan.NewHelmReportGenerator(goldenComparison("bitnami"))
+	tests := []struct {
+		name     string
+		metadata reports.Metadata
+		wantRows []string
+	}{
+		{
+			name: "with Trivy DB",
+			metadata: reports.Metadata{
+				ToolVersion:      "v1.4.0",
+				GeneratedAt:      time.Now().UTC().Format(time.RFC3339),
+				TrivyVersion:     "0.56.2",
+				TrivyDBUpdatedAt: "2024-11-05T00:30:29Z",
+				Command:          "helmscan --json bitnami/redis@18.1.0",
+			},
+			wantRows: []string{"| HelmScan Version | v1.4.0 |", "| Trivy Version | 0.56.2 |", "| Trivy DB Updated At | 2024-11-05T00:30:29Z |", "| Command | `helmscan --json bitnami/redis@18.1.0` |"},
+		},
+		{
+			// Without a DB update time, as before the first scan, the row is left out.
+			name: "without Trivy DB",
+			metadata: reports.Metadata{
+				ToolVersion:  "dev",
+				GeneratedAt:  time.Now().UTC().Format(time.RFC3339),
+				TrivyVersion: "0.56.2",
+				Command:      "helmscan",
+			},
+			wantRows: []string{"| HelmScan Version | dev |", "| Trivy Version | 0.56.2 |", "| Command | `helmscan` |"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := reports.ReportOptions{Metadata: &tt.metadata}
+
+			markdown := reports.RenderMarkdown(generator, opts)
+			if !strings.Contains(markdown, "### Report Metadata") {
+				t.Error("markdown report has no metadata section")
+			}
+			for _, row := range append(tt.wantRows, "| Generated At | "+tt.metadata.GeneratedAt+" |") {
+				if !strings.Contains(markdown, row) {
+					t.Errorf("markdown report is missing the metadata row %q", row)
+				}
+			}
+			if hasDB := strings.Contains(markdown, "Trivy DB Updated At"); hasDB != (tt.metadata.TrivyDBUpdatedAt != "") {
+				t.Errorf("markdown report lists the Trivy DB update time = %v, want %v", hasDB, !hasDB)
+			}
+
+			output, err := reports.RenderJSON(generator, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var report struct {
+				Metadata map[string]string `json:"metadata"`
+			}
+			if err := json.Unmarshal([]byte(output), &report); err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]string{
+				"tool_version":  tt.metadata.ToolVersion,
+				"generated_at":  tt.metadata.GeneratedAt,
+				"trivy_version": tt.metadata.TrivyVersion,
+				"command":       tt.metadata.Command,
+			}
+			if tt.metadata.TrivyDBUpdatedAt != "" {
+				want["trivy_db_updated_at"] = tt.metadata.TrivyDBUpdatedAt
+			}
+			if !reflect.DeepEqual(report.Metadata, want) {
+				t.Errorf("JSON metadata = %v, want %v", report.Metadata, want)
+			}
+			if _, err := time.Parse(time.RFC3339, report.Metadata["generated_at"]); err != nil {
+				t.Errorf("generated_at does not parse as RFC3339: %v", err)
+			}
+		})
+	}
+}
+
+func TestGenerateReportSaveErrors(t *testing.T) {
+	// A regular file where a directory is expected makes every save fail.
+	blocker := filepath.Join(t.TempDir(), "not-a-directory")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	generator := helmscan.NewHelmReportGenerator(goldenComparison("bitnami"))
+
+	tests := []struct {
+		name         string
+		generateJSON bool
+		generateMD   bool
+		opts         reports.ReportOptions
+		wantErr      string
+	}{
+		{name: "markdown", generateMD: true, opts: reports.ReportOptions{OutputDir: blocker}, wantErr: "error saving markdown report"},
+		{name: "json", generateJSON: true, opts: reports.ReportOptions{OutputDir: blocker}, wantErr: "error saving JSON report"},
+		{name: "report file", generateMD: true, opts: reports.ReportOptions{ReportFile: filepath.Join(blocker, "report.md")}, wantErr: "error writing report to file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := reports.GenerateReport(generator, tt.generateJSON, tt.generateMD, tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("GenerateReport() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRenderOperatingSystems(t *testing.T) {
+	var (
+		debian124 = helmscanTypes.OperatingSystem{Family: "debian", Name: "12.4"}
+		debian125 = helmscanTypes.OperatingSystem{Family: "debian", Name: "12.5"}
+		alpine    = helmscanTypes.OperatingSystem{Family: "alpine", Name: "3.19.1"}
+	)
+	tests := []struct {
+		name     string
+		setOS    func(comparison helmscanTypes.HelmComparison)
+		wantRows []string
+		wantJSON *reports.OperatingSystems
+	}{
+		{name: "no OS detected", setOS: func(helmscanTypes.HelmComparison) {}},
+		{
+			// Added and removed images have an OS on one side only.
+			name: "OS detected",
+			setOS: func(comparison helmscanTypes.HelmComparison) {
+				comparison.ChangedImages["docker.io/bitnami/nginx"][0].OS = debian124
+				comparison.ChangedImages["docker.io/bitnami/nginx"][1].OS = debian125
+				comparison.RemovedImages["docker.io/library/busybox"][0].OS = alpine
+			},
+			wantRows: []string{
+				"| docker.io/bitnami/nginx | debian 12.4 | debian 12.5 |",
+				"| docker.io/library/busybox | alpine 3.19.1 | - |",
+			},
+			wantJSON: &reports.OperatingSystems{
+				Before: map[string]helmscanTypes.OperatingSystem{"docker.io/bitnami/nginx": debian124, "docker.io/library/busybox": alpine},
+				After:  map[string]helmscanTypes.OperatingSystem{"docker.io/bitnami/nginx": debian125},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparison := goldenComparison("bitnami")
+			tt.setOS(comparison)
+			generator := helmscan.NewHelmReportGenerator(comparison)
+
+			markdown := reports.RenderMarkdown(generator, reports.ReportOptions{})
+			if has, want := strings.Contains(markdown, "Image Operating Systems"), tt.wantRows != nil; has != want {
+				t.Errorf("report has an Image Operating Systems section = %v, want %v", has, want)
+			}
+			for _, row := range tt.wantRows {
+				if !strings.Contains(markdown, row) {
+					t.Errorf("report is missing the row %q:\n%s", row, markdown)
+				}
+			}
+
+			output, err := reports.RenderJSON(generator, reports.ReportOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var report reports.JSONReport
+			if err := json.Unmarshal([]byte(output), &report); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(report.OperatingSystems, tt.wantJSON) {
+				t.Errorf("operating_systems = %+v, want %+v", report.OperatingSystems, tt.wantJSON)
+			}
+		})
+	}
+}
+
+func TestRenderImageChanges(t *testing.T) {
+	const (
+		digestA = "sha256:3127bd2ec9e8a1b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f9012345ab"
+		digestB = "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	)
+	tests := []struct {
+		name     string
+		changes  []helmscanTypes.ImageChange
+		wantRows []string
+	}{
+		{name: "compared by tag"},
+		{
+			// A digest that is not known is shown as a dash.
+			name: "compared by digest",
+			changes: []helmscanTypes.ImageChange{
+				{ImageName: "docker.io/bitnami/nginx", BeforeTag: "1.24.0", AfterTag: "1.25.0", AfterDigest: digestB},
+				{ImageName: "docker.io/bitnami/redis", BeforeTag: "7.2", AfterTag: "7.2", BeforeDigest: digestA, AfterDigest: digestB},
+			},
+			wantRows: []string{
+				"| docker.io/bitnami/nginx | 1.24.0 | 1.25.0 | - | " + digestB + " |",
+				"| docker.io/bitnami/redis | 7.2 | 7.2 | " + digestA + " | " + digestB + " |",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparison := goldenComparison("bitnami")
+			comparison.ImageChanges = tt.changes
+			generator := helmscan.NewHelmReportGenerator(comparison)
+
+			markdown := reports.RenderMarkdown(generator, reports.ReportOptions{})
+			if has, want := strings.Contains(markdown, "Image Changes"), tt.wantRows != nil; has != want {
+				t.Errorf("report has an Image Changes section = %v, want %v", has, want)
+			}
+			for _, row := range tt.wantRows {
+				if !strings.Contains(markdown, row) {
+					t.Errorf("report is missing the row %q:\n%s", row, markdown)
+				}
+			}
+
+			output, err := reports.RenderJSON(generator, reports.ReportOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var report reports.JSONReport
+			if err := json.Unmarshal([]byte(output), &report); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(report.ImageChanges, tt.changes) {
+				t.Errorf("image_changes = %+v, want %+v", report.ImageChanges, tt.changes)
+			}
+		})
+	}
+}
+
+func TestImagesAffectedBySeverityReported(t *testing.T) {
+	// Added and unchanged CVEs are in the after chart; the removed busybox CVE is not counted.
+	want := map[string]int{"critical": 1, "high": 1, "medium": 1, "low": 2}
+	comparison := goldenComparison("bitnami")
+	output, err := reports.RenderJSON(helmscan.NewHelmReportGenerator(comparison), reports.ReportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		output string
+	}{
+		{name: "JSON report", output: output},
+		{name: "legacy JSON report", output: reports.GenerateJSONReport(comparison)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var report reports.JSONReport
+			if err := json.Unmarshal([]byte(tt.output), &report); err != nil {
+				t.Fatal(err)
+			}
+			if got := report.Summary.ImagesAffectedBySeverity; !reflect.DeepEqual(got, want) {
+				t.Errorf("images_affected_by_severity = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestComparisonStatus(t *testing.T) {
+	// goldenComparison adds a critical, a high, a medium and a low CVE.
+	unchanged := goldenComparison("bitnami")
+	unchanged.AddedCVEs = nil
+	tests := []struct {
+		name       string
+		comparison helmscanTypes.HelmComparison
+		opts       reports.ReportOptions
+		want       reports.ReportStatus
+	}{
+		{
+			name:       "default severity",
+			comparison: goldenComparison("bitnami"),
+			want:       reports.ReportStatus{Result: reports.StatusFail, Message: "❌ 1 new critical and 1 new high CVEs introduced"},
+		},
+		{
+			name:       "critical severity",
+			comparison: goldenComparison("bitnami"),
+			opts:       reports.ReportOptions{StatusSeverity: "critical"},
+			want:       reports.ReportStatus{Result: reports.StatusFail, Message: "❌ 1 new critical CVE introduced"},
+		},
+		{
+			name:       "low severity",
+			comparison: goldenComparison("bitnami"),
+			opts:       reports.ReportOptions{StatusSeverity: "low"},
+			want: reports.ReportStatus{
+				Result:  reports.StatusFail,
+				Message: "❌ 1 new critical, 1 new high, 1 new medium and 1 new low CVEs introduced",
+			},
+		},
+		{
+			name:       "no added CVEs",
+			comparison: unchanged,
+			want:       reports.ReportStatus{Result: reports.StatusPass, Message: "✅ No new critical/high CVEs"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator := helmscan.NewHelmReportGenerator(tt.comparison)
+			markdown := reports.RenderMarkdown(generator, tt.opts)
+			banner := "> **" + tt.want.Message + "**\n\n"
+			if !strings.Contains(markdown, banner) {
+				t.Fatalf("markdown report has no %q banner:\n%s", tt.want.Message, markdown)
+			}
+			// The verdict comes before any section of the report.
+			if strings.Index(markdown, banner) > strings.Index(markdown, "CVE by Severity") {
+				t.Errorf("banner is not at the top of the report:\n%s", markdown)
+			}
+
+			output, err := reports.RenderJSON(generator, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var report reports.JSONReport
+			if err := json.Unmarshal([]byte(output), &report); err != nil {
+				t.Fatal(err)
+			}
+			if report.Status == nil || *report.Status != tt.want {
+				t.Errorf("JSON status = %+v, want %+v", report.Status, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountMode(t *testing.T) {
+	// apt is also found in the added oauth2-proxy image, so image mode counts it twice.
+	comparison := goldenComparison("bitnami")
+	apt := comparison.UnchangedCVEs["CVE-2011-3374"]["docker.io/bitnami/redis"]
+	comparison.After.ContainsImages[2].Vulnerabilities[apt.ID] = apt
+	comparison.AddedCVEs["CVE-2011-3374"] = map[string]helmscanTypes.Vulnerability{"quay.io/oauth2-proxy/oauth2-proxy": apt}
+	tests := []struct {
+		countMode string
+		want      []reports.SeverityCount
+	}{
+		{
+			countMode: reports.CountModeImage,
+			want: []reports.SeverityCount{
+				{Severity: "critical", Current: 1, Previous: 1, Difference: 0},
+				{Severity: "high", Current: 1, Previous: 1, Difference: 0},
+				{Severity: "medium", Current: 2, Previous: 1, Difference: 1},
+				{Severity: "low", Current: 3, Previous: 1, Difference: 2},
+			},
+		},
+		{
+			countMode: reports.CountModeChart,
+			want: []reports.SeverityCount{
+				{Severity: "critical", Current: 1, Previous: 1, Difference: 0},
+				{Severity: "high", Current: 1, Previous: 1, Difference: 0},
+				{Severity: "medium", Current: 2, Previous: 1, Difference: 1},
+				{Severity: "low", Current: 2, Previous: 1, Difference: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.countMode, func(t *testing.T) {
+			output, err := reports.RenderJSON(helmscan.NewHelmReportGenerator(comparison), reports.ReportOptions{CountMode: tt.countMode})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var report reports.JSONReport
+			if err := json.Unmarshal([]byte(output), &report); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(report.Summary.SeverityCounts, tt.want) {
+				t.Errorf("severity_counts = %+v, want %+v", report.Summary.SeverityCounts, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderInlineDiff(t *testing.T) {
+	// Rows are sorted by severity, then added, removed and unchanged.
+	wantRows := [][2]string{
+		{"+", "CVE-2023-45853"},
+		{"-", "CVE-2022-48174"},
+		{"+", "CVE-2024-6387"},
+		{"-", "CVE-2023-44487"},
+		{"+", "CVE-2024-7347"},
+		{"", "CVE-2023-5678"},
+		{"+", "CVE-2023-50495"},
+		{"", "CVE-2011-3374"},
+	}
+	tests := []struct {
+		compareOutput string
+		wantInline    bool
+	}{
+		{compareOutput: ""},
+		{compareOutput: reports.CompareOutputSections},
+		{compareOutput: reports.CompareOutputInlineDiff, wantInline: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.compareOutput, func(t *testing.T) {
+			markdown := reports.RenderMarkdown(helmscan.NewHelmReportGenerator(goldenComparison("bitnami")), reports.ReportOptions{CompareOutput: tt.compareOutput})
+			_, inline, found := strings.Cut(markdown, "### CVE Changes\n\n")
+			if found != tt.wantInline {
+				t.Fatalf("report has a CVE Changes section: %v, want %v", found, tt.wantInline)
+			}
+			// The separate sections are replaced by the inline diff.
+			for _, section := range []string{"### Added CVEs", "### Removed CVEs", "### Unchanged CVEs"} {
+				if got := strings.Contains(markdown, section); got == tt.wantInline {
+					t.Errorf("report has %q: %v, want %v", section, got, !tt.wantInline)
+				}
+			}
+			if !tt.wantInline {
+				return
+			}
+
+			var rows [][2]string
+			for _, line := range strings.Split(inline, "\n")[2:] {
+				if !strings.HasPrefix(line, "|") {
+					break
+				}
+				cells := strings.Split(line, "|")
+				id, _, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(cells[2]), "["), "]")
+				rows = append(rows, [2]string{strings.TrimSpace(cells[1]), id})
+			}
+			if !reflect.DeepEqual(rows, wantRows) {
+				t.Errorf("inline diff rows = %q, want %q\n%s", rows, wantRows, inline)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package reports
+
+import (
+	"fmt"
+	"strings"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+// thresholdGenerator keeps only the added and removed CVEs of a comparison at or above minSeverity,
+// or with below set only those beneath it and no unchanged CVEs, the informational changes
+// --ignore-below lists apart from the rest of the report.
+type thresholdGenerator struct {
+	ReportGenerator
+	minSeverity string
+	below       bool
+}
+
+// withoutInformationalChanges wraps generator so CVEs added or removed below opts.IgnoreBelow
+// neither appear among the added and removed CVEs nor count toward the verdict.
+func withoutInformationalChanges(generator ReportGenerator, opts ReportOptions) ReportGenerator {
+	if opts.IgnoreBelow == "" {
+		return generator
+	}
+	if _, wrapped := generator.(thresholdGenerator); wrapped {
+		return generator
+	}
+	return thresholdGenerator{ReportGenerator: generator, minSeverity: opts.IgnoreBelow}
+}
+
+// informationalChanges returns the CVEs added or removed below opts.IgnoreBelow as inline diff rows.
+func informationalChanges(generator ReportGenerator, opts ReportOptions) []diffCVE {
+	return inlineDiffRows(thresholdGenerator{ReportGenerator: generator, minSeverity: opts.IgnoreBelow, below: true})
+}
+
+func (g thresholdGenerator) GetAddedCVEs() map[string]map[string]helmscanTypes.Vulnerability {
+	return g.filter(g.ReportGenerator.GetAddedCVEs())
+}
+
+func (g thresholdGenerator) GetRemovedCVEs() map[string]map[string]helmscanTypes.Vulnerability {
+	return g.filter(g.ReportGenerator.GetRemovedCVEs())
+}
+
+func (g thresholdGenerator) GetUnchangedCVEs() map[string]map[string]helmscanTypes.Vulnerability {
+	if g.below {
+		return nil
+	}
+	return g.ReportGenerator.GetUnchangedCVEs()
+}
+
+func (g thresholdGenerator) filter(cves map[string]map[string]helmscanTypes.Vulnerability) map[string]map[string]helmscanTypes.Vulnerability {
+	filtered := make(map[string]map[string]helmscanTypes.Vulnerability)
+	for id, imageVulns := range cves {
+		for image, vuln := range imageVulns {
+			if (SeverityValue(vuln.Severity) < SeverityValue(g.minSeverity)) != g.below {
+				continue
+			}
+			if filtered[id] == nil {
+				filtered[id] = make(map[string]helmscanTypes.Vulnerability)
+			}
+			filtered[id][image] = vuln
+		}
+	}
+	return filtered
+}
+
+// comparisonStatus is the verdict of a comparison. With opts.IgnoreBelow set, a comparison that
+// added or removed only CVEs below it passes as no significant change.
+func comparisonStatus(generator ReportGenerator, opts ReportOptions) ReportStatus {
+	status := NewComparisonStatus(generator, opts.statusSeverity())
+	if opts.IgnoreBelow != "" && status.Result == StatusPass && len(generator.GetAddedCVEs()) == 0 && len(generator.GetRemovedCVEs()) == 0 {
+		status.Message = fmt.Sprintf("✅ No significant change: no %s CVEs added or removed", strings.Join(severitiesAtOrAbove(opts.IgnoreBelow), "/"))
+	}
+	return status
+}
+
+func formatInformationalSection(rows []diffCVE, opts ReportOptions) string {
+	var sb strings.Builder
+	sb.WriteString("### Informational Changes\n\n")
+	sb.WriteString(fmt.Sprintf("CVEs below %s added (+) or removed (-), which do not count toward the verdict.\n\n", opts.IgnoreBelow))
+	if len(rows) == 0 {
+		sb.WriteString("No informational changes found.\n\n")
+		return sb.String()
+	}
+	sb.WriteString(collapseCVEs(formatInlineDiffSection(rows, opts), len(rows), "informational", opts))
+	return sb.String()
+}
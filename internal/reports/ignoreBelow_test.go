@@ -0,0 +1,137 @@
+package reports
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func TestIgnoreBelow(t *testing.T) {
+	const image = "docker.io/bitnami/redis"
+	var (
+		apt   = helmscanTypes.Vulnerability{ID: "CVE-2011-3374", Severity: "low"}
+		tar   = helmscanTypes.Vulnerability{ID: "CVE-2005-2541", Severity: "low"}
+		glibc = helmscanTypes.Vulnerability{ID: "CVE-2024-2961", Severity: "high"}
+		zlib  = helmscanTypes.Vulnerability{ID: "CVE-2023-45853", Severity: "critical"}
+	)
+	cves := func(vulns ...helmscanTypes.Vulnerability) map[string]map[string]helmscanTypes.Vulnerability {
+		m := make(map[string]map[string]helmscanTypes.Vulnerability)
+		for _, vuln := range vulns {
+			m[vuln.ID] = map[string]helmscanTypes.Vulnerability{image: vuln}
+		}
+		return m
+	}
+	ids := func(cves []CVE) []string {
+		var ids []string
+		for _, cve := range cves {
+			ids = append(ids, cve.ID)
+		}
+		slices.Sort(ids)
+		return ids
+	}
+	tests := []struct {
+		name                 string
+		baseline, current    map[string]map[string]helmscanTypes.Vulnerability
+		ignoreBelow          string
+		wantResult           string
+		wantMessage          string
+		wantAdded            []string
+		wantRemoved          []string
+		wantInfoAdded        []string
+		wantInfoRemoved      []string
+		wantInformationalMD  bool
+		wantInformationalRow string
+	}{
+		{
+			name:        "low changes without a threshold",
+			baseline:    cves(zlib, tar),
+			current:     cves(zlib, apt),
+			wantResult:  StatusFail,
+			wantMessage: "❌ 1 new low CVE introduced",
+			wantAdded:   []string{apt.ID},
+			wantRemoved: []string{tar.ID},
+		},
+		{
+			name:                 "only low changes",
+			baseline:             cves(zlib, tar),
+			current:              cves(zlib, apt),
+			ignoreBelow:          "medium",
+			wantResult:           StatusPass,
+			wantMessage:          "✅ No significant change: no critical/high/medium CVEs added or removed",
+			wantInfoAdded:        []string{apt.ID},
+			wantInfoRemoved:      []string{tar.ID},
+			wantInformationalMD:  true,
+			wantInformationalRow: apt.ID,
+		},
+		{
+			name:                "significant change",
+			baseline:            cves(zlib),
+			current:             cves(zlib, glibc, apt),
+			ignoreBelow:         "medium",
+			wantResult:          StatusFail,
+			wantMessage:         "❌ 1 new high CVE introduced",
+			wantAdded:           []string{glibc.ID},
+			wantInfoAdded:       []string{apt.ID},
+			wantInformationalMD: true,
+		},
+		{
+			name:                 "no informational changes",
+			baseline:             cves(zlib),
+			current:              cves(zlib),
+			ignoreBelow:          "low",
+			wantResult:           StatusPass,
+			wantMessage:          "✅ No significant change: no critical/high/medium/low CVEs added or removed",
+			wantInformationalMD:  true,
+			wantInformationalRow: "No informational changes found.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator := NewBaselineReportGenerator("bitnami/redis@18.1.0", tt.current, Baseline{CVEs: tt.baseline})
+			opts := ReportOptions{StatusSeverity: "low", IgnoreBelow: tt.ignoreBelow}
+
+			output, err := RenderJSON(generator, opts)
+			if err != nil {
+				t.Fatalf("RenderJSON() error = %v", err)
+			}
+			var report JSONReport
+			if err := json.Unmarshal([]byte(output), &report); err != nil {
+				t.Fatal(err)
+			}
+			if report.Status.Result != tt.wantResult || report.Status.Message != tt.wantMessage {
+				t.Errorf("Status = %+v, want %s %q", *report.Status, tt.wantResult, tt.wantMessage)
+			}
+			for _, check := range []struct {
+				name      string
+				got, want []string
+			}{
+				{"AddedCVEs", ids(report.AddedCVEs), tt.wantAdded},
+				{"RemovedCVEs", ids(report.RemovedCVEs), tt.wantRemoved},
+				{"InformationalAddedCVEs", ids(report.InformationalAddedCVEs), tt.wantInfoAdded},
+				{"InformationalRemovedCVEs", ids(report.InformationalRemovedCVEs), tt.wantInfoRemoved},
+			} {
+				if !slices.Equal(check.got, check.want) {
+					t.Errorf("%s = %v, want %v", check.name, check.got, check.want)
+				}
+			}
+
+			markdown := RenderMarkdown(generator, opts)
+			if !strings.Contains(markdown, tt.wantMessage) {
+				t.Errorf("markdown is missing the verdict %q:\n%s", tt.wantMessage, markdown)
+			}
+			section := ""
+			if _, after, found := strings.Cut(markdown, "### Informational Changes"); found {
+				section = after
+			}
+			if got := section != ""; got != tt.wantInformationalMD {
+				t.Errorf("markdown has an informational section: %v, want %v\n%s", got, tt.wantInformationalMD, markdown)
+			}
+			if !strings.Contains(section, tt.wantInformationalRow) {
+				t.Errorf("informational section is missing %q:\n%s", tt.wantInformationalRow, section)
+			}
+		})
+	}
+}
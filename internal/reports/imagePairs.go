@@ -0,0 +1,138 @@
+package reports
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+// ImagePairsReport combines the image comparisons of an --image-pairs run, opening with the net
+// change in CVEs across every pair.
+type ImagePairsReport struct {
+	Metadata  *Metadata        `json:"metadata,omitempty"`
+	NetChange []NetCVEChange   `json:"net_change"`
+	Pairs     []ImagePairEntry `json:"pairs"`
+}
+
+// NetCVEChange counts the CVEs of one severity that the pairs added and removed in total.
+type NetCVEChange struct {
+	Severity string `json:"severity"`
+	Added    int    `json:"added"`
+	Removed  int    `json:"removed"`
+	Net      int    `json:"net"`
+}
+
+// ImagePairEntry is one before and after image of the run. Error is set instead of the comparison
+// when either image could not be scanned.
+type ImagePairEntry struct {
+	Before     string          `json:"before"`
+	After      string          `json:"after"`
+	Added      SeveritySummary `json:"added"`
+	Removed    SeveritySummary `json:"removed"`
+	Status     *ReportStatus   `json:"status,omitempty"`
+	Comparison json.RawMessage `json:"comparison,omitempty"`
+	Error      string          `json:"error,omitempty"`
+
+	generator ReportGenerator
+}
+
+// NewImagePairEntry summarizes the comparison of before and after with the CVEs and verdict the
+// comparison report shows.
+func NewImagePairEntry(before, after string, generator ReportGenerator, opts ReportOptions) ImagePairEntry {
+	changes := withoutInformationalChanges(withDisplayedSeverities(generator, opts), opts)
+	status := comparisonStatus(changes, opts)
+	return ImagePairEntry{
+		Before:    before,
+		After:     after,
+		Added:     countCVEs(changes.GetAddedCVEs()),
+		Removed:   countCVEs(changes.GetRemovedCVEs()),
+		Status:    &status,
+		generator: generator,
+	}
+}
+
+func countCVEs(cves map[string]map[string]helmscanTypes.Vulnerability) SeveritySummary {
+	vulns := make(map[string]helmscanTypes.Vulnerability)
+	for _, vuln := range cvesBySeverity(cves) {
+		vulns[vuln.ID] = vuln
+	}
+	return CountVulnerabilities(vulns)
+}
+
+// GenerateImagePairsReport renders the summary of every pair followed by each pair's comparison
+// report, as JSON or markdown.
+func GenerateImagePairsReport(report ImagePairsReport, generateJSON bool, opts ReportOptions) (string, error) {
+	report.Metadata = opts.Metadata
+	report.NetChange = nil
+	for _, severity := range severitiesAtOrAbove("low") {
+		change := NetCVEChange{Severity: severity}
+		for _, pair := range report.Pairs {
+			change.Added += pair.Added.count(severity)
+			change.Removed += pair.Removed.count(severity)
+		}
+		change.Net = change.Added - change.Removed
+		report.NetChange = append(report.NetChange, change)
+	}
+
+	pairOptions := opts
+	pairOptions.Metadata = nil
+	if generateJSON {
+		for i, pair := range report.Pairs {
+			if pair.generator == nil {
+				continue
+			}
+			comparison, err := RenderJSON(pair.generator, pairOptions)
+			if err != nil {
+				return "", err
+			}
+			report.Pairs[i].Comparison = json.RawMessage(comparison)
+		}
+		jsonBytes, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("error generating JSON report: %w", err)
+		}
+		return string(jsonBytes), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# Image Pair Comparison Report\n\n")
+	sb.WriteString(formatMetadataSection(report.Metadata))
+
+	var netRows [][]string
+	for _, change := range report.NetChange {
+		netRows = append(netRows, []string{change.Severity, strconv.Itoa(change.Added), strconv.Itoa(change.Removed), fmt.Sprintf("%+d", change.Net)})
+	}
+	sb.WriteString(FormatSection("Net CVE Change", FormatMarkdownTable([]string{"Severity", "Added", "Removed", "Net"}, netRows)))
+
+	var pairRows [][]string
+	for _, pair := range report.Pairs {
+		if pair.Error != "" {
+			pairRows = append(pairRows, []string{pair.Before, pair.After, "-", "-", "Failed: " + pair.Error})
+			continue
+		}
+		pairRows = append(pairRows, []string{pair.Before, pair.After, describeSummary(pair.Added), describeSummary(pair.Removed), pair.Status.Message})
+	}
+	sb.WriteString(FormatSection("Pairs", FormatMarkdownTable([]string{"Before", "After", "Added", "Removed", "Verdict"}, pairRows)))
+
+	for _, pair := range report.Pairs {
+		if pair.generator != nil {
+			sb.WriteString(RenderMarkdown(pair.generator, pairOptions))
+		}
+	}
+	return sb.String(), nil
+}
+
+// describeSummary phrases a summary such as "1 critical and 2 high CVEs", or "none".
+func describeSummary(summary SeveritySummary) string {
+	counts := make(map[string]int)
+	for _, severity := range severitiesAtOrAbove("low") {
+		counts[severity] = summary.count(severity)
+	}
+	if description := describeSeverityCounts(counts, ""); description != "" {
+		return description
+	}
+	return "none"
+}
@@ -0,0 +1,97 @@
+package reports
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func TestGenerateImagePairsReport(t *testing.T) {
+	const image = "docker.io/bitnami/redis"
+	var (
+		apt   = helmscanTypes.Vulnerability{ID: "CVE-2011-3374", Severity: "low"}
+		glibc = helmscanTypes.Vulnerability{ID: "CVE-2024-2961", Severity: "high"}
+		zlib  = helmscanTypes.Vulnerability{ID: "CVE-2023-45853", Severity: "critical"}
+	)
+	cves := func(vulns ...helmscanTypes.Vulnerability) map[string]map[string]helmscanTypes.Vulnerability {
+		m := make(map[string]map[string]helmscanTypes.Vulnerability)
+		for _, vuln := range vulns {
+			m[vuln.ID] = map[string]helmscanTypes.Vulnerability{image: vuln}
+		}
+		return m
+	}
+	pair := func(before, after string, baseline, current map[string]map[string]helmscanTypes.Vulnerability) ImagePairEntry {
+		generator := NewBaselineReportGenerator(after, current, Baseline{Path: before, CVEs: baseline})
+		return NewImagePairEntry(before, after, generator, ReportOptions{})
+	}
+	failed := ImagePairEntry{Before: "nginx:1.25", After: "nginx:1.26", Error: "unable to find the specified image"}
+
+	tests := []struct {
+		name         string
+		pairs        []ImagePairEntry
+		wantNet      []NetCVEChange
+		wantMarkdown []string
+	}{
+		{
+			name: "net change across pairs",
+			pairs: []ImagePairEntry{
+				pair("redis:7.2.4", "redis:7.2.5", cves(zlib, apt), cves(apt)),
+				pair("redis:7.2.5", "redis:7.2.6", cves(apt), cves(glibc)),
+			},
+			wantNet: []NetCVEChange{
+				{Severity: "critical", Removed: 1, Net: -1},
+				{Severity: "high", Added: 1, Net: 1},
+				{Severity: "medium"},
+				{Severity: "low", Removed: 1, Net: -1},
+			},
+			wantMarkdown: []string{
+				"# Image Pair Comparison Report",
+				"| critical | 0 | 1 | -1 |",
+				"| high | 1 | 0 | +1 |",
+				"| redis:7.2.5 | redis:7.2.6 | 1 high CVE | 1 low CVE |",
+				"Baseline Comparison Report",
+			},
+		},
+		{
+			name:  "failed pair",
+			pairs: []ImagePairEntry{failed},
+			wantNet: []NetCVEChange{
+				{Severity: "critical"}, {Severity: "high"}, {Severity: "medium"}, {Severity: "low"},
+			},
+			wantMarkdown: []string{"| nginx:1.25 | nginx:1.26 | - | - | Failed: unable to find the specified image |"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := GenerateImagePairsReport(ImagePairsReport{Pairs: tt.pairs}, true, ReportOptions{})
+			if err != nil {
+				t.Fatalf("GenerateImagePairsReport() error = %v", err)
+			}
+			var report ImagePairsReport
+			if err := json.Unmarshal([]byte(output), &report); err != nil {
+				t.Fatalf("GenerateImagePairsReport() is not JSON: %v", err)
+			}
+			if !reflect.DeepEqual(report.NetChange, tt.wantNet) {
+				t.Errorf("NetChange = %+v, want %+v", report.NetChange, tt.wantNet)
+			}
+			for i, pair := range report.Pairs {
+				if hasComparison := pair.Comparison != nil; hasComparison != (pair.Error == "") {
+					t.Errorf("pair %d has a comparison: %v, error %q", i, hasComparison, pair.Error)
+				}
+			}
+
+			markdown, err := GenerateImagePairsReport(ImagePairsReport{Pairs: tt.pairs}, false, ReportOptions{})
+			if err != nil {
+				t.Fatalf("GenerateImagePairsReport() error = %v", err)
+			}
+			for _, want := range tt.wantMarkdown {
+				if !strings.Contains(markdown, want) {
+					t.Errorf("markdown report is missing %q:\n%s", want, markdown)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package reports
+
+import (
+	"sort"
+	"strings"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+const (
+	// CompareOutputSections lists added, removed and unchanged CVEs in sections of their own.
+	CompareOutputSections = "sections"
+	// CompareOutputInlineDiff lists every CVE of a comparison in one table marked like a diff.
+	CompareOutputInlineDiff = "inline-diff"
+)
+
+const (
+	changeAdded     = "+"
+	changeRemoved   = "-"
+	changeUnchanged = " "
+)
+
+// changeOrder sorts the rows of a severity added first, then removed, then unchanged.
+var changeOrder = map[string]int{changeAdded: 0, changeRemoved: 1, changeUnchanged: 2}
+
+type diffCVE struct {
+	SortableCVE
+	Change string
+}
+
+// inlineDiffRows merges the added, removed and unchanged CVEs of a comparison into one list, sorted by
+// severity and then by change.
+func inlineDiffRows(generator ReportGenerator) []diffCVE {
+	var rows []diffCVE
+	for _, section := range []struct {
+		change string
+		cves   map[string]map[string]helmscanTypes.Vulnerability
+	}{
+		{changeAdded, generator.GetAddedCVEs()},
+		{changeRemoved, generator.GetRemovedCVEs()},
+		{changeUnchanged, generator.GetUnchangedCVEs()},
+	} {
+		var sortedCVEs SortableCVEList
+		for cveID, imageVulns := range section.cves {
+			sortedCVEs = append(sortedCVEs, newSortableCVE(cveID, imageVulns))
+		}
+		sort.Sort(sortedCVEs)
+		for _, cve := range sortedCVEs {
+			rows = append(rows, diffCVE{SortableCVE: cve, Change: section.change})
+		}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if SeverityValue(rows[i].Severity) != SeverityValue(rows[j].Severity) {
+			return SeverityValue(rows[i].Severity) > SeverityValue(rows[j].Severity)
+		}
+		if changeOrder[rows[i].Change] != changeOrder[rows[j].Change] {
+			return changeOrder[rows[i].Change] < changeOrder[rows[j].Change]
+		}
+		return rows[i].ID < rows[j].ID
+	})
+	return rows
+}
+
+// formatInlineDiffSection renders every CVE of a comparison in a single table, each row marked +
+// when the CVE was added, - when it was removed and left blank when it is unchanged.
+func formatInlineDiffSection(rows []diffCVE, opts ReportOptions) string {
+	if len(rows) == 0 {
+		return "No CVEs found.\n\n"
+	}
+	rows, hidden := topPerSeverity(rows, opts.Top, func(cve diffCVE) (string, float64, string) {
+		return cve.Severity, cve.CVSS, cve.ID
+	})
+
+	var tableRows [][]string
+	for _, cve := range rows {
+		row := []string{
+			cve.Change,
+			formatCVEID(cve.ID, cve.KEV, cve.PrimaryURL),
+			cve.Severity,
+			formatFixedVersion(cve.FixedVersion),
+			strings.Join(cve.Images, ", "),
+		}
+		if opts.Explain {
+			row = append(row, formatExplanation(cve.Title, cve.Description))
+		}
+		tableRows = append(tableRows, row)
+	}
+	headers := []string{"±", "CVE ID", "Severity", "Fixed Version", "Affected Images"}
+	if opts.Explain {
+		headers = append(headers, "Description")
+	}
+
+	hiddenCount := 0
+	for _, count := range hidden {
+		hiddenCount += count
+	}
+	return FormatMarkdownTable(headers, tableRows) + formatHiddenCVEs(hiddenCount)
+}
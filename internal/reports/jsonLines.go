@@ -0,0 +1,71 @@
+package reports
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+// JSONLine is one vulnerability finding in --format=jsonl output.
+type JSONLine struct {
+	Chart            string   `json:"chart,omitempty"`
+	Image            string   `json:"image"`
+	ID               string   `json:"id"`
+	Severity         string   `json:"severity"`
+	PkgName          string   `json:"package,omitempty"`
+	InstalledVersion string   `json:"installed_version,omitempty"`
+	FixedVersion     string   `json:"fixed_version,omitempty"`
+	CVSS             float64  `json:"cvss,omitempty"`
+	EPSS             *float64 `json:"epss,omitempty"`
+	KEV              bool     `json:"kev,omitempty"`
+}
+
+// WriteJSONLines encodes each vulnerability of an image as its own line on w, so large results
+// are never marshalled as a single document. chart is empty for image scans.
+func WriteJSONLines(w io.Writer, chart string, image string, vulns []helmscanTypes.Vulnerability) error {
+	encoder := json.NewEncoder(w)
+	for _, vuln := range vulns {
+		err := encoder.Encode(JSONLine{
+			Chart:            chart,
+			Image:            image,
+			ID:               vuln.ID,
+			Severity:         vuln.Severity,
+			PkgName:          vuln.PkgName,
+			InstalledVersion: vuln.InstalledVersion,
+			FixedVersion:     vuln.FixedVersion,
+			CVSS:             vuln.CVSS,
+			EPSS:             vuln.EPSS,
+			KEV:              vuln.KEV,
+		})
+		if err != nil {
+			return fmt.Errorf("error writing JSON lines: %w", err)
+		}
+	}
+	return nil
+}
+
+// CreateReportFile creates the file a streamed report is written to, following the same rules
+// as WriteReport. It returns nil for --report-file=-, where the report only goes to stdout.
+func CreateReportFile(filename string, opts ReportOptions) (*os.File, error) {
+	var path string
+	switch opts.ReportFile {
+	case "":
+		var err error
+		if path, err = scanFilePath(opts.reportsDir(), filename); err != nil {
+			return nil, err
+		}
+	case "-":
+		return nil, nil
+	default:
+		path = opts.ReportFile
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("error creating report file: %w", err)
+	}
+	return file, nil
+}
@@ -0,0 +1,136 @@
+package reports
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func TestWriteJSONLines(t *testing.T) {
+	score := 0.94
+	vulns := []helmscanTypes.Vulnerability{
+		{ID: "CVE-2023-45853", Severity: "critical", PkgName: "zlib1g", InstalledVersion: "1:1.2.13.dfsg-1", CVSS: 9.8, EPSS: &score, KEV: true},
+		// Newlines and quotes in fields must not break a line.
+		{ID: "CVE-2024-6387", Severity: "high", PkgName: "openssh\n\"client\"", FixedVersion: "1:9.2p1-2+deb12u3"},
+	}
+	tests := []struct {
+		name  string
+		chart string
+		vulns []helmscanTypes.Vulnerability
+		want  []JSONLine
+	}{
+		{
+			name:  "chart scan",
+			chart: "bitnami/redis@18.1.0",
+			vulns: vulns,
+			want: []JSONLine{
+				{Chart: "bitnami/redis@18.1.0", Image: "docker.io/bitnami/redis:7.2.4", ID: "CVE-2023-45853", Severity: "critical", PkgName: "zlib1g", InstalledVersion: "1:1.2.13.dfsg-1", CVSS: 9.8, EPSS: &score, KEV: true},
+				{Chart: "bitnami/redis@18.1.0", Image: "docker.io/bitnami/redis:7.2.4", ID: "CVE-2024-6387", Severity: "high", PkgName: "openssh\n\"client\"", FixedVersion: "1:9.2p1-2+deb12u3"},
+			},
+		},
+		{
+			name:  "image scan",
+			vulns: vulns[:1],
+			want: []JSONLine{
+				{Image: "docker.io/bitnami/redis:7.2.4", ID: "CVE-2023-45853", Severity: "critical", PkgName: "zlib1g", InstalledVersion: "1:1.2.13.dfsg-1", CVSS: 9.8, EPSS: &score, KEV: true},
+			},
+		},
+		{name: "no vulnerabilities"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteJSONLines(&buf, tt.chart, "docker.io/bitnami/redis:7.2.4", tt.vulns); err != nil {
+				t.Fatalf("WriteJSONLines() error = %v", err)
+			}
+			var lines []string
+			if buf.Len() > 0 {
+				lines = strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+			}
+			if len(lines) != len(tt.want) {
+				t.Fatalf("WriteJSONLines() wrote %d lines, want %d:\n%s", len(lines), len(tt.want), buf.String())
+			}
+			for i, line := range lines {
+				var got JSONLine
+				if err := json.Unmarshal([]byte(line), &got); err != nil {
+					t.Fatalf("line %d is not valid JSON on its own: %v\n%s", i, err, line)
+				}
+				gotJSON, _ := json.Marshal(got)
+				wantJSON, _ := json.Marshal(tt.want[i])
+				if !bytes.Equal(gotJSON, wantJSON) {
+					t.Errorf("line %d = %s, want %s", i, gotJSON, wantJSON)
+				}
+				if tt.chart == "" && strings.Contains(line, `"chart"`) {
+					t.Errorf("line %d of an image scan has a chart: %s", i, line)
+				}
+			}
+		})
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestWriteJSONLinesWriteError(t *testing.T) {
+	vulns := []helmscanTypes.Vulnerability{{ID: "CVE-2023-45853", Severity: "critical"}}
+	err := WriteJSONLines(failingWriter{}, "", "docker.io/bitnami/redis:7.2.4", vulns)
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("WriteJSONLines() error = %v, want the write error", err)
+	}
+}
+
+func TestCreateReportFile(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		opts     ReportOptions
+		wantPath string
+		wantErr  bool
+	}{
+		{
+			name:     "reports directory",
+			opts:     ReportOptions{OutputDir: dir},
+			wantPath: filepath.Join(dir, ReportSubpath("helm_scan_redis.jsonl")),
+		},
+		{
+			name:     "report file",
+			opts:     ReportOptions{ReportFile: filepath.Join(dir, "findings.jsonl")},
+			wantPath: filepath.Join(dir, "findings.jsonl"),
+		},
+		{name: "stdout", opts: ReportOptions{ReportFile: "-"}},
+		{
+			name:    "missing directory",
+			opts:    ReportOptions{ReportFile: filepath.Join(dir, "missing", "findings.jsonl")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := CreateReportFile("helm_scan_redis.jsonl", tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateReportFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantPath == "" {
+				if file != nil {
+					file.Close()
+					t.Errorf("CreateReportFile() = %s, want no file", file.Name())
+				}
+				return
+			}
+			defer file.Close()
+			if file.Name() != tt.wantPath {
+				t.Errorf("CreateReportFile() = %s, want %s", file.Name(), tt.wantPath)
+			}
+			if _, err := os.Stat(tt.wantPath); err != nil {
+				t.Errorf("report file was not created: %v", err)
+			}
+		})
+	}
+}
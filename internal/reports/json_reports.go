@@ -1,17 +1,49 @@
 package reports
 
+import helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+
 type JSONReport struct {
-	ReportType    string      `json:"report_type"`
-	Comparison    interface{} `json:"comparison"`
-	Summary       Summary     `json:"summary"`
-	AddedCVEs     []CVE       `json:"added_cves"`
-	RemovedCVEs   []CVE       `json:"removed_cves"`
-	UnchangedCVEs []CVE       `json:"unchanged_cves"`
+	ReportType        string                           `json:"report_type"`
+	Metadata          *Metadata                        `json:"metadata,omitempty"`
+	Status            *ReportStatus                    `json:"status,omitempty"`
+	Comparison        interface{}                      `json:"comparison"`
+	Summary           Summary                          `json:"summary"`
+	RepositoryChanges []helmscanTypes.RepositoryChange `json:"repository_changes,omitempty"`
+	ImageChanges      []helmscanTypes.ImageChange      `json:"image_changes,omitempty"`
+	AddedCVEs         []CVE                            `json:"added_cves"`
+	RemovedCVEs       []CVE                            `json:"removed_cves"`
+	UnchangedCVEs     []CVE                            `json:"unchanged_cves"`
+	SkippedImages     []helmscanTypes.SkippedImage     `json:"skipped_images,omitempty"`
+	OperatingSystems  *OperatingSystems                `json:"operating_systems,omitempty"`
+	RawOutputs        map[string]string                `json:"raw_outputs,omitempty"`
+	Remediation       []Remediation                    `json:"remediation,omitempty"`
+
+	// InformationalAddedCVEs and InformationalRemovedCVEs are the changes below --ignore-below,
+	// left out of AddedCVEs and RemovedCVEs.
+	InformationalAddedCVEs   []CVE `json:"informational_added_cves,omitempty"`
+	InformationalRemovedCVEs []CVE `json:"informational_removed_cves,omitempty"`
+}
+
+// OperatingSystems holds the base OS of each compared image in the before and after artifacts.
+type OperatingSystems struct {
+	Before map[string]helmscanTypes.OperatingSystem `json:"before"`
+	After  map[string]helmscanTypes.OperatingSystem `json:"after"`
+}
+
+type Metadata struct {
+	ToolVersion      string `json:"tool_version"`
+	GeneratedAt      string `json:"generated_at"`
+	TrivyVersion     string `json:"trivy_version"`
+	TrivyDBUpdatedAt string `json:"trivy_db_updated_at,omitempty"`
+	Command          string `json:"command"`
 }
 
 type Summary struct {
 	SeverityCounts []SeverityCount `json:"severity_counts"`
+	RiskScore      *RiskScore      `json:"risk_score,omitempty"`
 	ImageChanges   []ImageChange   `json:"image_changes,omitempty"`
+	// ImagesAffectedBySeverity counts the current images with at least one CVE of each severity.
+	ImagesAffectedBySeverity map[string]int `json:"images_affected_by_severity"`
 }
 
 type SeverityCount struct {
@@ -31,7 +63,36 @@ type ImageChange struct {
 }
 
 type CVE struct {
-	ID             string   `json:"id"`
-	Severity       string   `json:"severity"`
-	AffectedImages []string `json:"affected_images,omitempty"`
+	ID                string             `json:"id"`
+	Severity          string             `json:"severity"`
+	CVSS              float64            `json:"cvss,omitempty"`
+	EPSS              *float64           `json:"epss,omitempty"`
+	KEV               bool               `json:"kev,omitempty"`
+	FixedVersion      string             `json:"fixed_version,omitempty"`
+	Title             string             `json:"title,omitempty"`
+	Description       string             `json:"description,omitempty"`
+	PrimaryURL        string             `json:"primary_url,omitempty"`
+	References        []string           `json:"references,omitempty"`
+	AffectedImages    []string           `json:"affected_images,omitempty"`
+	AffectedResources []AffectedResource `json:"affected_resources,omitempty"`
+}
+
+// AffectedResource is a workload container that runs an image affected by a CVE.
+type AffectedResource struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Container string `json:"container,omitempty"`
+	Image     string `json:"image"`
+}
+
+const SummarySchemaVersion = 1
+
+type ExitSummary struct {
+	SchemaVersion  int  `json:"schemaVersion"`
+	Critical       int  `json:"critical"`
+	High           int  `json:"high"`
+	Medium         int  `json:"medium"`
+	Low            int  `json:"low"`
+	Total          int  `json:"total"`
+	NewSinceBefore *int `json:"newSinceBefore,omitempty"`
 }
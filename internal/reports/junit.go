@@ -0,0 +1,85 @@
+package reports
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// GenerateJUnit renders the scan of name as JUnit XML for CI test reports. Each image is a test
+// suite with a failing test case per CVE at or above the verdict severity, named by its CVE ID,
+// or a single passing test case when it has none.
+func GenerateJUnit(name string, vulnsByImage map[string][]helmscanTypes.Vulnerability, opts ReportOptions) (string, error) {
+	minSeverity := opts.statusSeverity()
+	passed := "no " + strings.Join(severitiesAtOrAbove(minSeverity), "/") + " CVEs"
+	report := junitTestSuites{Name: name}
+	for _, image := range sortedKeys(vulnsByImage) {
+		suite := junitTestSuite{Name: image}
+		for _, vuln := range sortBySeverity(vulnsByImage[image]) {
+			if SeverityValue(vuln.Severity) < SeverityValue(minSeverity) {
+				continue
+			}
+			suite.Cases = append(suite.Cases, junitTestCase{Name: vuln.ID, ClassName: image, Failure: junitVulnerabilityFailure(vuln)})
+		}
+		suite.Failures = len(suite.Cases)
+		if suite.Failures == 0 {
+			suite.Cases = []junitTestCase{{Name: passed, ClassName: image}}
+		}
+		suite.Tests = len(suite.Cases)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Suites = append(report.Suites, suite)
+	}
+
+	output, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error generating JUnit report: %w", err)
+	}
+	return xml.Header + string(output), nil
+}
+
+func junitVulnerabilityFailure(vuln helmscanTypes.Vulnerability) *junitFailure {
+	message := strings.ToLower(vuln.Severity) + " severity"
+	if vuln.PkgName != "" {
+		message += " in " + strings.TrimSpace(vuln.PkgName+" "+vuln.InstalledVersion)
+	}
+	if vuln.FixedVersion != "" {
+		message += ", fixed in " + vuln.FixedVersion
+	}
+	var details []string
+	for _, detail := range []string{vuln.Title, vuln.PrimaryURL} {
+		if detail != "" {
+			details = append(details, detail)
+		}
+	}
+	return &junitFailure{Message: message, Type: strings.ToLower(vuln.Severity), Text: strings.Join(details, "\n")}
+}
@@ -0,0 +1,141 @@
+package reports
+
+import (
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func TestGenerateJUnit(t *testing.T) {
+	const redis, exporter = "docker.io/bitnami/redis:7.2.4", "docker.io/bitnami/redis-exporter:1.58.0"
+	vulnsByImage := map[string][]helmscanTypes.Vulnerability{
+		redis: {
+			{ID: "CVE-2011-3374", Severity: "LOW", PkgName: "apt", InstalledVersion: "2.6.1"},
+			{ID: "CVE-2024-2961", Severity: "HIGH", PkgName: "libc6", InstalledVersion: "2.36-9", FixedVersion: "2.36-9+deb12u7", Title: "glibc: out of bounds write in iconv", PrimaryURL: "https://avd.aquasec.com/nvd/cve-2024-2961"},
+			{ID: "CVE-2023-45853", Severity: "CRITICAL", PkgName: "zlib1g", InstalledVersion: "1:1.2.13.dfsg-1"},
+		},
+		exporter: {{ID: "CVE-2023-45288", Severity: "MEDIUM", PkgName: "golang.org/x/net", InstalledVersion: "v0.17.0", FixedVersion: "0.23.0"}},
+	}
+	type testCase struct {
+		name    string
+		failure string
+	}
+	tests := []struct {
+		name         string
+		opts         ReportOptions
+		wantTests    int
+		wantFailures int
+		wantCases    map[string][]testCase
+	}{
+		{
+			name:         "default threshold",
+			wantTests:    3,
+			wantFailures: 2,
+			wantCases: map[string][]testCase{
+				redis: {
+					{name: "CVE-2023-45853", failure: "critical severity in zlib1g 1:1.2.13.dfsg-1"},
+					{name: "CVE-2024-2961", failure: "high severity in libc6 2.36-9, fixed in 2.36-9+deb12u7"},
+				},
+				exporter: {{name: "no critical/high CVEs"}},
+			},
+		},
+		{
+			name:         "low threshold",
+			opts:         ReportOptions{StatusSeverity: "low"},
+			wantTests:    4,
+			wantFailures: 4,
+			wantCases: map[string][]testCase{
+				redis: {
+					{name: "CVE-2023-45853", failure: "critical severity in zlib1g 1:1.2.13.dfsg-1"},
+					{name: "CVE-2024-2961", failure: "high severity in libc6 2.36-9, fixed in 2.36-9+deb12u7"},
+					{name: "CVE-2011-3374", failure: "low severity in apt 2.6.1"},
+				},
+				exporter: {{name: "CVE-2023-45288", failure: "medium severity in golang.org/x/net v0.17.0, fixed in 0.23.0"}},
+			},
+		},
+		{
+			name:         "critical threshold",
+			opts:         ReportOptions{StatusSeverity: "critical"},
+			wantTests:    2,
+			wantFailures: 1,
+			wantCases: map[string][]testCase{
+				redis:    {{name: "CVE-2023-45853", failure: "critical severity in zlib1g 1:1.2.13.dfsg-1"}},
+				exporter: {{name: "no critical CVEs"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := GenerateJUnit("bitnami/redis@18.1.0", vulnsByImage, tt.opts)
+			if err != nil {
+				t.Fatalf("GenerateJUnit() error = %v", err)
+			}
+			if !strings.HasPrefix(output, xml.Header) {
+				t.Errorf("GenerateJUnit() does not start with the XML header:\n%s", output)
+			}
+			var report junitTestSuites
+			if err := xml.Unmarshal([]byte(output), &report); err != nil {
+				t.Fatalf("GenerateJUnit() is not well-formed XML: %v\n%s", err, output)
+			}
+			if report.Name != "bitnami/redis@18.1.0" || report.Tests != tt.wantTests || report.Failures != tt.wantFailures {
+				t.Errorf("testsuites = %s with %d tests and %d failures, want bitnami/redis@18.1.0 with %d and %d",
+					report.Name, report.Tests, report.Failures, tt.wantTests, tt.wantFailures)
+			}
+
+			cases := make(map[string][]testCase)
+			for _, suite := range report.Suites {
+				failures := 0
+				for _, c := range suite.Cases {
+					if c.ClassName != suite.Name {
+						t.Errorf("test case %s has classname %s, want %s", c.Name, c.ClassName, suite.Name)
+					}
+					got := testCase{name: c.Name}
+					if c.Failure != nil {
+						got.failure = c.Failure.Message
+						failures++
+					}
+					cases[suite.Name] = append(cases[suite.Name], got)
+				}
+				if suite.Tests != len(suite.Cases) || suite.Failures != failures {
+					t.Errorf("testsuite %s counts %d tests and %d failures, has %d and %d", suite.Name, suite.Tests, suite.Failures, len(suite.Cases), failures)
+				}
+			}
+			if !reflect.DeepEqual(cases, tt.wantCases) {
+				t.Errorf("test cases = %+v, want %+v", cases, tt.wantCases)
+			}
+		})
+	}
+}
+
+func TestJUnitVulnerabilityFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		vuln helmscanTypes.Vulnerability
+		want junitFailure
+	}{
+		{
+			name: "details",
+			vuln: helmscanTypes.Vulnerability{ID: "CVE-2024-2961", Severity: "HIGH", PkgName: "libc6", InstalledVersion: "2.36-9", FixedVersion: "2.36-9+deb12u7", Title: "glibc: out of bounds write in iconv", PrimaryURL: "https://avd.aquasec.com/nvd/cve-2024-2961"},
+			want: junitFailure{
+				Message: "high severity in libc6 2.36-9, fixed in 2.36-9+deb12u7",
+				Type:    "high",
+				Text:    "glibc: out of bounds write in iconv\nhttps://avd.aquasec.com/nvd/cve-2024-2961",
+			},
+		},
+		{
+			name: "no package",
+			vuln: helmscanTypes.Vulnerability{ID: "CVE-2024-2961", Severity: "medium"},
+			want: junitFailure{Message: "medium severity", Type: "medium"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := junitVulnerabilityFailure(tt.vuln); *got != tt.want {
+				t.Errorf("junitVulnerabilityFailure() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package reports
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+// ScanMetrics holds the values written by --metrics-file for one scanned chart or image.
+type ScanMetrics struct {
+	ArtifactType string
+	ArtifactRef  string
+	Counts       helmscanTypes.SeverityCounts
+	Images       int
+	Duration     time.Duration
+}
+
+var metricsLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// FormatMetrics renders metrics in the Prometheus text exposition format.
+func FormatMetrics(m ScanMetrics) string {
+	label := fmt.Sprintf(`%s="%s"`, m.ArtifactType, metricsLabelEscaper.Replace(m.ArtifactRef))
+
+	var sb strings.Builder
+	sb.WriteString("# HELP helmscan_vulnerabilities Vulnerabilities found by severity.\n")
+	sb.WriteString("# TYPE helmscan_vulnerabilities gauge\n")
+	for _, severity := range []struct {
+		name  string
+		count int
+	}{
+		{"critical", m.Counts.Critical},
+		{"high", m.Counts.High},
+		{"medium", m.Counts.Medium},
+		{"low", m.Counts.Low},
+	} {
+		sb.WriteString(fmt.Sprintf("helmscan_vulnerabilities{%s,severity=\"%s\"} %d\n", label, severity.name, severity.count))
+	}
+	sb.WriteString("# HELP helmscan_images Images scanned.\n")
+	sb.WriteString("# TYPE helmscan_images gauge\n")
+	sb.WriteString(fmt.Sprintf("helmscan_images{%s} %d\n", label, m.Images))
+	sb.WriteString("# HELP helmscan_scan_duration_seconds Time taken by the scan.\n")
+	sb.WriteString("# TYPE helmscan_scan_duration_seconds gauge\n")
+	sb.WriteString(fmt.Sprintf("helmscan_scan_duration_seconds{%s} %.3f\n", label, m.Duration.Seconds()))
+	return sb.String()
+}
+
+// WriteMetricsFile writes the metrics through a temporary file and a rename, so the node exporter
+// textfile collector never reads a partial file.
+func WriteMetricsFile(path string, m ScanMetrics) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("error creating metrics file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(FormatMetrics(m)); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing metrics file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing metrics file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("error writing metrics file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error writing metrics file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package reports
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+// metricsLine matches a sample in the Prometheus text exposition format.
+var metricsLine = regexp.MustCompile(`^helmscan_[a-z_]+\{(chart|image)="(?:[^"\\]|\\.)*"(,severity="[a-z]+")?\} [0-9]+(\.[0-9]+)?$`)
+
+func TestFormatMetrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		metrics ScanMetrics
+		want    []string
+	}{
+		{
+			name: "chart",
+			metrics: ScanMetrics{
+				ArtifactType: "chart",
+				ArtifactRef:  "bitnami/redis@18.1.0",
+				Counts:       helmscanTypes.SeverityCounts{Critical: 3, High: 2, Low: 1},
+				Images:       2,
+				Duration:     1500 * time.Millisecond,
+			},
+			want: []string{
+				`helmscan_vulnerabilities{chart="bitnami/redis@18.1.0",severity="critical"} 3`,
+				`helmscan_vulnerabilities{chart="bitnami/redis@18.1.0",severity="high"} 2`,
+				`helmscan_vulnerabilities{chart="bitnami/redis@18.1.0",severity="medium"} 0`,
+				`helmscan_vulnerabilities{chart="bitnami/redis@18.1.0",severity="low"} 1`,
+				`helmscan_images{chart="bitnami/redis@18.1.0"} 2`,
+				`helmscan_scan_duration_seconds{chart="bitnami/redis@18.1.0"} 1.500`,
+			},
+		},
+		{
+			name: "image",
+			metrics: ScanMetrics{
+				ArtifactType: "image",
+				ArtifactRef:  "docker.io/bitnami/redis:7.2.4",
+				Counts:       helmscanTypes.SeverityCounts{Medium: 4},
+				Images:       1,
+			},
+			want: []string{
+				`helmscan_vulnerabilities{image="docker.io/bitnami/redis:7.2.4",severity="medium"} 4`,
+				`helmscan_images{image="docker.io/bitnami/redis:7.2.4"} 1`,
+				`helmscan_scan_duration_seconds{image="docker.io/bitnami/redis:7.2.4"} 0.000`,
+			},
+		},
+		{
+			name:    "escaped label",
+			metrics: ScanMetrics{ArtifactType: "chart", ArtifactRef: "a\"b\\c\nd"},
+			want:    []string{`helmscan_images{chart="a\"b\\c\nd"} 0`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FormatMetrics(tt.metrics)
+			if !strings.HasSuffix(got, "\n") {
+				t.Errorf("FormatMetrics() does not end with a newline")
+			}
+			lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+			for _, line := range lines {
+				if strings.HasPrefix(line, "# HELP ") || strings.HasPrefix(line, "# TYPE ") {
+					continue
+				}
+				if !metricsLine.MatchString(line) {
+					t.Errorf("malformed metrics line %q", line)
+				}
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want+"\n") {
+					t.Errorf("FormatMetrics() is missing %q:\n%s", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteMetricsFile(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		path    string
+		images  int
+		wantErr bool
+	}{
+		{name: "new file", path: filepath.Join(dir, "helmscan.prom"), images: 1},
+		{name: "replaces file", path: filepath.Join(dir, "helmscan.prom"), images: 2},
+		{name: "missing directory", path: filepath.Join(dir, "missing", "helmscan.prom"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := ScanMetrics{ArtifactType: "chart", ArtifactRef: "bitnami/redis@18.1.0", Images: tt.images}
+			err := WriteMetricsFile(tt.path, metrics)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WriteMetricsFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			data, err := os.ReadFile(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != FormatMetrics(metrics) {
+				t.Errorf("%s =\n%s\nwant\n%s", tt.path, data, FormatMetrics(metrics))
+			}
+			info, err := os.Stat(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0644 {
+				t.Errorf("%s mode = %v, want 0644", tt.path, info.Mode().Perm())
+			}
+			entries, err := os.ReadDir(filepath.Dir(tt.path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("temporary files were left behind: %v", entries)
+			}
+		})
+	}
+}
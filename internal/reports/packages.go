@@ -0,0 +1,94 @@
+package reports
+
+import (
+	"sort"
+	"strings"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+const (
+	GroupByCVE     = "cve"
+	GroupByPackage = "package"
+)
+
+// PackageGroup is one vulnerable package version with every CVE found in it and every image
+// that ships it.
+type PackageGroup struct {
+	Package          string   `json:"package"`
+	InstalledVersion string   `json:"installed_version"`
+	Severity         string   `json:"severity"`
+	CVEs             []string `json:"cves"`
+	AffectedImages   []string `json:"affected_images"`
+}
+
+// GroupByPackages pivots per-image vulnerabilities into one entry per package and installed
+// version, ordered by the highest severity of the package's CVEs.
+func GroupByPackages(vulnsByImage map[string][]helmscanTypes.Vulnerability) []PackageGroup {
+	type key struct{ pkg, version string }
+	groups := make(map[key]*PackageGroup)
+	cves := make(map[key]map[string]bool)
+	images := make(map[key]map[string]bool)
+
+	for image, vulns := range vulnsByImage {
+		for _, vuln := range vulns {
+			k := key{vuln.PkgName, vuln.InstalledVersion}
+			group, exists := groups[k]
+			if !exists {
+				group = &PackageGroup{Package: vuln.PkgName, InstalledVersion: vuln.InstalledVersion}
+				groups[k] = group
+				cves[k] = make(map[string]bool)
+				images[k] = make(map[string]bool)
+			}
+			if SeverityValue(vuln.Severity) > SeverityValue(group.Severity) {
+				group.Severity = strings.ToLower(vuln.Severity)
+			}
+			cves[k][vuln.ID] = true
+			images[k][image] = true
+		}
+	}
+
+	result := make([]PackageGroup, 0, len(groups))
+	for k, group := range groups {
+		group.CVEs = sortedKeys(cves[k])
+		group.AffectedImages = sortedKeys(images[k])
+		result = append(result, *group)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if SeverityValue(result[i].Severity) != SeverityValue(result[j].Severity) {
+			return SeverityValue(result[i].Severity) > SeverityValue(result[j].Severity)
+		}
+		if result[i].Package != result[j].Package {
+			return result[i].Package < result[j].Package
+		}
+		return result[i].InstalledVersion < result[j].InstalledVersion
+	})
+	return result
+}
+
+func sortedKeys[V any](set map[string]V) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatPackageSection(groups []PackageGroup) string {
+	if len(groups) == 0 {
+		return FormatSection("Vulnerabilities by Package", "No vulnerabilities found.\n")
+	}
+	var rows [][]string
+	for _, group := range groups {
+		rows = append(rows, []string{
+			group.Package,
+			group.InstalledVersion,
+			group.Severity,
+			strings.Join(group.CVEs, ", "),
+			strings.Join(group.AffectedImages, ", "),
+		})
+	}
+	return FormatSection("Vulnerabilities by Package",
+		FormatMarkdownTable([]string{"Package", "Installed Version", "Severity", "CVEs", "Affected Images"}, rows))
+}
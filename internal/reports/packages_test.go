@@ -0,0 +1,103 @@
+package reports
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func TestGroupByPackages(t *testing.T) {
+	var (
+		zlib     = helmscanTypes.Vulnerability{ID: "CVE-2023-45853", Severity: "CRITICAL", PkgName: "zlib1g", InstalledVersion: "1:1.2.13.dfsg-1"}
+		openssl1 = helmscanTypes.Vulnerability{ID: "CVE-2023-5678", Severity: "medium", PkgName: "openssl", InstalledVersion: "3.0.11"}
+		openssl2 = helmscanTypes.Vulnerability{ID: "CVE-2024-0727", Severity: "high", PkgName: "openssl", InstalledVersion: "3.0.11"}
+		openssl3 = helmscanTypes.Vulnerability{ID: "CVE-2024-0727", Severity: "high", PkgName: "openssl", InstalledVersion: "3.0.13"}
+		apt      = helmscanTypes.Vulnerability{ID: "CVE-2011-3374", Severity: "low", PkgName: "apt", InstalledVersion: "2.6.1"}
+	)
+	tests := []struct {
+		name         string
+		vulnsByImage map[string][]helmscanTypes.Vulnerability
+		want         []PackageGroup
+	}{
+		{
+			// One package version shipped by two images is one entry listing both images and
+			// every CVE, at the highest severity of them.
+			name: "merged across images",
+			vulnsByImage: map[string][]helmscanTypes.Vulnerability{
+				"docker.io/bitnami/redis":  {openssl1, openssl2, apt},
+				"docker.io/bitnami/nginx":  {openssl1, zlib},
+				"docker.io/bitnami/valkey": {apt},
+			},
+			want: []PackageGroup{
+				{Package: "zlib1g", InstalledVersion: "1:1.2.13.dfsg-1", Severity: "critical", CVEs: []string{"CVE-2023-45853"}, AffectedImages: []string{"docker.io/bitnami/nginx"}},
+				{Package: "openssl", InstalledVersion: "3.0.11", Severity: "high", CVEs: []string{"CVE-2023-5678", "CVE-2024-0727"}, AffectedImages: []string{"docker.io/bitnami/nginx", "docker.io/bitnami/redis"}},
+				{Package: "apt", InstalledVersion: "2.6.1", Severity: "low", CVEs: []string{"CVE-2011-3374"}, AffectedImages: []string{"docker.io/bitnami/redis", "docker.io/bitnami/valkey"}},
+			},
+		},
+		{
+			name: "installed versions kept apart",
+			vulnsByImage: map[string][]helmscanTypes.Vulnerability{
+				"docker.io/bitnami/redis": {openssl2},
+				"docker.io/bitnami/nginx": {openssl3},
+			},
+			want: []PackageGroup{
+				{Package: "openssl", InstalledVersion: "3.0.11", Severity: "high", CVEs: []string{"CVE-2024-0727"}, AffectedImages: []string{"docker.io/bitnami/redis"}},
+				{Package: "openssl", InstalledVersion: "3.0.13", Severity: "high", CVEs: []string{"CVE-2024-0727"}, AffectedImages: []string{"docker.io/bitnami/nginx"}},
+			},
+		},
+		{
+			name:         "no vulnerabilities",
+			vulnsByImage: map[string][]helmscanTypes.Vulnerability{"docker.io/bitnami/redis": nil},
+			want:         []PackageGroup{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GroupByPackages(tt.vulnsByImage); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GroupByPackages() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatPackageSection(t *testing.T) {
+	groups := []PackageGroup{
+		{Package: "openssl", InstalledVersion: "3.0.11", Severity: "high", CVEs: []string{"CVE-2023-5678", "CVE-2024-0727"}, AffectedImages: []string{"docker.io/bitnami/nginx", "docker.io/bitnami/redis"}},
+	}
+	section := formatPackageSection(groups)
+	if want := "| openssl | 3.0.11 | high | CVE-2023-5678, CVE-2024-0727 | docker.io/bitnami/nginx, docker.io/bitnami/redis |"; !strings.Contains(section, want) {
+		t.Errorf("section is missing %q:\n%s", want, section)
+	}
+	if empty := formatPackageSection(nil); !strings.Contains(empty, "No vulnerabilities found.") {
+		t.Errorf("empty section = %q", empty)
+	}
+}
+
+func TestSortedKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{name: "empty set", got: sortedKeys(map[string]bool{}), want: []string{}},
+		{name: "set", got: sortedKeys(map[string]bool{"zlib1g": true, "apt": true, "libc6": false}), want: []string{"apt", "libc6", "zlib1g"}},
+		{
+			name: "images",
+			got: sortedKeys(map[string][]*helmscanTypes.ContainerImage{
+				"quay.io/oauth2-proxy/oauth2-proxy": nil,
+				"docker.io/bitnami/redis":           nil,
+				"docker.io/bitnami/nginx":           nil,
+			}),
+			want: []string{"docker.io/bitnami/nginx", "docker.io/bitnami/redis", "quay.io/oauth2-proxy/oauth2-proxy"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("sortedKeys() = %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package reports
+
+import (
+	"sort"
+	"strings"
+)
+
+// RegressionSummary is the compact payload sent to --notify-webhook when a comparison adds
+// vulnerabilities at or above the notification severity.
+type RegressionSummary struct {
+	ReportType string            `json:"report_type"`
+	Comparison map[string]string `json:"comparison"`
+	NewCVEs    map[string]int    `json:"new_cves_by_severity"`
+	NewCVEIDs  []string          `json:"new_cve_ids"`
+}
+
+// NewRegressionSummary returns the added CVEs of a comparison at or above minSeverity, and
+// whether there were any.
+func NewRegressionSummary(generator ReportGenerator, minSeverity string) (RegressionSummary, bool) {
+	summary := RegressionSummary{
+		ReportType: generator.GetTitle(),
+		Comparison: generator.GetComparison(),
+		NewCVEs:    make(map[string]int),
+	}
+
+	seen := make(map[string]bool)
+	for _, images := range generator.GetAddedCVEs() {
+		for _, vuln := range images {
+			if SeverityValue(vuln.Severity) < SeverityValue(minSeverity) || seen[vuln.ID] {
+				continue
+			}
+			seen[vuln.ID] = true
+			summary.NewCVEs[strings.ToLower(vuln.Severity)]++
+			summary.NewCVEIDs = append(summary.NewCVEIDs, vuln.ID)
+		}
+	}
+	sort.Strings(summary.NewCVEIDs)
+
+	return summary, len(summary.NewCVEIDs) > 0
+}
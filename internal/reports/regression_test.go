@@ -0,0 +1,77 @@
+package reports_test
+
+import (
+	"encoding/json"
+	"maps"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/cliffcolvin/helmscan/internal/helmscan"
+	"github.com/cliffcolvin/helmscan/internal/reports"
+)
+
+func TestNewRegressionSummary(t *testing.T) {
+	// goldenComparison adds a critical, a high, a medium and a low CVE.
+	generator := helmscan.NewHelmReportGenerator(goldenComparison("bitnami"))
+	tests := []struct {
+		minSeverity   string
+		wantRegressed bool
+		wantCounts    map[string]int
+		wantIDs       []string
+	}{
+		{
+			minSeverity:   "critical",
+			wantRegressed: true,
+			wantCounts:    map[string]int{"critical": 1},
+			wantIDs:       []string{"CVE-2023-45853"},
+		},
+		{
+			minSeverity:   "high",
+			wantRegressed: true,
+			wantCounts:    map[string]int{"critical": 1, "high": 1},
+			wantIDs:       []string{"CVE-2023-45853", "CVE-2024-6387"},
+		},
+		{
+			minSeverity:   "low",
+			wantRegressed: true,
+			wantCounts:    map[string]int{"critical": 1, "high": 1, "medium": 1, "low": 1},
+			wantIDs:       []string{"CVE-2023-45853", "CVE-2023-50495", "CVE-2024-6387", "CVE-2024-7347"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.minSeverity, func(t *testing.T) {
+			summary, regressed := reports.NewRegressionSummary(generator, tt.minSeverity)
+			if regressed != tt.wantRegressed {
+				t.Errorf("regressed = %v, want %v", regressed, tt.wantRegressed)
+			}
+			if !maps.Equal(summary.NewCVEs, tt.wantCounts) {
+				t.Errorf("NewCVEs = %v, want %v", summary.NewCVEs, tt.wantCounts)
+			}
+			if !slices.Equal(summary.NewCVEIDs, tt.wantIDs) {
+				t.Errorf("NewCVEIDs = %v, want %v", summary.NewCVEIDs, tt.wantIDs)
+			}
+
+			// The webhook payload has exactly these fields.
+			data, err := json.Marshal(summary)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var payload map[string]any
+			if err := json.Unmarshal(data, &payload); err != nil {
+				t.Fatal(err)
+			}
+			if keys := slices.Sorted(maps.Keys(payload)); !reflect.DeepEqual(keys, []string{"comparison", "new_cve_ids", "new_cves_by_severity", "report_type"}) {
+				t.Errorf("payload fields = %v", keys)
+			}
+		})
+	}
+}
+
+func TestNewRegressionSummaryWithoutAddedCVEs(t *testing.T) {
+	comparison := goldenComparison("bitnami")
+	comparison.AddedCVEs = nil
+	if summary, regressed := reports.NewRegressionSummary(helmscan.NewHelmReportGenerator(comparison), "low"); regressed || len(summary.NewCVEIDs) != 0 {
+		t.Errorf("NewRegressionSummary() = %+v, %v, want no regression", summary, regressed)
+	}
+}
@@ -0,0 +1,211 @@
+package reports
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+// Remediation recommends the upgrade of one vulnerable package of an image: the smallest fixed
+// version that clears every fixable CVE of the package's highest severity. Clears counts every
+// CVE that version fixes, and FixedVersions lists the package's fixable CVEs by the version that
+// first fixes them.
+type Remediation struct {
+	Image              string              `json:"image"`
+	Package            string              `json:"package"`
+	InstalledVersion   string              `json:"installed_version"`
+	RecommendedVersion string              `json:"recommended_version"`
+	Severity           string              `json:"severity"`
+	Clears             SeveritySummary     `json:"clears"`
+	FixedVersions      []FixedVersionGroup `json:"fixed_versions"`
+}
+
+// FixedVersionGroup is the CVEs of a package first fixed in Version, with the highest severity
+// among them.
+type FixedVersionGroup struct {
+	Version  string   `json:"version"`
+	Severity string   `json:"severity"`
+	CVEs     []string `json:"cves"`
+}
+
+// NewRemediationPlan recommends an upgrade for each package with fixable CVEs in each image,
+// ordered by the severity the upgrade addresses.
+func NewRemediationPlan(vulnsByImage map[string][]helmscanTypes.Vulnerability) []Remediation {
+	type key struct{ image, pkg, version string }
+	fixes := make(map[key]map[string]helmscanTypes.Vulnerability)
+	for image, vulns := range vulnsByImage {
+		for _, vuln := range vulns {
+			if vuln.FixedVersion == "" {
+				continue
+			}
+			k := key{image, vuln.PkgName, vuln.InstalledVersion}
+			if fixes[k] == nil {
+				fixes[k] = make(map[string]helmscanTypes.Vulnerability)
+			}
+			fixes[k][vuln.ID] = vuln
+		}
+	}
+
+	var plan []Remediation
+	for k, vulns := range fixes {
+		plan = append(plan, newRemediation(k.image, k.pkg, k.version, vulns))
+	}
+	sort.Slice(plan, func(i, j int) bool {
+		if SeverityValue(plan[i].Severity) != SeverityValue(plan[j].Severity) {
+			return SeverityValue(plan[i].Severity) > SeverityValue(plan[j].Severity)
+		}
+		if plan[i].Image != plan[j].Image {
+			return plan[i].Image < plan[j].Image
+		}
+		return plan[i].Package < plan[j].Package
+	})
+	return plan
+}
+
+func newRemediation(image, pkg, installed string, vulns map[string]helmscanTypes.Vulnerability) Remediation {
+	remediation := Remediation{Image: image, Package: pkg, InstalledVersion: installed}
+	groups := make(map[string]*FixedVersionGroup)
+	fixedIn := make(map[string]string)
+	for _, id := range sortedKeys(vulns) {
+		vuln := vulns[id]
+		version := earliestFix(installed, vuln.FixedVersion)
+		fixedIn[id] = version
+		group, exists := groups[version]
+		if !exists {
+			group = &FixedVersionGroup{Version: version}
+			groups[version] = group
+		}
+		group.CVEs = append(group.CVEs, id)
+		if SeverityValue(vuln.Severity) > SeverityValue(group.Severity) {
+			group.Severity = strings.ToLower(vuln.Severity)
+		}
+		if SeverityValue(vuln.Severity) > SeverityValue(remediation.Severity) {
+			remediation.Severity = strings.ToLower(vuln.Severity)
+		}
+	}
+
+	for id, vuln := range vulns {
+		if SeverityValue(vuln.Severity) == SeverityValue(remediation.Severity) && compareVersions(fixedIn[id], remediation.RecommendedVersion) > 0 {
+			remediation.RecommendedVersion = fixedIn[id]
+		}
+	}
+	cleared := make(map[string]helmscanTypes.Vulnerability)
+	for id, vuln := range vulns {
+		if compareVersions(fixedIn[id], remediation.RecommendedVersion) <= 0 {
+			cleared[id] = vuln
+		}
+	}
+	remediation.Clears = CountVulnerabilities(cleared)
+
+	for _, group := range groups {
+		remediation.FixedVersions = append(remediation.FixedVersions, *group)
+	}
+	sort.Slice(remediation.FixedVersions, func(i, j int) bool {
+		return compareVersions(remediation.FixedVersions[i].Version, remediation.FixedVersions[j].Version) < 0
+	})
+	return remediation
+}
+
+// earliestFix picks the version to upgrade to from a fixed version such as "1.2.5, 1.3.1", which
+// Trivy lists for packages fixed on several release lines: the lowest one above installed.
+func earliestFix(installed, fixedVersion string) string {
+	var earliest string
+	for _, candidate := range strings.Split(fixedVersion, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "" || compareVersions(candidate, installed) <= 0 {
+			continue
+		}
+		if earliest == "" || compareVersions(candidate, earliest) < 0 {
+			earliest = candidate
+		}
+	}
+	if earliest == "" {
+		return strings.TrimSpace(fixedVersion)
+	}
+	return earliest
+}
+
+// compareVersions orders package versions of any ecosystem by comparing their runs of digits
+// numerically and everything else lexically, so 1.10.0 sorts after 1.9.2 and 3.0.2-r1 after
+// 3.0.2-r0. It returns -1, 0 or 1.
+func compareVersions(a, b string) int {
+	for a != "" && b != "" {
+		var partA, partB string
+		partA, a = nextVersionPart(a)
+		partB, b = nextVersionPart(b)
+		numA, errA := strconv.ParseUint(partA, 10, 64)
+		numB, errB := strconv.ParseUint(partB, 10, 64)
+		switch {
+		case errA == nil && errB == nil && numA != numB:
+			if numA < numB {
+				return -1
+			}
+			return 1
+		case (errA != nil || errB != nil) && partA != partB:
+			if partA < partB {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case a == "" && b == "":
+		return 0
+	case a == "":
+		return -1
+	}
+	return 1
+}
+
+func nextVersionPart(version string) (string, string) {
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+	end := 1
+	for end < len(version) && isDigit(version[end]) == isDigit(version[0]) {
+		end++
+	}
+	return version[:end], version[end:]
+}
+
+func formatRemediationSection(plan []Remediation) string {
+	if len(plan) == 0 {
+		return FormatSection("Remediation Plan", "No fixable vulnerabilities found.\n")
+	}
+	var rows [][]string
+	for _, remediation := range plan {
+		var fixedVersions []string
+		for _, group := range remediation.FixedVersions {
+			fixedVersions = append(fixedVersions, fmt.Sprintf("%s (%s)", group.Version, strings.Join(group.CVEs, ", ")))
+		}
+		counts := make(map[string]int)
+		for _, severity := range severitiesAtOrAbove("low") {
+			counts[severity] = remediation.Clears.count(severity)
+		}
+		rows = append(rows, []string{
+			remediation.Image,
+			remediation.Package,
+			remediation.InstalledVersion,
+			remediation.RecommendedVersion,
+			describeSeverityCounts(counts, ""),
+			strings.Join(fixedVersions, "; "),
+		})
+	}
+	return FormatSection("Remediation Plan",
+		FormatMarkdownTable([]string{"Image", "Package", "Installed Version", "Upgrade To", "Clears", "Fixed Versions"}, rows))
+}
+
+// currentVulnerabilitiesByImage lists the CVEs the compared-to artifact still has, added or
+// unchanged, by image.
+func currentVulnerabilitiesByImage(generator ReportGenerator) map[string][]helmscanTypes.Vulnerability {
+	vulnsByImage := make(map[string][]helmscanTypes.Vulnerability)
+	for _, cves := range []map[string]map[string]helmscanTypes.Vulnerability{generator.GetAddedCVEs(), generator.GetUnchangedCVEs()} {
+		for _, images := range cves {
+			for image, vuln := range images {
+				vulnsByImage[image] = append(vulnsByImage[image], vuln)
+			}
+		}
+	}
+	return vulnsByImage
+}
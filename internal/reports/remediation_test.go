@@ -0,0 +1,163 @@
+package reports
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "1.2.3", b: "1.2.3", want: 0},
+		{a: "1.10.0", b: "1.9.2", want: 1},
+		{a: "1.9.2", b: "1.10.0", want: -1},
+		{a: "3.0.2-r1", b: "3.0.2-r0", want: 1},
+		{a: "3.0.2", b: "3.0.2-r0", want: -1},
+		{a: "1:2.36-9", b: "1:2.36-10", want: -1},
+		{a: "v0.23.0", b: "v0.17.0", want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			if got := compareVersions(tt.a, tt.b); got != tt.want {
+				t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEarliestFix(t *testing.T) {
+	tests := []struct {
+		name         string
+		installed    string
+		fixedVersion string
+		want         string
+	}{
+		{name: "single version", installed: "1.2.3", fixedVersion: "1.2.5", want: "1.2.5"},
+		{name: "lowest of several", installed: "1.2.3", fixedVersion: "1.3.1, 1.2.5", want: "1.2.5"},
+		{name: "skips older release lines", installed: "1.3.0", fixedVersion: "1.2.5, 1.3.1", want: "1.3.1"},
+		{name: "none above installed", installed: "2.0.0", fixedVersion: " 1.2.5 ", want: "1.2.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := earliestFix(tt.installed, tt.fixedVersion); got != tt.want {
+				t.Errorf("earliestFix(%q, %q) = %q, want %q", tt.installed, tt.fixedVersion, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRemediationPlan(t *testing.T) {
+	openssl := func(id, severity, fixed string) helmscanTypes.Vulnerability {
+		return helmscanTypes.Vulnerability{ID: id, PkgName: "libssl3", InstalledVersion: "3.0.2-r0", FixedVersion: fixed, Severity: severity}
+	}
+	tests := []struct {
+		name         string
+		vulnsByImage map[string][]helmscanTypes.Vulnerability
+		want         []Remediation
+	}{
+		{
+			// The critical CVE decides the upgrade; the high CVE fixed later is left for another one.
+			name: "staggered fixes",
+			vulnsByImage: map[string][]helmscanTypes.Vulnerability{
+				"docker.io/bitnami/redis:7.2.4": {
+					openssl("CVE-2024-0001", "HIGH", "3.0.2-r1"),
+					openssl("CVE-2024-0002", "CRITICAL", "3.0.3"),
+					openssl("CVE-2024-0003", "MEDIUM", "3.0.5"),
+					openssl("CVE-2024-0004", "HIGH", "3.0.1, 3.0.4"),
+					openssl("CVE-2024-0005", "CRITICAL", ""),
+				},
+			},
+			want: []Remediation{{
+				Image:              "docker.io/bitnami/redis:7.2.4",
+				Package:            "libssl3",
+				InstalledVersion:   "3.0.2-r0",
+				RecommendedVersion: "3.0.3",
+				Severity:           "critical",
+				Clears:             SeveritySummary{Critical: 1, High: 1},
+				FixedVersions: []FixedVersionGroup{
+					{Version: "3.0.2-r1", Severity: "high", CVEs: []string{"CVE-2024-0001"}},
+					{Version: "3.0.3", Severity: "critical", CVEs: []string{"CVE-2024-0002"}},
+					{Version: "3.0.4", Severity: "high", CVEs: []string{"CVE-2024-0004"}},
+					{Version: "3.0.5", Severity: "medium", CVEs: []string{"CVE-2024-0003"}},
+				},
+			}},
+		},
+		{
+			name: "ordered by severity",
+			vulnsByImage: map[string][]helmscanTypes.Vulnerability{
+				"docker.io/bitnami/nginx:1.25": {
+					{ID: "CVE-2023-45853", PkgName: "zlib1g", InstalledVersion: "1:1.2.13", FixedVersion: "1:1.3", Severity: "LOW"},
+				},
+				"docker.io/bitnami/redis:7.2.4": {
+					{ID: "CVE-2024-2961", PkgName: "libc6", InstalledVersion: "2.36-9", FixedVersion: "2.36-10", Severity: "HIGH"},
+				},
+			},
+			want: []Remediation{
+				{
+					Image: "docker.io/bitnami/redis:7.2.4", Package: "libc6", InstalledVersion: "2.36-9", RecommendedVersion: "2.36-10", Severity: "high",
+					Clears:        SeveritySummary{High: 1},
+					FixedVersions: []FixedVersionGroup{{Version: "2.36-10", Severity: "high", CVEs: []string{"CVE-2024-2961"}}},
+				},
+				{
+					Image: "docker.io/bitnami/nginx:1.25", Package: "zlib1g", InstalledVersion: "1:1.2.13", RecommendedVersion: "1:1.3", Severity: "low",
+					Clears:        SeveritySummary{Low: 1},
+					FixedVersions: []FixedVersionGroup{{Version: "1:1.3", Severity: "low", CVEs: []string{"CVE-2023-45853"}}},
+				},
+			},
+		},
+		{
+			name: "nothing fixable",
+			vulnsByImage: map[string][]helmscanTypes.Vulnerability{
+				"docker.io/bitnami/redis:7.2.4": {openssl("CVE-2024-0005", "CRITICAL", "")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewRemediationPlan(tt.vulnsByImage); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewRemediationPlan() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatRemediationSection(t *testing.T) {
+	tests := []struct {
+		name string
+		plan []Remediation
+		want []string
+	}{
+		{name: "empty", want: []string{"Remediation Plan", "No fixable vulnerabilities found."}},
+		{
+			name: "upgrade",
+			plan: []Remediation{{
+				Image: "docker.io/bitnami/redis:7.2.4", Package: "libssl3", InstalledVersion: "3.0.2-r0", RecommendedVersion: "3.0.3", Severity: "critical",
+				Clears: SeveritySummary{Critical: 1, High: 1},
+				FixedVersions: []FixedVersionGroup{
+					{Version: "3.0.2-r1", Severity: "high", CVEs: []string{"CVE-2024-0001"}},
+					{Version: "3.0.3", Severity: "critical", CVEs: []string{"CVE-2024-0002"}},
+				},
+			}},
+			want: []string{
+				"| Image | Package | Installed Version | Upgrade To | Clears | Fixed Versions |",
+				"| docker.io/bitnami/redis:7.2.4 | libssl3 | 3.0.2-r0 | 3.0.3 |",
+				"3.0.2-r1 (CVE-2024-0001); 3.0.3 (CVE-2024-0002)",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatRemediationSection(tt.plan)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("formatRemediationSection() is missing %q:\n%s", want, got)
+				}
+			}
+		})
+	}
+}
@@ -9,5 +9,53 @@ type ReportGenerator interface {
 	GetAddedCVEs() map[string]map[string]helmscanTypes.Vulnerability
 	GetRemovedCVEs() map[string]map[string]helmscanTypes.Vulnerability
 	GetUnchangedCVEs() map[string]map[string]helmscanTypes.Vulnerability
+	GetSkippedImages() []helmscanTypes.SkippedImage
+	GetRepositoryChanges() []helmscanTypes.RepositoryChange
+	GetImageChanges() []helmscanTypes.ImageChange
+	GetAffectedResources() (before, after map[string][]AffectedResource)
+	GetOperatingSystems() (before, after map[string]helmscanTypes.OperatingSystem)
+	GetRawOutputs() map[string]string
 	GetBaseFilename() string
+	GetReportKind() string
+}
+
+type ReportOptions struct {
+	Metadata        *Metadata
+	Scanners        string
+	ScanManifests   bool
+	GroupBy         string
+	ReportFile      string
+	IgnoreUnfixed   bool
+	HashedFilenames bool
+	RiskWeights     RiskWeights
+	CollapsibleCVEs bool
+	Top             int
+	OutputDir       string
+	// StatusSeverity is the lowest severity counted by the report verdict, DefaultStatusSeverity
+	// when empty.
+	StatusSeverity string
+	// CountMode is CountModeImage (the default when empty) or CountModeChart.
+	CountMode string
+	// Explain adds each CVE's description to reports.
+	Explain bool
+	// CompareOutput is CompareOutputSections (the default when empty) or CompareOutputInlineDiff.
+	CompareOutput string
+	// Remediation adds a plan of the package upgrades that fix each image's CVEs.
+	Remediation bool
+	// DisplaySeverity limits the CVEs and counts reports show to these severities, all when empty.
+	// It does not change which CVEs the --fail-on gate counts.
+	DisplaySeverity []string
+	// IgnoreBelow moves CVEs a comparison added or removed below this severity out of the added and
+	// removed CVEs and the verdict, into an informational section.
+	IgnoreBelow string
+}
+
+// DefaultReportsDir is where reports are saved unless ReportOptions.OutputDir is set.
+const DefaultReportsDir = "working-files/scans"
+
+func (opts ReportOptions) reportsDir() string {
+	if opts.OutputDir != "" {
+		return opts.OutputDir
+	}
+	return DefaultReportsDir
 }
@@ -1,6 +1,8 @@
 package reports
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -11,39 +13,148 @@ import (
 	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
 )
 
+const maxSafeFileNameLength = 100
+
+// CreateSafeFileName turns an artifact reference into a single path component of lowercase
+// letters, digits and single dashes. Separators, dots and anything else are replaced, so refs
+// such as "../../etc" cannot escape working-files. Long names are truncated and suffixed with a
+// hash of the whole input so that distinct refs keep distinct names.
 func CreateSafeFileName(input string) string {
-	replacer := strings.NewReplacer(
-		"/", "-",
-		":", "-",
-		".", "-",
-		"@", "-",
-		" ", "-",
-		"_", "-",
-	)
-	return replacer.Replace(input)
+	var sb strings.Builder
+	dash := true
+	for _, r := range strings.ToLower(input) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+			dash = false
+		} else if !dash {
+			sb.WriteByte('-')
+			dash = true
+		}
+	}
+
+	name := strings.TrimSuffix(sb.String(), "-")
+	if name == "" {
+		name = "unnamed"
+	}
+	if len(name) > maxSafeFileNameLength {
+		sum := sha256.Sum256([]byte(input))
+		name = strings.TrimSuffix(name[:maxSafeFileNameLength-9], "-") + "-" + hex.EncodeToString(sum[:4])
+	}
+	return name
 }
 
-func SaveToFile(report string, filename string) error {
-	if err := os.MkdirAll("working-files/scans", 0755); err != nil {
-		return fmt.Errorf("error creating working-files directory: %w", err)
+// ReportFilename returns the file name, without extension, of a report of kind (such as
+// "helm_scan") about ref: kind_<readable ref> by default, or kind_<hash> with HashedFilenames.
+func ReportFilename(kind string, ref string, opts ReportOptions) string {
+	if opts.HashedFilenames {
+		return kind + "_" + filenameHash(ref, opts)
 	}
+	return kind + "_" + CreateSafeFileName(ref)
+}
 
-	baseDir := strings.TrimSuffix(filename, filepath.Ext(filename))
-	scanDir := filepath.Join("working-files/scans", CreateSafeFileName(baseDir))
-	if err := os.MkdirAll(scanDir, 0755); err != nil {
-		return fmt.Errorf("error creating scan directory: %w", err)
+// GeneratorFilename is ReportFilename for comparison reports.
+func GeneratorFilename(generator ReportGenerator, opts ReportOptions) string {
+	if opts.HashedFilenames {
+		return generator.GetReportKind() + "_" + filenameHash(generator.GetBaseFilename(), opts)
 	}
+	return CreateSafeFileName(generator.GetBaseFilename())
+}
+
+// filenameHash is a short hash of the artifact refs and the options that change report content,
+// so re-running the same scan writes to the same path. Metadata, ReportFile, HashedFilenames and
+// OutputDir are left out: they do not change what a report says.
+func filenameHash(ref string, opts ReportOptions) string {
+	key := strings.Join([]string{
+		ref,
+		fmt.Sprint(opts.IgnoreUnfixed),
+		opts.Scanners,
+		fmt.Sprint(opts.ScanManifests),
+		opts.GroupBy,
+		fmt.Sprint(opts.RiskWeights),
+		fmt.Sprint(opts.CollapsibleCVEs),
+		fmt.Sprint(opts.Top),
+		opts.StatusSeverity,
+		opts.CountMode,
+		fmt.Sprint(opts.Explain),
+		opts.CompareOutput,
+		fmt.Sprint(opts.Remediation),
+		strings.Join(opts.DisplaySeverity, ","),
+		opts.IgnoreBelow,
+	}, "\x00")
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:4])
+}
 
-	filepath := filepath.Join(scanDir, filename)
-	err := os.WriteFile(filepath, []byte(report), 0644)
+// SaveToFile saves report as filename in its own directory under DefaultReportsDir.
+func SaveToFile(report string, filename string) error {
+	return saveToDir(report, DefaultReportsDir, filename)
+}
+
+func saveToDir(report string, dir string, filename string) error {
+	filepath, err := scanFilePath(dir, filename)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(filepath, []byte(report), 0644)
 	if err != nil {
 		return fmt.Errorf("error writing report to file: %w", err)
 	}
 
-	fmt.Printf("\nReport saved to: %s\n", filepath)
+	fmt.Fprintf(os.Stderr, "\nReport saved to: %s\n", filepath)
 	return nil
 }
 
+// scanFilePath returns the path of filename in its own directory under dir, creating the directory.
+func scanFilePath(dir string, filename string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("error creating reports directory: %w", err)
+	}
+
+	path := filepath.Join(dir, ReportSubpath(filename))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("error creating scan directory: %w", err)
+	}
+
+	return path, nil
+}
+
+// ReportSubpath returns the path WriteReport saves filename to, relative to the reports directory,
+// when opts.ReportFile is not set: its own directory named after the file.
+func ReportSubpath(filename string) string {
+	baseDir := strings.TrimSuffix(filename, filepath.Ext(filename))
+	return filepath.Join(CreateSafeFileName(baseDir), filepath.Base(filename))
+}
+
+// WriteReport saves report to opts.ReportFile when set, or under the reports directory as filename
+// otherwise. A ReportFile of "-" writes nothing, leaving the caller to print the report to stdout.
+func WriteReport(report string, filename string, opts ReportOptions) error {
+	switch opts.ReportFile {
+	case "":
+		return saveToDir(report, opts.reportsDir(), filename)
+	case "-":
+		return nil
+	}
+
+	if err := os.WriteFile(opts.ReportFile, []byte(report), 0644); err != nil {
+		return fmt.Errorf("error writing report to file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "\nReport saved to: %s\n", opts.ReportFile)
+	return nil
+}
+
+// tableCellEscaper escapes the characters that would end a markdown table cell or row early.
+var tableCellEscaper = strings.NewReplacer(`\`, `\\`, "|", `\|`, "\r\n", "<br>", "\n", "<br>", "\r", "<br>")
+
+// markdownURLEscaper escapes the characters that would end the URL of a markdown link early.
+var markdownURLEscaper = strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29")
+
+// escapeTableCell makes value safe to place in a markdown table cell, so a pipe, backslash or
+// newline in an image, package or CVE field cannot add columns or break the row.
+func escapeTableCell(value string) string {
+	return tableCellEscaper.Replace(value)
+}
+
 func FormatMarkdownTable(headers []string, rows [][]string) string {
 	var sb strings.Builder
 
@@ -52,7 +163,11 @@ func FormatMarkdownTable(headers []string, rows [][]string) string {
 	sb.WriteString("|" + strings.Repeat("---------|", len(headers)) + "\n")
 
 	for _, row := range rows {
-		sb.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = escapeTableCell(cell)
+		}
+		sb.WriteString("| " + strings.Join(cells, " | ") + " |\n")
 	}
 
 	return sb.String()
@@ -73,14 +188,274 @@ func SeverityValue(severity string) int {
 	}
 }
 
+func formatMetadataSection(metadata *Metadata) string {
+	if metadata == nil {
+		return ""
+	}
+	rows := [][]string{
+		{"HelmScan Version", metadata.ToolVersion},
+		{"Generated At", metadata.GeneratedAt},
+		{"Trivy Version", metadata.TrivyVersion},
+	}
+	if metadata.TrivyDBUpdatedAt != "" {
+		rows = append(rows, []string{"Trivy DB Updated At", metadata.TrivyDBUpdatedAt})
+	}
+	rows = append(rows, []string{"Command", fmt.Sprintf("`%s`", metadata.Command)})
+	return FormatSection("Report Metadata", FormatMarkdownTable([]string{"Field", "Value"}, rows))
+}
+
+func formatImageSourcesSection(sources map[string][]helmscanTypes.SourceRef) string {
+	images := make([]string, 0, len(sources))
+	for image := range sources {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+
+	var rows [][]string
+	for _, image := range images {
+		for _, source := range sources[image] {
+			rows = append(rows, []string{image, source.Kind, source.Name, source.Container, source.Path})
+		}
+	}
+	return FormatSection("Image Sources", FormatMarkdownTable([]string{"Image", "Kind", "Name", "Container", "Path"}, rows))
+}
+
+func formatMirroredImagesSection(mirrored map[string]string) string {
+	images := make([]string, 0, len(mirrored))
+	for image := range mirrored {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+
+	rows := make([][]string, 0, len(images))
+	for _, image := range images {
+		rows = append(rows, []string{image, mirrored[image]})
+	}
+	return FormatSection("Registry Mirrors", "These images were scanned from a registry mirror.\n\n"+
+		FormatMarkdownTable([]string{"Image", "Scanned From"}, rows))
+}
+
+func formatSignaturesSection(signatures map[string]helmscanTypes.SignatureVerification) string {
+	images := make([]string, 0, len(signatures))
+	for image := range signatures {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+
+	rows := make([][]string, 0, len(images))
+	for _, image := range images {
+		status := "✅ Verified"
+		if !signatures[image].Verified {
+			status = "❌ Not verified"
+		}
+		rows = append(rows, []string{image, status, signatures[image].Error})
+	}
+	return FormatSection("Image Signatures", FormatMarkdownTable([]string{"Image", "Signature", "Details"}, rows))
+}
+
+func formatOperatingSystemsSection(systems map[string]helmscanTypes.OperatingSystem) string {
+	images := make([]string, 0, len(systems))
+	for image := range systems {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+
+	rows := make([][]string, 0, len(images))
+	for _, image := range images {
+		rows = append(rows, []string{image, systems[image].String()})
+	}
+	return FormatSection("Image Operating Systems", FormatMarkdownTable([]string{"Image", "OS"}, rows))
+}
+
+// formatOperatingSystemChangesSection lists the base OS of each compared image before and after,
+// which often explains CVEs appearing or disappearing together.
+func formatOperatingSystemChangesSection(before, after map[string]helmscanTypes.OperatingSystem) string {
+	seen := make(map[string]bool)
+	var images []string
+	for _, systems := range []map[string]helmscanTypes.OperatingSystem{before, after} {
+		for image := range systems {
+			if !seen[image] {
+				seen[image] = true
+				images = append(images, image)
+			}
+		}
+	}
+	sort.Strings(images)
+
+	osOrDash := func(systems map[string]helmscanTypes.OperatingSystem, image string) string {
+		if system, ok := systems[image]; ok {
+			return system.String()
+		}
+		return "-"
+	}
+	rows := make([][]string, 0, len(images))
+	for _, image := range images {
+		rows = append(rows, []string{image, osOrDash(before, image), osOrDash(after, image)})
+	}
+	return FormatSection("Image Operating Systems", FormatMarkdownTable([]string{"Image", "Before", "After"}, rows))
+}
+
+func formatRawOutputsSection(raw map[string]string) string {
+	images := make([]string, 0, len(raw))
+	for image := range raw {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+
+	rows := make([][]string, 0, len(images))
+	for _, image := range images {
+		rows = append(rows, []string{image, raw[image]})
+	}
+	return FormatSection("Raw Trivy Output", "The unprocessed Trivy JSON of each image was saved alongside this report.\n\n"+
+		FormatMarkdownTable([]string{"Image", "File"}, rows))
+}
+
+func formatManifestMisconfigurationsSection(misconfigs []helmscanTypes.Misconfiguration) string {
+	if len(misconfigs) == 0 {
+		return FormatSection("Manifest Misconfigurations", "No misconfigurations found in the rendered manifests.\n")
+	}
+
+	sorted := append([]helmscanTypes.Misconfiguration(nil), misconfigs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Target != sorted[j].Target {
+			return sorted[i].Target < sorted[j].Target
+		}
+		return SeverityValue(sorted[i].Severity) > SeverityValue(sorted[j].Severity)
+	})
+
+	var rows [][]string
+	for _, misconfig := range sorted {
+		rows = append(rows, []string{misconfig.Target, misconfig.ID, misconfig.Severity, misconfig.Title, misconfig.Message})
+	}
+	return FormatSection("Manifest Misconfigurations", FormatMarkdownTable([]string{"Manifest", "ID", "Severity", "Title", "Message"}, rows))
+}
+
+func formatSkippedImagesSection(skipped []helmscanTypes.SkippedImage) string {
+	if len(skipped) == 0 {
+		return ""
+	}
+	var rows [][]string
+	for _, image := range skipped {
+		rows = append(rows, []string{image.Reference, image.Reason})
+	}
+	return FormatSection("Skipped Images", "The following image references could not be scanned and were not assessed.\n\n"+
+		FormatMarkdownTable([]string{"Image", "Reason"}, rows))
+}
+
+func formatRepositoryChangesSection(changes []helmscanTypes.RepositoryChange) string {
+	if len(changes) == 0 {
+		return ""
+	}
+	var rows [][]string
+	for _, change := range changes {
+		rows = append(rows, []string{change.ImageName, change.BeforeRepository, change.AfterRepository})
+	}
+	return FormatSection("Repository Changes", "The following images are pulled from a different repository.\n\n"+
+		FormatMarkdownTable([]string{"Image", "Before Repository", "After Repository"}, rows))
+}
+
+func formatImageChangesSection(changes []helmscanTypes.ImageChange) string {
+	if len(changes) == 0 {
+		return ""
+	}
+	var rows [][]string
+	for _, change := range changes {
+		rows = append(rows, []string{change.ImageName, change.BeforeTag, change.AfterTag,
+			formatDigest(change.BeforeDigest), formatDigest(change.AfterDigest)})
+	}
+	return FormatSection("Image Changes", "The following images changed tag or digest.\n\n"+
+		FormatMarkdownTable([]string{"Image", "Before Tag", "After Tag", "Before Digest", "After Digest"}, rows))
+}
+
+func formatDigest(digest string) string {
+	if digest == "" {
+		return "-"
+	}
+	return digest
+}
+
+// formatCVEID links a CVE to its advisory and adds a KEV badge to CVEs in the CISA Known
+// Exploited Vulnerabilities catalog.
+func formatCVEID(id string, knownExploited bool, primaryURL string) string {
+	if primaryURL != "" {
+		id = fmt.Sprintf("[%s](%s)", id, markdownURLEscaper.Replace(primaryURL))
+	}
+	if knownExploited {
+		return id + " **[KEV]**"
+	}
+	return id
+}
+
+func formatEPSS(score *float64) string {
+	if score == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.3f", *score)
+}
+
+func formatFixedVersion(version string) string {
+	if version == "" {
+		return "-"
+	}
+	return version
+}
+
 func FormatSection(title string, content string) string {
 	return fmt.Sprintf("### %s\n\n%s\n", title, content)
 }
 
 type SortableCVE struct {
-	ID       string
-	Severity string
-	Images   []string
+	ID           string
+	Severity     string
+	CVSS         float64
+	EPSS         *float64
+	KEV          bool
+	FixedVersion string
+	Title        string
+	Description  string
+	PrimaryURL   string
+	References   []string
+	Images       []string
+}
+
+// newSortableCVE merges the per-image vulnerabilities of one CVE. Images and the distinct fixed
+// versions are sorted so reports are stable between runs.
+func newSortableCVE(id string, imageVulns map[string]helmscanTypes.Vulnerability) SortableCVE {
+	cve := SortableCVE{ID: id}
+	fixedVersions := make(map[string]bool)
+	for imageName, vuln := range imageVulns {
+		cve.Images = append(cve.Images, imageName)
+		// Images can disagree on severity; report the highest so the row lands in a stable group.
+		if cve.Severity == "" || SeverityValue(vuln.GetSeverity()) > SeverityValue(cve.Severity) {
+			cve.Severity = vuln.GetSeverity()
+		}
+		cve.CVSS = max(cve.CVSS, vuln.CVSS)
+		cve.EPSS = vuln.EPSS
+		cve.KEV = cve.KEV || vuln.KEV
+		if vuln.FixedVersion != "" {
+			fixedVersions[vuln.FixedVersion] = true
+		}
+		cve.addDetails(vuln.Title, vuln.Description, vuln.PrimaryURL, vuln.References)
+	}
+	sort.Strings(cve.Images)
+	cve.FixedVersion = strings.Join(sortedKeys(fixedVersions), ", ")
+	return cve
+}
+
+// addDetails fills in the description and references of the CVE from the first image that has them.
+func (cve *SortableCVE) addDetails(title, description, primaryURL string, references []string) {
+	if cve.Title == "" {
+		cve.Title = title
+	}
+	if cve.Description == "" {
+		cve.Description = description
+	}
+	if cve.PrimaryURL == "" {
+		cve.PrimaryURL = primaryURL
+	}
+	if len(cve.References) == 0 {
+		cve.References = references
+	}
 }
 
 type SortableCVEList []SortableCVE
@@ -94,42 +469,63 @@ func (s SortableCVEList) Less(i, j int) bool {
 	return SeverityValue(s[i].Severity) > SeverityValue(s[j].Severity)
 }
 
-func ConvertToJSONCVEs(cves map[string]map[string]helmscanTypes.Vulnerability) []CVE {
+// ConvertToJSONCVEs sorts cves for JSON output. resources maps the image keys of cves to the
+// resources that run them and may be nil.
+func ConvertToJSONCVEs(cves map[string]map[string]helmscanTypes.Vulnerability, resources map[string][]AffectedResource) []CVE {
 	var jsonCVEs []CVE
 	var sortedCVEs SortableCVEList
 
 	for cveID, imageVulns := range cves {
-		var images []string
-		var severity string
-		for imageName, vuln := range imageVulns {
-			images = append(images, imageName)
-			severity = vuln.GetSeverity()
-		}
-		sortedCVEs = append(sortedCVEs, SortableCVE{
-			ID:       cveID,
-			Severity: severity,
-			Images:   images,
-		})
+		sortedCVEs = append(sortedCVEs, newSortableCVE(cveID, imageVulns))
 	}
 
 	sort.Sort(sortedCVEs)
 
 	for _, cve := range sortedCVEs {
-		jsonCVEs = append(jsonCVEs, CVE{
+		jsonCVE := CVE{
 			ID:             cve.ID,
 			Severity:       cve.Severity,
+			CVSS:           cve.CVSS,
+			EPSS:           cve.EPSS,
+			KEV:            cve.KEV,
+			FixedVersion:   cve.FixedVersion,
+			Title:          cve.Title,
+			Description:    cve.Description,
+			PrimaryURL:     cve.PrimaryURL,
+			References:     cve.References,
 			AffectedImages: cve.Images,
-		})
+		}
+		for _, image := range cve.Images {
+			jsonCVE.AffectedResources = append(jsonCVE.AffectedResources, resources[image]...)
+		}
+		jsonCVEs = append(jsonCVEs, jsonCVE)
 	}
 
 	return jsonCVEs
 }
 
 type SingleScanReport struct {
-	ArtifactType string
-	ArtifactRef  string
-	Summary      SeveritySummary
-	CVEs         []CVE
+	ArtifactType      string
+	ArtifactRef       string
+	Metadata          *Metadata     `json:"metadata,omitempty"`
+	Status            *ReportStatus `json:"status,omitempty"`
+	Summary           SeveritySummary
+	RiskScore         int
+	CVEs              []CVE
+	SkippedImages     []helmscanTypes.SkippedImage     `json:",omitempty"`
+	Secrets           []helmscanTypes.Secret           `json:",omitempty"`
+	Misconfigurations []helmscanTypes.Misconfiguration `json:",omitempty"`
+
+	ManifestMisconfigurations []helmscanTypes.Misconfiguration               `json:",omitempty"`
+	ImageSources              map[string][]helmscanTypes.SourceRef           `json:",omitempty"`
+	MirroredImages            map[string]string                              `json:",omitempty"`
+	Signatures                map[string]helmscanTypes.SignatureVerification `json:",omitempty"`
+	RawOutputs                map[string]string                              `json:",omitempty"`
+	OperatingSystems          map[string]helmscanTypes.OperatingSystem       `json:",omitempty"`
+	Packages                  []PackageGroup                                 `json:",omitempty"`
+	Remediation               []Remediation                                  `json:",omitempty"`
+	// NoImages is set on a chart scan that rendered no images, so found nothing to scan.
+	NoImages bool `json:",omitempty"`
 }
 
 type SeveritySummary struct {
@@ -139,21 +535,43 @@ type SeveritySummary struct {
 	Low      int
 }
 
-func GenerateSingleScanReport(artifactType string, artifactRef string, vulns map[string]helmscanTypes.Vulnerability, generateJSON bool, ignoreUnfixed bool) string {
-	report := SingleScanReport{
+func NewSingleScanReport(artifactType string, artifactRef string, vulns map[string]helmscanTypes.Vulnerability) SingleScanReport {
+	return SingleScanReport{
 		ArtifactType: artifactType,
 		ArtifactRef:  artifactRef,
-		Summary:      countVulnerabilities(vulns),
+		Summary:      CountVulnerabilities(vulns),
 		CVEs:         convertVulnerabilitiesToCVEs(vulns),
 	}
+}
+
+func GenerateSingleScanReport(report SingleScanReport, generateJSON bool, ignoreUnfixed bool, opts ReportOptions) string {
+	report.Metadata = opts.Metadata
+	report.RiskScore = opts.riskWeights().score(report.Summary)
+	status := newSingleScanStatus(report.Summary, opts.statusSeverity())
+	report.Status = &status
+	if !opts.Explain {
+		report.CVEs = withoutExplanations(report.CVEs)
+	}
 
 	if generateJSON {
 		return GenerateJSONSingleReport(report)
 	}
-	return GenerateMarkdownSingleReport(report, ignoreUnfixed)
+	return GenerateMarkdownSingleReport(report, ignoreUnfixed, opts)
+}
+
+func GenerateSingleScanSummary(vulns map[string]helmscanTypes.Vulnerability) string {
+	counts := CountVulnerabilities(vulns)
+	return marshalExitSummary(ExitSummary{
+		SchemaVersion: SummarySchemaVersion,
+		Critical:      counts.Critical,
+		High:          counts.High,
+		Medium:        counts.Medium,
+		Low:           counts.Low,
+		Total:         counts.Critical + counts.High + counts.Medium + counts.Low,
+	})
 }
 
-func countVulnerabilities(vulns map[string]helmscanTypes.Vulnerability) SeveritySummary {
+func CountVulnerabilities(vulns map[string]helmscanTypes.Vulnerability) SeveritySummary {
 	summary := SeveritySummary{}
 	for _, vuln := range vulns {
 		switch strings.ToLower(vuln.GetSeverity()) {
@@ -174,8 +592,15 @@ func convertVulnerabilitiesToCVEs(vulns map[string]helmscanTypes.Vulnerability)
 	var cves []CVE
 	for id, vuln := range vulns {
 		cves = append(cves, CVE{
-			ID:       id,
-			Severity: vuln.GetSeverity(),
+			ID:          id,
+			Severity:    vuln.GetSeverity(),
+			CVSS:        vuln.CVSS,
+			EPSS:        vuln.EPSS,
+			KEV:         vuln.KEV,
+			Title:       vuln.Title,
+			Description: vuln.Description,
+			PrimaryURL:  vuln.PrimaryURL,
+			References:  vuln.References,
 		})
 	}
 
@@ -197,11 +622,18 @@ func GenerateJSONSingleReport(report SingleScanReport) string {
 	return string(jsonBytes)
 }
 
-func GenerateMarkdownSingleReport(report SingleScanReport, ignoreUnfixed bool) string {
+func GenerateMarkdownSingleReport(report SingleScanReport, ignoreUnfixed bool, opts ReportOptions) string {
 	var sb strings.Builder
 
 	sb.WriteString(fmt.Sprintf("# %s Scan Report\n", strings.Title(report.ArtifactType)))
 	sb.WriteString(fmt.Sprintf("## Artifact: %s\n\n", report.ArtifactRef))
+	if report.Status != nil {
+		sb.WriteString(formatStatusBanner(*report.Status))
+	}
+	if report.NoImages {
+		sb.WriteString(fmt.Sprintf("No images were found in %s, so no images were scanned.\n\n", report.ArtifactRef))
+	}
+	sb.WriteString(formatMetadataSection(report.Metadata))
 
 	sb.WriteString("### Vulnerability Summary\n\n")
 	if ignoreUnfixed {
@@ -209,29 +641,144 @@ func GenerateMarkdownSingleReport(report SingleScanReport, ignoreUnfixed bool) s
 	}
 	sb.WriteString("| Severity | Count |\n")
 	sb.WriteString("|----------|-------|\n")
-	sb.WriteString(fmt.Sprintf("| Critical | %d |\n", report.Summary.Critical))
-	sb.WriteString(fmt.Sprintf("| High | %d |\n", report.Summary.High))
-	sb.WriteString(fmt.Sprintf("| Medium | %d |\n", report.Summary.Medium))
-	sb.WriteString(fmt.Sprintf("| Low | %d |\n\n", report.Summary.Low))
+	for _, severity := range []string{"critical", "high", "medium", "low"} {
+		if opts.DisplaysSeverity(severity) {
+			sb.WriteString(fmt.Sprintf("| %s | %d |\n", strings.Title(severity), report.Summary.count(severity)))
+		}
+	}
+	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("**Risk score:** %d\n\n", report.RiskScore))
+
+	if len(report.ImageSources) > 0 {
+		sb.WriteString(formatImageSourcesSection(report.ImageSources))
+	}
+
+	if len(report.MirroredImages) > 0 {
+		sb.WriteString(formatMirroredImagesSection(report.MirroredImages))
+	}
+
+	if len(report.Signatures) > 0 {
+		sb.WriteString(formatSignaturesSection(report.Signatures))
+	}
 
-	sb.WriteString("### Vulnerabilities\n\n")
+	if len(report.OperatingSystems) > 0 {
+		sb.WriteString(formatOperatingSystemsSection(report.OperatingSystems))
+	}
+
+	if len(report.RawOutputs) > 0 {
+		sb.WriteString(formatRawOutputsSection(report.RawOutputs))
+	}
+
+	if opts.GroupBy == GroupByPackage {
+		sb.WriteString(formatPackageSection(report.Packages))
+	} else {
+		sb.WriteString("### Vulnerabilities\n\n")
+		sb.WriteString(collapseCVEs(formatCVETables(report.CVEs, opts), len(report.CVEs), "", opts))
+	}
+
+	if opts.Remediation {
+		sb.WriteString("\n")
+		sb.WriteString(formatRemediationSection(report.Remediation))
+	}
+
+	if scannerEnabled(opts.Scanners, "secret") || len(report.Secrets) > 0 {
+		sb.WriteString("\n")
+		sb.WriteString(formatSecretsSection(report.Secrets))
+	}
+
+	if scannerEnabled(opts.Scanners, "misconfig") || len(report.Misconfigurations) > 0 {
+		sb.WriteString("\n")
+		sb.WriteString(formatMisconfigurationsSection(report.Misconfigurations))
+	}
+
+	if opts.ScanManifests {
+		sb.WriteString("\n")
+		sb.WriteString(formatManifestMisconfigurationsSection(report.ManifestMisconfigurations))
+	}
+
+	if len(report.SkippedImages) > 0 {
+		sb.WriteString("\n")
+		sb.WriteString(formatSkippedImagesSection(report.SkippedImages))
+	}
+
+	return sb.String()
+}
+
+func formatCVETables(cves []CVE, opts ReportOptions) string {
+	cves, hidden := topPerSeverity(cves, opts.Top, func(cve CVE) (string, float64, string) {
+		return cve.Severity, cve.CVSS, cve.ID
+	})
+
+	var sb strings.Builder
+	showEPSS := false
+	for _, cve := range cves {
+		showEPSS = showEPSS || cve.EPSS != nil
+	}
 	currentSeverity := ""
-	for _, cve := range report.CVEs {
+	for _, cve := range cves {
 		if cve.Severity != currentSeverity {
 			if currentSeverity != "" {
+				sb.WriteString(formatMoreCVEs(hidden, currentSeverity))
 				sb.WriteString("\n")
 			}
 			sb.WriteString(fmt.Sprintf("#### %s\n", strings.Title(cve.Severity)))
-			sb.WriteString("| CVE ID | Severity |\n")
-			sb.WriteString("|---------|----------|\n")
+			header, separator := "| CVE ID | Severity |", "|---------|----------|"
+			if showEPSS {
+				header, separator = header+" EPSS |", separator+"------|"
+			}
+			if opts.Explain {
+				header, separator = header+" Description |", separator+"-------------|"
+			}
+			sb.WriteString(header + "\n" + separator + "\n")
 			currentSeverity = cve.Severity
 		}
-		sb.WriteString(fmt.Sprintf("| %s | %s |\n", cve.ID, cve.Severity))
+		row := fmt.Sprintf("| %s | %s |", escapeTableCell(formatCVEID(cve.ID, cve.KEV, cve.PrimaryURL)), escapeTableCell(cve.Severity))
+		if showEPSS {
+			row += fmt.Sprintf(" %s |", formatEPSS(cve.EPSS))
+		}
+		if opts.Explain {
+			row += fmt.Sprintf(" %s |", escapeTableCell(formatExplanation(cve.Title, cve.Description)))
+		}
+		sb.WriteString(row + "\n")
 	}
-
+	sb.WriteString(formatMoreCVEs(hidden, currentSeverity))
 	return sb.String()
 }
 
+func scannerEnabled(scanners string, scanner string) bool {
+	if scanners == "" {
+		scanners = helmscanTypes.DefaultScanners
+	}
+	for _, enabled := range strings.Split(scanners, ",") {
+		if strings.TrimSpace(enabled) == scanner {
+			return true
+		}
+	}
+	return false
+}
+
+func formatSecretsSection(secrets []helmscanTypes.Secret) string {
+	if len(secrets) == 0 {
+		return FormatSection("Secrets", "No secrets found.\n")
+	}
+	var rows [][]string
+	for _, secret := range secrets {
+		rows = append(rows, []string{secret.Image, secret.Target, secret.RuleID, secret.Severity, secret.Title})
+	}
+	return FormatSection("Secrets", FormatMarkdownTable([]string{"Image", "Target", "Rule", "Severity", "Title"}, rows))
+}
+
+func formatMisconfigurationsSection(misconfigs []helmscanTypes.Misconfiguration) string {
+	if len(misconfigs) == 0 {
+		return FormatSection("Misconfigurations", "No misconfigurations found.\n")
+	}
+	var rows [][]string
+	for _, misconfig := range misconfigs {
+		rows = append(rows, []string{misconfig.Image, misconfig.Target, misconfig.ID, misconfig.Severity, misconfig.Title})
+	}
+	return FormatSection("Misconfigurations", FormatMarkdownTable([]string{"Image", "Target", "ID", "Severity", "Title"}, rows))
+}
+
 func GenerateMarkdownReport(comparison helmscanTypes.HelmComparison) string {
 	var sb strings.Builder
 
@@ -274,24 +821,28 @@ func GenerateMarkdownReport(comparison helmscanTypes.HelmComparison) string {
 
 	var imageRows []string
 
-	for name, images := range comparison.AddedImages {
+	for _, name := range sortedKeys(comparison.AddedImages) {
+		images := comparison.AddedImages[name]
 		imageRows = append(imageRows, fmt.Sprintf("| %s | Added | - | %s | - | %s |",
-			name, images[0].Repository, images[0].Tag))
+			escapeTableCell(name), escapeTableCell(images[0].Repository), escapeTableCell(images[0].Tag)))
 	}
 
-	for name, images := range comparison.RemovedImages {
+	for _, name := range sortedKeys(comparison.RemovedImages) {
+		images := comparison.RemovedImages[name]
 		imageRows = append(imageRows, fmt.Sprintf("| %s | Removed | %s | - | %s | - |",
-			name, images[0].Repository, images[0].Tag))
+			escapeTableCell(name), escapeTableCell(images[0].Repository), escapeTableCell(images[0].Tag)))
 	}
 
-	for name, images := range comparison.ChangedImages {
+	for _, name := range sortedKeys(comparison.ChangedImages) {
+		images := comparison.ChangedImages[name]
 		imageRows = append(imageRows, fmt.Sprintf("| %s | Changed | %s | %s | %s | %s |",
-			name, images[0].Repository, images[1].Repository, images[0].Tag, images[1].Tag))
+			escapeTableCell(name), escapeTableCell(images[0].Repository), escapeTableCell(images[1].Repository), escapeTableCell(images[0].Tag), escapeTableCell(images[1].Tag)))
 	}
 
-	for name, images := range comparison.UnChangedImages {
+	for _, name := range sortedKeys(comparison.UnChangedImages) {
+		images := comparison.UnChangedImages[name]
 		imageRows = append(imageRows, fmt.Sprintf("| %s | Unchanged | %s | %s | %s | %s |",
-			name, images[0].Repository, images[1].Repository, images[0].Tag, images[1].Tag))
+			escapeTableCell(name), escapeTableCell(images[0].Repository), escapeTableCell(images[1].Repository), escapeTableCell(images[0].Tag), escapeTableCell(images[1].Tag)))
 	}
 
 	sb.WriteString(strings.Join(imageRows, "\n"))
@@ -320,12 +871,13 @@ func GenerateJSONReport(comparison helmscanTypes.HelmComparison) string {
 			"after_chart":  fmt.Sprintf("%s/%s@%s", comparison.After.HelmRepo, comparison.After.Name, comparison.After.Version),
 		},
 		Summary: Summary{
-			SeverityCounts: GenerateJSONSeverityCounts(comparison),
-			ImageChanges:   GenerateJSONImageChanges(comparison),
+			SeverityCounts:           GenerateJSONSeverityCounts(comparison),
+			ImageChanges:             GenerateJSONImageChanges(comparison),
+			ImagesAffectedBySeverity: imagesAffectedBySeverity(comparison.AddedCVEs, comparison.UnchangedCVEs),
 		},
-		AddedCVEs:     ConvertToJSONCVEs(comparison.AddedCVEs),
-		RemovedCVEs:   ConvertToJSONCVEs(comparison.RemovedCVEs),
-		UnchangedCVEs: ConvertToJSONCVEs(comparison.UnchangedCVEs),
+		AddedCVEs:     withoutExplanations(ConvertToJSONCVEs(comparison.AddedCVEs, nil)),
+		RemovedCVEs:   withoutExplanations(ConvertToJSONCVEs(comparison.RemovedCVEs, nil)),
+		UnchangedCVEs: withoutExplanations(ConvertToJSONCVEs(comparison.UnchangedCVEs, nil)),
 	}
 
 	jsonBytes, err := json.MarshalIndent(report, "", "  ")
@@ -342,17 +894,7 @@ func sortAndFormatCVEs(cves map[string]map[string]helmscanTypes.Vulnerability) s
 
 	var sortedCVEs SortableCVEList
 	for cveID, imageVulns := range cves {
-		var images []string
-		var severity string
-		for imageName, vuln := range imageVulns {
-			images = append(images, imageName)
-			severity = vuln.GetSeverity()
-		}
-		sortedCVEs = append(sortedCVEs, SortableCVE{
-			ID:       cveID,
-			Severity: severity,
-			Images:   images,
-		})
+		sortedCVEs = append(sortedCVEs, newSortableCVE(cveID, imageVulns))
 	}
 
 	sort.Sort(sortedCVEs)
@@ -363,7 +905,7 @@ func sortAndFormatCVEs(cves map[string]map[string]helmscanTypes.Vulnerability) s
 
 	currentSeverity := ""
 	for _, cve := range sortedCVEs {
-		if cve.Severity != currentSeverity {
+		if !strings.EqualFold(cve.Severity, currentSeverity) {
 			if currentSeverity != "" {
 				sb.WriteString("\n")
 			}
@@ -372,7 +914,7 @@ func sortAndFormatCVEs(cves map[string]map[string]helmscanTypes.Vulnerability) s
 			sb.WriteString("|--------|----------|------------------|\n")
 			currentSeverity = cve.Severity
 		}
-		sb.WriteString(fmt.Sprintf("| %s | %s | %s |\n", cve.ID, cve.Severity, strings.Join(cve.Images, ", ")))
+		sb.WriteString(fmt.Sprintf("| %s | %s | %s |\n", escapeTableCell(formatCVEID(cve.ID, false, cve.PrimaryURL)), escapeTableCell(cve.Severity), escapeTableCell(strings.Join(cve.Images, ", "))))
 	}
 	return sb.String()
 }
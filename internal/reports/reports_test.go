@@ -0,0 +1,806 @@
+package reports
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func TestGenerateSingleScanSummary(t *testing.T) {
+	tests := []struct {
+		name  string
+		vulns map[string]helmscanTypes.Vulnerability
+		want  map[string]int
+	}{
+		{
+			name: "every severity",
+			vulns: map[string]helmscanTypes.Vulnerability{
+				"redis:CVE-2023-45853": {ID: "CVE-2023-45853", Severity: "critical"},
+				"redis:CVE-2024-2961":  {ID: "CVE-2024-2961", Severity: "high"},
+				"nginx:CVE-2024-2961":  {ID: "CVE-2024-2961", Severity: "high"},
+				"redis:CVE-2023-50495": {ID: "CVE-2023-50495", Severity: "MEDIUM"},
+				"redis:CVE-2011-3374":  {ID: "CVE-2011-3374", Severity: "low"},
+			},
+			want: map[string]int{"schemaVersion": SummarySchemaVersion, "critical": 1, "high": 2, "medium": 1, "low": 1, "total": 5},
+		},
+		{
+			name:  "no vulnerabilities",
+			vulns: map[string]helmscanTypes.Vulnerability{},
+			want:  map[string]int{"schemaVersion": SummarySchemaVersion, "critical": 0, "high": 0, "medium": 0, "low": 0, "total": 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]int
+			if err := json.Unmarshal([]byte(GenerateSingleScanSummary(tt.vulns)), &got); err != nil {
+				t.Fatalf("summary is not a JSON object of counts: %v", err)
+			}
+			// Single scans have nothing to be new since, so newSinceBefore is left out.
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GenerateSingleScanSummary() = %v, want %v", got, tt.want)
+			}
+
+			var full SingleScanReport
+			if err := json.Unmarshal([]byte(GenerateJSONSingleReport(NewSingleScanReport("helm", "bitnami/redis@18.1.0", tt.vulns))), &full); err != nil {
+				t.Fatal(err)
+			}
+			fromReport := map[string]int{"critical": full.Summary.Critical, "high": full.Summary.High, "medium": full.Summary.Medium, "low": full.Summary.Low}
+			for severity, count := range fromReport {
+				if got[severity] != count {
+					t.Errorf("summary %s = %d, want %d as in the full report", severity, got[severity], count)
+				}
+			}
+		})
+	}
+}
+
+func TestSingleScanReportFindingSections(t *testing.T) {
+	secret := helmscanTypes.Secret{Image: "docker.io/bitnami/redis:7.2.4", Target: "/opt/bitnami/redis/etc/redis-default.conf", RuleID: "private-key", Severity: "high", Title: "Asymmetric Private Key"}
+	misconfig := helmscanTypes.Misconfiguration{Image: "docker.io/bitnami/redis:7.2.4", Target: "Dockerfile", ID: "DS002", Severity: "high", Title: "Image user should not be 'root'"}
+
+	tests := []struct {
+		name           string
+		scanners       string
+		secrets        []helmscanTypes.Secret
+		misconfigs     []helmscanTypes.Misconfiguration
+		wantSecrets    string
+		wantMisconfigs string
+	}{
+		{name: "default scanners", wantSecrets: "No secrets found.", wantMisconfigs: "No misconfigurations found."},
+		{name: "vulnerabilities only", scanners: "vuln"},
+		{name: "secrets enabled", scanners: "vuln,secret", wantSecrets: "No secrets found."},
+		{name: "misconfigurations enabled", scanners: "vuln, misconfig", wantMisconfigs: "No misconfigurations found."},
+		{
+			name:           "findings",
+			scanners:       "vuln,secret,misconfig",
+			secrets:        []helmscanTypes.Secret{secret},
+			misconfigs:     []helmscanTypes.Misconfiguration{misconfig},
+			wantSecrets:    "| docker.io/bitnami/redis:7.2.4 | /opt/bitnami/redis/etc/redis-default.conf | private-key | high | Asymmetric Private Key |",
+			wantMisconfigs: "| docker.io/bitnami/redis:7.2.4 | Dockerfile | DS002 | high |",
+		},
+		{
+			// Findings are never hidden, even from results scanned with other scanners.
+			name:           "findings of disabled scanners",
+			scanners:       "vuln",
+			secrets:        []helmscanTypes.Secret{secret},
+			misconfigs:     []helmscanTypes.Misconfiguration{misconfig},
+			wantSecrets:    "| private-key | high |",
+			wantMisconfigs: "| DS002 | high |",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := NewSingleScanReport("image", "docker.io/bitnami/redis:7.2.4", map[string]helmscanTypes.Vulnerability{})
+			report.Secrets = tt.secrets
+			report.Misconfigurations = tt.misconfigs
+			markdown := GenerateMarkdownSingleReport(report, false, ReportOptions{Scanners: tt.scanners})
+
+			sections := []struct{ heading, want string }{
+				{"### Secrets", tt.wantSecrets},
+				{"### Misconfigurations", tt.wantMisconfigs},
+			}
+			for _, section := range sections {
+				if has := strings.Contains(markdown, section.heading); has != (section.want != "") {
+					t.Errorf("report has %s section = %v, want %v", section.heading, has, !has)
+				}
+				if section.want != "" && !strings.Contains(markdown, section.want) {
+					t.Errorf("%s section is missing %q:\n%s", section.heading, section.want, markdown)
+				}
+			}
+		})
+	}
+}
+
+func TestSingleScanReportCollapsibleCVEs(t *testing.T) {
+	zlib := helmscanTypes.Vulnerability{ID: "CVE-2023-45853", Severity: "critical"}
+	apt := helmscanTypes.Vulnerability{ID: "CVE-2011-3374", Severity: "low"}
+
+	tests := []struct {
+		name        string
+		collapsible bool
+		vulns       map[string]helmscanTypes.Vulnerability
+		wantSummary string
+	}{
+		{name: "not collapsible", vulns: map[string]helmscanTypes.Vulnerability{zlib.ID: zlib, apt.ID: apt}},
+		{name: "one CVE", collapsible: true, vulns: map[string]helmscanTypes.Vulnerability{zlib.ID: zlib}, wantSummary: "<summary>1 CVE</summary>"},
+		{name: "several CVEs", collapsible: true, vulns: map[string]helmscanTypes.Vulnerability{zlib.ID: zlib, apt.ID: apt}, wantSummary: "<summary>2 CVEs</summary>"},
+		{name: "no CVEs", collapsible: true, vulns: map[string]helmscanTypes.Vulnerability{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := NewSingleScanReport("image", "docker.io/bitnami/redis:7.2.4", tt.vulns)
+			markdown := GenerateMarkdownSingleReport(report, false, ReportOptions{CollapsibleCVEs: tt.collapsible})
+
+			if has := strings.Contains(markdown, "<details>"); has != (tt.wantSummary != "") {
+				t.Fatalf("report has <details> = %v, want %v:\n%s", has, !has, markdown)
+			}
+			if tt.wantSummary == "" {
+				return
+			}
+			details := markdown[strings.Index(markdown, "<details>"):]
+			details = details[:strings.Index(details, "</details>")]
+			if !strings.HasPrefix(details, "<details>\n"+tt.wantSummary+"\n") {
+				t.Errorf("details block starts %q, want the summary %q", details, tt.wantSummary)
+			}
+			if !strings.Contains(details, "| "+zlib.ID+" |") {
+				t.Errorf("details block does not hold the CVE table:\n%s", details)
+			}
+			// The severity summary stays outside the collapsed block.
+			if strings.Contains(details, "\n### ") {
+				t.Errorf("details block holds a section heading:\n%s", details)
+			}
+		})
+	}
+}
+
+func TestSingleScanReportOperatingSystems(t *testing.T) {
+	tests := []struct {
+		name     string
+		systems  map[string]helmscanTypes.OperatingSystem
+		wantRows []string
+	}{
+		{name: "no OS detected"},
+		{
+			// Images are sorted, and an OS without a version shows only its family.
+			name: "OS detected",
+			systems: map[string]helmscanTypes.OperatingSystem{
+				"docker.io/bitnami/redis:7.2.4":   {Family: "debian", Name: "12.5"},
+				"docker.io/library/busybox:1.36":  {Family: "busybox"},
+				"docker.io/library/alpine:3.19.1": {Family: "alpine", Name: "3.19.1"},
+			},
+			wantRows: []string{
+				"| docker.io/bitnami/redis:7.2.4 | debian 12.5 |",
+				"| docker.io/library/alpine:3.19.1 | alpine 3.19.1 |",
+				"| docker.io/library/busybox:1.36 | busybox |",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := NewSingleScanReport("helm", "bitnami/redis@18.1.0", map[string]helmscanTypes.Vulnerability{})
+			report.OperatingSystems = tt.systems
+			markdown := GenerateMarkdownSingleReport(report, false, ReportOptions{})
+
+			if has, want := strings.Contains(markdown, "Image Operating Systems"), tt.wantRows != nil; has != want {
+				t.Fatalf("report has an Image Operating Systems section = %v, want %v", has, want)
+			}
+			last := -1
+			for _, row := range tt.wantRows {
+				i := strings.Index(markdown, row)
+				if i < 0 {
+					t.Fatalf("report is missing the row %q:\n%s", row, markdown)
+				}
+				if i < last {
+					t.Errorf("row %q is out of order:\n%s", row, markdown)
+				}
+				last = i
+			}
+		})
+	}
+}
+
+func TestGenerateMarkdownReportImageOrder(t *testing.T) {
+	img := func(name, tag string) *helmscanTypes.ContainerImage {
+		return &helmscanTypes.ContainerImage{Repository: "docker.io/bitnami", ImageName: name, Tag: tag}
+	}
+	group := func(names ...string) map[string][]*helmscanTypes.ContainerImage {
+		images := make(map[string][]*helmscanTypes.ContainerImage)
+		for _, name := range names {
+			images[name] = []*helmscanTypes.ContainerImage{img(name, "1.0.0"), img(name, "2.0.0")}
+		}
+		return images
+	}
+	tests := []struct {
+		name       string
+		comparison helmscanTypes.HelmComparison
+		want       []string
+	}{
+		{
+			name:       "added images",
+			comparison: helmscanTypes.HelmComparison{AddedImages: group("zeta", "alpha", "mid")},
+			want:       []string{"| alpha | Added |", "| mid | Added |", "| zeta | Added |"},
+		},
+		{
+			// Images are grouped by status, then ordered by name within each group.
+			name: "every status",
+			comparison: helmscanTypes.HelmComparison{
+				AddedImages:     group("oauth2-proxy", "exporter"),
+				RemovedImages:   group("busybox"),
+				ChangedImages:   group("redis", "nginx"),
+				UnChangedImages: group("os-shell", "kubectl"),
+			},
+			want: []string{
+				"| exporter | Added |", "| oauth2-proxy | Added |",
+				"| busybox | Removed |",
+				"| nginx | Changed |", "| redis | Changed |",
+				"| kubectl | Unchanged |", "| os-shell | Unchanged |",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := GenerateMarkdownReport(tt.comparison)
+			var rows []string
+			for _, line := range strings.Split(first, "\n") {
+				for _, want := range tt.want {
+					if strings.HasPrefix(line, want) {
+						rows = append(rows, want)
+					}
+				}
+			}
+			if !reflect.DeepEqual(rows, tt.want) {
+				t.Errorf("image rows = %q, want %q", rows, tt.want)
+			}
+			// Map iteration order changes between runs, so rendering again must give the same report.
+			for range 10 {
+				if again := GenerateMarkdownReport(tt.comparison); again != first {
+					t.Fatalf("GenerateMarkdownReport() is not deterministic:\n%s\n---\n%s", first, again)
+				}
+			}
+		})
+	}
+}
+
+func TestImagesAffectedBySeverity(t *testing.T) {
+	vuln := func(severity string) helmscanTypes.Vulnerability {
+		return helmscanTypes.Vulnerability{Severity: severity}
+	}
+	tests := []struct {
+		name    string
+		cveMaps []map[string]map[string]helmscanTypes.Vulnerability
+		want    map[string]int
+	}{
+		{name: "no CVEs", want: map[string]int{"critical": 0, "high": 0, "medium": 0, "low": 0}},
+		{
+			// The blast radius is one image, however many critical CVEs it has.
+			name: "one image has every critical CVE",
+			cveMaps: []map[string]map[string]helmscanTypes.Vulnerability{{
+				"CVE-2023-45853": {"docker.io/bitnami/redis": vuln("critical")},
+				"CVE-2024-6387":  {"docker.io/bitnami/redis": vuln("critical")},
+				"CVE-2011-3374":  {"docker.io/bitnami/redis": vuln("low"), "docker.io/bitnami/os-shell": vuln("low")},
+			}},
+			want: map[string]int{"critical": 1, "high": 0, "medium": 0, "low": 2},
+		},
+		{
+			name: "images counted once across maps",
+			cveMaps: []map[string]map[string]helmscanTypes.Vulnerability{
+				{"CVE-2023-45853": {"docker.io/bitnami/redis": vuln("CRITICAL")}},
+				{"CVE-2024-2961": {"docker.io/bitnami/redis": vuln("critical"), "docker.io/bitnami/nginx": vuln("high")}},
+			},
+			want: map[string]int{"critical": 1, "high": 1, "medium": 0, "low": 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := imagesAffectedBySeverity(tt.cveMaps...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("imagesAffectedBySeverity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscapeTableCell(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "docker.io/bitnami/redis", want: "docker.io/bitnami/redis"},
+		{value: "pkg|variant", want: `pkg\|variant`},
+		{value: `C:\images`, want: `C:\\images`},
+		// An escaped pipe in the value must not end up unescaped.
+		{value: `a\|b`, want: `a\\\|b`},
+		{value: "first\nsecond", want: "first<br>second"},
+		{value: "first\r\nsecond\rthird", want: "first<br>second<br>third"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := escapeTableCell(tt.value); got != tt.want {
+				t.Errorf("escapeTableCell(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// tableColumns returns the number of columns of a markdown table row, counting only the pipes
+// that are not escaped.
+func tableColumns(row string) int {
+	pipes := 0
+	for i := 0; i < len(row); i++ {
+		switch row[i] {
+		case '\\':
+			i++
+		case '|':
+			pipes++
+		}
+	}
+	return pipes - 1
+}
+
+func TestTablesWithPipes(t *testing.T) {
+	const image = "registry.example.com/team|a/app"
+	vuln := helmscanTypes.Vulnerability{ID: "CVE-2024-0001", Severity: "high", PkgName: "pkg|variant", FixedVersion: "1.0|2.0"}
+	cves := map[string]map[string]helmscanTypes.Vulnerability{vuln.ID: {image: vuln}}
+	img := &helmscanTypes.ContainerImage{Repository: "registry.example.com/team|a", ImageName: "app", Tag: "1.0|rc"}
+
+	tests := []struct {
+		name    string
+		output  string
+		row     string
+		columns int
+	}{
+		{
+			name:    "markdown table",
+			output:  FormatMarkdownTable([]string{"Image", "Package"}, [][]string{{image, "multi\nline"}}),
+			row:     "| registry.example.com",
+			columns: 2,
+		},
+		{name: "comparison CVE table", output: sortAndFormatCVEs(cves), row: "| CVE-2024-0001", columns: 3},
+		{
+			name:    "vulnerability section",
+			output:  formatVulnerabilitySection(cves, ReportOptions{}),
+			row:     "| CVE-2024-0001",
+			columns: 4,
+		},
+		{
+			name: "legacy comparison images",
+			output: GenerateMarkdownReport(helmscanTypes.HelmComparison{
+				ChangedImages: map[string][]*helmscanTypes.ContainerImage{image: {img, img}},
+			}),
+			row:     "| " + escapeTableCell(image),
+			columns: 6,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var found bool
+			for _, line := range strings.Split(tt.output, "\n") {
+				if !strings.HasPrefix(line, tt.row) {
+					continue
+				}
+				found = true
+				if got := tableColumns(line); got != tt.columns {
+					t.Errorf("row %q has %d columns, want %d", line, got, tt.columns)
+				}
+			}
+			if !found {
+				t.Fatalf("no row starting %q in:\n%s", tt.row, tt.output)
+			}
+		})
+	}
+}
+
+func TestFormatSignaturesSection(t *testing.T) {
+	tests := []struct {
+		name       string
+		signatures map[string]helmscanTypes.SignatureVerification
+		wantRows   []string
+	}{
+		{
+			name: "sorted by image",
+			signatures: map[string]helmscanTypes.SignatureVerification{
+				"docker.io/library/busybox:latest": {Error: "no signatures found"},
+				"docker.io/bitnami/redis:7.2.4":    {Verified: true},
+			},
+			wantRows: []string{
+				"| docker.io/bitnami/redis:7.2.4 | ✅ Verified |  |",
+				"| docker.io/library/busybox:latest | ❌ Not verified | no signatures found |",
+			},
+		},
+		{
+			name:       "error with a pipe",
+			signatures: map[string]helmscanTypes.SignatureVerification{"docker.io/bitnami/redis:7.2.4": {Error: "expected a|b"}},
+			wantRows:   []string{`| docker.io/bitnami/redis:7.2.4 | ❌ Not verified | expected a\|b |`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			section := formatSignaturesSection(tt.signatures)
+			if !strings.HasPrefix(section, "### Image Signatures\n") {
+				t.Errorf("section does not start with its heading:\n%s", section)
+			}
+			var rows []string
+			for _, line := range strings.Split(section, "\n") {
+				if strings.HasPrefix(line, "| docker.io/") {
+					rows = append(rows, line)
+				}
+			}
+			if !slices.Equal(rows, tt.wantRows) {
+				t.Errorf("rows = %q, want %q", rows, tt.wantRows)
+			}
+		})
+	}
+}
+
+func TestFormatCVEID(t *testing.T) {
+	tests := []struct {
+		name       string
+		kev        bool
+		primaryURL string
+		want       string
+	}{
+		{name: "plain", want: "CVE-2021-44228"},
+		{name: "known exploited", kev: true, want: "CVE-2021-44228 **[KEV]**"},
+		{
+			name:       "linked",
+			primaryURL: "https://avd.aquasec.com/nvd/cve-2021-44228",
+			want:       "[CVE-2021-44228](https://avd.aquasec.com/nvd/cve-2021-44228)",
+		},
+		{
+			name:       "URL with spaces and parentheses",
+			primaryURL: "https://example.com/advisories/log4j (2021)",
+			want:       "[CVE-2021-44228](https://example.com/advisories/log4j%20%282021%29)",
+		},
+		{
+			name:       "linked and known exploited",
+			kev:        true,
+			primaryURL: "https://avd.aquasec.com/nvd/cve-2021-44228",
+			want:       "[CVE-2021-44228](https://avd.aquasec.com/nvd/cve-2021-44228) **[KEV]**",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatCVEID("CVE-2021-44228", tt.kev, tt.primaryURL); got != tt.want {
+				t.Errorf("formatCVEID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCVEReferences(t *testing.T) {
+	const url = "https://avd.aquasec.com/nvd/cve-2021-44228"
+	references := []string{"https://nvd.nist.gov/vuln/detail/CVE-2021-44228", "https://logging.apache.org/log4j/2.x/security.html"}
+	tests := []struct {
+		name       string
+		vuln       helmscanTypes.Vulnerability
+		wantCell   string
+		wantInJSON []string
+	}{
+		{
+			name:       "with references",
+			vuln:       helmscanTypes.Vulnerability{ID: "CVE-2021-44228", Severity: "critical", PrimaryURL: url, References: references},
+			wantCell:   "| [CVE-2021-44228](" + url + ") |",
+			wantInJSON: references,
+		},
+		{
+			name:     "without references",
+			vuln:     helmscanTypes.Vulnerability{ID: "CVE-2021-44228", Severity: "critical"},
+			wantCell: "| CVE-2021-44228 |",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vulns := map[string]helmscanTypes.Vulnerability{tt.vuln.ID: tt.vuln}
+			cves := map[string]map[string]helmscanTypes.Vulnerability{tt.vuln.ID: {"docker.io/bitnami/log4j": tt.vuln}}
+			tables := map[string]string{
+				"single scan": GenerateMarkdownSingleReport(NewSingleScanReport("image", "docker.io/bitnami/log4j:2.14.1", vulns), false, ReportOptions{}),
+				"comparison":  formatVulnerabilitySection(cves, ReportOptions{}),
+				"legacy":      sortAndFormatCVEs(cves),
+			}
+			for name, table := range tables {
+				if !strings.Contains(table, tt.wantCell) {
+					t.Errorf("%s table has no %q cell:\n%s", name, tt.wantCell, table)
+				}
+			}
+
+			var report SingleScanReport
+			if err := json.Unmarshal([]byte(GenerateSingleScanReport(NewSingleScanReport("image", "docker.io/bitnami/log4j:2.14.1", vulns), true, false, ReportOptions{})), &report); err != nil {
+				t.Fatal(err)
+			}
+			jsonCVEs := ConvertToJSONCVEs(cves, nil)
+			for name, cve := range map[string]CVE{"single scan": report.CVEs[0], "comparison": jsonCVEs[0]} {
+				if !slices.Equal(cve.References, tt.wantInJSON) {
+					t.Errorf("%s JSON references = %v, want %v", name, cve.References, tt.wantInJSON)
+				}
+				if cve.PrimaryURL != tt.vuln.PrimaryURL {
+					t.Errorf("%s JSON primary_url = %q, want %q", name, cve.PrimaryURL, tt.vuln.PrimaryURL)
+				}
+			}
+		})
+	}
+}
+
+func TestSingleScanReportEPSS(t *testing.T) {
+	score := 0.97565
+	tests := []struct {
+		name         string
+		vulns        map[string]helmscanTypes.Vulnerability
+		wantMarkdown []string
+		wantJSON     []string
+		wantColumn   bool
+	}{
+		{
+			name: "without scores",
+			vulns: map[string]helmscanTypes.Vulnerability{
+				"CVE-2021-44228": {ID: "CVE-2021-44228", Severity: "critical"},
+			},
+			wantMarkdown: []string{"| CVE-2021-44228 | critical |\n"},
+		},
+		{
+			// CVEs without a score show a dash once any CVE has one.
+			name: "with scores",
+			vulns: map[string]helmscanTypes.Vulnerability{
+				"CVE-2021-44228": {ID: "CVE-2021-44228", Severity: "critical", EPSS: &score},
+				"CVE-2023-45853": {ID: "CVE-2023-45853", Severity: "critical"},
+			},
+			wantMarkdown: []string{"| CVE-2021-44228 | critical | 0.976 |\n", "| CVE-2023-45853 | critical | - |\n"},
+			wantJSON:     []string{`"epss": 0.97565`},
+			wantColumn:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := NewSingleScanReport("image", "docker.io/library/log4j:2.14", tt.vulns)
+			markdown := GenerateMarkdownSingleReport(report, false, ReportOptions{})
+			if has := strings.Contains(markdown, "| CVE ID | Severity | EPSS |"); has != tt.wantColumn {
+				t.Errorf("report has an EPSS column = %v, want %v:\n%s", has, tt.wantColumn, markdown)
+			}
+			for _, want := range tt.wantMarkdown {
+				if !strings.Contains(markdown, want) {
+					t.Errorf("markdown is missing %q:\n%s", want, markdown)
+				}
+			}
+
+			jsonReport := GenerateJSONSingleReport(report)
+			if has := strings.Contains(jsonReport, `"epss"`); has != (len(tt.wantJSON) > 0) {
+				t.Errorf("JSON report has epss fields = %v, want %v:\n%s", has, !has, jsonReport)
+			}
+			for _, want := range tt.wantJSON {
+				if !strings.Contains(jsonReport, want) {
+					t.Errorf("JSON report is missing %q:\n%s", want, jsonReport)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateSafeFileName(t *testing.T) {
+	long := strings.Repeat("registry.example.com/team/", 10) + "app:1.0.0"
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "chart", input: "bitnami/redis@18.1.0", want: "bitnami-redis-18-1-0"},
+		{name: "image", input: "docker.io/Bitnami/Redis:7.2.4", want: "docker-io-bitnami-redis-7-2-4"},
+		{name: "traversal", input: "../../etc/passwd", want: "etc-passwd"},
+		{name: "leading dots", input: "..hidden", want: "hidden"},
+		{name: "windows separators", input: `..\..\windows\system32`, want: "windows-system32"},
+		{name: "spaces and repeated separators", input: "  my chart //  v1 ", want: "my-chart-v1"},
+		{name: "only separators", input: "../..", want: "unnamed"},
+		{name: "empty", input: "", want: "unnamed"},
+		{name: "non-ASCII", input: "café@1.0", want: "caf-1-0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CreateSafeFileName(tt.input); got != tt.want {
+				t.Errorf("CreateSafeFileName(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("long", func(t *testing.T) {
+		got := CreateSafeFileName(long)
+		if len(got) > maxSafeFileNameLength {
+			t.Errorf("CreateSafeFileName() is %d bytes, want at most %d", len(got), maxSafeFileNameLength)
+		}
+		if !strings.HasPrefix(got, "registry-example-com-team-") {
+			t.Errorf("CreateSafeFileName() = %q, want it to keep the start of the reference", got)
+		}
+		// Refs that only differ after the truncation point keep distinct names.
+		if other := CreateSafeFileName(strings.TrimSuffix(long, "1.0.0") + "2.0.0"); other == got {
+			t.Errorf("CreateSafeFileName() = %q for two different long references", got)
+		}
+		if again := CreateSafeFileName(long); again != got {
+			t.Errorf("CreateSafeFileName() = %q, then %q for the same reference", got, again)
+		}
+	})
+
+	for _, input := range []string{"../../etc/passwd", "/abs/path", long, ".", ".."} {
+		if got := CreateSafeFileName(input); filepath.Base(got) != got || got == "." || got == ".." || strings.HasPrefix(got, ".") {
+			t.Errorf("CreateSafeFileName(%q) = %q, want a single path component", input, got)
+		}
+	}
+}
+
+func TestReportFilename(t *testing.T) {
+	const ref = "bitnami/redis@18.1.0"
+	hashed := ReportOptions{HashedFilenames: true}
+	tests := []struct {
+		name string
+		ref  string
+		opts ReportOptions
+		// Hashed names must be equal within a group and differ between groups.
+		group string
+	}{
+		{name: "hashed", ref: ref, opts: hashed, group: "default"},
+		{name: "hashed again", ref: ref, opts: hashed, group: "default"},
+		// Options that do not change report content do not change the name.
+		{name: "report file", ref: ref, opts: ReportOptions{HashedFilenames: true, ReportFile: "-"}, group: "default"},
+		{name: "output dir", ref: ref, opts: ReportOptions{HashedFilenames: true, OutputDir: "reports"}, group: "default"},
+		{name: "metadata", ref: ref, opts: ReportOptions{HashedFilenames: true, Metadata: &Metadata{Command: "helmscan"}}, group: "default"},
+		{name: "other ref", ref: "bitnami/redis@18.2.0", opts: hashed, group: "other ref"},
+		{name: "ignore unfixed", ref: ref, opts: ReportOptions{HashedFilenames: true, IgnoreUnfixed: true}, group: "ignore unfixed"},
+		{name: "scanners", ref: ref, opts: ReportOptions{HashedFilenames: true, Scanners: "vuln,secret"}, group: "scanners"},
+		{name: "scan manifests", ref: ref, opts: ReportOptions{HashedFilenames: true, ScanManifests: true}, group: "scan manifests"},
+		{name: "group by", ref: ref, opts: ReportOptions{HashedFilenames: true, GroupBy: GroupByPackage}, group: "group by"},
+		{name: "risk weights", ref: ref, opts: ReportOptions{HashedFilenames: true, RiskWeights: RiskWeights{Critical: 20}}, group: "risk weights"},
+		{name: "collapsible CVEs", ref: ref, opts: ReportOptions{HashedFilenames: true, CollapsibleCVEs: true}, group: "collapsible CVEs"},
+		{name: "top", ref: ref, opts: ReportOptions{HashedFilenames: true, Top: 5}, group: "top"},
+		{name: "status severity", ref: ref, opts: ReportOptions{HashedFilenames: true, StatusSeverity: "critical"}, group: "status severity"},
+		{name: "count mode", ref: ref, opts: ReportOptions{HashedFilenames: true, CountMode: CountModeChart}, group: "count mode"},
+		{name: "explain", ref: ref, opts: ReportOptions{HashedFilenames: true, Explain: true}, group: "explain"},
+		{name: "compare output", ref: ref, opts: ReportOptions{HashedFilenames: true, CompareOutput: CompareOutputInlineDiff}, group: "compare output"},
+		{name: "remediation", ref: ref, opts: ReportOptions{HashedFilenames: true, Remediation: true}, group: "remediation"},
+		{name: "display severity", ref: ref, opts: ReportOptions{HashedFilenames: true, DisplaySeverity: []string{"critical", "high"}}, group: "display severity"},
+		{name: "display severity subset", ref: ref, opts: ReportOptions{HashedFilenames: true, DisplaySeverity: []string{"critical"}}, group: "display severity subset"},
+		{name: "ignore below", ref: ref, opts: ReportOptions{HashedFilenames: true, IgnoreBelow: "high"}, group: "ignore below"},
+	}
+	names := make(map[string]string)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ReportFilename("helm_scan", tt.ref, tt.opts)
+			if hash, ok := strings.CutPrefix(got, "helm_scan_"); !ok || len(hash) != 8 || strings.Trim(hash, "0123456789abcdef") != "" {
+				t.Errorf("ReportFilename() = %q, want helm_scan_ and 8 hex characters", got)
+			}
+			for name, group := range names {
+				if same := name == got; same != (group == tt.group) {
+					t.Errorf("ReportFilename() = %q, same as the %s name %q = %v, want %v", got, group, name, same, !same)
+				}
+			}
+			names[got] = tt.group
+		})
+	}
+
+	if got, want := ReportFilename("helm_scan", ref, ReportOptions{}), "helm_scan_"+CreateSafeFileName(ref); got != want {
+		t.Errorf("ReportFilename() without HashedFilenames = %q, want the readable %q", got, want)
+	}
+}
+
+func TestConvertToJSONCVEsAffectedResources(t *testing.T) {
+	redis := AffectedResource{Kind: "StatefulSet", Name: "release-name-redis-master", Container: "redis", Image: "docker.io/bitnami/redis:7.2.4"}
+	replica := AffectedResource{Kind: "StatefulSet", Name: "release-name-redis-replicas", Container: "redis", Image: "docker.io/bitnami/redis:7.2.4"}
+	exporter := AffectedResource{Kind: "Deployment", Name: "release-name-redis-metrics", Container: "metrics", Image: "docker.io/bitnami/redis-exporter:1.58.0"}
+	resources := map[string][]AffectedResource{
+		"docker.io/bitnami/redis":          {redis, replica},
+		"docker.io/bitnami/redis-exporter": {exporter},
+	}
+	vuln := helmscanTypes.Vulnerability{Severity: "high"}
+
+	tests := []struct {
+		name      string
+		cves      map[string]map[string]helmscanTypes.Vulnerability
+		resources map[string][]AffectedResource
+		want      []AffectedResource
+	}{
+		{
+			name:      "image run by two resources",
+			cves:      map[string]map[string]helmscanTypes.Vulnerability{"CVE-2023-45853": {"docker.io/bitnami/redis": vuln}},
+			resources: resources,
+			want:      []AffectedResource{redis, replica},
+		},
+		{
+			name: "CVE in two images",
+			cves: map[string]map[string]helmscanTypes.Vulnerability{"CVE-2023-45853": {
+				"docker.io/bitnami/redis-exporter": vuln,
+				"docker.io/bitnami/redis":          vuln,
+			}},
+			resources: resources,
+			want:      []AffectedResource{redis, replica, exporter},
+		},
+		{
+			name:      "image without resources",
+			cves:      map[string]map[string]helmscanTypes.Vulnerability{"CVE-2023-45853": {"docker.io/bitnami/os-shell": vuln}},
+			resources: resources,
+		},
+		{
+			name: "no resources",
+			cves: map[string]map[string]helmscanTypes.Vulnerability{"CVE-2023-45853": {"docker.io/bitnami/redis": vuln}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ConvertToJSONCVEs(tt.cves, tt.resources)
+			if len(got) != 1 {
+				t.Fatalf("ConvertToJSONCVEs() returned %d CVEs, want 1", len(got))
+			}
+			if !reflect.DeepEqual(got[0].AffectedResources, tt.want) {
+				t.Errorf("AffectedResources = %+v, want %+v", got[0].AffectedResources, tt.want)
+			}
+			data, err := json.Marshal(got[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hasField := strings.Contains(string(data), `"affected_resources"`); hasField != (tt.want != nil) {
+				t.Errorf("JSON %s: affected_resources present = %v, want %v", data, hasField, tt.want != nil)
+			}
+		})
+	}
+}
+
+func TestSortAndFormatCVEsOneRowPerCVE(t *testing.T) {
+	vuln := func(severity string) helmscanTypes.Vulnerability {
+		return helmscanTypes.Vulnerability{Severity: severity}
+	}
+	tests := []struct {
+		name         string
+		cves         map[string]map[string]helmscanTypes.Vulnerability
+		wantRows     []string
+		wantHeadings []string
+	}{
+		{
+			name: "CVE in several images",
+			cves: map[string]map[string]helmscanTypes.Vulnerability{"CVE-2023-45853": {
+				"docker.io/bitnami/redis-exporter": vuln("high"),
+				"docker.io/bitnami/redis":          vuln("high"),
+				"docker.io/bitnami/os-shell":       vuln("high"),
+			}},
+			wantRows:     []string{"| CVE-2023-45853 | high | docker.io/bitnami/os-shell, docker.io/bitnami/redis, docker.io/bitnami/redis-exporter |"},
+			wantHeadings: []string{"#### High"},
+		},
+		{
+			// A severity override for one image must not split the CVE into a row per severity.
+			name: "images disagree on severity",
+			cves: map[string]map[string]helmscanTypes.Vulnerability{"CVE-2023-45853": {
+				"docker.io/bitnami/redis":          vuln("low"),
+				"docker.io/bitnami/redis-exporter": vuln("critical"),
+			}},
+			wantRows:     []string{"| CVE-2023-45853 | critical | docker.io/bitnami/redis, docker.io/bitnami/redis-exporter |"},
+			wantHeadings: []string{"#### Critical"},
+		},
+		{
+			name: "severity case differs between CVEs",
+			cves: map[string]map[string]helmscanTypes.Vulnerability{
+				"CVE-2023-45288": {"docker.io/bitnami/redis-exporter": vuln("HIGH")},
+				"CVE-2023-45853": {"docker.io/bitnami/redis": vuln("high")},
+			},
+			wantRows: []string{
+				"| CVE-2023-45288 | HIGH | docker.io/bitnami/redis-exporter |",
+				"| CVE-2023-45853 | high | docker.io/bitnami/redis |",
+			},
+			wantHeadings: []string{"#### HIGH"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rows, headings []string
+			for _, line := range strings.Split(sortAndFormatCVEs(tt.cves), "\n") {
+				if strings.HasPrefix(line, "| CVE-") {
+					rows = append(rows, line)
+				} else if strings.HasPrefix(line, "#### ") {
+					headings = append(headings, line)
+				}
+			}
+			if !reflect.DeepEqual(rows, tt.wantRows) {
+				t.Errorf("rows = %q, want %q", rows, tt.wantRows)
+			}
+			if !reflect.DeepEqual(headings, tt.wantHeadings) {
+				t.Errorf("headings = %q, want %q", headings, tt.wantHeadings)
+			}
+		})
+	}
+}
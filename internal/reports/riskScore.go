@@ -0,0 +1,93 @@
+package reports
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RiskWeights are the points each vulnerability of a severity adds to the risk score.
+type RiskWeights struct {
+	Critical int
+	High     int
+	Medium   int
+	Low      int
+}
+
+var DefaultRiskWeights = RiskWeights{Critical: 10, High: 5, Medium: 2, Low: 1}
+
+// RiskScore is the weighted vulnerability count of the current scan and, for comparisons, of
+// the previous one.
+type RiskScore struct {
+	Current    int `json:"current"`
+	Previous   int `json:"previous"`
+	Difference int `json:"difference"`
+}
+
+// ParseRiskWeights reads weights such as "critical=20,high=5". Severities that are not listed
+// keep their default weight.
+func ParseRiskWeights(value string) (RiskWeights, error) {
+	weights := DefaultRiskWeights
+	if strings.TrimSpace(value) == "" {
+		return weights, nil
+	}
+	for _, part := range strings.Split(value, ",") {
+		severity, weight, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return weights, fmt.Errorf("invalid risk weight %q, expected severity=weight", part)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(weight))
+		if err != nil || n < 0 {
+			return weights, fmt.Errorf("invalid risk weight %q, expected a non-negative integer", part)
+		}
+		switch strings.ToLower(strings.TrimSpace(severity)) {
+		case "critical":
+			weights.Critical = n
+		case "high":
+			weights.High = n
+		case "medium":
+			weights.Medium = n
+		case "low":
+			weights.Low = n
+		default:
+			return weights, fmt.Errorf("invalid risk weight %q, unknown severity %q", part, severity)
+		}
+	}
+	return weights, nil
+}
+
+func (w RiskWeights) score(summary SeveritySummary) int {
+	return summary.Critical*w.Critical + summary.High*w.High + summary.Medium*w.Medium + summary.Low*w.Low
+}
+
+// riskWeights returns the configured weights, or the defaults when none were set.
+func (opts ReportOptions) riskWeights() RiskWeights {
+	if opts.RiskWeights == (RiskWeights{}) {
+		return DefaultRiskWeights
+	}
+	return opts.RiskWeights
+}
+
+// NewRiskScore scores the current and previous counts of a comparison.
+func NewRiskScore(counts []SeverityCount, weights RiskWeights) RiskScore {
+	var current, previous SeveritySummary
+	for _, count := range counts {
+		switch count.Severity {
+		case "critical":
+			current.Critical, previous.Critical = count.Current, count.Previous
+		case "high":
+			current.High, previous.High = count.Current, count.Previous
+		case "medium":
+			current.Medium, previous.Medium = count.Current, count.Previous
+		case "low":
+			current.Low, previous.Low = count.Current, count.Previous
+		}
+	}
+	score := RiskScore{Current: weights.score(current), Previous: weights.score(previous)}
+	score.Difference = score.Current - score.Previous
+	return score
+}
+
+func formatRiskScore(score RiskScore) string {
+	return fmt.Sprintf("**Risk score:** %d (previous %d, %+d)\n", score.Current, score.Previous, score.Difference)
+}
@@ -0,0 +1,121 @@
+package reports
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func TestParseRiskWeights(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    RiskWeights
+		wantErr string
+	}{
+		{value: "", want: DefaultRiskWeights},
+		{value: "critical=20,high=8,medium=3,low=0", want: RiskWeights{Critical: 20, High: 8, Medium: 3, Low: 0}},
+		// Severities that are not listed keep their default weight.
+		{value: " Critical = 20 ", want: RiskWeights{Critical: 20, High: 5, Medium: 2, Low: 1}},
+		{value: "critical", wantErr: "expected severity=weight"},
+		{value: "high=-1", wantErr: "expected a non-negative integer"},
+		{value: "high=lots", wantErr: "expected a non-negative integer"},
+		{value: "severe=3", wantErr: `unknown severity "severe"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseRiskWeights(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseRiskWeights(%q) error = %v, want %q", tt.value, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRiskWeights(%q) error = %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRiskWeights(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRiskScore(t *testing.T) {
+	counts := []SeverityCount{
+		{Severity: "critical", Current: 1, Previous: 2},
+		{Severity: "high", Current: 3, Previous: 1},
+		{Severity: "medium", Current: 4, Previous: 0},
+		{Severity: "low", Current: 5, Previous: 7},
+	}
+	tests := []struct {
+		name    string
+		counts  []SeverityCount
+		weights RiskWeights
+		want    RiskScore
+	}{
+		{
+			// 1*10 + 3*5 + 4*2 + 5*1 and 2*10 + 1*5 + 0*2 + 7*1.
+			name:    "default weights",
+			counts:  counts,
+			weights: DefaultRiskWeights,
+			want:    RiskScore{Current: 38, Previous: 32, Difference: 6},
+		},
+		{
+			name:    "custom weights",
+			counts:  counts,
+			weights: RiskWeights{Critical: 100, High: 0, Medium: 0, Low: 1},
+			want:    RiskScore{Current: 105, Previous: 207, Difference: -102},
+		},
+		{name: "no vulnerabilities", weights: DefaultRiskWeights},
+		{
+			// Unknown severities carry no weight.
+			name:    "unknown severity",
+			counts:  []SeverityCount{{Severity: "unknown", Current: 9}},
+			weights: DefaultRiskWeights,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewRiskScore(tt.counts, tt.weights); got != tt.want {
+				t.Errorf("NewRiskScore() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSingleScanReportRiskScore(t *testing.T) {
+	vulns := map[string]helmscanTypes.Vulnerability{
+		"redis:CVE-2023-45853": {ID: "CVE-2023-45853", Severity: "critical"},
+		"redis:CVE-2024-6387":  {ID: "CVE-2024-6387", Severity: "high"},
+		"redis:CVE-2011-3374":  {ID: "CVE-2011-3374", Severity: "low"},
+		"proxy:CVE-2011-3374":  {ID: "CVE-2011-3374", Severity: "low"},
+	}
+	tests := []struct {
+		name string
+		opts ReportOptions
+		want int
+	}{
+		{name: "default weights", want: 10 + 5 + 1 + 1},
+		{name: "custom weights", opts: ReportOptions{RiskWeights: RiskWeights{Critical: 20, High: 8, Medium: 2, Low: 0}}, want: 28},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := NewSingleScanReport("chart", "bitnami/redis@18.1.0", vulns)
+
+			var parsed SingleScanReport
+			if err := json.Unmarshal([]byte(GenerateSingleScanReport(report, true, false, tt.opts)), &parsed); err != nil {
+				t.Fatal(err)
+			}
+			if parsed.RiskScore != tt.want {
+				t.Errorf("JSON RiskScore = %d, want %d", parsed.RiskScore, tt.want)
+			}
+			markdown := GenerateSingleScanReport(report, false, false, tt.opts)
+			if want := fmt.Sprintf("**Risk score:** %d\n", tt.want); !strings.Contains(markdown, want) {
+				t.Errorf("markdown report is missing %q:\n%s", want, markdown)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package reports
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+const SavedScanSchemaVersion = 1
+
+// SavedScan is the on-disk form of a complete chart scan, so reports can be regenerated later
+// without running Helm or Trivy again.
+type SavedScan struct {
+	SchemaVersion int                     `json:"schemaVersion"`
+	Chart         helmscanTypes.HelmChart `json:"chart"`
+}
+
+func SaveScan(chart helmscanTypes.HelmChart, path string) error {
+	data, err := json.MarshalIndent(SavedScan{SchemaVersion: SavedScanSchemaVersion, Chart: chart}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error serializing scan: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error writing scan to %s: %w", path, err)
+	}
+	return nil
+}
+
+func LoadScan(path string) (helmscanTypes.HelmChart, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return helmscanTypes.HelmChart{}, fmt.Errorf("error reading saved scan: %w", err)
+	}
+
+	var saved SavedScan
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return helmscanTypes.HelmChart{}, fmt.Errorf("error parsing saved scan %s: %w", path, err)
+	}
+	if saved.SchemaVersion != SavedScanSchemaVersion {
+		return helmscanTypes.HelmChart{}, fmt.Errorf("saved scan %s has schema version %d, expected %d", path, saved.SchemaVersion, SavedScanSchemaVersion)
+	}
+	return saved.Chart, nil
+}
@@ -0,0 +1,70 @@
+package reports
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func TestSaveScanRoundTrip(t *testing.T) {
+	epss := 0.00421
+	zlib := helmscanTypes.Vulnerability{ID: "CVE-2023-45853", Severity: "critical", PkgName: "zlib1g", InstalledVersion: "1:1.2.13.dfsg-1", CVSS: 9.8, EPSS: &epss, KEV: true}
+	chart := helmscanTypes.HelmChart{
+		Name:     "redis",
+		Version:  "18.1.0",
+		HelmRepo: "bitnami",
+		ContainsImages: []*helmscanTypes.ContainerImage{{
+			Repository:      "docker.io/bitnami",
+			ImageName:       "redis",
+			Tag:             "7.2.4-debian-12-r9",
+			OS:              helmscanTypes.OperatingSystem{Family: "debian", Name: "12.5"},
+			SourceRefs:      []helmscanTypes.SourceRef{{Kind: "StatefulSet", Name: "release-name-redis-master", Container: "redis", Path: "spec.template.spec.containers[0].image"}},
+			ScanResult:      helmscanTypes.ScanResult{Image: "docker.io/bitnami/redis:7.2.4-debian-12-r9", VulnList: []helmscanTypes.Vulnerability{zlib}},
+			Vulnerabilities: map[string]helmscanTypes.Vulnerability{zlib.ID: zlib},
+			Signature:       &helmscanTypes.SignatureVerification{Verified: true},
+		}},
+		SkippedImages: []helmscanTypes.SkippedImage{{Reference: "REPLACE_ME", Reason: "placeholder value"}},
+	}
+
+	path := filepath.Join(t.TempDir(), "scan.json")
+	if err := SaveScan(chart, path); err != nil {
+		t.Fatalf("SaveScan() error = %v", err)
+	}
+	loaded, err := LoadScan(path)
+	if err != nil {
+		t.Fatalf("LoadScan() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded, chart) {
+		t.Errorf("LoadScan() = %+v, want the saved chart %+v", loaded, chart)
+	}
+}
+
+func TestLoadScanErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "missing", wantErr: "error reading saved scan"},
+		{name: "not JSON", content: "{", wantErr: "error parsing saved scan"},
+		{name: "no schema version", content: `{"chart": {"Name": "redis"}}`, wantErr: "has schema version 0, expected 1"},
+		{name: "newer schema version", content: `{"schemaVersion": 2, "chart": {"Name": "redis"}}`, wantErr: "has schema version 2, expected 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "scan.json")
+			if tt.content != "" {
+				if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := LoadScan(path); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadScan() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
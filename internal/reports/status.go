@@ -0,0 +1,118 @@
+package reports
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	StatusPass = "pass"
+	StatusFail = "fail"
+	StatusWarn = "warn"
+)
+
+// DefaultStatusSeverity is the lowest severity the report verdict counts unless
+// ReportOptions.StatusSeverity is set.
+const DefaultStatusSeverity = "high"
+
+// ReportStatus is the verdict shown at the top of a report: whether a comparison added CVEs at or
+// above the status severity, or whether a single scan found any.
+type ReportStatus struct {
+	Result  string `json:"result"`
+	Message string `json:"message"`
+}
+
+func (opts ReportOptions) statusSeverity() string {
+	if opts.StatusSeverity == "" {
+		return DefaultStatusSeverity
+	}
+	return strings.ToLower(opts.StatusSeverity)
+}
+
+// NewComparisonStatus fails a comparison that adds CVEs at or above minSeverity, using the same
+// rule as --notify-webhook.
+func NewComparisonStatus(generator ReportGenerator, minSeverity string) ReportStatus {
+	summary, regressed := NewRegressionSummary(generator, minSeverity)
+	if !regressed {
+		return ReportStatus{
+			Result:  StatusPass,
+			Message: fmt.Sprintf("✅ No new %s CVEs", strings.Join(severitiesAtOrAbove(minSeverity), "/")),
+		}
+	}
+	return ReportStatus{
+		Result:  StatusFail,
+		Message: fmt.Sprintf("❌ %s introduced", describeSeverityCounts(summary.NewCVEs, "new ")),
+	}
+}
+
+// newSingleScanStatus warns when a single scan found CVEs at or above minSeverity.
+func newSingleScanStatus(summary SeveritySummary, minSeverity string) ReportStatus {
+	counts := make(map[string]int)
+	for _, severity := range severitiesAtOrAbove(minSeverity) {
+		counts[severity] = summary.count(severity)
+	}
+	description := describeSeverityCounts(counts, "")
+	if description == "" {
+		return ReportStatus{
+			Result:  StatusPass,
+			Message: fmt.Sprintf("✅ No %s CVEs found", strings.Join(severitiesAtOrAbove(minSeverity), "/")),
+		}
+	}
+	return ReportStatus{
+		Result:  StatusWarn,
+		Message: fmt.Sprintf("⚠️ %s found", description),
+	}
+}
+
+func (s SeveritySummary) count(severity string) int {
+	switch severity {
+	case "critical":
+		return s.Critical
+	case "high":
+		return s.High
+	case "medium":
+		return s.Medium
+	case "low":
+		return s.Low
+	}
+	return 0
+}
+
+func severitiesAtOrAbove(minSeverity string) []string {
+	var severities []string
+	for _, severity := range []string{"critical", "high", "medium", "low"} {
+		if SeverityValue(severity) >= SeverityValue(minSeverity) {
+			severities = append(severities, severity)
+		}
+	}
+	return severities
+}
+
+// describeSeverityCounts phrases counts such as "3 new critical and 1 new high CVEs", from the
+// most severe down, or returns "" when every count is zero.
+func describeSeverityCounts(counts map[string]int, qualifier string) string {
+	var parts []string
+	total := 0
+	for _, severity := range []string{"critical", "high", "medium", "low"} {
+		if counts[severity] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s%s", counts[severity], qualifier, severity))
+			total += counts[severity]
+		}
+	}
+	if total == 0 {
+		return ""
+	}
+
+	description := parts[len(parts)-1]
+	if len(parts) > 1 {
+		description = strings.Join(parts[:len(parts)-1], ", ") + " and " + description
+	}
+	if total == 1 {
+		return description + " CVE"
+	}
+	return description + " CVEs"
+}
+
+func formatStatusBanner(status ReportStatus) string {
+	return fmt.Sprintf("> **%s**\n\n", status.Message)
+}
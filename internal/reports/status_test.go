@@ -0,0 +1,133 @@
+package reports
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDescribeSeverityCounts(t *testing.T) {
+	tests := []struct {
+		name      string
+		counts    map[string]int
+		qualifier string
+		want      string
+	}{
+		{name: "none", counts: map[string]int{"critical": 0}, want: ""},
+		{name: "one", counts: map[string]int{"high": 1}, want: "1 high CVE"},
+		{name: "several of one severity", counts: map[string]int{"critical": 3}, qualifier: "new ", want: "3 new critical CVEs"},
+		{
+			name:      "two severities",
+			counts:    map[string]int{"high": 1, "critical": 3},
+			qualifier: "new ",
+			want:      "3 new critical and 1 new high CVEs",
+		},
+		{
+			name:   "every severity",
+			counts: map[string]int{"low": 4, "medium": 3, "high": 2, "critical": 1},
+			want:   "1 critical, 2 high, 3 medium and 4 low CVEs",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := describeSeverityCounts(tt.counts, tt.qualifier); got != tt.want {
+				t.Errorf("describeSeverityCounts() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSingleScanStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		summary     SeveritySummary
+		minSeverity string
+		want        ReportStatus
+	}{
+		{
+			name:        "no CVEs",
+			minSeverity: "high",
+			want:        ReportStatus{Result: StatusPass, Message: "✅ No critical/high CVEs found"},
+		},
+		{
+			name:        "only below the threshold",
+			summary:     SeveritySummary{Medium: 2, Low: 7},
+			minSeverity: "high",
+			want:        ReportStatus{Result: StatusPass, Message: "✅ No critical/high CVEs found"},
+		},
+		{
+			name:        "at the threshold",
+			summary:     SeveritySummary{Critical: 5, High: 2, Medium: 1},
+			minSeverity: "high",
+			want:        ReportStatus{Result: StatusWarn, Message: "⚠️ 5 critical and 2 high CVEs found"},
+		},
+		{
+			name:        "critical only",
+			summary:     SeveritySummary{Critical: 5, High: 2},
+			minSeverity: "critical",
+			want:        ReportStatus{Result: StatusWarn, Message: "⚠️ 5 critical CVEs found"},
+		},
+		{
+			name:        "low threshold",
+			summary:     SeveritySummary{Low: 1},
+			minSeverity: "low",
+			want:        ReportStatus{Result: StatusWarn, Message: "⚠️ 1 low CVE found"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newSingleScanStatus(tt.summary, tt.minSeverity); got != tt.want {
+				t.Errorf("newSingleScanStatus() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateSingleScanReportStatus(t *testing.T) {
+	report := SingleScanReport{
+		ArtifactType: "image",
+		ArtifactRef:  "docker.io/bitnami/redis:7.2.4",
+		Summary:      SeveritySummary{Critical: 1, Medium: 2},
+	}
+	tests := []struct {
+		name       string
+		opts       ReportOptions
+		wantStatus ReportStatus
+	}{
+		{
+			name:       "default severity",
+			wantStatus: ReportStatus{Result: StatusWarn, Message: "⚠️ 1 critical CVE found"},
+		},
+		{
+			name:       "medium severity",
+			opts:       ReportOptions{StatusSeverity: "MEDIUM"},
+			wantStatus: ReportStatus{Result: StatusWarn, Message: "⚠️ 1 critical and 2 medium CVEs found"},
+		},
+		{
+			name:       "critical severity",
+			opts:       ReportOptions{StatusSeverity: "critical"},
+			wantStatus: ReportStatus{Result: StatusWarn, Message: "⚠️ 1 critical CVE found"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			markdown := GenerateSingleScanReport(report, false, false, tt.opts)
+			// The banner comes straight after the artifact heading.
+			wantTop := "## Artifact: docker.io/bitnami/redis:7.2.4\n\n> **" + tt.wantStatus.Message + "**\n\n"
+			if !strings.Contains(markdown, wantTop) {
+				t.Errorf("markdown report does not open with the %q banner:\n%s", tt.wantStatus.Message, markdown)
+			}
+
+			var decoded struct {
+				Status *ReportStatus `json:"status"`
+			}
+			if err := json.Unmarshal([]byte(GenerateSingleScanReport(report, true, false, tt.opts)), &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Status == nil || !reflect.DeepEqual(*decoded.Status, tt.wantStatus) {
+				t.Errorf("JSON status = %+v, want %+v", decoded.Status, tt.wantStatus)
+			}
+		})
+	}
+}
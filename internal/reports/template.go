@@ -0,0 +1,96 @@
+package reports
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+var templateFuncs = template.FuncMap{
+	"severities": func() []string {
+		return []string{"critical", "high", "medium", "low"}
+	},
+	"severityRank":   SeverityValue,
+	"sortBySeverity": sortBySeverity,
+	"cvesBySeverity": cvesBySeverity,
+	"title":          titleCase,
+	"upper":          strings.ToUpper,
+	"lower":          strings.ToLower,
+	"join":           strings.Join,
+}
+
+// LoadTemplate parses a --template file, so syntax errors and unknown functions are reported
+// before any scanning starts.
+func LoadTemplate(path string) (*template.Template, error) {
+	tmpl, err := template.New(filepath.Base(path)).Funcs(templateFuncs).ParseFiles(path)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// RenderTemplate executes tmpl against a HelmChart for single scans or a HelmComparison for
+// comparisons.
+func RenderTemplate(tmpl *template.Template, data interface{}) (string, error) {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("error rendering template: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// TemplateExtension returns the file extension for reports rendered from the template at path:
+// report.html.tmpl produces .html files, and templates without an inner extension produce .txt.
+func TemplateExtension(path string) string {
+	name := filepath.Base(path)
+	if ext := filepath.Ext(name); ext == ".tmpl" || ext == ".tpl" {
+		name = strings.TrimSuffix(name, ext)
+	}
+	if ext := filepath.Ext(name); ext != "" {
+		return ext
+	}
+	return ".txt"
+}
+
+// sortBySeverity returns a copy of vulns ordered from critical to low, then by ID.
+func sortBySeverity(vulns []helmscanTypes.Vulnerability) []helmscanTypes.Vulnerability {
+	sorted := append([]helmscanTypes.Vulnerability(nil), vulns...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if SeverityValue(sorted[i].Severity) != SeverityValue(sorted[j].Severity) {
+			return SeverityValue(sorted[i].Severity) > SeverityValue(sorted[j].Severity)
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	return sorted
+}
+
+// cvesBySeverity flattens a CVE to image map, such as HelmComparison.AddedCVEs, to one
+// vulnerability per CVE ordered from critical to low.
+func cvesBySeverity(cves map[string]map[string]helmscanTypes.Vulnerability) []helmscanTypes.Vulnerability {
+	var vulns []helmscanTypes.Vulnerability
+	for _, images := range cves {
+		if len(images) == 0 {
+			continue
+		}
+		first := ""
+		for image := range images {
+			if first == "" || image < first {
+				first = image
+			}
+		}
+		vulns = append(vulns, images[first])
+	}
+	return sortBySeverity(vulns)
+}
+
+func titleCase(s string) string {
+	words := strings.Fields(s)
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + strings.ToLower(word[1:])
+	}
+	return strings.Join(words, " ")
+}
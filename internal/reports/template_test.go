@@ -0,0 +1,142 @@
+package reports_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+	"github.com/cliffcolvin/helmscan/internal/reports"
+)
+
+func writeTemplate(t *testing.T, name, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		wantErr string
+	}{
+		{name: "valid", text: "{{ range severities }}{{ title . }} {{ end }}"},
+		{name: "syntax error", text: "{{ range .AddedCVEs }}", wantErr: "error parsing template"},
+		{name: "unknown function", text: "{{ shout .Name }}", wantErr: `function "shout" not defined`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := reports.LoadTemplate(writeTemplate(t, "report.tmpl", tt.text))
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("LoadTemplate() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("LoadTemplate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := reports.LoadTemplate(filepath.Join(t.TempDir(), "missing.tmpl")); err == nil {
+			t.Error("LoadTemplate() error = nil, want an error for a missing file")
+		}
+	})
+}
+
+func TestRenderTemplate(t *testing.T) {
+	chart := helmscanTypes.HelmChart{
+		Name: "redis", Version: "18.1.0", HelmRepo: "bitnami",
+		ContainsImages: []*helmscanTypes.ContainerImage{image("docker.io/bitnami", "redis", "7.2.4")},
+	}
+	chart.ContainsImages[0].ScanResult.VulnList = []helmscanTypes.Vulnerability{
+		{ID: "CVE-2011-3374", Severity: "low"},
+		{ID: "CVE-2024-6387", Severity: "high"},
+		{ID: "CVE-2023-45853", Severity: "critical"},
+		{ID: "CVE-2023-44487", Severity: "high"},
+	}
+	tests := []struct {
+		name string
+		text string
+		data any
+		want string
+	}{
+		{
+			name: "comparison",
+			text: "# {{ .Before.Name }} {{ .Before.Version }} to {{ .After.Version }}\n" +
+				"{{ range cvesBySeverity .AddedCVEs }}- {{ .ID }} ({{ title .Severity }})\n{{ end }}",
+			data: goldenComparison("bitnami"),
+			want: "# web 1.0.0 to 2.0.0\n" +
+				"- CVE-2023-45853 (Critical)\n" +
+				"- CVE-2024-6387 (High)\n" +
+				"- CVE-2024-7347 (Medium)\n" +
+				"- CVE-2023-50495 (Low)\n",
+		},
+		{
+			name: "single scan",
+			text: "{{ .HelmRepo }}/{{ .Name }}@{{ .Version }}\n" +
+				"{{ range .ContainsImages }}{{ range sortBySeverity .ScanResult.VulnList }}{{ upper .Severity }} {{ .ID }}\n{{ end }}{{ end }}",
+			data: chart,
+			want: "bitnami/redis@18.1.0\n" +
+				"CRITICAL CVE-2023-45853\n" +
+				"HIGH CVE-2023-44487\n" +
+				"HIGH CVE-2024-6387\n" +
+				"LOW CVE-2011-3374\n",
+		},
+		{
+			name: "helpers",
+			text: `{{ join severities "," }} {{ severityRank "CRITICAL" }} {{ severityRank "unknown" }} {{ title "not FIXED" }} {{ lower "HIGH" }}`,
+			data: chart,
+			want: "critical,high,medium,low 4 0 Not Fixed high",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := reports.LoadTemplate(writeTemplate(t, "report.tmpl", tt.text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := reports.RenderTemplate(tmpl, tt.data)
+			if err != nil {
+				t.Fatalf("RenderTemplate() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("RenderTemplate() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("missing field", func(t *testing.T) {
+		tmpl, err := reports.LoadTemplate(writeTemplate(t, "report.tmpl", "{{ .Before.Name }}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := reports.RenderTemplate(tmpl, chart); err == nil || !strings.Contains(err.Error(), "error rendering template") {
+			t.Errorf("RenderTemplate() error = %v, want an error rendering a comparison template against a chart", err)
+		}
+	})
+}
+
+func TestTemplateExtension(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "report.tmpl", want: ".txt"},
+		{path: "templates/report.html.tmpl", want: ".html"},
+		{path: "report.md.tpl", want: ".md"},
+		{path: "report.csv", want: ".csv"},
+		{path: "report", want: ".txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := reports.TemplateExtension(tt.path); got != tt.want {
+				t.Errorf("TemplateExtension(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package reports
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const tableOfContentsHeading = "Contents"
+
+// formatTableOfContents links every ### section of body. It is placed between header and body,
+// and anchors are computed over the whole document in order, so they match the slugs GitHub
+// generates, including the -1, -2 suffixes for repeated headings.
+func formatTableOfContents(header, body string) string {
+	seen := make(map[string]int)
+	anchor := func(heading string) string {
+		slug := githubSlug(heading)
+		n := seen[slug]
+		seen[slug]++
+		if n > 0 {
+			slug = fmt.Sprintf("%s-%d", slug, n)
+		}
+		return slug
+	}
+
+	for _, line := range strings.Split(header, "\n") {
+		if _, text, ok := markdownHeading(line); ok {
+			anchor(text)
+		}
+	}
+	anchor(tableOfContentsHeading)
+
+	var entries []string
+	for _, line := range strings.Split(body, "\n") {
+		level, text, ok := markdownHeading(line)
+		if !ok {
+			continue
+		}
+		slug := anchor(text)
+		if level == 3 {
+			entries = append(entries, fmt.Sprintf("- [%s](#%s)", text, slug))
+		}
+	}
+	if len(entries) == 0 {
+		return ""
+	}
+	return FormatSection(tableOfContentsHeading, strings.Join(entries, "\n")+"\n")
+}
+
+func markdownHeading(line string) (int, string, bool) {
+	level := len(line) - len(strings.TrimLeft(line, "#"))
+	if level == 0 || level > 6 || !strings.HasPrefix(line[level:], " ") {
+		return 0, "", false
+	}
+	return level, strings.TrimSpace(line[level:]), true
+}
+
+// githubSlug converts heading text to the anchor GitHub gives it: lowercase, spaces become
+// dashes, and punctuation other than dashes and underscores is dropped.
+func githubSlug(text string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			sb.WriteRune(r)
+		case r == ' ':
+			sb.WriteByte('-')
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,61 @@
+package reports
+
+import "testing"
+
+func TestGithubSlug(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "CVE by Severity", want: "cve-by-severity"},
+		{text: "Before Chart: bitnami/redis@18.1.0", want: "before-chart-bitnamiredis1810"},
+		{text: "Unchanged CVEs (3)", want: "unchanged-cves-3"},
+		{text: "snake_case and-dashes", want: "snake_case-and-dashes"},
+		{text: "Image: café", want: "image-café"},
+		{text: "Two  spaces", want: "two--spaces"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := githubSlug(tt.text); got != tt.want {
+				t.Errorf("githubSlug(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatTableOfContents(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		body   string
+		want   string
+	}{
+		{
+			name:   "sections",
+			header: "## Helm Chart Comparison Report\n### Before Chart: bitnami/web@1.0.0\n\n",
+			body:   "### CVE by Severity\n\n| a |\n### Added CVEs\n\n#### High\n| b |\n",
+			want:   "### Contents\n\n- [CVE by Severity](#cve-by-severity)\n- [Added CVEs](#added-cves)\n\n",
+		},
+		{
+			// GitHub numbers repeated headings in document order, including those in the header
+			// and the Contents heading itself.
+			name:   "repeated headings",
+			header: "## Report\n### Added CVEs\n\n",
+			body:   "### Contents\n### Added CVEs\n#### Added CVEs\n### Added CVEs\n",
+			want:   "### Contents\n\n- [Contents](#contents-1)\n- [Added CVEs](#added-cves-1)\n- [Added CVEs](#added-cves-3)\n\n",
+		},
+		{
+			name:   "no sections",
+			header: "## Report\n",
+			body:   "No vulnerabilities found.\n#not a heading\n",
+			want:   "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatTableOfContents(tt.header, tt.body); got != tt.want {
+				t.Errorf("formatTableOfContents() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package reports
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// topPerSeverity keeps the n highest CVSS scored items of each severity, breaking ties by CVE ID,
+// and returns them in their original order along with how many were dropped per lowercase
+// severity. key returns an item's severity, CVSS score and ID. n <= 0 keeps every item.
+func topPerSeverity[T any](items []T, n int, key func(T) (string, float64, string)) ([]T, map[string]int) {
+	if n <= 0 {
+		return items, nil
+	}
+
+	bySeverity := make(map[string][]int)
+	for i, item := range items {
+		severity, _, _ := key(item)
+		severity = strings.ToLower(severity)
+		bySeverity[severity] = append(bySeverity[severity], i)
+	}
+
+	keep := make(map[int]bool)
+	hidden := make(map[string]int)
+	for severity, indexes := range bySeverity {
+		sort.SliceStable(indexes, func(a, b int) bool {
+			_, scoreA, idA := key(items[indexes[a]])
+			_, scoreB, idB := key(items[indexes[b]])
+			if scoreA != scoreB {
+				return scoreA > scoreB
+			}
+			return idA < idB
+		})
+		for rank, i := range indexes {
+			if rank < n {
+				keep[i] = true
+			} else {
+				hidden[severity]++
+			}
+		}
+	}
+
+	kept := make([]T, 0, len(keep))
+	for i, item := range items {
+		if keep[i] {
+			kept = append(kept, item)
+		}
+	}
+	return kept, hidden
+}
+
+// formatMoreCVEs notes the rows --top left out of a severity table.
+func formatMoreCVEs(hidden map[string]int, severity string) string {
+	return formatHiddenCVEs(hidden[strings.ToLower(severity)])
+}
+
+func formatHiddenCVEs(count int) string {
+	if count == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n*… and %d more (all CVEs are listed in the JSON report)*\n", count)
+}
@@ -0,0 +1,118 @@
+package reports
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+func TestTopPerSeverity(t *testing.T) {
+	cves := []CVE{
+		{ID: "CVE-2024-0001", Severity: "critical", CVSS: 9.1},
+		{ID: "CVE-2024-0002", Severity: "critical", CVSS: 9.8},
+		{ID: "CVE-2024-0003", Severity: "CRITICAL", CVSS: 9.3},
+		{ID: "CVE-2024-0004", Severity: "high", CVSS: 7.5},
+		{ID: "CVE-2024-0005", Severity: "high", CVSS: 7.5},
+		{ID: "CVE-2024-0006", Severity: "low", CVSS: 3.1},
+	}
+	ids := func(cves []CVE) []string {
+		var ids []string
+		for _, cve := range cves {
+			ids = append(ids, cve.ID)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name       string
+		n          int
+		wantIDs    []string
+		wantHidden map[string]int
+	}{
+		{
+			name:    "no limit",
+			n:       0,
+			wantIDs: ids(cves),
+		},
+		{
+			// Severities match case-insensitively, and equal scores keep the lower CVE ID.
+			name:       "top one",
+			n:          1,
+			wantIDs:    []string{"CVE-2024-0002", "CVE-2024-0004", "CVE-2024-0006"},
+			wantHidden: map[string]int{"critical": 2, "high": 1},
+		},
+		{
+			// Kept items stay in their original order, not score order.
+			name:       "top two",
+			n:          2,
+			wantIDs:    []string{"CVE-2024-0002", "CVE-2024-0003", "CVE-2024-0004", "CVE-2024-0005", "CVE-2024-0006"},
+			wantHidden: map[string]int{"critical": 1},
+		},
+		{
+			name:       "limit above every group",
+			n:          5,
+			wantIDs:    ids(cves),
+			wantHidden: map[string]int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, hidden := topPerSeverity(cves, tt.n, func(cve CVE) (string, float64, string) {
+				return cve.Severity, cve.CVSS, cve.ID
+			})
+			if got := ids(kept); !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Errorf("kept %v, want %v", got, tt.wantIDs)
+			}
+			if !reflect.DeepEqual(hidden, tt.wantHidden) {
+				t.Errorf("hidden = %v, want %v", hidden, tt.wantHidden)
+			}
+		})
+	}
+}
+
+func TestTopTruncatesMarkdownOnly(t *testing.T) {
+	vulns := map[string]helmscanTypes.Vulnerability{
+		"CVE-2024-0001": {ID: "CVE-2024-0001", Severity: "high", CVSS: 7.1},
+		"CVE-2024-0002": {ID: "CVE-2024-0002", Severity: "high", CVSS: 8.8},
+		"CVE-2024-0003": {ID: "CVE-2024-0003", Severity: "high", CVSS: 7.5},
+		"CVE-2024-0004": {ID: "CVE-2024-0004", Severity: "low", CVSS: 3.3},
+	}
+	cves := make(map[string]map[string]helmscanTypes.Vulnerability)
+	for id, vuln := range vulns {
+		cves[id] = map[string]helmscanTypes.Vulnerability{"docker.io/bitnami/redis": vuln}
+	}
+	const more = "*… and 2 more (all CVEs are listed in the JSON report)*"
+
+	tests := []struct {
+		name     string
+		markdown string
+	}{
+		{name: "comparison", markdown: formatVulnerabilitySection(cves, ReportOptions{Top: 1})},
+		{name: "single scan", markdown: GenerateMarkdownSingleReport(NewSingleScanReport("image", "docker.io/bitnami/redis:7.2.4", vulns), false, ReportOptions{Top: 1})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, id := range []string{"CVE-2024-0002", "CVE-2024-0004"} {
+				if !strings.Contains(tt.markdown, id) {
+					t.Errorf("markdown is missing the top CVE %s:\n%s", id, tt.markdown)
+				}
+			}
+			for _, id := range []string{"CVE-2024-0001", "CVE-2024-0003"} {
+				if strings.Contains(tt.markdown, id) {
+					t.Errorf("markdown lists %s, which --top 1 drops:\n%s", id, tt.markdown)
+				}
+			}
+			// The note follows the high table, before the low one, which was not truncated.
+			high, low := strings.Index(tt.markdown, "#### High"), strings.Index(tt.markdown, "#### Low")
+			if i := strings.Index(tt.markdown, more); i < high || i > low || strings.Count(tt.markdown, "… and") != 1 {
+				t.Errorf("markdown should note %q once, after the high table:\n%s", more, tt.markdown)
+			}
+		})
+	}
+
+	if got := ConvertToJSONCVEs(cves, nil); len(got) != len(cves) {
+		t.Errorf("JSON lists %d CVEs, want all %d", len(got), len(cves))
+	}
+}
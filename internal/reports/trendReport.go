@@ -0,0 +1,72 @@
+package reports
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	helmscanTypes "github.com/cliffcolvin/helmscan/internal/helmScanTypes"
+)
+
+// TrendReport summarises the vulnerabilities of several versions of the same chart.
+type TrendReport struct {
+	ChartRef string       `json:"chart"`
+	Metadata *Metadata    `json:"metadata,omitempty"`
+	Versions []TrendEntry `json:"versions"`
+}
+
+type TrendEntry struct {
+	Version string          `json:"version"`
+	Images  int             `json:"images"`
+	Summary SeveritySummary `json:"summary"`
+}
+
+func NewTrendEntry(version string, images int, vulns map[string]helmscanTypes.Vulnerability) TrendEntry {
+	return TrendEntry{
+		Version: version,
+		Images:  images,
+		Summary: CountVulnerabilities(vulns),
+	}
+}
+
+func GenerateTrendReport(report TrendReport, generateJSON bool, opts ReportOptions) (string, error) {
+	report.Metadata = opts.Metadata
+
+	if generateJSON {
+		jsonBytes, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("error generating JSON report: %w", err)
+		}
+		return string(jsonBytes), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# Helm Chart Trend Report\n")
+	sb.WriteString(fmt.Sprintf("## Chart: %s\n\n", report.ChartRef))
+	sb.WriteString(formatMetadataSection(report.Metadata))
+
+	var rows [][]string
+	for i, entry := range report.Versions {
+		total := entry.Summary.Critical + entry.Summary.High + entry.Summary.Medium + entry.Summary.Low
+		change := "-"
+		if i > 0 {
+			prev := report.Versions[i-1].Summary
+			change = fmt.Sprintf("%+d", total-(prev.Critical+prev.High+prev.Medium+prev.Low))
+		}
+		rows = append(rows, []string{
+			entry.Version,
+			strconv.Itoa(entry.Images),
+			strconv.Itoa(entry.Summary.Critical),
+			strconv.Itoa(entry.Summary.High),
+			strconv.Itoa(entry.Summary.Medium),
+			strconv.Itoa(entry.Summary.Low),
+			strconv.Itoa(total),
+			change,
+		})
+	}
+	sb.WriteString(FormatSection("Vulnerabilities by Version",
+		FormatMarkdownTable([]string{"Version", "Images", "Critical", "High", "Medium", "Low", "Total", "Change"}, rows)))
+
+	return sb.String(), nil
+}